@@ -1,29 +1,44 @@
 package main
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/docker/app/internal"
+	"github.com/docker/app/internal/formatter"
 	"github.com/docker/app/internal/packager"
+	"github.com/docker/app/internal/plan"
 	"github.com/docker/app/render"
 	"github.com/docker/app/types"
 	"github.com/docker/cli/cli"
 	"github.com/docker/cli/cli/command"
 	"github.com/docker/cli/cli/command/stack"
+	stackkubernetes "github.com/docker/cli/cli/command/stack/kubernetes"
 	"github.com/docker/cli/cli/command/stack/options"
 	"github.com/docker/cli/cli/command/stack/swarm"
+	composetypes "github.com/docker/cli/cli/compose/types"
+	"github.com/docker/cli/kubernetes"
 	cliopts "github.com/docker/cli/opts"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	kubeclient "k8s.io/client-go/kubernetes"
 )
 
 type deployOptions struct {
 	deployComposeFiles     []string
 	deploySettingsFiles    []string
+	deployEnvironment      string
 	deployEnv              []string
 	deployOrchestrator     string
 	deployKubeConfig       string
 	deployNamespace        string
 	deployStackName        string
 	deploySendRegistryAuth bool
+	deployDryRun           bool
+	deployFormatter        string
+	deployPinned           bool
+	deploySource           sourceOptions
 }
 
 // deployCmd represents the deploy command
@@ -40,6 +55,7 @@ func deployCmd(dockerCli command.Cli) *cobra.Command {
 		},
 	}
 
+	cmd.Flags().StringVar(&opts.deployEnvironment, "env", "", "Environment (named settings) to use")
 	cmd.Flags().StringArrayVarP(&opts.deploySettingsFiles, "settings-files", "f", []string{}, "Override settings files")
 	cmd.Flags().StringArrayVarP(&opts.deployEnv, "set", "s", []string{}, "Override settings values")
 	cmd.Flags().StringVarP(&opts.deployOrchestrator, "orchestrator", "o", "swarm", "Orchestrator to deploy on (swarm, kubernetes)")
@@ -47,6 +63,10 @@ func deployCmd(dockerCli command.Cli) *cobra.Command {
 	cmd.Flags().StringVarP(&opts.deployNamespace, "namespace", "n", "default", "Kubernetes namespace to deploy into")
 	cmd.Flags().StringVarP(&opts.deployStackName, "name", "d", "", "Stack name (default: app name)")
 	cmd.Flags().BoolVarP(&opts.deploySendRegistryAuth, "with-registry-auth", "", false, "Sends registry auth")
+	cmd.Flags().BoolVar(&opts.deployDryRun, "dry-run", false, "Print the services to create, update and remove instead of deploying")
+	cmd.Flags().StringVar(&opts.deployFormatter, "formatter", "text", "Configure the dry-run output format (text|json)")
+	cmd.Flags().BoolVar(&opts.deployPinned, "pinned", false, "Fail if the image of a service is not pinned to a digest")
+	opts.deploySource.addFlags(cmd.Flags())
 	if internal.Experimental == "on" {
 		cmd.Flags().StringArrayVarP(&opts.deployComposeFiles, "compose-files", "c", []string{}, "Override Compose files")
 	}
@@ -54,9 +74,15 @@ func deployCmd(dockerCli command.Cli) *cobra.Command {
 }
 
 func runDeploy(dockerCli command.Cli, flags *pflag.FlagSet, appname string, opts deployOptions) error {
-	app, err := packager.Extract(appname,
+	pullOpts, err := opts.deploySource.pullOptions()
+	if err != nil {
+		return err
+	}
+	app, err := packager.ExtractWith(appname, pullOpts,
+		types.WithEnvironment(opts.deployEnvironment),
 		types.WithSettingsFiles(opts.deploySettingsFiles...),
-		types.WithComposeFiles(opts.deployComposeFiles...),
+		types.WithComposeOverrideFiles(opts.deployComposeFiles...),
+		packager.WithDependencies(pullOpts),
 	)
 	if err != nil {
 		return err
@@ -67,7 +93,11 @@ func runDeploy(dockerCli command.Cli, flags *pflag.FlagSet, appname string, opts
 		return err
 	}
 	d := cliopts.ConvertKVStringsToMap(opts.deployEnv)
-	rendered, err := render.Render(app, d)
+	renderOps := opts.deploySource.renderOptions()
+	if opts.deployPinned {
+		renderOps = append(renderOps, render.WithPinnedImages())
+	}
+	rendered, err := render.Render(app, d, renderOps...)
 	if err != nil {
 		return err
 	}
@@ -75,9 +105,60 @@ func runDeploy(dockerCli command.Cli, flags *pflag.FlagSet, appname string, opts
 	if stackName == "" {
 		stackName = internal.AppNameFromDir(app.Name)
 	}
+	if opts.deployDryRun {
+		return runDeployPlan(dockerCli, rendered, deployOrchestrator, stackName, opts)
+	}
 	return stack.RunDeploy(dockerCli, flags, rendered, deployOrchestrator, options.Deploy{
 		Namespace:        stackName,
 		ResolveImage:     swarm.ResolveImageAlways,
 		SendRegistryAuth: opts.deploySendRegistryAuth,
 	})
 }
+
+// runDeployPlan prints the changes a deploy would apply to the running stack
+func runDeployPlan(dockerCli command.Cli, rendered *composetypes.Config, orchestrator command.Orchestrator, stackName string, opts deployOptions) error {
+	var (
+		current []composetypes.ServiceConfig
+		err     error
+	)
+	switch {
+	case orchestrator.HasAll():
+		return errors.New("dry-run requires a single orchestrator (swarm or kubernetes)")
+	case orchestrator.HasKubernetes():
+		stacks, err := kubernetesStacks(opts)
+		if err != nil {
+			return err
+		}
+		current, err = plan.KubernetesServices(stacks, stackName)
+		if err != nil {
+			return err
+		}
+	default:
+		current, err = plan.SwarmServices(context.Background(), dockerCli.Client(), stackName)
+		if err != nil {
+			return err
+		}
+	}
+	res, err := formatter.FormatDiff(plan.Compute(current, rendered, orchestrator.HasSwarm()), opts.deployFormatter)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(dockerCli.Out(), res)
+	return nil
+}
+
+func kubernetesStacks(opts deployOptions) (stackkubernetes.StackClient, error) {
+	config, err := kubernetes.NewKubernetesConfig(opts.deployKubeConfig).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientSet, err := kubeclient.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	factory, err := stackkubernetes.NewFactory(opts.deployNamespace, config, clientSet)
+	if err != nil {
+		return nil, err
+	}
+	return factory.Stacks(false)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/docker/app/internal/diff"
+	"github.com/docker/app/internal/formatter"
+	"github.com/docker/app/internal/packager"
+	"github.com/docker/app/render"
+	"github.com/docker/app/types"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	composetypes "github.com/docker/cli/cli/compose/types"
+	cliopts "github.com/docker/cli/opts"
+	"github.com/spf13/cobra"
+)
+
+type diffOptions struct {
+	diffFromSettingsFiles []string
+	diffFromEnvironment   string
+	diffFromEnv           []string
+	diffSettingsFiles     []string
+	diffEnvironment       string
+	diffEnv               []string
+	diffFormatter         string
+}
+
+// diffCmd represents the diff command
+func diffCmd(dockerCli command.Cli) *cobra.Command {
+	var opts diffOptions
+
+	cmd := &cobra.Command{
+		Use:   "diff <app-name> [<other-app-name>] [--from-set key=value...] [-s key=value...]",
+		Short: "Show the differences between two rendered applications",
+		Long: `Render two applications, or the same application with different settings, and show the differences service by service.
+The --from-* flags apply to the first application, the other settings flags to the second one. Secret settings are redacted.`,
+		Args: cli.RequiresRangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			other := args[0]
+			if len(args) > 1 {
+				other = args[1]
+			}
+			before, err := renderForDiff(args[0], opts.diffFromEnvironment, opts.diffFromSettingsFiles, opts.diffFromEnv)
+			if err != nil {
+				return err
+			}
+			after, err := renderForDiff(other, opts.diffEnvironment, opts.diffSettingsFiles, opts.diffEnv)
+			if err != nil {
+				return err
+			}
+			res, err := formatter.FormatDiff(diff.Compare(before, after), opts.diffFormatter)
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(dockerCli.Out(), res)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&opts.diffFromEnvironment, "from-env", "", "Environment (named settings) to use for the first application")
+	cmd.Flags().StringArrayVar(&opts.diffFromSettingsFiles, "from-settings-files", []string{}, "Override settings files of the first application")
+	cmd.Flags().StringArrayVar(&opts.diffFromEnv, "from-set", []string{}, "Override settings values of the first application")
+	cmd.Flags().StringVar(&opts.diffEnvironment, "env", "", "Environment (named settings) to use for the second application")
+	cmd.Flags().StringArrayVarP(&opts.diffSettingsFiles, "settings-files", "f", []string{}, "Override settings files of the second application")
+	cmd.Flags().StringArrayVarP(&opts.diffEnv, "set", "s", []string{}, "Override settings values of the second application")
+	cmd.Flags().StringVar(&opts.diffFormatter, "formatter", "text", "Configure the output format (text|json)")
+	return cmd
+}
+
+func renderForDiff(appname, environment string, settingsFiles, env []string) (*composetypes.Config, error) {
+	app, err := packager.Extract(appname,
+		types.WithEnvironment(environment),
+		types.WithSettingsFiles(settingsFiles...),
+		packager.WithDependencies(packager.PullOptions{}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer app.Cleanup()
+	return render.Render(app, cliopts.ConvertKVStringsToMap(env), render.WithRedactedSecrets())
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+
+	"github.com/docker/app/internal/local"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/spf13/cobra"
+)
+
+func downCmd(dockerCli command.Cli) *cobra.Command {
+	var (
+		projectNameFlag string
+		removeVolumes   bool
+	)
+	cmd := &cobra.Command{
+		Use:   "down [<app-name>]",
+		Short: "Stop and remove the containers and networks of an application run by up",
+		Args:  cli.RequiresMaxArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			project, err := projectName(firstOrEmpty(args), projectNameFlag)
+			if err != nil {
+				return err
+			}
+			return local.Down(context.Background(), dockerCli.Client(), project, removeVolumes, dockerCli.Out())
+		},
+	}
+	cmd.Flags().StringVarP(&projectNameFlag, "name", "d", "", "Project name (default: app name)")
+	cmd.Flags().BoolVarP(&removeVolumes, "volumes", "v", false, "Also remove the volumes of the application")
+	return cmd
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/docker/app/internal/export"
+	"github.com/docker/app/internal/packager"
+	"github.com/docker/app/types"
+	"github.com/docker/cli/cli"
+	cliopts "github.com/docker/cli/opts"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportComposeSettingsFile []string
+	exportComposeEnv          []string
+)
+
+func exportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the application to another format",
+		Args:  cli.NoArgs,
+	}
+	cmd.AddCommand(exportComposeCmd())
+	return cmd
+}
+
+func exportComposeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compose <dir> [<app-name>] [-s key=value...] [-f settings-file...]",
+		Short: "Export the application as a plain compose project",
+		Long: `Export the application as a compose project run by docker-compose, without docker-app.
+
+The docker-compose.yml file refers to the settings as variables, upper-cased with underscores
+(${WEB_PORT} for web.port), with their default values in the .env file. Each environment gets
+an override file, docker-compose.<environment>.yml, to use along with docker-compose.yml:
+
+  docker-compose -f docker-compose.yml -f docker-compose.production.yml up`,
+		Args: cli.RequiresRangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := packager.Extract(firstOrEmpty(args[1:]),
+				types.WithSettingsFiles(exportComposeSettingsFile...),
+			)
+			if err != nil {
+				return err
+			}
+			defer app.Cleanup()
+			return export.Compose(app, cliopts.ConvertKVStringsToMap(exportComposeEnv), args[0])
+		},
+	}
+	cmd.Flags().StringArrayVarP(&exportComposeSettingsFile, "settings-files", "f", []string{}, "Override settings files")
+	cmd.Flags().StringArrayVarP(&exportComposeEnv, "set", "s", []string{}, "Override settings values")
+	return cmd
+}
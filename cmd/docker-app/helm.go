@@ -15,31 +15,39 @@ import (
 var (
 	helmComposeFiles []string
 	helmSettingsFile []string
+	helmEnvironment  string
 	helmEnv          []string
 	helmRender       bool
+	helmManifests    bool
 	stackVersion     string
+	helmSecrets      secretsOptions
 )
 
 func helmCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "helm [<app-name>] [-s key=value...] [-f settings-file...]",
+		Use:   "helm [<app-name>] [-s key=value...] [-f settings-file...] [--env environment]",
 		Short: "Generate a Helm chart",
 		Long:  `Generate a Helm chart for the application.`,
 		Args:  cli.RequiresMaxArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			app, err := packager.Extract(firstOrEmpty(args),
+				types.WithEnvironment(helmEnvironment),
 				types.WithSettingsFiles(helmSettingsFile...),
-				types.WithComposeFiles(helmComposeFiles...),
+				types.WithComposeOverrideFiles(helmComposeFiles...),
+				packager.WithDependencies(packager.PullOptions{}),
 			)
 			if err != nil {
 				return err
 			}
 			defer app.Cleanup()
 			d := cliopts.ConvertKVStringsToMap(helmEnv)
+			if helmManifests {
+				return helm.Manifests(app, d, helmSecrets.renderOptions()...)
+			}
 			if stackVersion != helm.V1Beta1 && stackVersion != helm.V1Beta2 {
 				return fmt.Errorf("invalid stack version %q (accepted values: %s, %s)", stackVersion, helm.V1Beta1, helm.V1Beta2)
 			}
-			return helm.Helm(app, d, helmRender, stackVersion)
+			return helm.Helm(app, d, helmRender, stackVersion, helmSecrets.renderOptions()...)
 		},
 	}
 	if internal.Experimental == "on" {
@@ -49,8 +57,11 @@ func helmCmd() *cobra.Command {
 		cmd.Long += ` If the --render option is used, the docker-compose.yml will
 be rendered instead of exported as a template.`
 	}
+	cmd.Flags().BoolVar(&helmManifests, "manifests", false, "Generate plain Kubernetes manifests of the rendered application, not requiring the Stack CRD")
+	cmd.Flags().StringVar(&helmEnvironment, "env", "", "Environment (named settings) to use")
 	cmd.Flags().StringArrayVarP(&helmSettingsFile, "settings-files", "f", []string{}, "Override settings files")
 	cmd.Flags().StringArrayVarP(&helmEnv, "set", "s", []string{}, "Override settings values")
+	helmSecrets.addFlags(cmd.Flags())
 	cmd.Flags().StringVarP(&stackVersion, "stack-version", "", helm.V1Beta2, "Version of the stack specification for the produced helm chart (v1beta1 / v1beta2)")
 	return cmd
 }
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"github.com/docker/app/internal"
 	"github.com/docker/app/internal/image"
 	"github.com/docker/app/internal/packager"
 	"github.com/docker/app/render"
 	"github.com/docker/app/types"
+	"github.com/docker/cli/cli/command"
 	cliopts "github.com/docker/cli/opts"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
@@ -18,19 +23,20 @@ var (
 	imageAddEnv          []string
 )
 
-func imageAddCmd() *cobra.Command {
+func imageAddCmd(dockerCli command.Cli) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "image-add <app-name> [services...]",
 		Short: "Add images for given services (default: all) to the app package",
 		Long: `This command renders the app's docker-compose.yml file, looks for the
 images it uses, and saves them from the local docker daemon to the images/
-subdirectory.`,
+subdirectory, an OCI image layout where the layers shared by several images
+are only stored once.`,
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			oappname := args[0]
-			app, err := packager.Extract(oappname,
+			app, err := packager.Extract(args[0],
 				types.WithSettingsFiles(imageAddSettingsFile...),
-				types.WithComposeFiles(imageAddComposeFiles...),
+				types.WithComposeOverrideFiles(imageAddComposeFiles...),
+				packager.WithDependencies(packager.PullOptions{}),
 			)
 			if err != nil {
 				return err
@@ -41,28 +47,9 @@ subdirectory.`,
 			if err != nil {
 				return err
 			}
-			if err := image.Add(app.Name, args[1:], config); err != nil {
-				return err
-			}
-			// check if source was a tarball
-			s, err := os.Stat(oappname)
-			if err != nil {
-				// try appending our extension
-				oappname = internal.DirNameFromAppName(oappname)
-				s, err = os.Stat(oappname)
-				if err != nil {
-					return err
-				}
-			}
-			if !s.IsDir() {
-				target, err := os.Create(oappname)
-				if err != nil {
-					return err
-				}
-				// source was a tarball, rebuild it
-				return packager.Pack(app.Name, target)
-			}
-			return nil
+			return withAppDir(app, true, func(dir string) error {
+				return image.Add(context.Background(), dockerCli.Client(), dir, args[1:], config, dockerCli.Out())
+			})
 		},
 	}
 	if internal.Experimental == "on" {
@@ -72,3 +59,41 @@ subdirectory.`,
 	}
 	return cmd
 }
+
+// withAppDir calls f with the directory of the app, unpacking it to a
+// temporary directory if it is packed, and packing it back if repack is set
+func withAppDir(app *types.App, repack bool, f func(dir string) error) error {
+	s, err := os.Stat(app.Path)
+	if err != nil {
+		return errors.Errorf("images can only be stored in local applications, and %s is not", app.Name)
+	}
+	if s.IsDir() {
+		return f(app.Path)
+	}
+	tmp, err := ioutil.TempDir("", "docker-app-images")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(tmp)
+	if err := packager.Unpack(app.Path, tmp); err != nil {
+		return errors.Wrapf(err, "images can only be stored in directory or packed applications, and %s is not", app.Path)
+	}
+	dir := filepath.Join(tmp, internal.AppNameFromDir(app.Path)+internal.AppExtension)
+	if err := f(dir); err != nil || !repack {
+		return err
+	}
+	// write next to the package first, so that it is not truncated on failure
+	target, err := ioutil.TempFile(filepath.Dir(app.Path), filepath.Base(app.Path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(target.Name())
+	err = packager.Pack(dir, target)
+	if cerr := target.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(target.Name(), app.Path)
+}
@@ -1,23 +1,30 @@
 package main
 
 import (
+	"context"
+
 	"github.com/docker/app/internal/image"
 	"github.com/docker/app/internal/packager"
+	"github.com/docker/cli/cli/command"
 	"github.com/spf13/cobra"
 )
 
-func imageLoadCmd() *cobra.Command {
+func imageLoadCmd(dockerCli command.Cli) *cobra.Command {
 	return &cobra.Command{
 		Use:   "image-load <app-name> [services...]",
 		Short: "Load stored images for given services (default: all) to the local docker daemon",
-		Args:  cobra.MinimumNArgs(1),
+		Long: `This command loads the images stored in the images/ subdirectory of the app
+package to the local docker daemon, sending only the layers it doesn't have.`,
+		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			app, err := packager.Extract(args[0])
 			if err != nil {
 				return err
 			}
 			defer app.Cleanup()
-			return image.Load(app.Name, args[1:])
+			return withAppDir(app, false, func(dir string) error {
+				return image.Load(context.Background(), dockerCli.Client(), dir, args[1:], dockerCli.Out())
+			})
 		},
 	}
 }
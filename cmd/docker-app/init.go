@@ -11,22 +11,33 @@ var (
 	initDescription string
 	initMaintainers []string
 	initSingleFile  bool
+	initFromHelm    string
+	initFromK8s     []string
 )
 
 // initCmd represents the init command
 func initCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "init <app-name> [-c <compose-file>] [-d <description>] [-m name:email ...]",
+		Use:   "init <app-name> [-c <compose-file> | --from-helm <chart-dir> | --from-k8s <manifests> ...] [-d <description>] [-m name:email ...]",
 		Short: "Start building a Docker application",
-		Long:  `Start building a Docker application. Will automatically detect a docker-compose.yml file in the current directory.`,
-		Args:  cli.ExactArgs(1),
+		Long: `Start building a Docker application. Will automatically detect a docker-compose.yml file in the current directory.
+
+The application can be imported from a Helm chart or Kubernetes manifests instead: the Deployments, StatefulSets, DaemonSets,
+Services, ConfigMaps, Secrets and PersistentVolumeClaims become the compose file, and the chart values become the settings.
+What can't be imported is reported.`,
+		Args: cli.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return packager.Init(args[0], initComposeFile, initDescription, initMaintainers, initSingleFile)
+			return packager.Init(args[0], initComposeFile, initDescription, initMaintainers, initSingleFile, packager.ImportSource{
+				HelmChart: initFromHelm,
+				Manifests: initFromK8s,
+			})
 		},
 	}
 	cmd.Flags().StringVarP(&initComposeFile, "compose-file", "c", "", "Initial Compose file (optional)")
 	cmd.Flags().StringVarP(&initDescription, "description", "d", "", "Initial description (optional)")
 	cmd.Flags().StringArrayVarP(&initMaintainers, "maintainer", "m", []string{}, "Maintainer (name:email) (optional)")
 	cmd.Flags().BoolVarP(&initSingleFile, "single-file", "s", false, "Create a single-file application")
+	cmd.Flags().StringVar(&initFromHelm, "from-helm", "", "Import a Helm chart directory (optional)")
+	cmd.Flags().StringArrayVar(&initFromK8s, "from-k8s", []string{}, "Import Kubernetes manifests, a file or a directory (optional)")
 	return cmd
 }
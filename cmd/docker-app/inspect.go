@@ -12,18 +12,21 @@ import (
 
 var (
 	inspectSettingsFile []string
+	inspectEnvironment  string
 	inspectEnv          []string
 )
 
 // inspectCmd represents the inspect command
 func inspectCmd(dockerCli command.Cli) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "inspect [<app-name>] [-s key=value...] [-f settings-file...]",
+		Use:   "inspect [<app-name>] [-s key=value...] [-f settings-file...] [--env environment]",
 		Short: "Shows metadata, settings and a summary of the compose file for a given application",
 		Args:  cli.RequiresMaxArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			app, err := packager.Extract(firstOrEmpty(args),
+				types.WithEnvironment(inspectEnvironment),
 				types.WithSettingsFiles(inspectSettingsFile...),
+				packager.WithDependencies(packager.PullOptions{}),
 			)
 			if err != nil {
 				return err
@@ -33,6 +36,7 @@ func inspectCmd(dockerCli command.Cli) *cobra.Command {
 			return inspect.Inspect(dockerCli.Out(), app, argSettings)
 		},
 	}
+	cmd.Flags().StringVar(&inspectEnvironment, "env", "", "Environment (named settings) to use")
 	cmd.Flags().StringArrayVarP(&inspectSettingsFile, "settings-files", "f", []string{}, "Override settings files")
 	cmd.Flags().StringArrayVarP(&inspectEnv, "set", "s", []string{}, "Override settings values")
 	return cmd
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/app/internal"
+	"github.com/docker/app/internal/packager"
+	"github.com/docker/app/internal/yaml"
+	"github.com/docker/app/types"
+	"github.com/docker/app/types/metadata"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var lockUpdate bool
+
+func lockCmd(dockerCli command.Cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lock [<app-name>] [--update]",
+		Short: "Pin the application dependencies to the digests of their resolved versions",
+		Long: `Resolve the application dependencies and write their versions and digests to the dependencies.lock file.
+Dependencies already locked keep their version, unless --update is given or their version constraint changed.`,
+		Args: cli.RequiresMaxArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := packager.Extract(firstOrEmpty(args))
+			if err != nil {
+				return err
+			}
+			defer app.Cleanup()
+			if err := checkLockable(app); err != nil {
+				return err
+			}
+			if len(app.Metadata().Dependencies) == 0 {
+				fmt.Fprintln(dockerCli.Out(), "No dependencies to lock")
+			}
+			lock, err := packager.Lock(app, lockUpdate)
+			if err != nil {
+				return err
+			}
+			if err := writeLock(app, lock); err != nil {
+				return err
+			}
+			for _, d := range lock.Dependencies {
+				fmt.Fprintf(dockerCli.Out(), "%s: %s:%s@%s\n", d.Name, d.Image, d.Version, d.Digest)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&lockUpdate, "update", false, "Resolve the newest versions, ignoring the existing lock")
+	return cmd
+}
+
+func checkLockable(app *types.App) error {
+	if s, err := os.Stat(app.Path); err != nil || !s.IsDir() {
+		return errors.Errorf("cannot lock %s: only application directories can be locked, use split first", app.Path)
+	}
+	return nil
+}
+
+// writeLock writes the lock to the app directory, removing the lock file if
+// it pins nothing
+func writeLock(app *types.App, lock *metadata.Lock) error {
+	lockFile := filepath.Join(app.Path, internal.DependenciesLockFileName)
+	if len(lock.Dependencies) == 0 && len(lock.Images) == 0 {
+		if err := os.Remove(lockFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := yaml.Marshal(lock)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(ioutil.WriteFile(lockFile, data, 0644), "failed to write dependencies lock")
+}
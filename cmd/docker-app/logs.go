@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/docker/app/internal/local"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/spf13/cobra"
+)
+
+func logsCmd(dockerCli command.Cli) *cobra.Command {
+	var (
+		projectNameFlag string
+		services        []string
+		opts            local.LogsOptions
+	)
+	cmd := &cobra.Command{
+		Use:   "logs [<app-name>] [--service service...]",
+		Short: "Print the logs of the containers of an application run by up",
+		Args:  cli.RequiresMaxArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			project, err := projectName(firstOrEmpty(args), projectNameFlag)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := interruptContext()
+			defer cancel()
+			return local.Logs(ctx, dockerCli.Client(), project, services, opts, dockerCli.Out())
+		},
+	}
+	cmd.Flags().StringVarP(&projectNameFlag, "name", "d", "", "Project name (default: app name)")
+	cmd.Flags().StringArrayVar(&services, "service", []string{}, "Only print the logs of the given services")
+	cmd.Flags().BoolVarP(&opts.Follow, "follow", "f", false, "Follow the logs")
+	cmd.Flags().StringVar(&opts.Tail, "tail", "all", "Number of lines to show from the end of the logs of each container")
+	cmd.Flags().BoolVarP(&opts.Timestamps, "timestamps", "t", false, "Show timestamps")
+	return cmd
+}
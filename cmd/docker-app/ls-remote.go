@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/docker/app/internal/packager"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/spf13/cobra"
+)
+
+var lsRemoteDigests bool
+
+func lsRemoteCmd(dockerCli command.Cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ls-remote <repository>",
+		Short: "List the versions of an application pushed to a registry",
+		Args:  cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			apps, err := packager.ListRemote(args[0])
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(dockerCli.Out(), 0, 0, 1, ' ', 0)
+			if lsRemoteDigests {
+				fmt.Fprintln(w, "TAG\tDIGEST\tVERSION\tDESCRIPTION\tMAINTAINERS")
+			} else {
+				fmt.Fprintln(w, "TAG\tVERSION\tDESCRIPTION\tMAINTAINERS")
+			}
+			for _, app := range apps {
+				if lsRemoteDigests {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", app.Tag, app.Digest, app.Version, app.Description, app.Maintainers)
+				} else {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", app.Tag, app.Version, app.Description, app.Maintainers)
+				}
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&lsRemoteDigests, "digests", false, "Show digests")
+	return cmd
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/docker/app/internal/packager"
+	"github.com/docker/app/internal/store"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/docker/distribution/reference"
+	"github.com/spf13/cobra"
+)
+
+func lsCmd(dockerCli command.Cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "ls",
+		Short: "List the applications of the local store",
+		Args:  cli.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			apps, err := packager.ListStore(store.New(store.DefaultDir()))
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(dockerCli.Out(), 0, 0, 1, ' ', 0)
+			fmt.Fprintln(w, "REPOSITORY\tTAG\tDIGEST")
+			for _, app := range apps {
+				named, err := reference.ParseNormalizedNamed(app.Ref)
+				if err != nil {
+					return err
+				}
+				tag := "<none>"
+				if tagged, ok := named.(reference.Tagged); ok {
+					tag = tagged.Tag()
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", reference.FamiliarName(named), tag, app.Digest)
+			}
+			return w.Flush()
+		},
+	}
+}
@@ -26,7 +26,7 @@ func extraFiles(appname string) ([]string, error) {
 	var res []string
 	for _, f := range files {
 		hit := false
-		for _, afn := range internal.FileNames {
+		for _, afn := range append(internal.FileNames, internal.OptionalFileNames...) {
 			if afn == f.Name() {
 				hit = true
 				break
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/docker/app/internal/packager"
+	"github.com/docker/app/types"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	cliopts "github.com/docker/cli/opts"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pinSettingsFile []string
+	pinEnvironment  string
+	pinEnv          []string
+	pinUpdate       bool
+)
+
+func pinCmd(dockerCli command.Cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pin [<app-name>] [-s key=value...] [-f settings-file...] [--env environment] [--update]",
+		Short: "Pin the images of the application services to their digests",
+		Long: `Render the application, resolve the images of its services to their digests on the registry, and write them
+to the dependencies.lock file. Images already pinned keep their digest, unless --update is given or the image changed.
+Rendered applications use the pinned digests, and render and deploy --pinned refuse images not pinned to a digest.`,
+		Args: cli.RequiresMaxArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := packager.Extract(firstOrEmpty(args),
+				types.WithEnvironment(pinEnvironment),
+				types.WithSettingsFiles(pinSettingsFile...),
+			)
+			if err != nil {
+				return err
+			}
+			defer app.Cleanup()
+			if err := checkLockable(app); err != nil {
+				return err
+			}
+			lock, err := packager.Pin(app, cliopts.ConvertKVStringsToMap(pinEnv), pinUpdate)
+			if err != nil {
+				return err
+			}
+			if err := writeLock(app, lock); err != nil {
+				return err
+			}
+			for _, i := range lock.Images {
+				fmt.Fprintf(dockerCli.Out(), "%s: %s@%s\n", i.Service, i.Image, i.Digest)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&pinEnvironment, "env", "", "Environment (named settings) to use")
+	cmd.Flags().StringArrayVarP(&pinSettingsFile, "settings-files", "f", []string{}, "Override settings files")
+	cmd.Flags().StringArrayVarP(&pinEnv, "set", "s", []string{}, "Override settings values")
+	cmd.Flags().BoolVar(&pinUpdate, "update", false, "Resolve the digests of the images already pinned again")
+	return cmd
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+
+	"github.com/docker/app/internal/local"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/spf13/cobra"
+)
+
+func psCmd(dockerCli command.Cli) *cobra.Command {
+	var projectNameFlag string
+	cmd := &cobra.Command{
+		Use:   "ps [<app-name>]",
+		Short: "List the containers of an application run by up",
+		Args:  cli.RequiresMaxArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			project, err := projectName(firstOrEmpty(args), projectNameFlag)
+			if err != nil {
+				return err
+			}
+			return local.Ps(context.Background(), dockerCli.Client(), project, dockerCli.Out())
+		},
+	}
+	cmd.Flags().StringVarP(&projectNameFlag, "name", "d", "", "Project name (default: app name)")
+	return cmd
+}
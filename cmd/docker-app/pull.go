@@ -1,19 +1,52 @@
 package main
 
 import (
+	"fmt"
+
 	"github.com/docker/app/internal/packager"
+	"github.com/docker/app/internal/store"
 	"github.com/docker/cli/cli"
 	"github.com/spf13/cobra"
 )
 
+type pullOptions struct {
+	ociLayout string
+	verify    verifyOptions
+}
+
 func pullCmd() *cobra.Command {
-	return &cobra.Command{
+	var opts pullOptions
+	cmd := &cobra.Command{
 		Use:   "pull <repotag>",
 		Short: "Pull an application from a registry",
-		Args:  cli.ExactArgs(1),
+		Long: `Pull an application from a registry, or with --oci-layout from an OCI image layout directory, in which
+case the reference name may be omitted if the layout holds a single application.`,
+		Args: cli.RequiresMaxArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			_, err := packager.Pull(args[0], ".")
+			if opts.ociLayout != "" {
+				appDir, err := packager.PullLayout(opts.ociLayout, firstOrEmpty(args), ".")
+				if err == nil {
+					fmt.Println(appDir)
+				}
+				return err
+			}
+			if len(args) == 0 {
+				return fmt.Errorf("\"docker-app pull\" requires a reference when --oci-layout is not set")
+			}
+			policy, err := opts.verify.policy()
+			if err != nil {
+				return err
+			}
+			// pulled apps are stored, as deploy and render store them
+			_, err = packager.PullWith(args[0], ".", packager.PullOptions{
+				Policy: packager.PullAlways,
+				Store:  store.New(store.DefaultDir()),
+				Verify: policy,
+			})
 			return err
 		},
 	}
+	cmd.Flags().StringVar(&opts.ociLayout, "oci-layout", "", "Read the application from this OCI image layout directory instead of a registry")
+	opts.verify.addFlags(cmd.Flags())
+	return cmd
 }
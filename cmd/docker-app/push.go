@@ -12,6 +12,8 @@ type pushOptions struct {
 	namespace string
 	tag       string
 	repo      string
+	ociLayout string
+	sign      string
 }
 
 func pushCmd() *cobra.Command {
@@ -26,9 +28,27 @@ func pushCmd() *cobra.Command {
 				return err
 			}
 			defer app.Cleanup()
+			if opts.ociLayout != "" && opts.sign != "" {
+				return fmt.Errorf("--sign cannot be used with --oci-layout")
+			}
+			if opts.ociLayout != "" {
+				ref, dgst, err := packager.PushLayout(app, opts.ociLayout, opts.namespace, opts.tag, opts.repo)
+				if err == nil {
+					fmt.Printf("%s@%s\n", ref, dgst)
+				}
+				return err
+			}
 			dgst, err := packager.Push(app, opts.namespace, opts.tag, opts.repo)
+			if err != nil {
+				return err
+			}
+			fmt.Println(dgst)
+			if opts.sign == "" {
+				return nil
+			}
+			sigRef, err := packager.Sign(packager.ImageName(app, opts.namespace, opts.tag, opts.repo), dgst, opts.sign)
 			if err == nil {
-				fmt.Println(dgst)
+				fmt.Printf("Signature pushed to %s\n", sigRef)
 			}
 			return err
 		},
@@ -36,5 +56,7 @@ func pushCmd() *cobra.Command {
 	cmd.Flags().StringVar(&opts.namespace, "namespace", "", "Namespace to use (default: namespace in metadata)")
 	cmd.Flags().StringVarP(&opts.tag, "tag", "t", "", "Tag to use (default: version in metadata)")
 	cmd.Flags().StringVar(&opts.repo, "repo", "", "Name of the remote repository (default: <app-name>.dockerapp)")
+	cmd.Flags().StringVar(&opts.sign, "sign", "", "Sign the pushed application with this private key file")
+	cmd.Flags().StringVar(&opts.ociLayout, "oci-layout", "", "Write the application to this OCI image layout directory instead of a registry")
 	return cmd
 }
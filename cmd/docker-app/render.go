@@ -2,7 +2,7 @@ package main
 
 import (
 	"fmt"
-	"os"
+	"io/ioutil"
 
 	"github.com/docker/app/internal"
 	"github.com/docker/app/internal/formatter"
@@ -11,6 +11,7 @@ import (
 	"github.com/docker/app/types"
 	"github.com/docker/cli/cli"
 	"github.com/docker/cli/cli/command"
+	composetypes "github.com/docker/cli/cli/compose/types"
 	cliopts "github.com/docker/cli/opts"
 	"github.com/spf13/cobra"
 )
@@ -19,44 +20,43 @@ var (
 	formatDriver       string
 	renderComposeFiles []string
 	renderSettingsFile []string
+	renderEnvironment  string
 	renderEnv          []string
 	renderOutput       string
+	renderRedact       bool
+	renderPinned       bool
+	renderSource       sourceOptions
+	renderWatch        bool
 )
 
 func renderCmd(dockerCli command.Cli) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "render <app-name> [-s key=value...] [-f settings-file...]",
+		Use:   "render <app-name> [-s key=value...] [-f settings-file...] [--env environment]",
 		Short: "Render the Compose file for the application",
 		Long:  `Render the Compose file for the application.`,
 		Args:  cli.RequiresMaxArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			app, err := packager.Extract(firstOrEmpty(args),
-				types.WithSettingsFiles(renderSettingsFile...),
-				types.WithComposeFiles(renderComposeFiles...),
-			)
-			if err != nil {
-				return err
-			}
-			defer app.Cleanup()
-			d := cliopts.ConvertKVStringsToMap(renderEnv)
-			rendered, err := render.Render(app, d)
-			if err != nil {
-				return err
-			}
-			res, err := formatter.Format(rendered, formatDriver)
-			if err != nil {
-				return err
-			}
-			if renderOutput == "-" {
-				fmt.Fprint(dockerCli.Out(), res)
-			} else {
-				f, err := os.Create(renderOutput)
+			if !renderWatch {
+				rendered, err := renderApp(firstOrEmpty(args))
 				if err != nil {
 					return err
 				}
-				fmt.Fprint(f, res)
+				return writeRendered(dockerCli, rendered)
 			}
-			return nil
+			files := append(append([]string{}, renderSettingsFile...), renderComposeFiles...)
+			return watchApp(dockerCli, firstOrEmpty(args), files, func() (*composetypes.Config, error) {
+				return renderApp(firstOrEmpty(args))
+			}, func(previous, rendered *composetypes.Config) error {
+				if previous != nil {
+					if err := printChanges(dockerCli.Out(), previous, rendered); err != nil {
+						return err
+					}
+					if renderOutput == "-" {
+						return nil
+					}
+				}
+				return writeRendered(dockerCli, rendered)
+			})
 		},
 	}
 	if internal.Experimental == "on" {
@@ -66,9 +66,52 @@ func renderCmd(dockerCli command.Cli) *cobra.Command {
   the app's own Compose file.`
 		cmd.Flags().StringArrayVarP(&renderComposeFiles, "compose-files", "c", []string{}, "Override Compose files")
 	}
+	cmd.Flags().StringVar(&renderEnvironment, "env", "", "Environment (named settings) to use")
 	cmd.Flags().StringArrayVarP(&renderSettingsFile, "settings-files", "f", []string{}, "Override settings files")
 	cmd.Flags().StringArrayVarP(&renderEnv, "set", "s", []string{}, "Override settings values")
 	cmd.Flags().StringVarP(&renderOutput, "output", "o", "-", "Output file")
-	cmd.Flags().StringVar(&formatDriver, "formatter", "yaml", "Configure the output format (yaml|json)")
+	cmd.Flags().StringVar(&formatDriver, "formatter", "yaml", "Configure the output format (yaml|json|kubernetes)")
+	cmd.Flags().BoolVar(&renderRedact, "redact", false, "Redact secret settings values instead of resolving them")
+	cmd.Flags().BoolVar(&renderPinned, "pinned", false, "Fail if the image of a service is not pinned to a digest")
+	cmd.Flags().BoolVarP(&renderWatch, "watch", "w", false, "Render again each time the application files change, printing the changes")
+	renderSource.addFlags(cmd.Flags())
 	return cmd
 }
+
+func renderApp(appname string) (*composetypes.Config, error) {
+	pullOpts, err := renderSource.pullOptions()
+	if err != nil {
+		return nil, err
+	}
+	app, err := packager.ExtractWith(appname, pullOpts,
+		types.WithEnvironment(renderEnvironment),
+		types.WithSettingsFiles(renderSettingsFile...),
+		types.WithComposeOverrideFiles(renderComposeFiles...),
+		packager.WithDependencies(pullOpts),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer app.Cleanup()
+	d := cliopts.ConvertKVStringsToMap(renderEnv)
+	renderOps := renderSource.renderOptions()
+	if renderRedact {
+		renderOps = append(renderOps, render.WithRedactedSecrets())
+	}
+	if renderPinned {
+		renderOps = append(renderOps, render.WithPinnedImages())
+	}
+	return render.Render(app, d, renderOps...)
+}
+
+func writeRendered(dockerCli command.Cli, rendered *composetypes.Config) error {
+	res, err := formatter.Format(rendered, formatDriver)
+	if err != nil {
+		return err
+	}
+	if renderOutput == "-" {
+		fmt.Fprint(dockerCli.Out(), res)
+		return nil
+	}
+	return ioutil.WriteFile(renderOutput, []byte(res), 0644)
+}
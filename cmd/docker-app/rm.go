@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/docker/app/internal/packager"
+	"github.com/docker/app/internal/store"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/spf13/cobra"
+)
+
+func rmCmd(dockerCli command.Cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rm <app-name>...",
+		Short: "Remove applications from the local store",
+		Long: `Remove applications from the local store. Removing a reference pinned to a digest
+removes all the tags of that digest.`,
+		Args: cli.RequiresMinArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s := store.New(store.DefaultDir())
+			for _, ref := range args {
+				if err := packager.RemoveFromStore(s, ref); err != nil {
+					return err
+				}
+				fmt.Fprintf(dockerCli.Out(), "Removed %s\n", ref)
+			}
+			return nil
+		},
+	}
+}
@@ -44,22 +44,36 @@ func newRootCmd(dockerCli *command.DockerCli) *cobra.Command {
 func addCommands(cmd *cobra.Command, dockerCli command.Cli) {
 	cmd.AddCommand(
 		deployCmd(dockerCli),
+		diffCmd(dockerCli),
+		downCmd(dockerCli),
+		exportCmd(),
 		forkCmd(),
 		helmCmd(),
 		initCmd(),
 		inspectCmd(dockerCli),
+		lockCmd(dockerCli),
+		logsCmd(dockerCli),
+		lsCmd(dockerCli),
+		lsRemoteCmd(dockerCli),
 		mergeCmd(dockerCli),
+		pinCmd(dockerCli),
+		psCmd(dockerCli),
 		pushCmd(),
 		renderCmd(dockerCli),
+		rmCmd(dockerCli),
+		searchCmd(dockerCli),
 		splitCmd(),
+		tagCmd(),
+		upCmd(dockerCli),
+		upgradeCmd(dockerCli),
 		validateCmd(),
 		versionCmd(dockerCli),
 		completionCmd(dockerCli, cmd),
 	)
 	if internal.Experimental == "on" {
 		cmd.AddCommand(
-			imageAddCmd(),
-			imageLoadCmd(),
+			imageAddCmd(dockerCli),
+			imageLoadCmd(dockerCli),
 			packCmd(dockerCli),
 			pullCmd(),
 			unpackCmd(),
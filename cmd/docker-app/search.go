@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/docker/app/internal/packager"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/spf13/cobra"
+)
+
+func searchCmd(dockerCli command.Cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "search <registry> [<term>]",
+		Short: "Search a registry for applications",
+		Long: `Search a registry for applications whose repository name contains the term, ignoring case.
+Only repositories with the .dockerapp extension are listed. The registry must support the catalog API, which Docker Hub doesn't.`,
+		Args: cli.RequiresRangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			term := ""
+			if len(args) > 1 {
+				term = args[1]
+			}
+			repos, err := packager.Search(args[0], term)
+			if err != nil {
+				return err
+			}
+			for _, repo := range repos {
+				fmt.Fprintln(dockerCli.Out(), repo)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/docker/app/internal/packager"
+	"github.com/docker/app/internal/store"
+	"github.com/docker/app/render"
+	"github.com/docker/cli/cli/config"
+	"github.com/spf13/pflag"
+)
+
+type verifyOptions struct {
+	verify      bool
+	trustedKeys string
+}
+
+func (o *verifyOptions) addFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.verify, "verify", false, "Refuse applications pulled from a registry without a valid signature by a trusted key")
+	flags.StringVar(&o.trustedKeys, "trusted-keys", filepath.Join(config.Dir(), "app", "trusted-keys"), "Directory of the trusted public keys")
+}
+
+// policy returns the verification policy, nil if verification is disabled
+func (o *verifyOptions) policy() (*packager.VerifyPolicy, error) {
+	if !o.verify {
+		return nil, nil
+	}
+	return packager.NewVerifyPolicy(o.trustedKeys)
+}
+
+// secretsOptions are the options of the commands resolving the secret
+// settings of apps which may come from a registry
+type secretsOptions struct {
+	allowAppSecrets bool
+}
+
+func (o *secretsOptions) addFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.allowAppSecrets, "allow-app-secrets", false, "Resolve the secret references declared by applications from a registry or an URL")
+}
+
+// renderOptions returns the rendering options resolving the secrets as allowed
+func (o *secretsOptions) renderOptions() []func(*render.Options) {
+	if o.allowAppSecrets {
+		return []func(*render.Options){render.WithRemoteSecrets()}
+	}
+	return nil
+}
+
+// sourceOptions are the options of the commands loading apps which may come
+// from a registry
+type sourceOptions struct {
+	verifyOptions
+	secretsOptions
+	pull string
+}
+
+func (o *sourceOptions) addFlags(flags *pflag.FlagSet) {
+	o.verifyOptions.addFlags(flags)
+	o.secretsOptions.addFlags(flags)
+	flags.StringVar(&o.pull, "pull", string(packager.PullMissing), `Pull applications from a registry "always", only those "missing" from the local store, or "never"`)
+}
+
+func (o *sourceOptions) pullOptions() (packager.PullOptions, error) {
+	policy, err := packager.ParsePullPolicy(o.pull)
+	if err != nil {
+		return packager.PullOptions{}, err
+	}
+	verify, err := o.policy()
+	if err != nil {
+		return packager.PullOptions{}, err
+	}
+	return packager.PullOptions{
+		Policy: policy,
+		Store:  store.New(store.DefaultDir()),
+		Verify: verify,
+	}, nil
+}
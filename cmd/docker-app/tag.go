@@ -0,0 +1,18 @@
+package main
+
+import (
+	"github.com/docker/app/internal/store"
+	"github.com/docker/cli/cli"
+	"github.com/spf13/cobra"
+)
+
+func tagCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "tag <source> <target>",
+		Short: "Tag an application of the local store with another reference",
+		Args:  cli.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return store.New(store.DefaultDir()).Tag(args[0], args[1])
+		},
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+
+	"github.com/docker/app/internal"
+	"github.com/docker/app/internal/local"
+	"github.com/docker/app/internal/packager"
+	"github.com/docker/app/render"
+	"github.com/docker/app/types"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	composetypes "github.com/docker/cli/cli/compose/types"
+	cliopts "github.com/docker/cli/opts"
+	"github.com/spf13/cobra"
+)
+
+type upOptions struct {
+	upComposeFiles  []string
+	upSettingsFiles []string
+	upEnvironment   string
+	upEnv           []string
+	upProjectName   string
+	upWatch         bool
+	secretsOptions
+}
+
+func upCmd(dockerCli command.Cli) *cobra.Command {
+	var opts upOptions
+
+	cmd := &cobra.Command{
+		Use:   "up [<app-name>]",
+		Short: "Run the application on a single engine",
+		Long: `Run the application on the engine without swarm mode, as docker-compose would: create its networks
+and volumes, then the containers of its services after those they depend on. Running it again
+recreates the containers whose service changed, and removes those of the removed services.
+
+With --watch, the application is rendered and updated again each time its files change.`,
+		Args: cli.RequiresMaxArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runUp(dockerCli, firstOrEmpty(args), opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.upEnvironment, "env", "", "Environment (named settings) to use")
+	cmd.Flags().StringArrayVarP(&opts.upSettingsFiles, "settings-files", "f", []string{}, "Override settings files")
+	cmd.Flags().StringArrayVarP(&opts.upEnv, "set", "s", []string{}, "Override settings values")
+	cmd.Flags().StringVarP(&opts.upProjectName, "name", "d", "", "Project name, prefixing the containers, networks and volumes (default: app name)")
+	cmd.Flags().BoolVarP(&opts.upWatch, "watch", "w", false, "Update the application each time its files change, printing the changes")
+	opts.secretsOptions.addFlags(cmd.Flags())
+	if internal.Experimental == "on" {
+		cmd.Flags().StringArrayVarP(&opts.upComposeFiles, "compose-files", "c", []string{}, "Override Compose files")
+	}
+	return cmd
+}
+
+func runUp(dockerCli command.Cli, appname string, opts upOptions) error {
+	if !opts.upWatch {
+		app, rendered, err := renderUp(appname, opts)
+		if err != nil {
+			return err
+		}
+		return local.Up(context.Background(), dockerCli.Client(), upProject(app, opts), app.Metadata(), rendered, dockerCli.Out())
+	}
+	var app *types.App
+	files := append(append([]string{}, opts.upSettingsFiles...), opts.upComposeFiles...)
+	return watchApp(dockerCli, appname, files, func() (*composetypes.Config, error) {
+		var (
+			rendered *composetypes.Config
+			err      error
+		)
+		app, rendered, err = renderUp(appname, opts)
+		return rendered, err
+	}, func(previous, rendered *composetypes.Config) error {
+		if previous != nil {
+			if err := printChanges(dockerCli.Out(), previous, rendered); err != nil {
+				return err
+			}
+		}
+		return local.Up(context.Background(), dockerCli.Client(), upProject(app, opts), app.Metadata(), rendered, dockerCli.Out())
+	})
+}
+
+// renderUp renders the application to run
+func renderUp(appname string, opts upOptions) (*types.App, *composetypes.Config, error) {
+	app, err := packager.Extract(appname,
+		types.WithEnvironment(opts.upEnvironment),
+		types.WithSettingsFiles(opts.upSettingsFiles...),
+		types.WithComposeOverrideFiles(opts.upComposeFiles...),
+		packager.WithDependencies(packager.PullOptions{}),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer app.Cleanup()
+	rendered, err := render.Render(app, cliopts.ConvertKVStringsToMap(opts.upEnv), opts.renderOptions()...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return app, rendered, nil
+}
+
+func upProject(app *types.App, opts upOptions) string {
+	if opts.upProjectName != "" {
+		return opts.upProjectName
+	}
+	return internal.AppNameFromDir(app.Name)
+}
+
+// projectName returns the given project name, or else the name of the app
+func projectName(appname, name string) (string, error) {
+	if name != "" {
+		return name, nil
+	}
+	app, err := packager.Extract(appname)
+	if err != nil {
+		return "", err
+	}
+	defer app.Cleanup()
+	return internal.AppNameFromDir(app.Name), nil
+}
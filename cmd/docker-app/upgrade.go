@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/app/internal/packager"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var upgradeVersion string
+
+func upgradeCmd(dockerCli command.Cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "upgrade [<app-name>] [--version constraint]",
+		Short: "Merge the changes of a newer version of the parent application into a fork",
+		Long: `Merge the changes of a newer version of the parent application into a fork.
+The newest version of the parent satisfying the --version semantic version constraint (e.g. "^1.2", "~1.2.3", ">=1.2 <2") is used.
+Conflicting changes are left between conflict markers, to be resolved manually.`,
+		Args: cli.RequiresMaxArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := packager.Extract(firstOrEmpty(args))
+			if err != nil {
+				return err
+			}
+			defer app.Cleanup()
+			result, err := packager.Upgrade(app, upgradeVersion)
+			if err != nil {
+				return err
+			}
+			if len(result.Available) != 0 {
+				fmt.Fprintf(dockerCli.Out(), "Newer versions of %s: %s\n", result.Parent, strings.Join(result.Available, ", "))
+			}
+			if result.To == "" {
+				fmt.Fprintf(dockerCli.Out(), "%s is up to date with %s:%s\n", app.Name, result.Parent, result.From)
+				return nil
+			}
+			fmt.Fprintf(dockerCli.Out(), "Upgraded %s from %s:%s to %s:%s\n", app.Name, result.Parent, result.From, result.Parent, result.To)
+			if len(result.Conflicts) != 0 {
+				return errors.Errorf("conflicting changes, to be resolved manually, in: %s", strings.Join(result.Conflicts, ", "))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&upgradeVersion, "version", "", "Semantic version constraint of the parent version to upgrade to (default: the newest)")
+	return cmd
+}
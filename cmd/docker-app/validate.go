@@ -11,28 +11,34 @@ import (
 
 var (
 	validateSettingsFile []string
+	validateEnvironment  string
 	validateEnv          []string
+	validateSecrets      secretsOptions
 )
 
 func validateCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "validate [<app-name>] [-s key=value...] [-f settings-file...]",
+		Use:   "validate [<app-name>] [-s key=value...] [-f settings-file...] [--env environment]",
 		Short: "Checks the rendered application is syntactically correct",
 		Args:  cli.RequiresMaxArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			app, err := packager.Extract(firstOrEmpty(args),
+				types.WithEnvironment(validateEnvironment),
 				types.WithSettingsFiles(validateSettingsFile...),
+				packager.WithDependencies(packager.PullOptions{}),
 			)
 			if err != nil {
 				return err
 			}
 			defer app.Cleanup()
 			argSettings := cliopts.ConvertKVStringsToMap(validateEnv)
-			_, err = render.Render(app, argSettings)
+			_, err = render.Render(app, argSettings, validateSecrets.renderOptions()...)
 			return err
 		},
 	}
+	cmd.Flags().StringVar(&validateEnvironment, "env", "", "Environment (named settings) to use")
 	cmd.Flags().StringArrayVarP(&validateSettingsFile, "settings-files", "f", []string{}, "Override settings files")
 	cmd.Flags().StringArrayVarP(&validateEnv, "set", "s", []string{}, "Override settings values")
+	validateSecrets.addFlags(cmd.Flags())
 	return cmd
 }
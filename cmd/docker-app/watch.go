@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/docker/app/internal/diff"
+	"github.com/docker/app/internal/formatter"
+	"github.com/docker/app/internal/packager"
+	"github.com/docker/app/internal/watch"
+	"github.com/docker/cli/cli/command"
+	composetypes "github.com/docker/cli/cli/compose/types"
+)
+
+// watchApp renders the application, then renders it again each time its files
+// or the given settings and compose files change, and calls apply with the
+// previously and the newly rendered configs. Errors are printed without
+// stopping the watch, which lasts until interrupted.
+func watchApp(dockerCli command.Cli, appname string, files []string, render func() (*composetypes.Config, error), apply func(previous, rendered *composetypes.Config) error) error {
+	path, err := packager.Locate(appname)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := interruptContext()
+	defer cancel()
+	var previous *composetypes.Config
+	return watch.Run(ctx, append([]string{path}, files...), watch.Interval, func() {
+		rendered, err := render()
+		if err == nil {
+			err = apply(previous, rendered)
+		}
+		if err != nil {
+			fmt.Fprintf(dockerCli.Err(), "Error: %s\n", err)
+			return
+		}
+		previous = rendered
+	})
+}
+
+// printChanges prints the changes of the rendered config since the previous
+// rendering
+func printChanges(out io.Writer, previous, rendered *composetypes.Config) error {
+	d := diff.Compare(previous, rendered)
+	if d.Empty() {
+		fmt.Fprintln(out, "No changes")
+		return nil
+	}
+	res, err := formatter.FormatDiff(d, "text")
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(out, res)
+	return nil
+}
+
+// interruptContext returns a context canceled on interrupt
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(interrupt)
+		cancel()
+	}
+}
@@ -1,7 +1,9 @@
 package compose
 
 import (
+	"fmt"
 	"regexp"
+	"strconv"
 
 	"github.com/docker/cli/cli/compose/loader"
 	"github.com/docker/cli/cli/compose/template"
@@ -9,17 +11,44 @@ import (
 	"github.com/pkg/errors"
 )
 
-// Load applies the specified function when loading a slice of compose data
-func Load(composes [][]byte, apply func(string) (string, error)) ([]composetypes.ConfigFile, error) {
+var yamlLine = regexp.MustCompile(`^yaml: line (\d+):`)
+
+// ParseError is an error parsing compose data, once the function applied
+type ParseError struct {
+	// Index is the index of the compose data in the slice
+	Index int
+	// Line is the line of the error in the data the function returned,
+	// starting at 1, or 0 if unknown
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse Compose file: %s", e.Err)
+}
+
+// Cause returns the underlying error
+func (e *ParseError) Cause() error {
+	return e.Err
+}
+
+// Load applies the specified function when loading a slice of compose data,
+// given the index of each compose data in the slice. Parsing errors are
+// *ParseError.
+func Load(composes [][]byte, apply func(int, string) (string, error)) ([]composetypes.ConfigFile, error) {
 	configFiles := []composetypes.ConfigFile{}
-	for _, data := range composes {
-		s, err := apply(string(data))
+	for i, data := range composes {
+		s, err := apply(i, string(data))
 		if err != nil {
 			return nil, err
 		}
 		parsed, err := loader.ParseYAML([]byte(s))
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to parse Compose file %s", data)
+			perr := &ParseError{Index: i, Err: errors.Cause(err)}
+			if m := yamlLine.FindStringSubmatch(perr.Err.Error()); m != nil {
+				perr.Line, _ = strconv.Atoi(m[1])
+			}
+			return nil, perr
 		}
 		configFiles = append(configFiles, composetypes.ConfigFile{Config: parsed})
 	}
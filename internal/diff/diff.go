@@ -0,0 +1,290 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	composetypes "github.com/docker/cli/cli/compose/types"
+)
+
+// Status of an element present in only one or in both compared configs
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// Diff is the difference between two rendered Compose configs
+type Diff struct {
+	Services []ServiceDiff  `json:"services,omitempty"`
+	Networks []ResourceDiff `json:"networks,omitempty"`
+	Volumes  []ResourceDiff `json:"volumes,omitempty"`
+}
+
+// ServiceDiff is the difference between two versions of a service
+type ServiceDiff struct {
+	Name    string   `json:"name"`
+	Status  string   `json:"status"`
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// Change is the difference of a single field, e.g. "image", "replicas",
+// "ports.8080:80/tcp", "environment.DEBUG", "volumes./data" or
+// "networks.front" for services, "driver" or "labels.tier" for networks and
+// volumes
+type Change struct {
+	Field  string `json:"field"`
+	Status string `json:"status"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// ResourceDiff is the difference between two versions of a top-level network
+// or volume
+type ResourceDiff struct {
+	Name    string   `json:"name"`
+	Status  string   `json:"status"`
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// Empty returns true if there is no difference
+func (d *Diff) Empty() bool {
+	return len(d.Services) == 0 && len(d.Networks) == 0 && len(d.Volumes) == 0
+}
+
+// Compare returns the difference between the before and after configs, sorted by name
+func Compare(before, after *composetypes.Config) *Diff {
+	d := &Diff{}
+	beforeServices := servicesByName(before.Services)
+	afterServices := servicesByName(after.Services)
+	for _, name := range unionKeys(serviceNames(before.Services), serviceNames(after.Services)) {
+		b, inBefore := beforeServices[name]
+		a, inAfter := afterServices[name]
+		var status string
+		switch {
+		case !inBefore:
+			status = Added
+		case !inAfter:
+			status = Removed
+		default:
+			status = Changed
+		}
+		changes := compareFields(b, a)
+		if len(changes) == 0 {
+			continue
+		}
+		d.Services = append(d.Services, ServiceDiff{Name: name, Status: status, Changes: changes})
+	}
+	d.Networks = compareResources(networksByName(before.Networks), networksByName(after.Networks))
+	d.Volumes = compareResources(volumesByName(before.Volumes), volumesByName(after.Volumes))
+	return d
+}
+
+func compareFields(before, after map[string]string) []Change {
+	var changes []Change
+	for _, field := range unionKeys(before, after) {
+		b, inBefore := before[field]
+		a, inAfter := after[field]
+		switch {
+		case !inBefore:
+			changes = append(changes, Change{Field: field, Status: Added, After: a})
+		case !inAfter:
+			changes = append(changes, Change{Field: field, Status: Removed, Before: b})
+		case a != b:
+			changes = append(changes, Change{Field: field, Status: Changed, Before: b, After: a})
+		}
+	}
+	return changes
+}
+
+func compareResources(before, after map[string]map[string]string) []ResourceDiff {
+	var diffs []ResourceDiff
+	for _, name := range unionKeys(resourceNames(before), resourceNames(after)) {
+		b, inBefore := before[name]
+		a, inAfter := after[name]
+		changes := compareFields(b, a)
+		switch {
+		case !inBefore:
+			diffs = append(diffs, ResourceDiff{Name: name, Status: Added, Changes: changes})
+		case !inAfter:
+			diffs = append(diffs, ResourceDiff{Name: name, Status: Removed, Changes: changes})
+		case len(changes) > 0:
+			diffs = append(diffs, ResourceDiff{Name: name, Status: Changed, Changes: changes})
+		}
+	}
+	return diffs
+}
+
+func servicesByName(services []composetypes.ServiceConfig) map[string]map[string]string {
+	m := make(map[string]map[string]string, len(services))
+	for _, service := range services {
+		m[service.Name] = serviceFields(service)
+	}
+	return m
+}
+
+func serviceNames(services []composetypes.ServiceConfig) map[string]string {
+	m := make(map[string]string, len(services))
+	for _, service := range services {
+		m[service.Name] = service.Name
+	}
+	return m
+}
+
+// serviceFields flattens the compared fields of a service
+func serviceFields(service composetypes.ServiceConfig) map[string]string {
+	fields := map[string]string{
+		"image":    service.Image,
+		"replicas": strconv.Itoa(getReplicas(service)),
+	}
+	for _, port := range service.Ports {
+		fields["ports."+portKey(port)] = formatPort(port)
+	}
+	for name, value := range service.Environment {
+		v := ""
+		if value != nil {
+			v = *value
+		}
+		fields["environment."+name] = v
+	}
+	for _, volume := range service.Volumes {
+		fields["volumes."+volume.Target] = formatVolume(volume)
+	}
+	for name, network := range service.Networks {
+		var aliases []string
+		if network != nil {
+			aliases = network.Aliases
+		}
+		fields["networks."+name] = strings.Join(aliases, ",")
+	}
+	return fields
+}
+
+func getReplicas(service composetypes.ServiceConfig) int {
+	if service.Deploy.Replicas != nil {
+		return int(*service.Deploy.Replicas)
+	}
+	return 1
+}
+
+func protocol(port composetypes.ServicePortConfig) string {
+	if port.Protocol == "" {
+		return "tcp"
+	}
+	return port.Protocol
+}
+
+// portKey identifies a port by its published port, target, protocol and mode,
+// several ports possibly being published for the same target
+func portKey(port composetypes.ServicePortConfig) string {
+	s := fmt.Sprintf("%d/%s", port.Target, protocol(port))
+	if port.Published != 0 {
+		s = fmt.Sprintf("%d:%s", port.Published, s)
+	}
+	if port.Mode != "" {
+		s += "/" + port.Mode
+	}
+	return s
+}
+
+func formatPort(port composetypes.ServicePortConfig) string {
+	s := fmt.Sprintf("%d/%s", port.Target, protocol(port))
+	if port.Published != 0 {
+		s = fmt.Sprintf("%d:%s", port.Published, s)
+	}
+	if port.Mode != "" {
+		s += " (" + port.Mode + ")"
+	}
+	return s
+}
+
+func formatVolume(volume composetypes.ServiceVolumeConfig) string {
+	s := volume.Type + ":" + volume.Source
+	if volume.ReadOnly {
+		s += ":ro"
+	}
+	return s
+}
+
+func networksByName(networks map[string]composetypes.NetworkConfig) map[string]map[string]string {
+	m := make(map[string]map[string]string, len(networks))
+	for name, network := range networks {
+		fields := resourceFields(network.Name, network.Driver, network.DriverOpts, network.External, network.Labels)
+		setField(fields, "ipam.driver", network.Ipam.Driver)
+		var subnets []string
+		for _, pool := range network.Ipam.Config {
+			if pool != nil {
+				subnets = append(subnets, pool.Subnet)
+			}
+		}
+		setField(fields, "ipam.subnets", strings.Join(subnets, ","))
+		if network.Internal {
+			fields["internal"] = "true"
+		}
+		if network.Attachable {
+			fields["attachable"] = "true"
+		}
+		m[name] = fields
+	}
+	return m
+}
+
+func volumesByName(volumes map[string]composetypes.VolumeConfig) map[string]map[string]string {
+	m := make(map[string]map[string]string, len(volumes))
+	for name, volume := range volumes {
+		m[name] = resourceFields(volume.Name, volume.Driver, volume.DriverOpts, volume.External, volume.Labels)
+	}
+	return m
+}
+
+// resourceFields flattens the compared fields shared by networks and volumes,
+// leaving out the empty ones
+func resourceFields(name, driver string, driverOpts map[string]string, external composetypes.External, labels composetypes.Labels) map[string]string {
+	fields := map[string]string{}
+	setField(fields, "name", name)
+	setField(fields, "driver", driver)
+	for k, v := range driverOpts {
+		fields["driver_opts."+k] = v
+	}
+	if external.External {
+		fields["external"] = "true"
+		setField(fields, "external.name", external.Name)
+	}
+	for k, v := range labels {
+		fields["labels."+k] = v
+	}
+	return fields
+}
+
+func setField(fields map[string]string, field, value string) {
+	if value != "" {
+		fields[field] = value
+	}
+}
+
+func resourceNames(resources map[string]map[string]string) map[string]string {
+	m := make(map[string]string, len(resources))
+	for name := range resources {
+		m[name] = name
+	}
+	return m
+}
+
+// unionKeys returns the sorted keys present in any of the maps
+func unionKeys(before, after map[string]string) []string {
+	set := map[string]bool{}
+	for k := range before {
+		set[k] = true
+	}
+	for k := range after {
+		set[k] = true
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,139 @@
+package diff
+
+import (
+	"testing"
+
+	composetypes "github.com/docker/cli/cli/compose/types"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func uint64Ptr(i uint64) *uint64 {
+	return &i
+}
+
+func TestCompareIdentical(t *testing.T) {
+	config := &composetypes.Config{
+		Services: []composetypes.ServiceConfig{{Name: "web", Image: "nginx"}},
+		Networks: map[string]composetypes.NetworkConfig{"front": {}},
+	}
+	d := Compare(config, config)
+	assert.Check(t, d.Empty())
+}
+
+func TestCompare(t *testing.T) {
+	before := &composetypes.Config{
+		Services: []composetypes.ServiceConfig{
+			{
+				Name:  "web",
+				Image: "nginx:1.14",
+				Ports: []composetypes.ServicePortConfig{
+					{Target: 80, Published: 8080, Protocol: "tcp"},
+					{Target: 443, Published: 8443},
+				},
+				Environment: composetypes.MappingWithEquals{"DEBUG": strPtr("true"), "LEVEL": strPtr("info")},
+				Volumes:     []composetypes.ServiceVolumeConfig{{Type: "volume", Source: "data", Target: "/data"}},
+				Networks:    map[string]*composetypes.ServiceNetworkConfig{"front": nil},
+			},
+			{Name: "cache", Image: "redis"},
+		},
+		Networks: map[string]composetypes.NetworkConfig{"front": {}},
+		Volumes:  map[string]composetypes.VolumeConfig{"data": {}},
+	}
+	after := &composetypes.Config{
+		Services: []composetypes.ServiceConfig{
+			{
+				Name:        "web",
+				Image:       "nginx:1.15",
+				Ports:       []composetypes.ServicePortConfig{{Target: 80, Published: 80}},
+				Environment: composetypes.MappingWithEquals{"LEVEL": strPtr("info"), "NAME": strPtr("web")},
+				Volumes:     []composetypes.ServiceVolumeConfig{{Type: "volume", Source: "data", Target: "/data", ReadOnly: true}},
+				Networks:    map[string]*composetypes.ServiceNetworkConfig{"front": nil, "back": {Aliases: []string{"www"}}},
+				Deploy:      composetypes.DeployConfig{Replicas: uint64Ptr(3)},
+			},
+			{Name: "db", Image: "postgres"},
+		},
+		Networks: map[string]composetypes.NetworkConfig{"front": {}, "back": {}},
+	}
+	d := Compare(before, after)
+	assert.Check(t, is.DeepEqual(d, &Diff{
+		Services: []ServiceDiff{
+			{Name: "cache", Status: Removed, Changes: []Change{
+				{Field: "image", Status: Removed, Before: "redis"},
+				{Field: "replicas", Status: Removed, Before: "1"},
+			}},
+			{Name: "db", Status: Added, Changes: []Change{
+				{Field: "image", Status: Added, After: "postgres"},
+				{Field: "replicas", Status: Added, After: "1"},
+			}},
+			{Name: "web", Status: Changed, Changes: []Change{
+				{Field: "environment.DEBUG", Status: Removed, Before: "true"},
+				{Field: "environment.NAME", Status: Added, After: "web"},
+				{Field: "image", Status: Changed, Before: "nginx:1.14", After: "nginx:1.15"},
+				{Field: "networks.back", Status: Added, After: "www"},
+				{Field: "ports.8080:80/tcp", Status: Removed, Before: "8080:80/tcp"},
+				{Field: "ports.80:80/tcp", Status: Added, After: "80:80/tcp"},
+				{Field: "ports.8443:443/tcp", Status: Removed, Before: "8443:443/tcp"},
+				{Field: "replicas", Status: Changed, Before: "1", After: "3"},
+				{Field: "volumes./data", Status: Changed, Before: "volume:data", After: "volume:data:ro"},
+			}},
+		},
+		Networks: []ResourceDiff{{Name: "back", Status: Added}},
+		Volumes:  []ResourceDiff{{Name: "data", Status: Removed}},
+	}))
+}
+
+func TestComparePortsOfTheSameTarget(t *testing.T) {
+	before := &composetypes.Config{
+		Services: []composetypes.ServiceConfig{{Name: "web", Image: "nginx", Ports: []composetypes.ServicePortConfig{
+			{Target: 80, Published: 8080},
+			{Target: 80, Published: 8081},
+		}}},
+	}
+	after := &composetypes.Config{
+		Services: []composetypes.ServiceConfig{{Name: "web", Image: "nginx", Ports: []composetypes.ServicePortConfig{
+			{Target: 80, Published: 8080},
+			{Target: 80, Published: 8081, Mode: "host"},
+		}}},
+	}
+	d := Compare(before, after)
+	assert.Check(t, is.DeepEqual(d.Services, []ServiceDiff{
+		{Name: "web", Status: Changed, Changes: []Change{
+			{Field: "ports.8081:80/tcp", Status: Removed, Before: "8081:80/tcp"},
+			{Field: "ports.8081:80/tcp/host", Status: Added, After: "8081:80/tcp (host)"},
+		}},
+	}))
+}
+
+func TestCompareResourceConfigs(t *testing.T) {
+	before := &composetypes.Config{
+		Networks: map[string]composetypes.NetworkConfig{
+			"front": {Driver: "overlay", Labels: composetypes.Labels{"tier": "front"}},
+		},
+		Volumes: map[string]composetypes.VolumeConfig{
+			"data": {Driver: "local", DriverOpts: map[string]string{"type": "nfs"}},
+		},
+	}
+	after := &composetypes.Config{
+		Networks: map[string]composetypes.NetworkConfig{
+			"front": {Driver: "overlay", Attachable: true, Labels: composetypes.Labels{"tier": "web"}},
+		},
+		Volumes: map[string]composetypes.VolumeConfig{
+			"data": {Driver: "local", DriverOpts: map[string]string{"type": "tmpfs"}},
+		},
+	}
+	d := Compare(before, after)
+	assert.Check(t, is.DeepEqual(d, &Diff{
+		Networks: []ResourceDiff{{Name: "front", Status: Changed, Changes: []Change{
+			{Field: "attachable", Status: Added, After: "true"},
+			{Field: "labels.tier", Status: Changed, Before: "front", After: "web"},
+		}}},
+		Volumes: []ResourceDiff{{Name: "data", Status: Changed, Changes: []Change{
+			{Field: "driver_opts.type", Status: Changed, Before: "nfs", After: "tmpfs"},
+		}}},
+	}))
+}
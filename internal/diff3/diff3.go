@@ -0,0 +1,131 @@
+package diff3
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Merge merges, line by line, the changes made from base to theirs into ours.
+// Conflicting changes are written between conflict markers labelled with the
+// given names. It returns the merged content and whether it has conflicts.
+func Merge(base, ours, theirs []byte, oursLabel, theirsLabel string) ([]byte, bool) {
+	baseLines, ourLines, theirLines := splitLines(base), splitLines(ours), splitLines(theirs)
+	ourMatches := match(baseLines, ourLines)
+	theirMatches := match(baseLines, theirLines)
+
+	var (
+		out      bytes.Buffer
+		conflict bool
+		o, a, b  int
+	)
+	for {
+		// stable line, unchanged on both sides
+		if a < len(baseLines) && ourMatches[a] == o && theirMatches[a] == b {
+			out.WriteString(baseLines[a])
+			o, a, b = o+1, a+1, b+1
+			continue
+		}
+		// find the next base line kept on both sides, closing the changed chunk
+		next := a
+		for next < len(baseLines) && (ourMatches[next] == -1 || theirMatches[next] == -1) {
+			next++
+		}
+		nextOurs, nextTheirs := len(ourLines), len(theirLines)
+		if next < len(baseLines) {
+			nextOurs, nextTheirs = ourMatches[next], theirMatches[next]
+		}
+		baseChunk, ourChunk, theirChunk := baseLines[a:next], ourLines[o:nextOurs], theirLines[b:nextTheirs]
+		switch {
+		case equal(ourChunk, baseChunk):
+			writeLines(&out, theirChunk)
+		case equal(theirChunk, baseChunk), equal(ourChunk, theirChunk):
+			writeLines(&out, ourChunk)
+		default:
+			conflict = true
+			out.WriteString("<<<<<<< " + oursLabel + "\n")
+			writeLines(&out, ourChunk)
+			terminateLine(&out)
+			out.WriteString("=======\n")
+			writeLines(&out, theirChunk)
+			terminateLine(&out)
+			out.WriteString(">>>>>>> " + theirsLabel + "\n")
+		}
+		if next >= len(baseLines) {
+			break
+		}
+		o, a, b = nextOurs, next, nextTheirs
+	}
+	return out.Bytes(), conflict
+}
+
+// splitLines splits the data in lines, keeping the line terminators
+func splitLines(data []byte) []string {
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// match returns, for each line of a, the index of the matching line of b in
+// their longest common subsequence, or -1
+func match(a, b []string) []int {
+	// lengths[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			case lengths[i+1][j] >= lengths[i][j+1]:
+				lengths[i][j] = lengths[i+1][j]
+			default:
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+	matches := make([]int, len(a))
+	i, j := 0, 0
+	for i < len(a) {
+		switch {
+		case j < len(b) && a[i] == b[j]:
+			matches[i] = j
+			i, j = i+1, j+1
+		case j < len(b) && lengths[i][j+1] > lengths[i+1][j]:
+			j++
+		default:
+			matches[i] = -1
+			i++
+		}
+	}
+	return matches
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLines(out *bytes.Buffer, lines []string) {
+	for _, l := range lines {
+		out.WriteString(l)
+	}
+}
+
+// terminateLine ends the last line written, so that a conflict marker can follow
+func terminateLine(out *bytes.Buffer) {
+	if out.Len() > 0 && out.Bytes()[out.Len()-1] != '\n' {
+		out.WriteByte('\n')
+	}
+}
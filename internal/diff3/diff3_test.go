@@ -0,0 +1,76 @@
+package diff3
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+const base = `version: "3.6"
+services:
+  web:
+    image: nginx:1.14
+    ports:
+      - 80:80
+  db:
+    image: postgres:10
+`
+
+func TestMerge(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ours     string
+		theirs   string
+		expected string
+		conflict bool
+	}{
+		{
+			name:     "unchanged",
+			ours:     base,
+			theirs:   base,
+			expected: base,
+		},
+		{
+			name:     "theirs-only",
+			ours:     base,
+			theirs:   "version: \"3.6\"\nservices:\n  web:\n    image: nginx:1.15\n    ports:\n      - 80:80\n  db:\n    image: postgres:10\n",
+			expected: "version: \"3.6\"\nservices:\n  web:\n    image: nginx:1.15\n    ports:\n      - 80:80\n  db:\n    image: postgres:10\n",
+		},
+		{
+			name:     "both-sides-distinct-lines",
+			ours:     "version: \"3.6\"\nservices:\n  web:\n    image: nginx:1.14\n    ports:\n      - 8080:80\n  db:\n    image: postgres:10\n",
+			theirs:   "version: \"3.6\"\nservices:\n  web:\n    image: nginx:1.15\n    ports:\n      - 80:80\n  db:\n    image: postgres:11\n  cache:\n    image: redis\n",
+			expected: "version: \"3.6\"\nservices:\n  web:\n    image: nginx:1.15\n    ports:\n      - 8080:80\n  db:\n    image: postgres:11\n  cache:\n    image: redis\n",
+		},
+		{
+			name:     "same-change",
+			ours:     "version: \"3.6\"\nservices:\n  web:\n    image: nginx:1.15\n    ports:\n      - 80:80\n  db:\n    image: postgres:10\n",
+			theirs:   "version: \"3.6\"\nservices:\n  web:\n    image: nginx:1.15\n    ports:\n      - 80:80\n  db:\n    image: postgres:10\n",
+			expected: "version: \"3.6\"\nservices:\n  web:\n    image: nginx:1.15\n    ports:\n      - 80:80\n  db:\n    image: postgres:10\n",
+		},
+		{
+			name:     "conflict",
+			ours:     "version: \"3.6\"\nservices:\n  web:\n    image: httpd\n    ports:\n      - 80:80\n  db:\n    image: postgres:10\n",
+			theirs:   "version: \"3.6\"\nservices:\n  web:\n    image: nginx:1.15\n    ports:\n      - 80:80\n  db:\n    image: postgres:10",
+			expected: "version: \"3.6\"\nservices:\n  web:\n<<<<<<< fork\n    image: httpd\n=======\n    image: nginx:1.15\n>>>>>>> upstream\n    ports:\n      - 80:80\n  db:\n    image: postgres:10",
+			conflict: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			merged, conflict := Merge([]byte(base), []byte(tc.ours), []byte(tc.theirs), "fork", "upstream")
+			assert.Check(t, is.Equal(string(merged), tc.expected))
+			assert.Check(t, is.Equal(conflict, tc.conflict))
+		})
+	}
+}
+
+func TestMergeFromEmptyBase(t *testing.T) {
+	merged, conflict := Merge(nil, []byte("a: 1\n"), []byte("a: 1\n"), "fork", "upstream")
+	assert.Check(t, !conflict)
+	assert.Check(t, is.Equal(string(merged), "a: 1\n"))
+	merged, conflict = Merge(nil, nil, []byte("a: 1\n"), "fork", "upstream")
+	assert.Check(t, !conflict)
+	assert.Check(t, is.Equal(string(merged), "a: 1\n"))
+}
@@ -0,0 +1,543 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/app/internal/yaml"
+	"github.com/docker/app/render"
+	"github.com/docker/app/types"
+	"github.com/docker/app/types/settings"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ComposeFileName is the name of the exported compose file
+	ComposeFileName = "docker-compose.yml"
+	// EnvFileName is the name of the file docker-compose reads the default
+	// values of the variables from
+	EnvFileName = ".env"
+)
+
+// invalidVariableChars matches the setting name characters not allowed in
+// variable names
+var invalidVariableChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// OverlayFileName returns the name of the exported compose overlay at the
+// given position
+func OverlayFileName(i int) string {
+	return fmt.Sprintf("docker-compose.overlay-%d.yml", i)
+}
+
+// EnvironmentFileName returns the name of the override file of an
+// environment
+func EnvironmentFileName(name string) string {
+	return fmt.Sprintf("docker-compose.%s.yml", name)
+}
+
+// VariableName returns the name of the variable of a setting, upper-cased
+// with underscores
+func VariableName(setting string) string {
+	return invalidVariableChars.ReplaceAllString(strings.ToUpper(setting), "_")
+}
+
+// composeFile orders the top-level keys of the exported compose files
+type composeFile struct {
+	Version  interface{}            `yaml:"version,omitempty"`
+	Services map[string]interface{} `yaml:"services,omitempty"`
+	Others   map[string]interface{} `yaml:",inline"`
+}
+
+type exporter struct {
+	app *types.App
+	// renderers are the renderers of each compose file
+	renderers [][]string
+	metadata  settings.Settings
+	// metadataValues are the flattened metadata, inlined
+	metadataValues map[string]string
+	// env are the settings overriding the settings files
+	env settings.Settings
+	// variables are the variable names, by setting
+	variables map[string]string
+	// referred are the settings the compose files refer to
+	referred map[string]bool
+}
+
+// Compose exports the application to dir as a plain compose project, run by
+// docker-compose without docker-app:
+//   - docker-compose.yml, the compose file of the application, referring to
+//     the settings as ${VARIABLE}, upper-cased with underscores
+//   - docker-compose.overlay-<n>.yml, its compose overlays
+//   - .env, the default values of the variables, from the settings merged
+//     with env, and COMPOSE_FILE listing the overlays
+//   - docker-compose.<environment>.yml, for each environment, overriding the
+//     services, volumes, networks, configs and secrets it changes
+//
+// The compose files are rendered by the renderers beforehand, the metadata
+// they refer to is inlined and the services x-enabled disables removed. The
+// yatee renderer, which would inline the settings too, only applies when
+// the app metadata or DOCKERAPP_RENDERERS lists it.
+func Compose(app *types.App, env map[string]string, dir string) error {
+	if len(app.Dependencies()) != 0 {
+		return errors.New("applications with dependencies can't be exported as a compose project")
+	}
+	renderers, err := render.ComposeRenderers(app)
+	if err != nil {
+		return err
+	}
+	if _, ok := os.LookupEnv("DOCKERAPP_RENDERERS"); !ok && !app.Metadata().DeclaresRenderers() {
+		// yatee resolves the settings references itself, so it only
+		// applies when asked for
+		for i, chain := range renderers {
+			var withoutYatee []string
+			for _, r := range chain {
+				if r != "yatee" {
+					withoutYatee = append(withoutYatee, r)
+				}
+			}
+			renderers[i] = withoutYatee
+		}
+	}
+	metadata, err := settings.Load(app.MetadataRaw(), settings.WithPrefix("app"))
+	if err != nil {
+		return err
+	}
+	envSettings, err := settings.FromFlatten(env)
+	if err != nil {
+		return err
+	}
+	e := &exporter{
+		app:            app,
+		renderers:      renderers,
+		metadata:       metadata,
+		metadataValues: metadata.Flatten(),
+		env:            envSettings,
+		variables:      map[string]string{},
+		referred:       map[string]bool{},
+	}
+	defaults, err := e.settings(nil)
+	if err != nil {
+		return err
+	}
+	defaultValues := defaults.Flatten()
+	settingsByVariable := map[string]string{}
+	for setting := range defaultValues {
+		variable := VariableName(setting)
+		if other, ok := settingsByVariable[variable]; ok {
+			return errors.Errorf("settings %s and %s are both exported as variable %s", other, setting, variable)
+		}
+		settingsByVariable[variable] = setting
+		e.variables[setting] = variable
+	}
+	files, err := e.load(defaults)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create compose project directory")
+	}
+	composeFiles := []string{ComposeFileName}
+	for i, file := range files {
+		exported, err := interpolate(file, defaultValues, e.reference)
+		if err != nil {
+			return err
+		}
+		name := ComposeFileName
+		if i != 0 {
+			name = OverlayFileName(i)
+			composeFiles = append(composeFiles, name)
+		}
+		if err := writeComposeFile(filepath.Join(dir, name), exported); err != nil {
+			return err
+		}
+	}
+	for _, name := range app.NamedSettingsNames() {
+		if err := e.exportEnvironment(dir, name, files, defaultValues); err != nil {
+			return errors.Wrapf(err, "failed to export environment %s", name)
+		}
+	}
+	return e.writeEnvFile(filepath.Join(dir, EnvFileName), defaultValues, composeFiles)
+}
+
+// load applies the renderers to the compose files, with the settings and
+// the metadata, and parses them
+func (e *exporter) load(s settings.Settings) ([]map[string]interface{}, error) {
+	all, err := settings.Merge(s, e.metadata)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to merge settings")
+	}
+	configFiles, err := render.LoadComposes(e.app, all, e.renderers)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load composefiles")
+	}
+	var files []map[string]interface{}
+	for _, f := range configFiles {
+		files = append(files, f.Config)
+	}
+	return files, nil
+}
+
+// reference replaces the settings a string refers to with their variable,
+// and the metadata with its value
+func (e *exporter) reference(s string) (interface{}, error) {
+	return replace(s, func(key, modifier string) (string, error) {
+		if value, ok := e.metadataValues[key]; ok {
+			return escape(value), nil
+		}
+		variable, ok := e.variables[key]
+		if !ok {
+			return "", errors.Errorf("required variable %s is missing a value", key)
+		}
+		e.referred[key] = true
+		return "${" + variable + modifier + "}", nil
+	})
+}
+
+// values returns the function replacing the settings and metadata a string
+// refers to with their value, the secret references with their variable.
+// Strings made of a single reference to an integer or boolean value become
+// that integer or boolean.
+func (e *exporter) values(values map[string]string) func(string) (interface{}, error) {
+	lookup := func(key, modifier string) (string, error) {
+		if value, ok := e.metadataValues[key]; ok {
+			return escape(value), nil
+		}
+		value, ok := values[key]
+		if !ok {
+			return "", errors.Errorf("required variable %s is missing a value", key)
+		}
+		if variable, ok := e.variables[key]; ok && e.isSecretReference(key, value) {
+			e.referred[key] = true
+			return "${" + variable + modifier + "}", nil
+		}
+		return escape(value), nil
+	}
+	return func(s string) (interface{}, error) {
+		replaced, err := replace(s, lookup)
+		if err != nil {
+			return nil, err
+		}
+		if m := render.Pattern.FindString(s); m == s && s != "$$" {
+			if i, err := strconv.Atoi(replaced); err == nil {
+				return i, nil
+			}
+			if b, err := strconv.ParseBool(replaced); err == nil && strings.ToLower(replaced) == replaced {
+				return b, nil
+			}
+		}
+		return replaced, nil
+	}
+}
+
+// settings loads the settings of the application, with the named settings
+// of an environment layered on top of the default ones, and the env settings.
+// They are loaded again each time, as merging settings shares their maps.
+func (e *exporter) settings(named []byte) (settings.Settings, error) {
+	contents := e.app.SettingsRaw()
+	if named != nil {
+		// the first settings content is the app default settings
+		i := 1
+		if len(contents) == 0 {
+			i = 0
+		}
+		contents = append(append(append([][]byte{}, contents[:i]...), named), contents[i:]...)
+	}
+	loaded, err := settings.LoadMultiple(contents, settings.WithSchema(e.app.SettingsSchema()))
+	if err != nil {
+		return nil, err
+	}
+	merged, err := settings.Merge(loaded, e.env)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to merge settings")
+	}
+	return merged, nil
+}
+
+// exportEnvironment writes the override file of an environment, holding what
+// it changes in the compose files
+func (e *exporter) exportEnvironment(dir, name string, files []map[string]interface{}, defaultValues map[string]string) error {
+	s, err := e.settings(e.app.NamedSettingsRaw()[name])
+	if err != nil {
+		return err
+	}
+	envFiles, err := e.load(s)
+	if err != nil {
+		return err
+	}
+	envValues := s.Flatten()
+	override := map[string]interface{}{}
+	for i, file := range files {
+		base, err := interpolate(file, defaultValues, e.values(defaultValues))
+		if err != nil {
+			return err
+		}
+		changed, err := interpolate(envFiles[i], envValues, e.values(envValues))
+		if err != nil {
+			return err
+		}
+		for _, service := range removedServices(base, changed) {
+			log.Warnf("service %s is disabled in environment %s, which compose override files can't express", service, name)
+		}
+		merge(override, overrides(base, changed))
+	}
+	if len(override) == 0 {
+		return nil
+	}
+	override["version"] = files[0]["version"]
+	return writeComposeFile(filepath.Join(dir, EnvironmentFileName(name)), override)
+}
+
+// writeEnvFile writes the default values of the variables the compose files
+// refer to, and the list of compose files when there are overlays. Secret
+// references are left empty, to be set in the environment.
+func (e *exporter) writeEnvFile(path string, values map[string]string, composeFiles []string) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString("# Default values of the application settings, overridden by the environment\n")
+	if len(composeFiles) > 1 {
+		fmt.Fprintf(buf, "COMPOSE_FILE=%s\n", strings.Join(composeFiles, string(os.PathListSeparator)))
+	}
+	var keys []string
+	for key := range e.referred {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return e.variables[keys[i]] < e.variables[keys[j]] })
+	for _, key := range keys {
+		value := values[key]
+		if e.isSecretReference(key, value) {
+			fmt.Fprintf(buf, "# %s is the secret %s, set it in the environment\n", e.variables[key], value)
+			value = ""
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return errors.Errorf("setting %s spans multiple lines, which %s can't hold", key, EnvFileName)
+		}
+		fmt.Fprintf(buf, "%s=%s\n", e.variables[key], value)
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// isSecretReference returns whether the value of the setting is a secret
+// reference, the schema marking the setting as secret
+func (e *exporter) isSecretReference(key, value string) bool {
+	return e.app.SettingsSchema().IsSecret(key) && settings.IsSecretReference(value)
+}
+
+// interpolate returns a copy of the compose file, its strings transformed by
+// the function, without the services disabled by x-enabled with the values
+func interpolate(file map[string]interface{}, values map[string]string, transform func(string) (interface{}, error)) (map[string]interface{}, error) {
+	disabled := map[string]bool{}
+	services, _ := file["services"].(map[string]interface{})
+	for name, s := range services {
+		service, _ := s.(map[string]interface{})
+		xEnabled, ok := service["x-enabled"]
+		if !ok {
+			continue
+		}
+		if value, ok := xEnabled.(string); ok {
+			var err error
+			if xEnabled, err = replace(value, func(key, _ string) (string, error) {
+				if value, ok := values[key]; ok {
+					return value, nil
+				}
+				return "", errors.Errorf("required variable %s is missing a value", key)
+			}); err != nil {
+				return nil, errors.Wrapf(err, "invalid x-enabled for service %s", name)
+			}
+		}
+		enabled, err := render.IsEnabled(xEnabled)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid x-enabled for service %s", name)
+		}
+		disabled[name] = !enabled
+	}
+	filtered := map[string]interface{}{}
+	for k, v := range file {
+		filtered[k] = v
+	}
+	if services != nil {
+		enabledServices := map[string]interface{}{}
+		for name, s := range services {
+			if disabled[name] {
+				continue
+			}
+			if service, ok := s.(map[string]interface{}); ok {
+				withoutXEnabled := map[string]interface{}{}
+				for k, v := range service {
+					if k != "x-enabled" {
+						withoutXEnabled[k] = v
+					}
+				}
+				s = withoutXEnabled
+			}
+			enabledServices[name] = s
+		}
+		filtered["services"] = enabledServices
+	}
+	result, err := transformValue(filtered, transform)
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[string]interface{}), nil
+}
+
+func transformValue(v interface{}, transform func(string) (interface{}, error)) (interface{}, error) {
+	switch value := v.(type) {
+	case string:
+		return transform(value)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			transformed, err := transformValue(item, transform)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = transformed
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(value))
+		for i, item := range value {
+			transformed, err := transformValue(item, transform)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = transformed
+		}
+		return l, nil
+	}
+	return v, nil
+}
+
+// replace replaces the references of a string with the value the function
+// returns for their setting and modifier, keeping the escaped dollars
+func replace(s string, value func(key, modifier string) (string, error)) (string, error) {
+	var err error
+	result := render.Pattern.ReplaceAllStringFunc(s, func(match string) string {
+		if err != nil {
+			return ""
+		}
+		if match == "$$" {
+			return match
+		}
+		key, modifier := splitReference(match)
+		if key == "" {
+			err = errors.Errorf("invalid template: %q", s)
+			return ""
+		}
+		var v string
+		v, err = value(key, modifier)
+		return v
+	})
+	return result, err
+}
+
+// splitReference returns the setting and the modifier, such as a default
+// value, of a reference
+func splitReference(reference string) (string, string) {
+	name := strings.TrimPrefix(reference, "$")
+	if strings.HasPrefix(name, "{") {
+		if !strings.HasSuffix(name, "}") {
+			return "", ""
+		}
+		name = name[1 : len(name)-1]
+	}
+	if i := strings.IndexAny(name, ":-?"); i >= 0 {
+		return name[:i], name[i:]
+	}
+	return name, ""
+}
+
+func escape(value string) string {
+	return strings.Replace(value, "$", "$$", -1)
+}
+
+// overrides returns the override of the compose file changing base to
+// changed: the services, volumes, networks, configs and secrets added, and
+// the fields of the services and the other objects changed
+func overrides(base, changed map[string]interface{}) map[string]interface{} {
+	override := map[string]interface{}{}
+	for _, section := range []string{"services", "volumes", "networks", "configs", "secrets"} {
+		baseObjects, _ := base[section].(map[string]interface{})
+		changedObjects, _ := changed[section].(map[string]interface{})
+		objects := map[string]interface{}{}
+		for name, object := range changedObjects {
+			baseObject, ok := baseObjects[name]
+			switch {
+			case !ok:
+				objects[name] = object
+			case section == "services":
+				fields := map[string]interface{}{}
+				baseFields, _ := baseObject.(map[string]interface{})
+				changedFields, _ := object.(map[string]interface{})
+				for field, value := range changedFields {
+					if !reflect.DeepEqual(baseFields[field], value) {
+						fields[field] = value
+					}
+				}
+				if len(fields) != 0 {
+					objects[name] = fields
+				}
+			case !reflect.DeepEqual(baseObject, object):
+				objects[name] = object
+			}
+		}
+		if len(objects) != 0 {
+			override[section] = objects
+		}
+	}
+	return override
+}
+
+// removedServices returns the services of base missing from changed
+func removedServices(base, changed map[string]interface{}) []string {
+	var removed []string
+	baseServices, _ := base["services"].(map[string]interface{})
+	changedServices, _ := changed["services"].(map[string]interface{})
+	for name := range baseServices {
+		if _, ok := changedServices[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// merge merges src into dst, recursively, src winning
+func merge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, ok := v.(map[string]interface{})
+		dstMap, ok2 := dst[k].(map[string]interface{})
+		if ok && ok2 {
+			merge(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+func writeComposeFile(path string, file map[string]interface{}) error {
+	ordered := composeFile{Others: map[string]interface{}{}}
+	for k, v := range file {
+		switch k {
+		case "version":
+			ordered.Version = v
+		case "services":
+			ordered.Services, _ = v.(map[string]interface{})
+		default:
+			ordered.Others[k] = v
+		}
+	}
+	data, err := yaml.Marshal(ordered)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal %s", filepath.Base(path))
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,103 @@
+package export
+
+import (
+	"testing"
+
+	"github.com/docker/app/types"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func TestCompose(t *testing.T) {
+	dir := fs.NewDir(t, "export",
+		fs.WithDir("app.dockerapp",
+			fs.WithFile("metadata.yml", "version: 0.1.0\nname: app\n"),
+			fs.WithFile("docker-compose.yml", `version: "3.6"
+services:
+  web:
+    image: nginx:${web.version}
+    labels:
+      app: ${app.name}
+      price: $$5
+    ports:
+    - ${web.port}:80
+    deploy:
+      replicas: ${web.replicas}
+  debug:
+    image: busybox
+    x-enabled: ${debug}
+`),
+			fs.WithDir("compose", fs.WithFile("1.yml", `version: "3.6"
+services:
+  web:
+    environment:
+      PASSWORD: ${db.password}
+`)),
+			fs.WithFile("settings-schema.yml", `properties:
+  db:
+    properties:
+      password:
+        type: string
+        secret: true
+`),
+			fs.WithFile("settings.yml", `web:
+  version: latest
+  port: 8080
+  replicas: 1
+db:
+  password: secret:env:DB_PASSWORD
+debug: false
+`),
+			fs.WithDir("settings", fs.WithFile("production.yml", "web:\n  replicas: 3\ndebug: true\n")),
+		),
+	)
+	defer dir.Remove()
+	app, err := types.NewAppFromDefaultFiles(dir.Join("app.dockerapp"))
+	assert.NilError(t, err)
+
+	assert.NilError(t, Compose(app, map[string]string{"web.version": "1.15"}, dir.Join("out")))
+	expected := fs.Expected(t,
+		fs.WithMode(0755),
+		fs.WithFile(ComposeFileName, `version: "3.6"
+services:
+  web:
+    deploy:
+      replicas: ${WEB_REPLICAS}
+    image: nginx:${WEB_VERSION}
+    labels:
+      app: app
+      price: $$5
+    ports:
+    - ${WEB_PORT}:80
+`, fs.WithMode(0644)),
+		fs.WithFile(OverlayFileName(1), `version: "3.6"
+services:
+  web:
+    environment:
+      PASSWORD: ${DB_PASSWORD}
+`, fs.WithMode(0644)),
+		fs.WithFile(EnvFileName, `# Default values of the application settings, overridden by the environment
+COMPOSE_FILE=docker-compose.yml:docker-compose.overlay-1.yml
+# DB_PASSWORD is the secret secret:env:DB_PASSWORD, set it in the environment
+DB_PASSWORD=
+WEB_PORT=8080
+WEB_REPLICAS=1
+WEB_VERSION=1.15
+`, fs.WithMode(0644)),
+		fs.WithFile(EnvironmentFileName("production"), `version: "3.6"
+services:
+  debug:
+    image: busybox
+  web:
+    deploy:
+      replicas: 3
+`, fs.WithMode(0644)),
+	)
+	assert.Assert(t, fs.Equal(dir.Join("out"), expected))
+}
+
+func TestVariableName(t *testing.T) {
+	assert.Check(t, is.Equal(VariableName("web.port"), "WEB_PORT"))
+	assert.Check(t, is.Equal(VariableName("myapp.nginx_version"), "MYAPP_NGINX_VERSION"))
+}
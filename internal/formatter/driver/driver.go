@@ -1,6 +1,7 @@
 package driver
 
 import (
+	"github.com/docker/app/internal/diff"
 	composetypes "github.com/docker/cli/cli/compose/types"
 )
 
@@ -9,3 +10,9 @@ type Driver interface {
 	// Format executes the formatter on the source config
 	Format(config *composetypes.Config) (string, error)
 }
+
+// DiffDriver is the interface that must be implemented by a diff formatter driver.
+type DiffDriver interface {
+	// FormatDiff executes the formatter on the difference between two configs
+	FormatDiff(d *diff.Diff) (string, error)
+}
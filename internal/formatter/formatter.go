@@ -4,14 +4,16 @@ import (
 	"sort"
 	"sync"
 
+	"github.com/docker/app/internal/diff"
 	"github.com/docker/app/internal/formatter/driver"
 	composetypes "github.com/docker/cli/cli/compose/types"
 	"github.com/pkg/errors"
 )
 
 var (
-	driversMu sync.RWMutex
-	drivers   = map[string]driver.Driver{}
+	driversMu   sync.RWMutex
+	drivers     = map[string]driver.Driver{}
+	diffDrivers = map[string]driver.DiffDriver{}
 )
 
 // Register makes a formatter available by the provided name.
@@ -56,3 +58,42 @@ func Drivers() []string {
 	sort.Strings(list)
 	return list
 }
+
+// RegisterDiff makes a diff formatter available by the provided name.
+// If RegisterDiff is called twice with the same name or if driver is nil,
+// it panics.
+func RegisterDiff(name string, driver driver.DiffDriver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if driver == nil {
+		panic("formatter: RegisterDiff driver is nil")
+	}
+	if _, dup := diffDrivers[name]; dup {
+		panic("formatter: RegisterDiff called twice for driver " + name)
+	}
+	diffDrivers[name] = driver
+}
+
+// FormatDiff uses the specified diff formatter to create a printable output.
+// If the diff formatter is not registered, this errors out.
+func FormatDiff(d *diff.Diff, formatter string) (string, error) {
+	driversMu.RLock()
+	dd, ok := diffDrivers[formatter]
+	driversMu.RUnlock()
+	if !ok {
+		return "", errors.Errorf("unknown diff formatter %q", formatter)
+	}
+	return dd.FormatDiff(d)
+}
+
+// DiffDrivers returns a sorted list of the names of the registered diff drivers.
+func DiffDrivers() []string {
+	list := []string{}
+	driversMu.RLock()
+	for name := range diffDrivers {
+		list = append(list, name)
+	}
+	driversMu.RUnlock()
+	sort.Strings(list)
+	return list
+}
@@ -3,6 +3,7 @@ package formatter
 import (
 	"testing"
 
+	"github.com/docker/app/internal/diff"
 	"github.com/docker/app/internal/formatter/driver"
 	composetypes "github.com/docker/cli/cli/compose/types"
 	"github.com/pkg/errors"
@@ -16,6 +17,10 @@ func (d *fakeDriver) Format(config *composetypes.Config) (string, error) {
 	return "fake", nil
 }
 
+func (d *fakeDriver) FormatDiff(diff *diff.Diff) (string, error) {
+	return "fake diff", nil
+}
+
 type fakeErrorDriver struct{}
 
 func (d *fakeErrorDriver) Format(config *composetypes.Config) (string, error) {
@@ -91,6 +96,30 @@ func TestFormat(t *testing.T) {
 	assert.Check(t, is.Equal(s, "fake"))
 }
 
+func TestRegisterDiffDuplicatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("The code did not panic")
+		}
+		resetDrivers()
+	}()
+	RegisterDiff("bar", &fakeDriver{})
+	RegisterDiff("bar", &fakeDriver{})
+}
+
+func TestFormatDiff(t *testing.T) {
+	RegisterDiff("fake", &fakeDriver{})
+	defer resetDrivers()
+	assert.Check(t, is.DeepEqual(DiffDrivers(), []string{"fake"}))
+	s, err := FormatDiff(&diff.Diff{}, "fake")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(s, "fake diff"))
+
+	_, err = FormatDiff(&diff.Diff{}, "toto")
+	assert.Check(t, is.ErrorContains(err, `unknown diff formatter "toto"`))
+}
+
 func resetDrivers() {
 	drivers = map[string]driver.Driver{}
+	diffDrivers = map[string]driver.DiffDriver{}
 }
@@ -3,6 +3,7 @@ package json
 import (
 	"encoding/json"
 
+	"github.com/docker/app/internal/diff"
 	"github.com/docker/app/internal/formatter"
 	composetypes "github.com/docker/cli/cli/compose/types"
 	"github.com/pkg/errors"
@@ -10,6 +11,7 @@ import (
 
 func init() {
 	formatter.Register("json", &Driver{})
+	formatter.RegisterDiff("json", &Driver{})
 }
 
 // Driver is the json implementation of formatter drivers.
@@ -23,3 +25,12 @@ func (d *Driver) Format(config *composetypes.Config) (string, error) {
 	}
 	return string(result) + "\n", nil
 }
+
+// FormatDiff creates a JSON document from the diff.
+func (d *Driver) FormatDiff(changes *diff.Diff) (string, error) {
+	result, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to produce json structure")
+	}
+	return string(result) + "\n", nil
+}
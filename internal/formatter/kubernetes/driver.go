@@ -0,0 +1,27 @@
+package kubernetes
+
+import (
+	"github.com/docker/app/internal/formatter"
+	"github.com/docker/app/internal/kube"
+	composetypes "github.com/docker/cli/cli/compose/types"
+)
+
+func init() {
+	formatter.Register("kubernetes", &Driver{})
+}
+
+// Driver is the kubernetes implementation of formatter drivers.
+type Driver struct{}
+
+// Format creates the YAML manifests of the Kubernetes objects of the config.
+func (d *Driver) Format(config *composetypes.Config) (string, error) {
+	objects, err := kube.Convert(config, "")
+	if err != nil {
+		return "", err
+	}
+	result, err := kube.Marshal(objects)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
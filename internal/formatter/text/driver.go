@@ -0,0 +1,56 @@
+package text
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/app/internal/diff"
+	"github.com/docker/app/internal/formatter"
+)
+
+func init() {
+	formatter.RegisterDiff("text", &Driver{})
+}
+
+// Driver is the human readable text implementation of diff formatter drivers.
+type Driver struct{}
+
+var statusSymbols = map[string]string{
+	diff.Added:   "+",
+	diff.Removed: "-",
+	diff.Changed: "~",
+}
+
+// FormatDiff creates a text listing of the diff, one line per change.
+func (d *Driver) FormatDiff(dd *diff.Diff) (string, error) {
+	if dd.Empty() {
+		return "No differences\n", nil
+	}
+	var out strings.Builder
+	for _, service := range dd.Services {
+		fmt.Fprintf(&out, "%s service %s\n", statusSymbols[service.Status], service.Name)
+		writeChanges(&out, service.Changes)
+	}
+	for _, network := range dd.Networks {
+		fmt.Fprintf(&out, "%s network %s\n", statusSymbols[network.Status], network.Name)
+		writeChanges(&out, network.Changes)
+	}
+	for _, volume := range dd.Volumes {
+		fmt.Fprintf(&out, "%s volume %s\n", statusSymbols[volume.Status], volume.Name)
+		writeChanges(&out, volume.Changes)
+	}
+	return out.String(), nil
+}
+
+func writeChanges(out *strings.Builder, changes []diff.Change) {
+	for _, c := range changes {
+		switch c.Status {
+		case diff.Added:
+			fmt.Fprintf(out, "    + %s: %s\n", c.Field, c.After)
+		case diff.Removed:
+			fmt.Fprintf(out, "    - %s: %s\n", c.Field, c.Before)
+		default:
+			fmt.Fprintf(out, "    ~ %s: %s -> %s\n", c.Field, c.Before, c.After)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/docker/app/internal/diff"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/golden"
+)
+
+func TestFormatDiff(t *testing.T) {
+	d := &Driver{}
+	s, err := d.FormatDiff(&diff.Diff{})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(s, "No differences\n"))
+
+	s, err = d.FormatDiff(&diff.Diff{
+		Services: []diff.ServiceDiff{
+			{Name: "db", Status: diff.Added, Changes: []diff.Change{
+				{Field: "image", Status: diff.Added, After: "postgres"},
+			}},
+			{Name: "web", Status: diff.Changed, Changes: []diff.Change{
+				{Field: "environment.DEBUG", Status: diff.Removed, Before: "true"},
+				{Field: "image", Status: diff.Changed, Before: "nginx:1.14", After: "nginx:1.15"},
+			}},
+		},
+		Networks: []diff.ResourceDiff{
+			{Name: "back", Status: diff.Added},
+			{Name: "front", Status: diff.Changed, Changes: []diff.Change{
+				{Field: "driver", Status: diff.Changed, Before: "bridge", After: "overlay"},
+			}},
+		},
+		Volumes: []diff.ResourceDiff{{Name: "data", Status: diff.Removed}},
+	})
+	assert.NilError(t, err)
+	golden.Assert(t, s, "diff.golden")
+}
@@ -0,0 +1,167 @@
+package helm
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/docker/app/internal/helm/templatetypes"
+	"github.com/docker/app/internal/yaml"
+	"github.com/pkg/errors"
+)
+
+/* Services toggled by x-enabled.
+
+Services whose x-enabled refers to a setting are deployed under a Helm
+condition on the matching value. The stack can't hold template actions outside
+of values, so those services are marshaled on their own and replaced in the
+stack by a placeholder, which is then expanded to the service wrapped in an
+{{ if }} block.
+*/
+
+// settingReference matches x-enabled values referring to a single setting
+var settingReference = regexp.MustCompile(`^\$\{?([a-zA-Z0-9_.]+)\}?$`)
+
+// conditionalBlock is a service deployed under a condition
+type conditionalBlock struct {
+	condition string
+	body      []byte
+}
+
+// serviceConditions returns the Helm conditions of the services toggled by a
+// setting, removing the services statically disabled
+func serviceConditions(config *templatetypes.Config) (map[string]string, error) {
+	conditions := map[string]string{}
+	services := []templatetypes.ServiceConfig{}
+	for _, service := range config.Services {
+		if xEnabled, ok := service.Extras["x-enabled"]; ok {
+			condition, enabled, err := enabledCondition(xEnabled)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid x-enabled for service %s", service.Name)
+			}
+			if !enabled {
+				continue
+			}
+			if condition != "" {
+				conditions[service.Name] = condition
+			}
+			delete(service.Extras, "x-enabled")
+		}
+		services = append(services, service)
+	}
+	config.Services = services
+	return conditions, nil
+}
+
+// enabledCondition returns the Helm condition of the x-enabled value if it
+// refers to a setting, or whether it enables the service otherwise
+func enabledCondition(e interface{}) (string, bool, error) {
+	switch v := e.(type) {
+	case bool:
+		return "", v, nil
+	case string:
+		v = strings.TrimSpace(v)
+		if strings.HasPrefix(v, "!") {
+			condition, enabled, err := enabledCondition(v[1:])
+			if err != nil || condition == "" {
+				return "", !enabled, err
+			}
+			return "not " + condition, true, nil
+		}
+		if m := settingReference.FindStringSubmatch(v); m != nil {
+			return ".Values." + m[1], true, nil
+		}
+		switch strings.ToLower(v) {
+		case "1", "true":
+			return "", true, nil
+		case "", "0", "false":
+			return "", false, nil
+		}
+		return "", false, errors.Errorf("%s is neither a boolean nor a single setting", v)
+	}
+	return "", false, errors.Errorf("invalid type (%T) for x-enabled", e)
+}
+
+func placeholder(service string) string {
+	return "__x_enabled_" + service + "__"
+}
+
+// makeComposeFile marshals the compose file of v1beta1 stacks, with the
+// conditional services as blocks
+func makeComposeFile(config *templatetypes.Config, conditions map[string]string, blocks map[string]conditionalBlock) ([]byte, error) {
+	composeFile, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(conditions) == 0 {
+		return composeFile, nil
+	}
+	dict := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(composeFile, dict); err != nil {
+		return nil, err
+	}
+	services := dict["services"].(map[interface{}]interface{})
+	for name, condition := range conditions {
+		body, err := yaml.Marshal(map[string]interface{}{name: services[name]})
+		if err != nil {
+			return nil, err
+		}
+		// the compose file is converted as a whole, unlike the blocks
+		converted, err := toGoTemplate(string(body))
+		if err != nil {
+			return nil, err
+		}
+		blocks[placeholder(name)] = conditionalBlock{condition: condition, body: []byte(converted)}
+		services[name] = placeholder(name)
+	}
+	return yaml.Marshal(dict)
+}
+
+// extractConditionalServices replaces the conditional services of v1beta2
+// stacks with placeholders, once converted
+func extractConditionalServices(stack map[interface{}]interface{}, conditions map[string]string, blocks map[string]conditionalBlock) error {
+	if len(conditions) == 0 {
+		return nil
+	}
+	spec, _ := stack["spec"].(map[interface{}]interface{})
+	services, _ := spec["services"].([]interface{})
+	for i, s := range services {
+		service, _ := s.(map[interface{}]interface{})
+		name, _ := service["name"].(string)
+		condition, ok := conditions[name]
+		if !ok {
+			continue
+		}
+		body, err := yaml.Marshal([]interface{}{service})
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal service %s", name)
+		}
+		blocks[placeholder(name)] = conditionalBlock{condition: condition, body: body}
+		services[i] = placeholder(name)
+	}
+	return nil
+}
+
+// expandConditionalBlocks replaces the lines holding placeholders with their
+// service, indented the same, in an {{ if }} block
+func expandConditionalBlocks(data []byte, blocks map[string]conditionalBlock) []byte {
+	var placeholders []string
+	for p := range blocks {
+		placeholders = append(placeholders, p)
+	}
+	sort.Strings(placeholders)
+	for _, p := range placeholders {
+		block := blocks[p]
+		line := regexp.MustCompile(`(?m)^( *)\S.*` + regexp.QuoteMeta(p) + `\n`)
+		data = line.ReplaceAllFunc(data, func(match []byte) []byte {
+			indent := line.FindSubmatch(match)[1]
+			res := fmt.Sprintf("{{- if %s }}\n", block.condition)
+			for _, l := range strings.SplitAfter(strings.TrimSuffix(string(block.body), "\n"), "\n") {
+				res += string(indent) + l
+			}
+			return []byte(res + "\n{{- end }}\n")
+		})
+	}
+	return data
+}
@@ -12,7 +12,9 @@ import (
 	"github.com/docker/app/internal/compose"
 	"github.com/docker/app/internal/helm/templateconversion"
 	"github.com/docker/app/internal/helm/templateloader"
+	"github.com/docker/app/internal/helm/templatetypes"
 	"github.com/docker/app/internal/helm/templatev1beta2"
+	"github.com/docker/app/internal/kube"
 	"github.com/docker/app/internal/slices"
 	"github.com/docker/app/internal/yaml"
 	"github.com/docker/app/render"
@@ -44,7 +46,7 @@ with the appropriate content (value or template)
 */
 
 // Helm renders an app as an Helm Chart
-func Helm(app *types.App, env map[string]string, shouldRender bool, stackVersion string) error {
+func Helm(app *types.App, env map[string]string, shouldRender bool, stackVersion string, renderOps ...func(*render.Options)) error {
 	targetDir := internal.AppNameFromDir(app.Name) + ".chart"
 	if err := os.MkdirAll(targetDir, 0755); err != nil {
 		return errors.Wrap(err, "failed to create Chart directory")
@@ -53,27 +55,79 @@ func Helm(app *types.App, env map[string]string, shouldRender bool, stackVersion
 	if err := makeChart(&meta, targetDir); err != nil {
 		return err
 	}
-	if shouldRender {
-		return helmRender(app, targetDir, env, stackVersion)
+	// the manifests of a previous export would deploy the app twice
+	if err := os.Remove(filepath.Join(targetDir, "templates", "manifests.yaml")); err != nil && !os.IsNotExist(err) {
+		return err
 	}
-	// FIXME(vdemeester) support multiple file for helm
-	if len(app.Composes()) > 1 {
-		return errors.New("helm rendering doesn't support multiple composefiles")
+	if shouldRender {
+		return helmRender(app, targetDir, env, stackVersion, renderOps...)
 	}
-	data := app.Composes()[0]
 	// FIXME(vdemeester): remove the need to create this slice
 	variables := []string{}
-	vars, err := compose.ExtractVariables(data, render.Pattern)
+	var configs []*templatetypes.Config
+	for _, data := range app.Composes() {
+		vars, err := compose.ExtractVariables(data, render.Pattern)
+		if err != nil {
+			return err
+		}
+		for k := range vars {
+			variables = append(variables, k)
+		}
+		parsed, err := loader.ParseYAML(data)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse template compose")
+		}
+		config, err := templateloader.LoadTemplate(parsed)
+		if err != nil {
+			return errors.Wrap(err, "failed to load template compose")
+		}
+		configs = append(configs, config)
+	}
+	rendered, err := templateloader.Merge(configs)
 	if err != nil {
+		return errors.Wrap(err, "failed to merge template composes")
+	}
+	if err := makeStack(app.Name, targetDir, rendered, stackVersion); err != nil {
+		return err
+	}
+	return makeValues(app, targetDir, env, variables)
+}
+
+// Manifests renders an app as an Helm Chart made of plain Kubernetes
+// manifests, which doesn't need the Stack CRD to be installed on the cluster.
+// The app is rendered with its settings, the chart having no values.
+func Manifests(app *types.App, env map[string]string, renderOps ...func(*render.Options)) error {
+	targetDir := internal.AppNameFromDir(app.Name) + ".chart"
+	if err := os.MkdirAll(filepath.Join(targetDir, "templates"), 0755); err != nil {
+		return errors.Wrap(err, "failed to create Chart directory")
+	}
+	meta := app.Metadata()
+	if err := makeChart(&meta, targetDir); err != nil {
 		return err
 	}
-	for k := range vars {
-		variables = append(variables, k)
+	rendered, err := render.Render(app, env, renderOps...)
+	if err != nil {
+		return err
 	}
-	if err := makeStack(app.Name, targetDir, data, stackVersion); err != nil {
+	objects, err := kube.Convert(rendered, internal.AppNameFromDir(app.Name))
+	if err != nil {
 		return err
 	}
-	return makeValues(app, targetDir, env, variables)
+	manifests, err := kube.Marshal(objects)
+	if err != nil {
+		return err
+	}
+	// the stack of a previous export would deploy the app twice
+	if err := os.Remove(filepath.Join(targetDir, "templates", "stack.yaml")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(targetDir, "templates", "manifests.yaml"), escapeTemplates(manifests), 0644)
+}
+
+// escapeTemplates escapes the template actions of the rendered content, such
+// as those of configs, from the Helm template engine
+func escapeTemplates(data []byte) []byte {
+	return []byte(strings.Replace(string(data), "{{", `{{"{{"}}`, -1))
 }
 
 // makeValues updates helm values.yaml with used variables from settings and env
@@ -109,20 +163,17 @@ func makeValues(app *types.App, targetDir string, env map[string]string, variabl
 	return ioutil.WriteFile(filepath.Join(targetDir, "values.yaml"), valuesRaw, 0644)
 }
 
-// makeStack converts data into a helm template for a stack
-func makeStack(appname string, targetDir string, data []byte, stackVersion string) error {
-	parsed, err := loader.ParseYAML(data)
-	if err != nil {
-		return errors.Wrap(err, "failed to parse template compose")
-	}
-	rendered, err := templateloader.LoadTemplate(parsed)
+// makeStack converts the template compose into a helm template for a stack
+func makeStack(appname string, targetDir string, rendered *templatetypes.Config, stackVersion string) error {
+	conditions, err := serviceConditions(rendered)
 	if err != nil {
-		return errors.Wrap(err, "failed to load template compose")
+		return err
 	}
 	if err := os.MkdirAll(filepath.Join(targetDir, "templates"), 0755); err != nil {
 		return err
 	}
 	var stack interface{}
+	blocks := map[string]conditionalBlock{}
 	switch stackVersion {
 	case V1Beta2:
 		stackSpec := templateconversion.FromComposeConfig(rendered)
@@ -132,7 +183,7 @@ func makeStack(appname string, targetDir string, data []byte, stackVersion strin
 			Spec:       stackSpec,
 		}
 	case V1Beta1:
-		composeFile, err := yaml.Marshal(rendered)
+		composeFile, err := makeComposeFile(rendered, conditions, blocks)
 		if err != nil {
 			return err
 		}
@@ -159,15 +210,20 @@ func makeStack(appname string, targetDir string, data []byte, stackVersion strin
 	if err != nil {
 		return errors.Wrap(err, "failed to convert stack templates")
 	}
+	if stackVersion == V1Beta2 {
+		if err := extractConditionalServices(preStack, conditions, blocks); err != nil {
+			return err
+		}
+	}
 	stackData, err = yaml.Marshal(preStack)
 	if err != nil {
 		return errors.Wrap(err, "failed to marshal final stack")
 	}
-	return ioutil.WriteFile(filepath.Join(targetDir, "templates", "stack.yaml"), stackData, 0644)
+	return ioutil.WriteFile(filepath.Join(targetDir, "templates", "stack.yaml"), expandConditionalBlocks(stackData, blocks), 0644)
 }
 
-func helmRender(app *types.App, targetDir string, env map[string]string, stackVersion string) error {
-	rendered, err := render.Render(app, env)
+func helmRender(app *types.App, targetDir string, env map[string]string, stackVersion string, renderOps ...func(*render.Options)) error {
+	rendered, err := render.Render(app, env, renderOps...)
 	if err != nil {
 		return err
 	}
@@ -344,43 +400,29 @@ func convertTemplatesList(list []interface{}) error {
 // convertTemplates replaces $foo with {{ .foo }}, and resolves template_ keys
 func convertTemplates(dict map[interface{}]interface{}) error {
 	for k, v := range dict {
-		kk := k.(string)
-		if strings.HasPrefix(kk, "template_") {
-			dk := strings.TrimPrefix(kk, "template_")
-			vd, ok := v.(map[interface{}]interface{})
-			if !ok {
-				return fmt.Errorf("Expected a map, got %T", v)
+		// *OrTemplate values are marshaled as their value or template
+		if kk := k.(string); strings.HasPrefix(kk, "template_") {
+			delete(dict, k)
+			dict[strings.TrimPrefix(kk, "template_")] = v
+		}
+	}
+	for k, v := range dict {
+		switch vv := v.(type) {
+		case string:
+			vv, err := toGoTemplate(vv)
+			if err != nil {
+				return err
 			}
-			template, ok := vd["valuetemplate"]
-			if ok {
-				var err error
-				template, err = toGoTemplate(template.(string))
-				if err != nil {
-					return err
-				}
-			} else {
-				template = vd["value"]
+			dict[k] = vv
+		case map[interface{}]interface{}:
+			err := convertTemplates(vv)
+			if err != nil {
+				return err
 			}
-			delete(dict, k)
-			dict[dk] = template
-		} else {
-			switch vv := v.(type) {
-			case string:
-				vv, err := toGoTemplate(vv)
-				if err != nil {
-					return err
-				}
-				dict[k] = vv
-			case map[interface{}]interface{}:
-				err := convertTemplates(vv)
-				if err != nil {
-					return err
-				}
-			case []interface{}:
-				err := convertTemplatesList(vv)
-				if err != nil {
-					return err
-				}
+		case []interface{}:
+			err := convertTemplatesList(vv)
+			if err != nil {
+				return err
 			}
 		}
 	}
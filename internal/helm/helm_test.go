@@ -0,0 +1,134 @@
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/docker/app/types"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+const (
+	baseCompose = `version: "3.6"
+services:
+  web:
+    image: nginx:${web.version}
+    ports:
+    - 80:80
+    deploy:
+      replicas: 1
+  debug:
+    image: busybox
+    x-enabled: ${debug}
+  disabled:
+    image: busybox
+    x-enabled: false
+`
+	overlayCompose = `version: "3.6"
+services:
+  web:
+    ports:
+    - ${web.port}:80
+    deploy:
+      replicas: ${web.replicas}
+      resources:
+        limits:
+          cpus: ${web.cpus}
+          memory: ${web.memory}
+  debug:
+    x-enabled: "!${production}"
+`
+)
+
+func TestHelmMultipleComposeFiles(t *testing.T) {
+	dir := fs.NewDir(t, "helm",
+		fs.WithDir("app.dockerapp",
+			fs.WithFile("metadata.yml", "version: 0.1.0\nname: app\n"),
+			fs.WithFile("docker-compose.yml", baseCompose),
+			fs.WithDir("compose", fs.WithFile("1.yml", overlayCompose)),
+			fs.WithFile("settings.yml", `web:
+  version: latest
+  port: 8080
+  replicas: 2
+  cpus: "0.5"
+  memory: 64M
+debug: false
+production: true
+`),
+		),
+	)
+	defer dir.Remove()
+	cwd, err := os.Getwd()
+	assert.NilError(t, err)
+	defer os.Chdir(cwd)
+	assert.NilError(t, os.Chdir(dir.Path()))
+	app, err := types.NewAppFromDefaultFiles("app.dockerapp")
+	assert.NilError(t, err)
+
+	assert.NilError(t, Helm(app, nil, false, V1Beta2))
+	stack, err := ioutil.ReadFile(dir.Join("app.chart", "templates", "stack.yaml"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Contains(string(stack), `spec:
+  services:
+{{- if not .Values.production }}
+  - image: busybox
+    name: debug
+{{- end }}
+  - deploy:
+      replicas: '{{.Values.web.replicas}}'
+      resources:
+        limits:
+          cpus: '{{.Values.web.cpus}}'
+          memory: '{{.Values.web.memory}}'
+    image: nginx:{{.Values.web.version}}
+    name: web
+    ports:
+    - mode: ingress
+      protocol: tcp
+      published: '{{.Values.web.port}}'
+      target: 80
+    - mode: ingress
+      protocol: tcp
+      published: 80
+      target: 80
+`))
+	assert.Check(t, !is.Contains(string(stack), "disabled")().Success())
+	values, err := ioutil.ReadFile(dir.Join("app.chart", "values.yaml"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Contains(string(values), "production: true\n"))
+	assert.Check(t, is.Contains(string(values), "replicas: 2\n"))
+
+	assert.NilError(t, Helm(app, nil, false, V1Beta1))
+	stack, err = ioutil.ReadFile(dir.Join("app.chart", "templates", "stack.yaml"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Contains(string(stack), `
+{{- if not .Values.production }}
+      debug:
+        image: busybox
+{{- end }}
+`))
+}
+
+func TestEnabledCondition(t *testing.T) {
+	for _, tc := range []struct {
+		value     interface{}
+		condition string
+		enabled   bool
+	}{
+		{value: true, enabled: true},
+		{value: "false"},
+		{value: "! 0", enabled: true},
+		{value: "${debug}", condition: ".Values.debug", enabled: true},
+		{value: "! $app.debug", condition: "not .Values.app.debug", enabled: true},
+	} {
+		condition, enabled, err := enabledCondition(tc.value)
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(condition, tc.condition))
+		assert.Check(t, is.Equal(enabled, tc.enabled))
+	}
+	_, _, err := enabledCondition("${a}-${b}")
+	assert.Check(t, is.ErrorContains(err, "neither a boolean nor a single setting"))
+}
@@ -0,0 +1,139 @@
+package helm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/docker/app/internal/kube"
+	"github.com/docker/app/internal/yaml"
+	"github.com/pkg/errors"
+)
+
+var (
+	// templateAction matches the actions of a chart template
+	templateAction = regexp.MustCompile(`{{-?\s*(.*?)\s*-?}}`)
+	// valueReference matches the actions printing a value, quoted or not
+	valueReference = regexp.MustCompile(`^(quote\s+)?\.Values\.([a-zA-Z0-9_.]+)(\s*\|\s*quote)?$`)
+	// nameInclude matches the includes of the name helpers charts define
+	nameInclude = regexp.MustCompile(`^(include|template)\s+"[^"]*name"\s+\.$`)
+)
+
+// Chart is a Helm chart imported as Kubernetes manifests, the values its
+// templates print replaced by ${...} placeholders
+type Chart struct {
+	Name        string
+	Version     string
+	AppVersion  string `yaml:"appVersion"`
+	Description string
+	Maintainers []struct {
+		Name  string
+		Email string
+	}
+	// Values is the content of values.yaml
+	Values []byte `yaml:"-"`
+	// Manifests are the objects of the templates
+	Manifests []kube.Manifest `yaml:"-"`
+	// Unsupported describes the template actions left out, along with
+	// their line
+	Unsupported []string `yaml:"-"`
+}
+
+// Import reads the chart in chartDir, its templates rendered for a release
+// named after the application. The actions printing values are replaced by
+// the matching setting, and those printing the release name, the chart name
+// or version or including a name helper by their value. The lines holding
+// any other action are left out and reported as unsupported.
+func Import(chartDir, appName string) (*Chart, error) {
+	data, err := ioutil.ReadFile(filepath.Join(chartDir, "Chart.yaml"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read Chart.yaml")
+	}
+	chart := &Chart{}
+	if err := yaml.Unmarshal(data, chart); err != nil {
+		return nil, errors.Wrap(err, "failed to parse Chart.yaml")
+	}
+	chart.Values, err = ioutil.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to read values.yaml")
+	}
+	templatesDir := filepath.Join(chartDir, "templates")
+	err = filepath.Walk(templatesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(path)
+		if info.IsDir() || strings.HasPrefix(info.Name(), "_") || (ext != ".yaml" && ext != ".yml") {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		source, err := filepath.Rel(chartDir, path)
+		if err != nil {
+			return err
+		}
+		manifests, err := kube.ReadManifests(source, chart.rewrite(source, data, appName))
+		if err != nil {
+			return err
+		}
+		chart.Manifests = append(chart.Manifests, manifests...)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the chart templates")
+	}
+	return chart, nil
+}
+
+// rewrite replaces the actions of a template by settings or their value,
+// leaving out the lines holding actions it can't replace
+func (c *Chart) rewrite(source string, data []byte, appName string) []byte {
+	var lines []string
+	for i, line := range strings.Split(string(data), "\n") {
+		supported := true
+		rewritten := templateAction.ReplaceAllStringFunc(line, func(action string) string {
+			replacement, ok := c.replacement(templateAction.FindStringSubmatch(action)[1], appName)
+			if !ok {
+				supported = false
+				c.Unsupported = append(c.Unsupported, fmt.Sprintf("%s:%d: %s is not supported, the line is left out", source, i+1, action))
+			}
+			return replacement
+		})
+		if !supported || (rewritten != line && strings.TrimSpace(rewritten) == "") {
+			continue
+		}
+		lines = append(lines, rewritten)
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+func (c *Chart) replacement(action, appName string) (string, bool) {
+	if strings.HasPrefix(action, "/*") {
+		return "", true
+	}
+	if m := valueReference.FindStringSubmatch(action); m != nil {
+		if m[1] != "" || m[3] != "" {
+			return `"${` + m[2] + `}"`, true
+		}
+		return "${" + m[2] + "}", true
+	}
+	if nameInclude.MatchString(action) {
+		return appName, true
+	}
+	switch action {
+	case ".Release.Name":
+		return appName, true
+	case ".Chart.Name":
+		return c.Name, true
+	case ".Chart.Version":
+		return c.Version, true
+	case ".Chart.AppVersion":
+		return c.AppVersion, true
+	}
+	return "", false
+}
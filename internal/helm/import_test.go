@@ -0,0 +1,74 @@
+package helm
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+const webTemplate = `{{/* the web server */}}
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ include "chart.fullname" . }}
+  labels:
+{{ include "chart.labels" . | indent 4 }}
+    version: {{ .Chart.Version }}
+spec:
+  replicas: {{ .Values.web.replicas }}
+  template:
+    spec:
+      containers:
+      - name: web
+        image: {{ .Values.web.image | quote }}
+`
+
+func TestImport(t *testing.T) {
+	dir := fs.NewDir(t, "chart",
+		fs.WithFile("Chart.yaml", `name: chart
+version: 1.0.0
+description: A chart
+maintainers:
+- name: jane
+  email: jane@example.com
+`),
+		fs.WithFile("values.yaml", "web:\n  replicas: 2\n"),
+		fs.WithDir("templates",
+			fs.WithFile("_helpers.tpl", `{{- define "chart.fullname" -}}{{ .Release.Name }}{{- end -}}`),
+			fs.WithFile("NOTES.txt", "{{ .Release.Name }} is deployed"),
+			fs.WithFile("web.yaml", webTemplate),
+		),
+	)
+	defer dir.Remove()
+
+	chart, err := Import(dir.Path(), "myapp")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(chart.Name, "chart"))
+	assert.Check(t, is.Equal(chart.Version, "1.0.0"))
+	assert.Check(t, is.Equal(chart.Maintainers[0].Email, "jane@example.com"))
+	assert.Check(t, is.Equal(string(chart.Values), "web:\n  replicas: 2\n"))
+	assert.Check(t, is.DeepEqual(chart.Unsupported, []string{
+		`templates/web.yaml:7: {{ include "chart.labels" . | indent 4 }} is not supported, the line is left out`,
+	}))
+	assert.Assert(t, is.Len(chart.Manifests, 1))
+	assert.Check(t, is.DeepEqual(chart.Manifests[0].Object, map[interface{}]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[interface{}]interface{}{
+			"name":   "myapp",
+			"labels": map[interface{}]interface{}{"version": "1.0.0"},
+		},
+		"spec": map[interface{}]interface{}{
+			"replicas": "${web.replicas}",
+			"template": map[interface{}]interface{}{
+				"spec": map[interface{}]interface{}{
+					"containers": []interface{}{
+						map[interface{}]interface{}{"name": "web", "image": "${web.image}"},
+					},
+				},
+			},
+		},
+	}))
+}
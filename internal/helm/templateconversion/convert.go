@@ -249,7 +249,7 @@ func fromComposeUpdateConfig(u *templatetypes.UpdateConfig) *v1beta2.UpdateConfi
 	}
 }
 
-func fromComposeRestartPolicy(r *types.RestartPolicy) *v1beta2.RestartPolicy {
+func fromComposeRestartPolicy(r *templatetypes.RestartPolicy) *v1beta2.RestartPolicy {
 	if r == nil {
 		return nil
 	}
@@ -220,6 +220,7 @@ func LoadService(name string, serviceDict map[string]interface{}, workingDir str
 		return nil, err
 	}
 	serviceConfig.Name = name
+	serviceConfig.Extras = getExtras(serviceDict)
 
 	if err := resolveEnvironment(serviceConfig, workingDir, lookupEnv); err != nil {
 		return nil, err
@@ -231,6 +232,19 @@ func LoadService(name string, serviceDict map[string]interface{}, workingDir str
 	return serviceConfig, nil
 }
 
+func getExtras(dict map[string]interface{}) map[string]interface{} {
+	extras := map[string]interface{}{}
+	for key, value := range dict {
+		if strings.HasPrefix(key, "x-") {
+			extras[key] = value
+		}
+	}
+	if len(extras) == 0 {
+		return nil
+	}
+	return extras
+}
+
 func updateEnvironment(environment map[string]*string, vars map[string]*string, lookupEnv template.Mapping) {
 	for k, v := range vars {
 		interpolatedV, ok := lookupEnv(k)
@@ -442,10 +456,11 @@ func toServicePortConfigs(value string) ([]interface{}, error) {
 			protocol = portsProtocol[1]
 		}
 		portPort := strings.Split(portsProtocol[0], ":")
-		tgt, _ := transformUInt64OrTemplate(portPort[0]) // can't fail on string
+		// published:target, or target only
+		tgt, _ := transformUInt64OrTemplate(portPort[len(portPort)-1]) // can't fail on string
 		pub := templatetypes.UInt64OrTemplate{}
 		if len(portPort) > 1 {
-			ipub, _ := transformUInt64OrTemplate(portPort[1])
+			ipub, _ := transformUInt64OrTemplate(portPort[0])
 			pub = ipub.(templatetypes.UInt64OrTemplate)
 		}
 		portConfigs = append(portConfigs, templatetypes.ServicePortConfig{
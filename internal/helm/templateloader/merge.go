@@ -0,0 +1,224 @@
+package templateloader
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/docker/app/internal/helm/templatetypes"
+	"github.com/docker/cli/cli/compose/types"
+	"github.com/imdario/mergo"
+	"github.com/pkg/errors"
+)
+
+type specials struct {
+	m map[reflect.Type]func(dst, src reflect.Value) error
+}
+
+func (s *specials) Transformer(t reflect.Type) func(dst, src reflect.Value) error {
+	if fn, ok := s.m[t]; ok {
+		return fn
+	}
+	return nil
+}
+
+// Merge merges the configs loaded by LoadTemplate, the same way the compose
+// loader merges compose files: the services are merged field by field, the
+// later configs overriding the earlier ones.
+func Merge(configs []*templatetypes.Config) (*templatetypes.Config, error) {
+	base := configs[0]
+	for _, override := range configs[1:] {
+		var err error
+		base.Services, err = mergeServices(base.Services, override.Services)
+		if err != nil {
+			return base, errors.Wrapf(err, "cannot merge services from %s", override.Filename)
+		}
+		if err := mergo.Map(&base.Volumes, &override.Volumes, mergo.WithOverride); err != nil {
+			return base, errors.Wrapf(err, "cannot merge volumes from %s", override.Filename)
+		}
+		if err := mergo.Map(&base.Networks, &override.Networks, mergo.WithOverride); err != nil {
+			return base, errors.Wrapf(err, "cannot merge networks from %s", override.Filename)
+		}
+		if err := mergo.Map(&base.Secrets, &override.Secrets, mergo.WithOverride); err != nil {
+			return base, errors.Wrapf(err, "cannot merge secrets from %s", override.Filename)
+		}
+		if err := mergo.Map(&base.Configs, &override.Configs, mergo.WithOverride); err != nil {
+			return base, errors.Wrapf(err, "cannot merge configs from %s", override.Filename)
+		}
+	}
+	return base, nil
+}
+
+func mergeServices(base, override []templatetypes.ServiceConfig) ([]templatetypes.ServiceConfig, error) {
+	baseServices := mapByName(base)
+	specials := &specials{
+		m: map[reflect.Type]func(dst, src reflect.Value) error{
+			reflect.TypeOf(&types.LoggingConfig{}):                   safelyMerge(mergeLoggingConfig),
+			reflect.TypeOf([]templatetypes.ServicePortConfig{}):      mergeSlice(toServicePortConfigsMap, toServicePortConfigsSlice),
+			reflect.TypeOf([]templatetypes.ServiceSecretConfig{}):    mergeSlice(toServiceSecretConfigsMap, toServiceSecretConfigsSlice),
+			reflect.TypeOf([]templatetypes.ServiceConfigObjConfig{}): mergeSlice(toServiceConfigObjConfigsMap, toServiceConfigObjConfigsSlice),
+			// a value or a template overrides both
+			reflect.TypeOf(templatetypes.BoolOrTemplate{}):      overrideIfSet,
+			reflect.TypeOf(templatetypes.UInt64OrTemplate{}):    overrideIfSet,
+			reflect.TypeOf(templatetypes.UnitBytesOrTemplate{}): overrideIfSet,
+			reflect.TypeOf(templatetypes.DurationOrTemplate{}):  overrideIfSet,
+		},
+	}
+	for name, overrideService := range mapByName(override) {
+		overrideService := overrideService
+		if baseService, ok := baseServices[name]; ok {
+			if err := mergo.Merge(&baseService, &overrideService, mergo.WithAppendSlice, mergo.WithOverride, mergo.WithTransformers(specials)); err != nil {
+				return base, errors.Wrapf(err, "cannot merge service %s", name)
+			}
+			baseServices[name] = baseService
+			continue
+		}
+		baseServices[name] = overrideService
+	}
+	services := []templatetypes.ServiceConfig{}
+	for _, baseService := range baseServices {
+		services = append(services, baseService)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services, nil
+}
+
+func mapByName(services []templatetypes.ServiceConfig) map[string]templatetypes.ServiceConfig {
+	m := map[string]templatetypes.ServiceConfig{}
+	for _, service := range services {
+		m[service.Name] = service
+	}
+	return m
+}
+
+func overrideIfSet(dst, src reflect.Value) error {
+	if !reflect.DeepEqual(src.Interface(), reflect.Zero(src.Type()).Interface()) {
+		dst.Set(src)
+	}
+	return nil
+}
+
+func toServicePortConfigsMap(s interface{}) (map[interface{}]interface{}, error) {
+	ports, ok := s.([]templatetypes.ServicePortConfig)
+	if !ok {
+		return nil, errors.Errorf("not a servicePortConfig slice: %v", s)
+	}
+	m := map[interface{}]interface{}{}
+	for _, p := range ports {
+		m[portKey(p.Published)] = p
+	}
+	return m, nil
+}
+
+// portKey returns the published port or template ports are merged by
+func portKey(published templatetypes.UInt64OrTemplate) string {
+	if published.ValueTemplate != "" || published.Value == nil {
+		return published.ValueTemplate
+	}
+	return fmt.Sprint(*published.Value)
+}
+
+func toServicePortConfigsSlice(dst reflect.Value, m map[interface{}]interface{}) error {
+	s := []templatetypes.ServicePortConfig{}
+	for _, v := range m {
+		s = append(s, v.(templatetypes.ServicePortConfig))
+	}
+	sort.Slice(s, func(i, j int) bool { return portKey(s[i].Published) < portKey(s[j].Published) })
+	dst.Set(reflect.ValueOf(s))
+	return nil
+}
+
+func toServiceSecretConfigsMap(s interface{}) (map[interface{}]interface{}, error) {
+	secrets, ok := s.([]templatetypes.ServiceSecretConfig)
+	if !ok {
+		return nil, errors.Errorf("not a serviceSecretConfig: %v", s)
+	}
+	m := map[interface{}]interface{}{}
+	for _, secret := range secrets {
+		m[secret.Source] = secret
+	}
+	return m, nil
+}
+
+func toServiceSecretConfigsSlice(dst reflect.Value, m map[interface{}]interface{}) error {
+	s := []templatetypes.ServiceSecretConfig{}
+	for _, v := range m {
+		s = append(s, v.(templatetypes.ServiceSecretConfig))
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Source < s[j].Source })
+	dst.Set(reflect.ValueOf(s))
+	return nil
+}
+
+func toServiceConfigObjConfigsMap(s interface{}) (map[interface{}]interface{}, error) {
+	configs, ok := s.([]templatetypes.ServiceConfigObjConfig)
+	if !ok {
+		return nil, errors.Errorf("not a serviceConfigObjConfig: %v", s)
+	}
+	m := map[interface{}]interface{}{}
+	for _, config := range configs {
+		m[config.Source] = config
+	}
+	return m, nil
+}
+
+func toServiceConfigObjConfigsSlice(dst reflect.Value, m map[interface{}]interface{}) error {
+	s := []templatetypes.ServiceConfigObjConfig{}
+	for _, v := range m {
+		s = append(s, v.(templatetypes.ServiceConfigObjConfig))
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Source < s[j].Source })
+	dst.Set(reflect.ValueOf(s))
+	return nil
+}
+
+type tomapFn func(s interface{}) (map[interface{}]interface{}, error)
+type writeValueFromMapFn func(reflect.Value, map[interface{}]interface{}) error
+
+func safelyMerge(mergeFn func(dst, src reflect.Value) error) func(dst, src reflect.Value) error {
+	return func(dst, src reflect.Value) error {
+		if src.IsNil() {
+			return nil
+		}
+		if dst.IsNil() {
+			dst.Set(src)
+			return nil
+		}
+		return mergeFn(dst, src)
+	}
+}
+
+func mergeSlice(tomap tomapFn, writeValue writeValueFromMapFn) func(dst, src reflect.Value) error {
+	return func(dst, src reflect.Value) error {
+		dstMap, err := tomap(dst.Interface())
+		if err != nil {
+			return err
+		}
+		srcMap, err := tomap(src.Interface())
+		if err != nil {
+			return err
+		}
+		if err := mergo.Map(&dstMap, srcMap, mergo.WithOverride); err != nil {
+			return err
+		}
+		return writeValue(dst, dstMap)
+	}
+}
+
+func mergeLoggingConfig(dst, src reflect.Value) error {
+	dstLogging := dst.Interface().(*types.LoggingConfig)
+	srcLogging := src.Interface().(*types.LoggingConfig)
+	// same driver, merging options
+	if dstLogging.Driver == srcLogging.Driver || dstLogging.Driver == "" || srcLogging.Driver == "" {
+		if dstLogging.Driver == "" {
+			dstLogging.Driver = srcLogging.Driver
+		}
+		if dstLogging.Options == nil {
+			dstLogging.Options = map[string]string{}
+		}
+		return mergo.Merge(&dstLogging.Options, srcLogging.Options, mergo.WithOverride)
+	}
+	// different driver, override with src
+	dst.Set(src)
+	return nil
+}
@@ -30,6 +30,38 @@ type DurationOrTemplate struct {
 	ValueTemplate string         `yaml:",omitempty"`
 }
 
+// MarshalYAML makes BoolOrTemplate implement yaml.Marshaller
+func (b BoolOrTemplate) MarshalYAML() (interface{}, error) {
+	if b.ValueTemplate != "" {
+		return b.ValueTemplate, nil
+	}
+	return b.Value, nil
+}
+
+// MarshalYAML makes UInt64OrTemplate implement yaml.Marshaller
+func (u UInt64OrTemplate) MarshalYAML() (interface{}, error) {
+	if u.ValueTemplate != "" || u.Value == nil {
+		return u.ValueTemplate, nil
+	}
+	return *u.Value, nil
+}
+
+// MarshalYAML makes UnitBytesOrTemplate implement yaml.Marshaller
+func (u UnitBytesOrTemplate) MarshalYAML() (interface{}, error) {
+	if u.ValueTemplate != "" {
+		return u.ValueTemplate, nil
+	}
+	return u.Value, nil
+}
+
+// MarshalYAML makes DurationOrTemplate implement yaml.Marshaller
+func (d DurationOrTemplate) MarshalYAML() (interface{}, error) {
+	if d.ValueTemplate != "" || d.Value == nil {
+		return d.ValueTemplate, nil
+	}
+	return d.Value.String(), nil
+}
+
 // Config is a full compose file configuration
 type Config struct {
 	Filename string `yaml:"-"`
@@ -91,7 +123,7 @@ type ServiceConfig struct {
 	Networks        map[string]*types.ServiceNetworkConfig `yaml:",omitempty"`
 	Pid             string                                 `yaml:",omitempty"`
 	Ports           []ServicePortConfig                    `yaml:",omitempty"`
-	Privileged      BoolOrTemplate                         `yaml:"template_privileged,omitempty"`
+	Privileged      BoolOrTemplate                         `yaml:",omitempty"`
 	ReadOnly        BoolOrTemplate                         `mapstructure:"read_only" yaml:"read_only,omitempty"`
 	Restart         string                                 `yaml:",omitempty"`
 	Secrets         []ServiceSecretConfig                  `yaml:",omitempty"`
@@ -113,15 +145,15 @@ type ServiceConfig struct {
 
 // DeployConfig the deployment configuration for a service
 type DeployConfig struct {
-	Mode           string               `yaml:",omitempty"`
-	Replicas       UInt64OrTemplate     `yaml:",omitempty"`
-	Labels         types.Labels         `yaml:",omitempty"`
-	UpdateConfig   *UpdateConfig        `mapstructure:"update_config" yaml:"update_config,omitempty"`
-	RollbackConfig *UpdateConfig        `mapstructure:"rollback_config" yaml:"rollback_config,omitempty"`
-	Resources      Resources            `yaml:",omitempty"`
-	RestartPolicy  *types.RestartPolicy `mapstructure:"restart_policy" yaml:"restart_policy,omitempty"`
-	Placement      types.Placement      `yaml:",omitempty"`
-	EndpointMode   string               `mapstructure:"endpoint_mode" yaml:"endpoint_mode,omitempty"`
+	Mode           string           `yaml:",omitempty"`
+	Replicas       UInt64OrTemplate `yaml:",omitempty"`
+	Labels         types.Labels     `yaml:",omitempty"`
+	UpdateConfig   *UpdateConfig    `mapstructure:"update_config" yaml:"update_config,omitempty"`
+	RollbackConfig *UpdateConfig    `mapstructure:"rollback_config" yaml:"rollback_config,omitempty"`
+	Resources      Resources        `yaml:",omitempty"`
+	RestartPolicy  *RestartPolicy   `mapstructure:"restart_policy" yaml:"restart_policy,omitempty"`
+	Placement      types.Placement  `yaml:",omitempty"`
+	EndpointMode   string           `mapstructure:"endpoint_mode" yaml:"endpoint_mode,omitempty"`
 }
 
 // HealthCheckConfig the healthcheck configuration for a service
@@ -130,18 +162,26 @@ type HealthCheckConfig struct {
 	Timeout     DurationOrTemplate    `yaml:",omitempty"`
 	Interval    DurationOrTemplate    `yaml:",omitempty"`
 	Retries     UInt64OrTemplate      `yaml:",omitempty"`
-	StartPeriod *time.Duration        `mapstructure:"start_period" yaml:"start_period,omitempty"`
-	Disable     bool                  `yaml:",omitempty"`
+	StartPeriod DurationOrTemplate    `mapstructure:"start_period" yaml:"start_period,omitempty"`
+	Disable     BoolOrTemplate        `yaml:",omitempty"`
 }
 
 // UpdateConfig the service update configuration
 type UpdateConfig struct {
-	Parallelism     UInt64OrTemplate `yaml:",omitempty"`
-	Delay           time.Duration    `yaml:",omitempty"`
-	FailureAction   string           `mapstructure:"failure_action" yaml:"failure_action,omitempty"`
-	Monitor         time.Duration    `yaml:",omitempty"`
-	MaxFailureRatio float32          `mapstructure:"max_failure_ratio" yaml:"max_failure_ratio,omitempty"`
-	Order           string           `yaml:",omitempty"`
+	Parallelism     UInt64OrTemplate   `yaml:",omitempty"`
+	Delay           DurationOrTemplate `yaml:",omitempty"`
+	FailureAction   string             `mapstructure:"failure_action" yaml:"failure_action,omitempty"`
+	Monitor         DurationOrTemplate `yaml:",omitempty"`
+	MaxFailureRatio float32            `mapstructure:"max_failure_ratio" yaml:"max_failure_ratio,omitempty"`
+	Order           string             `yaml:",omitempty"`
+}
+
+// RestartPolicy the service restart policy
+type RestartPolicy struct {
+	Condition   string             `yaml:",omitempty"`
+	Delay       DurationOrTemplate `yaml:",omitempty"`
+	MaxAttempts UInt64OrTemplate   `mapstructure:"max_attempts" yaml:"max_attempts,omitempty"`
+	Window      DurationOrTemplate `yaml:",omitempty"`
 }
 
 // Resources the resource limits and reservations
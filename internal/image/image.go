@@ -1,56 +1,403 @@
 package image
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/docker/app/internal/slices"
+	"github.com/docker/app/pkg/resto"
 	composetypes "github.com/docker/cli/cli/compose/types"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/jsonmessage"
+	units "github.com/docker/go-units"
+	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 )
 
-// Add add service images to the app package
-func Add(appname string, services []string, config *composetypes.Config) error {
-	if err := os.Mkdir(filepath.Join(appname, "images"), 0755); err != nil {
-		return errors.Wrap(err, "cannot create 'images' folder")
-	}
+// LayoutDir is the directory of the app package holding the OCI image layout
+// of its images
+const LayoutDir = "images"
+
+// annotationService is the annotation of the index entries naming the service
+// using the image
+const annotationService = "com.docker.app.service"
+
+// Client is the part of the Engine API client used to save and load images
+type Client interface {
+	ImageInspectWithRaw(ctx context.Context, image string) (types.ImageInspect, []byte, error)
+	ImageList(ctx context.Context, options types.ImageListOptions) ([]types.ImageSummary, error)
+	ImageLoad(ctx context.Context, input io.Reader, quiet bool) (types.ImageLoadResponse, error)
+	ImageSave(ctx context.Context, images []string) (io.ReadCloser, error)
+}
+
+// saveManifest is an entry of the manifest.json file of the tarballs of
+// ImageSave and ImageLoad
+type saveManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// Add saves the images of the given services (default: all) from the engine
+// to the OCI image layout of the app directory. Layers are compressed and
+// stored once, whatever the number of images sharing them, and images already
+// stored are not saved again.
+func Add(ctx context.Context, client Client, appDir string, services []string, config *composetypes.Config, out io.Writer) error {
+	byImage := map[string][]string{}
+	var images []string
 	for _, s := range config.Services {
 		if len(services) != 0 && !slices.ContainsString(services, s.Name) {
 			continue
 		}
-		cmd := exec.Command("docker", "save", "-o", filepath.Join(appname, "images", s.Name), s.Image)
-		output, err := cmd.CombinedOutput()
+		if s.Image == "" {
+			return errors.Errorf("service %s has no image", s.Name)
+		}
+		if _, ok := byImage[s.Image]; !ok {
+			images = append(images, s.Image)
+		}
+		byImage[s.Image] = append(byImage[s.Image], s.Name)
+	}
+	for _, s := range services {
+		if !hasService(config, s) {
+			return errors.Errorf("service %s not found", s)
+		}
+	}
+	l, err := openLayout(filepath.Join(appDir, LayoutDir))
+	if err != nil {
+		return err
+	}
+	manifests := map[string]ociv1.Descriptor{}
+	ids := map[string]digest.Digest{}
+	var missing []string
+	for _, image := range images {
+		inspect, _, err := client.ImageInspectWithRaw(ctx, image)
+		if err != nil {
+			return errors.Wrapf(err, "failed to inspect image %s", image)
+		}
+		ids[image] = digest.Digest(inspect.ID)
+		if desc, ok := l.manifests[ids[image]]; ok {
+			fmt.Fprintf(out, "%s: already added\n", image)
+			manifests[image] = desc
+			continue
+		}
+		missing = append(missing, image)
+	}
+	if len(missing) > 0 {
+		saved, err := l.save(ctx, client, missing, out)
 		if err != nil {
-			fmt.Println(output)
 			return err
 		}
+		for _, image := range missing {
+			desc, ok := saved[ids[image]]
+			if !ok {
+				return errors.Errorf("image %s was not saved by the engine", image)
+			}
+			manifests[image] = desc
+		}
 	}
-	return nil
+	for _, image := range images {
+		for _, service := range byImage[image] {
+			l.setService(service, image, manifests[image])
+		}
+	}
+	return l.commit()
 }
 
-// Load loads app packed images to the docker daemon
-func Load(appname string, services []string) error {
-	imageDir, err := os.Open(filepath.Join(appname, "images"))
-	if err != nil {
-		return fmt.Errorf("no images found in app")
+// Load loads the images of the given services (default: all) stored in the app
+// directory to the engine. Only the layers the engine is missing are sent.
+func Load(ctx context.Context, client Client, appDir string, services []string, out io.Writer) error {
+	dir := filepath.Join(appDir, LayoutDir)
+	if _, err := os.Stat(filepath.Join(dir, ociv1.ImageLayoutFile)); err != nil {
+		return errors.New("no images found in app")
 	}
-	defer imageDir.Close()
-	images, err := imageDir.Readdirnames(0)
+	l, err := openLayout(dir)
 	if err != nil {
 		return err
 	}
-	for _, i := range images {
-		if len(services) != 0 && !slices.ContainsString(services, i) {
+	var images []*storedImage
+	byManifest := map[digest.Digest]*storedImage{}
+	found := map[string]bool{}
+	for _, desc := range l.index.Manifests {
+		service := desc.Annotations[annotationService]
+		if len(services) != 0 && !slices.ContainsString(services, service) {
 			continue
 		}
-		cmd := exec.Command("docker", "load", "-i", filepath.Join(appname, "images", i))
-		output, err := cmd.CombinedOutput()
+		found[service] = true
+		image, ok := byManifest[desc.Digest]
+		if !ok {
+			if image, err = l.readImage(desc.Digest); err != nil {
+				return err
+			}
+			byManifest[desc.Digest] = image
+			images = append(images, image)
+		}
+		if tag, ok := repoTag(desc.Annotations[ociv1.AnnotationRefName]); ok && !slices.ContainsString(image.refs, tag) {
+			image.refs = append(image.refs, tag)
+		}
+	}
+	for _, s := range services {
+		if !found[s] {
+			return errors.Errorf("no image found for service %s", s)
+		}
+	}
+	if len(images) == 0 {
+		return errors.New("no images found in app")
+	}
+	loaded, err := loadedChains(ctx, client)
+	if err != nil {
+		return err
+	}
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(l.writeLoadTarball(w, images, loaded, out))
+	}()
+	resp, err := client.ImageLoad(ctx, r, false)
+	// unblock the writer if the engine didn't read it all
+	r.Close()
+	if err != nil {
+		return errors.Wrap(err, "failed to load images")
+	}
+	defer resp.Body.Close()
+	if resp.JSON {
+		return jsonmessage.DisplayJSONMessagesStream(resp.Body, out, 0, false, nil)
+	}
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
+
+// repoTag returns the tag the image is loaded with, none for images pinned
+// to a digest
+func repoTag(image string) (string, bool) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", false
+	}
+	if _, ok := named.(reference.Digested); ok {
+		return "", false
+	}
+	return reference.FamiliarString(reference.TagNameOnly(named)), true
+}
+
+func hasService(config *composetypes.Config, name string) bool {
+	for _, s := range config.Services {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// layout is the OCI image layout of the images of an app
+type layout struct {
+	dir   string
+	index *ociv1.Index
+	// manifests are the manifests of the layout, by image ID
+	manifests map[digest.Digest]ociv1.Descriptor
+	// layers are the compressed layers of the layout, by diff ID
+	layers map[digest.Digest]ociv1.Descriptor
+}
+
+// storedImage is an image of the layout
+type storedImage struct {
+	refs     []string
+	config   ociv1.Descriptor
+	diffIDs  []digest.Digest
+	manifest ociv1.Manifest
+}
+
+func openLayout(dir string) (*layout, error) {
+	if err := resto.InitLayout(dir); err != nil {
+		return nil, err
+	}
+	index, err := resto.ReadLayoutIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	l := &layout{
+		dir:       dir,
+		index:     index,
+		manifests: map[digest.Digest]ociv1.Descriptor{},
+		layers:    map[digest.Digest]ociv1.Descriptor{},
+	}
+	for _, desc := range index.Manifests {
+		image, err := l.readImage(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		l.manifests[image.config.Digest] = ociv1.Descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Size: desc.Size}
+		for i, diffID := range image.diffIDs {
+			l.layers[diffID] = image.manifest.Layers[i]
+		}
+	}
+	return l, nil
+}
+
+func (l *layout) readImage(dgst digest.Digest) (*storedImage, error) {
+	raw, err := resto.ReadLayoutBlob(l.dir, dgst)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read manifest %s", dgst)
+	}
+	image := &storedImage{}
+	if err := json.Unmarshal(raw, &image.manifest); err != nil {
+		return nil, errors.Wrapf(err, "invalid manifest %s", dgst)
+	}
+	image.config = image.manifest.Config
+	raw, err = resto.ReadLayoutBlob(l.dir, image.config.Digest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read image config %s", image.config.Digest)
+	}
+	if image.diffIDs, err = diffIDs(raw); err != nil {
+		return nil, err
+	}
+	if len(image.diffIDs) != len(image.manifest.Layers) {
+		return nil, errors.Errorf("invalid manifest %s: %d layers for %d diff IDs", dgst, len(image.manifest.Layers), len(image.diffIDs))
+	}
+	return image, nil
+}
+
+func diffIDs(config []byte) ([]digest.Digest, error) {
+	var img ociv1.Image
+	if err := json.Unmarshal(config, &img); err != nil {
+		return nil, errors.Wrap(err, "invalid image config")
+	}
+	return img.RootFS.DiffIDs, nil
+}
+
+// save saves the images from the engine to the layout, and returns their
+// manifests by image ID
+func (l *layout) save(ctx context.Context, client Client, images []string, out io.Writer) (map[digest.Digest]ociv1.Descriptor, error) {
+	fmt.Fprintf(out, "Saving %s\n", strings.Join(images, ", "))
+	rc, err := client.ImageSave(ctx, images)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to save images")
+	}
+	defer rc.Close()
+	tmp, err := ioutil.TempDir("", "docker-app-images")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(tmp)
+	if err := archive.Untar(rc, tmp, &archive.TarOptions{NoLchown: true}); err != nil {
+		return nil, errors.Wrap(err, "failed to save images")
+	}
+	raw, err := ioutil.ReadFile(filepath.Join(tmp, "manifest.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to save images")
+	}
+	var saved []saveManifest
+	if err := json.Unmarshal(raw, &saved); err != nil {
+		return nil, errors.Wrap(err, "invalid manifest of saved images")
+	}
+	manifests := map[digest.Digest]ociv1.Descriptor{}
+	for _, m := range saved {
+		desc, err := l.addImage(tmp, m, out)
 		if err != nil {
-			fmt.Println(output)
-			return err
+			return nil, err
+		}
+		manifests[desc.config] = desc.manifest
+	}
+	return manifests, nil
+}
+
+type addedImage struct {
+	config   digest.Digest
+	manifest ociv1.Descriptor
+}
+
+// addImage adds an image saved to the directory to the layout, compressing
+// the layers it is missing
+func (l *layout) addImage(dir string, m saveManifest, out io.Writer) (addedImage, error) {
+	config, err := ioutil.ReadFile(filepath.Join(dir, filepath.Clean("/"+m.Config)))
+	if err != nil {
+		return addedImage{}, errors.Wrap(err, "failed to read saved image config")
+	}
+	configDigest := digest.FromBytes(config)
+	ids, err := diffIDs(config)
+	if err != nil {
+		return addedImage{}, err
+	}
+	if len(ids) != len(m.Layers) {
+		return addedImage{}, errors.Errorf("invalid saved image %s: %d layers for %d diff IDs", configDigest, len(m.Layers), len(ids))
+	}
+	manifest := ociv1.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		Config: ociv1.Descriptor{
+			MediaType: ociv1.MediaTypeImageConfig,
+			Digest:    configDigest,
+			Size:      int64(len(config)),
+		},
+		Layers: []ociv1.Descriptor{},
+	}
+	for i, diffID := range ids {
+		desc, ok := l.layers[diffID]
+		if ok {
+			fmt.Fprintf(out, "%s: already exists\n", shortID(diffID))
+		} else {
+			if desc, err = l.addLayer(filepath.Join(dir, filepath.Clean("/"+m.Layers[i])), diffID); err != nil {
+				return addedImage{}, err
+			}
+			fmt.Fprintf(out, "%s: added (%s)\n", shortID(diffID), units.HumanSize(float64(desc.Size)))
+			l.layers[diffID] = desc
+		}
+		manifest.Layers = append(manifest.Layers, desc)
+	}
+	if err := resto.WriteLayoutBlob(l.dir, configDigest, config); err != nil {
+		return addedImage{}, err
+	}
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		return addedImage{}, err
+	}
+	desc := ociv1.Descriptor{
+		MediaType: ociv1.MediaTypeImageManifest,
+		Digest:    digest.FromBytes(raw),
+		Size:      int64(len(raw)),
+	}
+	if err := resto.WriteLayoutBlob(l.dir, desc.Digest, raw); err != nil {
+		return addedImage{}, err
+	}
+	l.manifests[configDigest] = desc
+	return addedImage{config: configDigest, manifest: desc}, nil
+}
+
+// setService makes the service use the image
+func (l *layout) setService(service, image string, desc ociv1.Descriptor) {
+	manifests := []ociv1.Descriptor{}
+	for _, m := range l.index.Manifests {
+		if m.Annotations[annotationService] != service {
+			manifests = append(manifests, m)
 		}
 	}
-	return nil
+	desc.Annotations = map[string]string{
+		ociv1.AnnotationRefName: image,
+		annotationService:       service,
+	}
+	l.index.Manifests = append(manifests, desc)
+}
+
+// commit writes the index of the layout and removes the blobs it no longer
+// refers to
+func (l *layout) commit() error {
+	if err := resto.WriteLayoutIndex(l.dir, l.index); err != nil {
+		return err
+	}
+	return resto.CollectLayoutGarbage(l.dir, l.index)
+}
+
+func shortID(dgst digest.Digest) string {
+	hex := dgst.Hex()
+	if len(hex) > 12 {
+		hex = hex[:12]
+	}
+	return hex
 }
@@ -0,0 +1,216 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	composetypes "github.com/docker/cli/cli/compose/types"
+	"github.com/docker/docker/api/types"
+	digest "github.com/opencontainers/go-digest"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+type fakeImage struct {
+	id      digest.Digest
+	config  []byte
+	diffIDs []digest.Digest
+	layers  map[digest.Digest][]byte
+}
+
+func newFakeImage(t *testing.T, layers ...string) fakeImage {
+	img := fakeImage{layers: map[digest.Digest][]byte{}}
+	for _, l := range layers {
+		dgst := digest.FromString(l)
+		img.diffIDs = append(img.diffIDs, dgst)
+		img.layers[dgst] = []byte(l)
+	}
+	config, err := json.Marshal(ociv1.Image{RootFS: ociv1.RootFS{Type: "layers", DiffIDs: img.diffIDs}})
+	assert.NilError(t, err)
+	img.config = config
+	img.id = digest.FromBytes(config)
+	return img
+}
+
+// fakeEngine implements the image operations of the Engine API
+type fakeEngine struct {
+	images map[string]fakeImage
+	saves  int
+	// loaded are the files of the last loaded tarball
+	loaded map[string][]byte
+}
+
+func (e *fakeEngine) find(ref string) (fakeImage, bool) {
+	if img, ok := e.images[ref]; ok {
+		return img, true
+	}
+	for _, img := range e.images {
+		if img.id.String() == ref {
+			return img, true
+		}
+	}
+	return fakeImage{}, false
+}
+
+func (e *fakeEngine) ImageInspectWithRaw(ctx context.Context, ref string) (types.ImageInspect, []byte, error) {
+	img, ok := e.find(ref)
+	if !ok {
+		return types.ImageInspect{}, nil, errors.New("no such image: " + ref)
+	}
+	var layers []string
+	for _, d := range img.diffIDs {
+		layers = append(layers, d.String())
+	}
+	return types.ImageInspect{ID: img.id.String(), RootFS: types.RootFS{Type: "layers", Layers: layers}}, nil, nil
+}
+
+func (e *fakeEngine) ImageList(ctx context.Context, options types.ImageListOptions) ([]types.ImageSummary, error) {
+	var summaries []types.ImageSummary
+	for _, img := range e.images {
+		summaries = append(summaries, types.ImageSummary{ID: img.id.String()})
+	}
+	return summaries, nil
+}
+
+func (e *fakeEngine) ImageSave(ctx context.Context, refs []string) (io.ReadCloser, error) {
+	e.saves++
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	add := func(name string, data []byte) {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg})
+		tw.Write(data)
+	}
+	var manifests []saveManifest
+	for _, ref := range refs {
+		img, ok := e.find(ref)
+		if !ok {
+			return nil, errors.New("no such image: " + ref)
+		}
+		m := saveManifest{Config: img.id.Hex() + ".json", RepoTags: []string{ref}}
+		add(m.Config, img.config)
+		for _, d := range img.diffIDs {
+			m.Layers = append(m.Layers, d.Hex()+"/layer.tar")
+			add(d.Hex()+"/layer.tar", img.layers[d])
+		}
+		manifests = append(manifests, m)
+	}
+	raw, _ := json.Marshal(manifests)
+	add("manifest.json", raw)
+	tw.Close()
+	return ioutil.NopCloser(buf), nil
+}
+
+func (e *fakeEngine) ImageLoad(ctx context.Context, input io.Reader, quiet bool) (types.ImageLoadResponse, error) {
+	e.loaded = map[string][]byte{}
+	tr := tar.NewReader(input)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return types.ImageLoadResponse{}, err
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return types.ImageLoadResponse{}, err
+		}
+		e.loaded[h.Name] = data
+	}
+	return types.ImageLoadResponse{Body: ioutil.NopCloser(strings.NewReader("Loaded\n"))}, nil
+}
+
+func gunzip(t *testing.T, data []byte) string {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	assert.NilError(t, err)
+	res, err := ioutil.ReadAll(r)
+	assert.NilError(t, err)
+	return string(res)
+}
+
+func countBlobs(t *testing.T, dir *fs.Dir) int {
+	blobs, err := ioutil.ReadDir(dir.Join(LayoutDir, "blobs", "sha256"))
+	assert.NilError(t, err)
+	return len(blobs)
+}
+
+func TestAddLoad(t *testing.T) {
+	engine := &fakeEngine{images: map[string]fakeImage{
+		"nginx": newFakeImage(t, "base", "nginx"),
+		"redis": newFakeImage(t, "base", "redis"),
+	}}
+	config := &composetypes.Config{Services: []composetypes.ServiceConfig{
+		{Name: "web", Image: "nginx"},
+		{Name: "db", Image: "redis"},
+		{Name: "cache", Image: "redis"},
+	}}
+	app := fs.NewDir(t, "app.dockerapp")
+	defer app.Remove()
+	out := &bytes.Buffer{}
+
+	err := Load(context.Background(), engine, app.Path(), nil, out)
+	assert.ErrorContains(t, err, "no images found in app")
+	err = Add(context.Background(), engine, app.Path(), []string{"unknown"}, config, out)
+	assert.ErrorContains(t, err, "service unknown not found")
+
+	assert.NilError(t, Add(context.Background(), engine, app.Path(), nil, config, out))
+	assert.Check(t, is.Equal(engine.saves, 1))
+	assert.Check(t, is.Contains(out.String(), "Saving nginx, redis\n"))
+	assert.Check(t, is.Contains(out.String(), ": already exists\n"))
+	// 3 layers, the base one being shared, 2 configs and 2 manifests
+	assert.Check(t, is.Equal(countBlobs(t, app), 7))
+
+	// stored images are not saved again
+	out.Reset()
+	assert.NilError(t, Add(context.Background(), engine, app.Path(), nil, config, out))
+	assert.Check(t, is.Equal(engine.saves, 1))
+	assert.Check(t, is.Equal(out.String(), "nginx: already added\nredis: already added\n"))
+
+	// the layers of replaced images are removed
+	engine.images["nginx"] = newFakeImage(t, "base", "nginx 2")
+	assert.NilError(t, Add(context.Background(), engine, app.Path(), []string{"web"}, config, out))
+	assert.Check(t, is.Equal(engine.saves, 2))
+	assert.Check(t, is.Equal(countBlobs(t, app), 7))
+
+	// only the layers the engine is missing are loaded
+	target := &fakeEngine{images: map[string]fakeImage{"debian": newFakeImage(t, "base")}}
+	out.Reset()
+	assert.NilError(t, Load(context.Background(), target, app.Path(), nil, out))
+	assert.Check(t, is.Contains(out.String(), "Loaded\n"))
+	base, nginx, redis := digest.FromString("base"), digest.FromString("nginx 2"), digest.FromString("redis")
+	_, ok := target.loaded[base.Hex()+"/layer.tar"]
+	assert.Check(t, !ok)
+	assert.Check(t, is.Equal(gunzip(t, target.loaded[nginx.Hex()+"/layer.tar"]), "nginx 2"))
+	assert.Check(t, is.Equal(gunzip(t, target.loaded[redis.Hex()+"/layer.tar"]), "redis"))
+	var manifests []saveManifest
+	assert.NilError(t, json.Unmarshal(target.loaded["manifest.json"], &manifests))
+	assert.Check(t, is.DeepEqual(manifests, []saveManifest{
+		{
+			Config:   engine.images["redis"].id.Hex() + ".json",
+			RepoTags: []string{"redis:latest"},
+			Layers:   []string{base.Hex() + "/layer.tar", redis.Hex() + "/layer.tar"},
+		},
+		{
+			Config:   engine.images["nginx"].id.Hex() + ".json",
+			RepoTags: []string{"nginx:latest"},
+			Layers:   []string{base.Hex() + "/layer.tar", nginx.Hex() + "/layer.tar"},
+		},
+	}))
+	assert.Check(t, is.DeepEqual(target.loaded[engine.images["nginx"].id.Hex()+".json"], engine.images["nginx"].config))
+
+	assert.NilError(t, Load(context.Background(), target, app.Path(), []string{"db"}, out))
+	assert.NilError(t, json.Unmarshal(target.loaded["manifest.json"], &manifests))
+	assert.Check(t, is.Len(manifests, 1))
+	err = Load(context.Background(), target, app.Path(), []string{"unknown"}, out)
+	assert.ErrorContains(t, err, "no image found for service unknown")
+}
@@ -0,0 +1,170 @@
+package image
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/app/pkg/resto"
+	"github.com/docker/docker/api/types"
+	units "github.com/docker/go-units"
+	digest "github.com/opencontainers/go-digest"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// addLayer compresses the saved layer to a blob of the layout, checking it
+// matches the diff ID
+func (l *layout) addLayer(path string, diffID digest.Digest) (ociv1.Descriptor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ociv1.Descriptor{}, errors.Wrap(err, "failed to read saved layer")
+	}
+	defer f.Close()
+	tmp, err := ioutil.TempFile(l.dir, "layer")
+	if err != nil {
+		return ociv1.Descriptor{}, errors.Wrap(err, "failed to add layer")
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	compressed := digest.SHA256.Digester()
+	uncompressed := diffID.Algorithm().Digester()
+	gz := gzip.NewWriter(io.MultiWriter(tmp, compressed.Hash()))
+	if _, err := io.Copy(gz, io.TeeReader(f, uncompressed.Hash())); err != nil {
+		return ociv1.Descriptor{}, errors.Wrap(err, "failed to add layer")
+	}
+	if err := gz.Close(); err != nil {
+		return ociv1.Descriptor{}, errors.Wrap(err, "failed to add layer")
+	}
+	if uncompressed.Digest() != diffID {
+		return ociv1.Descriptor{}, errors.Errorf("saved layer %s does not match its diff ID %s", path, diffID)
+	}
+	s, err := tmp.Stat()
+	if err != nil {
+		return ociv1.Descriptor{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return ociv1.Descriptor{}, err
+	}
+	desc := ociv1.Descriptor{
+		MediaType: ociv1.MediaTypeImageLayerGzip,
+		Digest:    compressed.Digest(),
+		Size:      s.Size(),
+	}
+	p, err := resto.LayoutBlobPath(l.dir, desc.Digest)
+	if err != nil {
+		return ociv1.Descriptor{}, err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return ociv1.Descriptor{}, err
+	}
+	return desc, errors.Wrap(os.Rename(tmp.Name(), p), "failed to add layer")
+}
+
+// chainIDs returns the chain IDs of the layers, which identify a layer along
+// with its parents
+func chainIDs(diffIDs []digest.Digest) []digest.Digest {
+	ids := make([]digest.Digest, len(diffIDs))
+	for i, diffID := range diffIDs {
+		if i == 0 {
+			ids[i] = diffID
+		} else {
+			ids[i] = digest.FromString(ids[i-1].String() + " " + diffID.String())
+		}
+	}
+	return ids
+}
+
+// loadedChains returns the chain IDs of the layers of the images of the engine
+func loadedChains(ctx context.Context, client Client) (map[digest.Digest]bool, error) {
+	images, err := client.ImageList(ctx, types.ImageListOptions{All: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list images")
+	}
+	loaded := map[digest.Digest]bool{}
+	for _, image := range images {
+		inspect, _, err := client.ImageInspectWithRaw(ctx, image.ID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to inspect image %s", image.ID)
+		}
+		if inspect.RootFS.Type != "layers" {
+			continue
+		}
+		var diffIDs []digest.Digest
+		for _, l := range inspect.RootFS.Layers {
+			diffIDs = append(diffIDs, digest.Digest(l))
+		}
+		for _, id := range chainIDs(diffIDs) {
+			loaded[id] = true
+		}
+	}
+	return loaded, nil
+}
+
+// writeLoadTarball writes the tarball loading the images, leaving out the
+// layers already loaded. The engine skips the layers it has without reading
+// them, and decompresses the others.
+func (l *layout) writeLoadTarball(w io.Writer, images []*storedImage, loaded map[digest.Digest]bool, out io.Writer) error {
+	tw := tar.NewWriter(w)
+	var manifests []saveManifest
+	written := map[digest.Digest]bool{}
+	for _, image := range images {
+		m := saveManifest{
+			Config:   image.config.Digest.Hex() + ".json",
+			RepoTags: image.refs,
+		}
+		if err := l.addBlobToTarball(tw, m.Config, image.config); err != nil {
+			return err
+		}
+		for i, chainID := range chainIDs(image.diffIDs) {
+			path := filepath.ToSlash(filepath.Join(image.diffIDs[i].Hex(), "layer.tar"))
+			m.Layers = append(m.Layers, path)
+			switch {
+			case loaded[chainID]:
+				fmt.Fprintf(out, "%s: already loaded\n", shortID(image.diffIDs[i]))
+			case written[image.diffIDs[i]]:
+			default:
+				fmt.Fprintf(out, "%s: loading (%s)\n", shortID(image.diffIDs[i]), units.HumanSize(float64(image.manifest.Layers[i].Size)))
+				if err := l.addBlobToTarball(tw, path, image.manifest.Layers[i]); err != nil {
+					return err
+				}
+				written[image.diffIDs[i]] = true
+			}
+		}
+		manifests = append(manifests, m)
+	}
+	raw, err := json.Marshal(manifests)
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "manifest.json", Mode: 0644, Size: int64(len(raw)), Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(raw); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func (l *layout) addBlobToTarball(tw *tar.Writer, name string, desc ociv1.Descriptor) error {
+	p, err := resto.LayoutBlobPath(l.dir, desc.Digest)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read blob %s", desc.Digest)
+	}
+	defer f.Close()
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: desc.Size, Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
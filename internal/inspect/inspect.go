@@ -7,6 +7,8 @@ import (
 	"strings"
 	"text/tabwriter"
 
+	"github.com/docker/app/internal/secrets"
+	"github.com/docker/app/internal/slices"
 	"github.com/docker/app/render"
 	"github.com/docker/app/types"
 	"github.com/docker/app/types/settings"
@@ -16,7 +18,7 @@ import (
 // Inspect dumps the metadata of an app
 func Inspect(out io.Writer, app *types.App, argSettings map[string]string) error {
 	// Render the compose file
-	config, err := render.Render(app, argSettings)
+	config, err := render.Render(app, argSettings, render.WithRedactedSecrets())
 	if err != nil {
 		return err
 	}
@@ -65,6 +67,30 @@ func Inspect(out io.Writer, app *types.App, argSettings map[string]string) error
 		}
 	}, "Setting", "Value")
 
+	// Add Environment section
+	environments := app.NamedSettingsNames()
+	overrides, err := extractEnvironmentOverrides(app, environments)
+	if err != nil {
+		return err
+	}
+	printSection(out, len(environments), func(w io.Writer) {
+		for _, env := range environments {
+			name := env
+			if env == app.Environment() {
+				name += " (selected)"
+			}
+			if len(overrides[env]) == 0 {
+				fmt.Fprintf(w, "%s\t\n", name)
+			}
+			for i, o := range overrides[env] {
+				if i > 0 {
+					name = ""
+				}
+				fmt.Fprintf(w, "%s\t%s: %s -> %s\n", name, o.key, o.old, o.new)
+			}
+		}
+	}, "Environment", "Overrides")
+
 	return nil
 }
 
@@ -136,5 +162,61 @@ func mergeAndFlattenSettings(app *types.App, argSettings map[string]string) (map
 	if err != nil {
 		return nil, err
 	}
-	return s.Flatten(), nil
+	flatten := s.Flatten()
+	secrets.RedactFlatten(flatten, s.SecretKeys(app.SettingsSchema()))
+	return flatten, nil
+}
+
+// override is a settings value an environment changes
+type override struct {
+	key, old, new string
+}
+
+// unset is the value shown for the settings an environment adds
+const unset = "<unset>"
+
+// extractEnvironmentOverrides returns, for each environment, the settings it
+// changes from the app default settings, sorted by key, the secrets redacted
+func extractEnvironmentOverrides(app *types.App, environments []string) (map[string][]override, error) {
+	defaults := settings.Settings{}
+	if raw := app.SettingsRaw(); len(raw) > 0 {
+		var err error
+		if defaults, err = settings.Load(raw[0]); err != nil {
+			return nil, err
+		}
+	}
+	flatDefaults := defaults.Flatten()
+	overrides := make(map[string][]override, len(environments))
+	for _, env := range environments {
+		s, err := settings.Load(app.NamedSettingsRaw()[env])
+		if err != nil {
+			return nil, err
+		}
+		merged, err := settings.Merge(defaults, s)
+		if err != nil {
+			return nil, err
+		}
+		secretKeys := merged.SecretKeys(app.SettingsSchema())
+		flat := s.Flatten()
+		var keys []string
+		for k := range flat {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			old, ok := flatDefaults[k]
+			if !ok {
+				old = unset
+			}
+			o := override{key: k, old: old, new: flat[k]}
+			if slices.ContainsString(secretKeys, k) {
+				if ok {
+					o.old = secrets.Redacted
+				}
+				o.new = secrets.Redacted
+			}
+			overrides[env] = append(overrides[env], o)
+		}
+	}
+	return overrides, nil
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/docker/app/internal"
+	"github.com/docker/app/loader"
 	"github.com/docker/app/types"
 	"gotest.tools/assert"
 	"gotest.tools/fs"
@@ -92,26 +93,90 @@ description: "this is sparta !"`),
 port: 8080
 text: hello`),
 		),
+		fs.WithDir("secrets",
+			fs.WithFile(internal.ComposeFileName, `
+version: "3.1"
+
+services:
+  db:
+    image: postgres
+    environment:
+      POSTGRES_PASSWORD: ${db.password}
+      POSTGRES_USER: ${db.user}
+`),
+			fs.WithFile(internal.MetadataFileName, `
+version: 0.1.0
+name: foo`),
+			fs.WithFile(internal.SettingsSchemaFileName, `
+properties:
+  db:
+    properties:
+      user:
+        type: string
+        secret: true
+      password:
+        type: string
+        secret: true`),
+			fs.WithFile(internal.SettingsFileName, `
+db:
+  user: secret:env:DB_USER
+  password: s3cr3t`),
+		),
+		fs.WithDir("environments",
+			fs.WithFile(internal.ComposeFileName, composeYAML),
+			fs.WithFile(internal.MetadataFileName, `
+version: 0.1.0
+name: foo`),
+			fs.WithFile(internal.SettingsSchemaFileName, `
+properties:
+  token:
+    type: string
+    secret: true`),
+			fs.WithFile(internal.SettingsFileName, `
+web:
+  port: 8080
+  replicas: 1
+text: hello
+token: t0k3n`),
+			fs.WithDir(internal.NamedSettingsDir,
+				fs.WithFile("development.yml", `
+text: hello dev
+debug: true`),
+				fs.WithFile("production.yml", `
+web:
+  port: 80
+  replicas: 3
+token: pr0d`),
+			),
+		),
 	)
 	defer dir.Remove()
 
 	for _, testcase := range []struct {
-		name string
-		args map[string]string
+		name        string
+		args        map[string]string
+		environment string
+		golden      string
 	}{
 		{name: "no-maintainers"},
 		{name: "no-description"},
 		{name: "no-settings"},
 		{name: "overridden", args: map[string]string{"web.port": "80"}},
 		{name: "full"},
+		{name: "secrets"},
+		{name: "environments"},
+		{name: "environments", environment: "production", golden: "environments-production"},
 	} {
-		t.Run(testcase.name, func(t *testing.T) {
+		if testcase.golden == "" {
+			testcase.golden = testcase.name
+		}
+		t.Run(testcase.golden, func(t *testing.T) {
 			outBuffer := new(bytes.Buffer)
-			app, err := types.NewAppFromDefaultFiles(dir.Join(testcase.name))
+			app, err := loader.LoadFromDirectory(dir.Join(testcase.name), types.WithEnvironment(testcase.environment))
 			assert.NilError(t, err)
 			err = Inspect(outBuffer, app, testcase.args)
 			assert.NilError(t, err)
-			assert.Assert(t, golden.String(outBuffer.String(), fmt.Sprintf("inspect-%s.golden", testcase.name)))
+			assert.Assert(t, golden.String(outBuffer.String(), fmt.Sprintf("inspect-%s.golden", testcase.golden)))
 		})
 	}
 }
@@ -0,0 +1,607 @@
+package kube
+
+import (
+	"io/ioutil"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	composetypes "github.com/docker/cli/cli/compose/types"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// LabelService is the label selecting the pods of a service
+	LabelService = "com.docker.service.name"
+	// LabelStack is the label of the objects of a stack, when named
+	LabelStack = "com.docker.stack.namespace"
+
+	// defaultVolumeSize is the requested size of the persistent volume
+	// claims, unless the volume has a size driver option
+	defaultVolumeSize = "1Gi"
+	// publishedSuffix is the suffix of the load balancer services of the
+	// published ports
+	publishedSuffix = "-published"
+)
+
+// Convert converts the rendered config to Kubernetes objects, labeled with
+// the stack name if not empty. Services become Deployments, DaemonSets for the
+// global ones, or StatefulSets for those mounting a volume no other service
+// mounts, and get a ClusterIP Service named after them exposing their ports,
+// and a LoadBalancer Service exposing their published ports. Configs and
+// secrets become ConfigMaps and Secrets read from their file, and the volumes
+// not claimed by a StatefulSet become PersistentVolumeClaims.
+func Convert(config *composetypes.Config, stack string) ([]runtime.Object, error) {
+	c := &converter{config: config, stack: stack, templated: map[string]bool{}}
+	mountedBy := map[string][]composetypes.ServiceConfig{}
+	for _, s := range config.Services {
+		for _, v := range s.Volumes {
+			if c.isNamedVolume(v) {
+				mountedBy[v.Source] = append(mountedBy[v.Source], s)
+			}
+		}
+	}
+	for name, services := range mountedBy {
+		// the pods of daemon sets can't claim volumes of their own
+		if len(services) == 1 && services[0].Deploy.Mode != "global" && !config.Volumes[name].External.External {
+			c.templated[name] = true
+		}
+	}
+	var objects []runtime.Object
+	for _, name := range sortedKeys(config.Configs) {
+		cm, err := c.configMap(name, composetypes.FileObjectConfig(config.Configs[name]))
+		if err != nil {
+			return nil, err
+		}
+		if cm != nil {
+			objects = append(objects, cm)
+		}
+	}
+	for _, name := range sortedKeys(config.Secrets) {
+		secret, err := c.secret(name, composetypes.FileObjectConfig(config.Secrets[name]))
+		if err != nil {
+			return nil, err
+		}
+		if secret != nil {
+			objects = append(objects, secret)
+		}
+	}
+	for _, name := range sortedKeys(config.Volumes) {
+		if !c.templated[name] && !config.Volumes[name].External.External {
+			claim, err := c.persistentVolumeClaim(name, corev1.ReadWriteMany)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, claim)
+		}
+	}
+	services := append([]composetypes.ServiceConfig{}, config.Services...)
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	for _, s := range services {
+		workload, err := c.workload(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to convert service %s", s.Name)
+		}
+		objects = append(objects, workload)
+		objects = append(objects, c.services(s)...)
+	}
+	return objects, nil
+}
+
+type converter struct {
+	config *composetypes.Config
+	stack  string
+	// templated are the named volumes mounted by a single stateful set,
+	// claimed by its volume claim templates
+	templated map[string]bool
+}
+
+func (c *converter) objectMeta(name string, labels map[string]string) metav1.ObjectMeta {
+	meta := metav1.ObjectMeta{Name: name}
+	if c.stack != "" || len(labels) != 0 {
+		meta.Labels = map[string]string{}
+		for k, v := range labels {
+			meta.Labels[k] = v
+		}
+		if c.stack != "" {
+			meta.Labels[LabelStack] = c.stack
+		}
+	}
+	return meta
+}
+
+func (c *converter) selector(service string) map[string]string {
+	labels := map[string]string{LabelService: service}
+	if c.stack != "" {
+		labels[LabelStack] = c.stack
+	}
+	return labels
+}
+
+func (c *converter) isNamedVolume(v composetypes.ServiceVolumeConfig) bool {
+	if v.Type != "volume" || v.Source == "" {
+		return false
+	}
+	_, ok := c.config.Volumes[v.Source]
+	return ok
+}
+
+func (c *converter) configMap(name string, cfg composetypes.FileObjectConfig) (*corev1.ConfigMap, error) {
+	if cfg.External.External {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(cfg.File)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read config %s", name)
+	}
+	return &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: c.objectMeta(name, cfg.Labels),
+		Data:       map[string]string{name: string(data)},
+	}, nil
+}
+
+func (c *converter) secret(name string, cfg composetypes.FileObjectConfig) (*corev1.Secret, error) {
+	if cfg.External.External {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(cfg.File)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read secret %s", name)
+	}
+	return &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: c.objectMeta(name, cfg.Labels),
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{name: data},
+	}, nil
+}
+
+func (c *converter) persistentVolumeClaim(name string, mode corev1.PersistentVolumeAccessMode) (*corev1.PersistentVolumeClaim, error) {
+	volume := c.config.Volumes[name]
+	size := volume.DriverOpts["size"]
+	if size == "" {
+		size = defaultVolumeSize
+	}
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid size %q of volume %s", size, name)
+	}
+	return &corev1.PersistentVolumeClaim{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolumeClaim"},
+		ObjectMeta: c.objectMeta(name, volume.Labels),
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{mode},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: quantity},
+			},
+		},
+	}, nil
+}
+
+// workload returns the Deployment, DaemonSet or StatefulSet of the service
+func (c *converter) workload(s composetypes.ServiceConfig) (runtime.Object, error) {
+	pod, claims, err := c.podTemplate(s)
+	if err != nil {
+		return nil, err
+	}
+	meta := c.objectMeta(s.Name, s.Deploy.Labels)
+	selector := &metav1.LabelSelector{MatchLabels: c.selector(s.Name)}
+	replicas := replicas(s)
+	switch {
+	case s.Deploy.Mode == "global":
+		return &appsv1.DaemonSet{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "DaemonSet"},
+			ObjectMeta: meta,
+			Spec: appsv1.DaemonSetSpec{
+				Selector:       selector,
+				Template:       pod,
+				UpdateStrategy: daemonSetUpdateStrategy(s.Deploy.UpdateConfig),
+			},
+		}, nil
+	case len(claims) > 0:
+		return &appsv1.StatefulSet{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+			ObjectMeta: meta,
+			Spec: appsv1.StatefulSetSpec{
+				Replicas:             replicas,
+				Selector:             selector,
+				ServiceName:          s.Name,
+				Template:             pod,
+				VolumeClaimTemplates: claims,
+			},
+		}, nil
+	default:
+		return &appsv1.Deployment{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+			ObjectMeta: meta,
+			Spec: appsv1.DeploymentSpec{
+				Replicas: replicas,
+				Selector: selector,
+				Template: pod,
+				Strategy: deploymentStrategy(s.Deploy.UpdateConfig),
+			},
+		}, nil
+	}
+}
+
+func replicas(s composetypes.ServiceConfig) *int32 {
+	if s.Deploy.Replicas == nil {
+		return nil
+	}
+	r := int32(*s.Deploy.Replicas)
+	return &r
+}
+
+// deploymentStrategy maps the parallelism of updates to the maximum number
+// of unavailable pods, the other pods being started first with the
+// start-first order
+func deploymentStrategy(u *composetypes.UpdateConfig) appsv1.DeploymentStrategy {
+	if u == nil || u.Parallelism == nil {
+		return appsv1.DeploymentStrategy{}
+	}
+	parallelism := intstr.FromInt(int(*u.Parallelism))
+	zero := intstr.FromInt(0)
+	rolling := &appsv1.RollingUpdateDeployment{MaxUnavailable: &parallelism, MaxSurge: &zero}
+	if u.Order == "start-first" {
+		rolling = &appsv1.RollingUpdateDeployment{MaxUnavailable: &zero, MaxSurge: &parallelism}
+	}
+	return appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType, RollingUpdate: rolling}
+}
+
+func daemonSetUpdateStrategy(u *composetypes.UpdateConfig) appsv1.DaemonSetUpdateStrategy {
+	if u == nil || u.Parallelism == nil {
+		return appsv1.DaemonSetUpdateStrategy{}
+	}
+	parallelism := intstr.FromInt(int(*u.Parallelism))
+	return appsv1.DaemonSetUpdateStrategy{
+		Type:          appsv1.RollingUpdateDaemonSetStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDaemonSet{MaxUnavailable: &parallelism},
+	}
+}
+
+// podTemplate returns the pod template of the service, and the claims of the
+// volumes it doesn't share
+func (c *converter) podTemplate(s composetypes.ServiceConfig) (corev1.PodTemplateSpec, []corev1.PersistentVolumeClaim, error) {
+	container := corev1.Container{
+		Name:       s.Name,
+		Image:      s.Image,
+		Command:    s.Entrypoint,
+		Args:       s.Command,
+		WorkingDir: s.WorkingDir,
+		TTY:        s.Tty,
+		Stdin:      s.StdinOpen,
+		Env:        env(s.Environment),
+	}
+	resources, err := resources(s.Deploy.Resources)
+	if err != nil {
+		return corev1.PodTemplateSpec{}, nil, err
+	}
+	container.Resources = resources
+	if container.SecurityContext, err = securityContext(s); err != nil {
+		return corev1.PodTemplateSpec{}, nil, err
+	}
+	for _, p := range s.Ports {
+		port := corev1.ContainerPort{ContainerPort: int32(p.Target), Protocol: protocol(p.Protocol)}
+		if p.Mode == "host" {
+			port.HostPort = int32(p.Published)
+		}
+		container.Ports = append(container.Ports, port)
+	}
+	if probe := probe(s.HealthCheck); probe != nil {
+		container.LivenessProbe = probe
+		container.ReadinessProbe = probe
+	}
+	pod := corev1.PodSpec{
+		Hostname:     s.Hostname,
+		NodeSelector: nodeSelector(s.Deploy.Placement.Constraints),
+	}
+	if s.StopGracePeriod != nil {
+		seconds := int64(time.Duration(*s.StopGracePeriod).Seconds())
+		pod.TerminationGracePeriodSeconds = &seconds
+	}
+	var claims []corev1.PersistentVolumeClaim
+	for i, v := range s.Volumes {
+		volumeName := "volume-" + strconv.Itoa(i)
+		mount := corev1.VolumeMount{Name: volumeName, MountPath: v.Target, ReadOnly: v.ReadOnly}
+		switch {
+		case c.isNamedVolume(v) && c.templated[v.Source]:
+			mount.Name = v.Source
+			claim, err := c.persistentVolumeClaim(v.Source, corev1.ReadWriteOnce)
+			if err != nil {
+				return corev1.PodTemplateSpec{}, nil, err
+			}
+			claim.TypeMeta = metav1.TypeMeta{}
+			claims = append(claims, *claim)
+			container.VolumeMounts = append(container.VolumeMounts, mount)
+			continue
+		case c.isNamedVolume(v):
+			claimName := v.Source
+			if external := c.config.Volumes[v.Source].External; external.External && external.Name != "" {
+				claimName = external.Name
+			}
+			pod.Volumes = append(pod.Volumes, corev1.Volume{Name: volumeName, VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName, ReadOnly: v.ReadOnly},
+			}})
+		case v.Type == "bind":
+			pod.Volumes = append(pod.Volumes, corev1.Volume{Name: volumeName, VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{Path: v.Source},
+			}})
+		case v.Type == "tmpfs":
+			pod.Volumes = append(pod.Volumes, corev1.Volume{Name: volumeName, VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory},
+			}})
+		default:
+			// anonymous volumes live as long as the pod
+			pod.Volumes = append(pod.Volumes, corev1.Volume{Name: volumeName, VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			}})
+		}
+		container.VolumeMounts = append(container.VolumeMounts, mount)
+	}
+	for i, cfg := range s.Configs {
+		volume, mount := c.fileVolume("config-"+strconv.Itoa(i), composetypes.FileReferenceConfig(cfg), "/"+cfg.Source)
+		volume.ConfigMap = &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: externalName(cfg.Source, c.config.Configs[cfg.Source].External)},
+			Items:                []corev1.KeyToPath{{Key: cfg.Source, Path: path.Base(mount.MountPath), Mode: mode(cfg.Mode)}},
+		}
+		pod.Volumes = append(pod.Volumes, volume)
+		container.VolumeMounts = append(container.VolumeMounts, mount)
+	}
+	for i, secret := range s.Secrets {
+		volume, mount := c.fileVolume("secret-"+strconv.Itoa(i), composetypes.FileReferenceConfig(secret), "/run/secrets/"+secret.Source)
+		volume.Secret = &corev1.SecretVolumeSource{
+			SecretName: externalName(secret.Source, c.config.Secrets[secret.Source].External),
+			Items:      []corev1.KeyToPath{{Key: secret.Source, Path: path.Base(mount.MountPath), Mode: mode(secret.Mode)}},
+		}
+		pod.Volumes = append(pod.Volumes, volume)
+		container.VolumeMounts = append(container.VolumeMounts, mount)
+	}
+	pod.Containers = []corev1.Container{container}
+	meta := metav1.ObjectMeta{Labels: c.selector(s.Name)}
+	if len(s.Labels) != 0 {
+		meta.Annotations = map[string]string{}
+		for k, v := range s.Labels {
+			meta.Annotations[k] = v
+		}
+	}
+	return corev1.PodTemplateSpec{ObjectMeta: meta, Spec: pod}, claims, nil
+}
+
+// fileVolume returns the volume and mount of a config or secret, mounted as a
+// single file at its target, defaulting to the given path. The content of
+// configs and secrets is keyed by their name.
+func (c *converter) fileVolume(name string, ref composetypes.FileReferenceConfig, defaultTarget string) (corev1.Volume, corev1.VolumeMount) {
+	target := ref.Target
+	if target == "" {
+		target = defaultTarget
+	} else if !path.IsAbs(target) {
+		target = path.Join(path.Dir(defaultTarget), target)
+	}
+	return corev1.Volume{Name: name}, corev1.VolumeMount{
+		Name:      name,
+		MountPath: target,
+		SubPath:   path.Base(target),
+		ReadOnly:  true,
+	}
+}
+
+func externalName(name string, external composetypes.External) string {
+	if external.External && external.Name != "" {
+		return external.Name
+	}
+	return name
+}
+
+func mode(m *uint32) *int32 {
+	if m == nil {
+		return nil
+	}
+	res := int32(*m)
+	return &res
+}
+
+func env(environment composetypes.MappingWithEquals) []corev1.EnvVar {
+	var res []corev1.EnvVar
+	for _, k := range sortedKeys(environment) {
+		v := ""
+		if environment[k] != nil {
+			v = *environment[k]
+		}
+		res = append(res, corev1.EnvVar{Name: k, Value: v})
+	}
+	return res
+}
+
+func resources(r composetypes.Resources) (corev1.ResourceRequirements, error) {
+	var res corev1.ResourceRequirements
+	var err error
+	if res.Limits, err = resourceList(r.Limits); err != nil {
+		return res, err
+	}
+	res.Requests, err = resourceList(r.Reservations)
+	return res, err
+}
+
+func resourceList(r *composetypes.Resource) (corev1.ResourceList, error) {
+	if r == nil || (r.NanoCPUs == "" && r.MemoryBytes == 0) {
+		return nil, nil
+	}
+	list := corev1.ResourceList{}
+	if r.NanoCPUs != "" {
+		cpus, err := resource.ParseQuantity(r.NanoCPUs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid cpus %q", r.NanoCPUs)
+		}
+		list[corev1.ResourceCPU] = cpus
+	}
+	if r.MemoryBytes != 0 {
+		list[corev1.ResourceMemory] = *resource.NewQuantity(int64(r.MemoryBytes), resource.BinarySI)
+	}
+	return list, nil
+}
+
+func securityContext(s composetypes.ServiceConfig) (*corev1.SecurityContext, error) {
+	var sc corev1.SecurityContext
+	empty := true
+	if s.User != "" {
+		// only numeric users can be mapped, names being resolved in the image
+		parts := strings.SplitN(s.User, ":", 2)
+		uid, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("user %q is not numeric", s.User)
+		}
+		sc.RunAsUser = &uid
+		if len(parts) == 2 {
+			gid, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				return nil, errors.Errorf("group of user %q is not numeric", s.User)
+			}
+			sc.RunAsGroup = &gid
+		}
+		empty = false
+	}
+	if s.Privileged {
+		sc.Privileged = &s.Privileged
+		empty = false
+	}
+	if s.ReadOnly {
+		sc.ReadOnlyRootFilesystem = &s.ReadOnly
+		empty = false
+	}
+	if len(s.CapAdd) != 0 || len(s.CapDrop) != 0 {
+		sc.Capabilities = &corev1.Capabilities{}
+		for _, c := range s.CapAdd {
+			sc.Capabilities.Add = append(sc.Capabilities.Add, corev1.Capability(c))
+		}
+		for _, c := range s.CapDrop {
+			sc.Capabilities.Drop = append(sc.Capabilities.Drop, corev1.Capability(c))
+		}
+		empty = false
+	}
+	if empty {
+		return nil, nil
+	}
+	return &sc, nil
+}
+
+func protocol(p string) corev1.Protocol {
+	if strings.ToLower(p) == "udp" {
+		return corev1.ProtocolUDP
+	}
+	return corev1.ProtocolTCP
+}
+
+// probe returns the liveness and readiness probe of the healthcheck, if
+// enabled
+func probe(h *composetypes.HealthCheckConfig) *corev1.Probe {
+	if h == nil || h.Disable || len(h.Test) == 0 || h.Test[0] == "NONE" {
+		return nil
+	}
+	var command []string
+	switch h.Test[0] {
+	case "CMD":
+		command = h.Test[1:]
+	case "CMD-SHELL":
+		command = []string{"/bin/sh", "-c", strings.Join(h.Test[1:], " ")}
+	default:
+		command = []string{"/bin/sh", "-c", strings.Join(h.Test, " ")}
+	}
+	p := &corev1.Probe{Handler: corev1.Handler{Exec: &corev1.ExecAction{Command: command}}}
+	if h.Interval != nil {
+		p.PeriodSeconds = seconds(*h.Interval)
+	}
+	if h.Timeout != nil {
+		p.TimeoutSeconds = seconds(*h.Timeout)
+	}
+	if h.StartPeriod != nil {
+		p.InitialDelaySeconds = seconds(*h.StartPeriod)
+	}
+	if h.Retries != nil {
+		p.FailureThreshold = int32(*h.Retries)
+	}
+	return p
+}
+
+// seconds rounds the duration up to the second, the precision of probes
+func seconds(d composetypes.Duration) int32 {
+	return int32((time.Duration(d) + time.Second - 1) / time.Second)
+}
+
+// nodeSelector converts the node label equality constraints, the only ones
+// with an equivalent
+func nodeSelector(constraints []string) map[string]string {
+	var res map[string]string
+	for _, c := range constraints {
+		parts := strings.SplitN(c, "==", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(key, "node.labels.") {
+			continue
+		}
+		if res == nil {
+			res = map[string]string{}
+		}
+		res[strings.TrimPrefix(key, "node.labels.")] = strings.TrimSpace(parts[1])
+	}
+	return res
+}
+
+// services returns the Service exposing the ports of the service by its name,
+// headless if it has none, and the LoadBalancer Service of its published
+// ports in ingress mode
+func (c *converter) services(s composetypes.ServiceConfig) []runtime.Object {
+	internal := &corev1.Service{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: c.objectMeta(s.Name, nil),
+		Spec:       corev1.ServiceSpec{Selector: c.selector(s.Name)},
+	}
+	published := &corev1.Service{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: c.objectMeta(s.Name+publishedSuffix, nil),
+		Spec:       corev1.ServiceSpec{Selector: c.selector(s.Name), Type: corev1.ServiceTypeLoadBalancer},
+	}
+	seen := map[string]bool{}
+	for _, p := range s.Ports {
+		key := strconv.Itoa(int(p.Target)) + "/" + string(protocol(p.Protocol))
+		if !seen[key] {
+			seen[key] = true
+			internal.Spec.Ports = append(internal.Spec.Ports, corev1.ServicePort{
+				Name:       portName(p.Target, protocol(p.Protocol)),
+				Port:       int32(p.Target),
+				TargetPort: intstr.FromInt(int(p.Target)),
+				Protocol:   protocol(p.Protocol),
+			})
+		}
+		if p.Published != 0 && p.Mode != "host" {
+			published.Spec.Ports = append(published.Spec.Ports, corev1.ServicePort{
+				Name:       portName(p.Published, protocol(p.Protocol)),
+				Port:       int32(p.Published),
+				TargetPort: intstr.FromInt(int(p.Target)),
+				Protocol:   protocol(p.Protocol),
+			})
+		}
+	}
+	if len(internal.Spec.Ports) == 0 {
+		internal.Spec.ClusterIP = corev1.ClusterIPNone
+	}
+	objects := []runtime.Object{internal}
+	if len(published.Spec.Ports) > 0 {
+		objects = append(objects, published)
+	}
+	return objects
+}
+
+func portName(port uint32, p corev1.Protocol) string {
+	return strings.ToLower(string(p)) + "-" + strconv.Itoa(int(port))
+}
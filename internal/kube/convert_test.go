@@ -0,0 +1,246 @@
+package kube
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	composetypes "github.com/docker/cli/cli/compose/types"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func uint64Ptr(i uint64) *uint64 {
+	return &i
+}
+
+func durationPtr(d time.Duration) *composetypes.Duration {
+	res := composetypes.Duration(d)
+	return &res
+}
+
+func kinds(objects []runtime.Object) []string {
+	var res []string
+	for _, o := range objects {
+		res = append(res, o.GetObjectKind().GroupVersionKind().Kind)
+	}
+	return res
+}
+
+func TestConvertWorkloads(t *testing.T) {
+	config := &composetypes.Config{
+		Services: []composetypes.ServiceConfig{
+			{Name: "web", Image: "nginx", Deploy: composetypes.DeployConfig{
+				Replicas:     uint64Ptr(3),
+				Labels:       map[string]string{"tier": "front"},
+				UpdateConfig: &composetypes.UpdateConfig{Parallelism: uint64Ptr(2)},
+				Placement:    composetypes.Placement{Constraints: []string{"node.labels.zone == east", "node.role == worker"}},
+				Resources: composetypes.Resources{
+					Limits:       &composetypes.Resource{NanoCPUs: "0.5", MemoryBytes: 64 * 1024 * 1024},
+					Reservations: &composetypes.Resource{MemoryBytes: 32 * 1024 * 1024},
+				},
+			}},
+			{Name: "agent", Image: "agent", Deploy: composetypes.DeployConfig{Mode: "global"},
+				Volumes: []composetypes.ServiceVolumeConfig{{Type: "volume", Source: "logs", Target: "/logs"}}},
+			{Name: "db", Image: "postgres", Volumes: []composetypes.ServiceVolumeConfig{
+				{Type: "volume", Source: "data", Target: "/var/lib/postgresql/data"},
+				{Type: "volume", Target: "/tmp/anonymous"},
+			}},
+		},
+		Volumes: map[string]composetypes.VolumeConfig{
+			"data": {DriverOpts: map[string]string{"size": "10Gi"}},
+			"logs": {},
+		},
+	}
+	objects, err := Convert(config, "app")
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(kinds(objects), []string{
+		"PersistentVolumeClaim",
+		"DaemonSet", "Service",
+		"StatefulSet", "Service",
+		"Deployment", "Service",
+	}))
+
+	// volumes of daemon sets are claimed on their own
+	logs := objects[0].(*corev1.PersistentVolumeClaim)
+	assert.Check(t, is.Equal(logs.Name, "logs"))
+	assert.Check(t, is.DeepEqual(logs.Spec.AccessModes, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}))
+	agent := objects[1].(*appsv1.DaemonSet)
+	assert.Check(t, is.Equal(agent.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName, "logs"))
+
+	db := objects[3].(*appsv1.StatefulSet)
+	assert.Check(t, is.Equal(db.Spec.ServiceName, "db"))
+	assert.Assert(t, is.Len(db.Spec.VolumeClaimTemplates, 1))
+	claim := db.Spec.VolumeClaimTemplates[0]
+	assert.Check(t, is.Equal(claim.Name, "data"))
+	size := claim.Spec.Resources.Requests[corev1.ResourceStorage]
+	assert.Check(t, is.Equal(size.String(), "10Gi"))
+	assert.Check(t, is.DeepEqual(db.Spec.Template.Spec.Containers[0].VolumeMounts, []corev1.VolumeMount{
+		{Name: "data", MountPath: "/var/lib/postgresql/data"},
+		{Name: "volume-1", MountPath: "/tmp/anonymous"},
+	}))
+	assert.Check(t, db.Spec.Template.Spec.Volumes[0].EmptyDir != nil)
+	// services without ports are headless
+	assert.Check(t, is.Equal(objects[4].(*corev1.Service).Spec.ClusterIP, corev1.ClusterIPNone))
+
+	web := objects[5].(*appsv1.Deployment)
+	assert.Check(t, is.Equal(*web.Spec.Replicas, int32(3)))
+	assert.Check(t, is.DeepEqual(web.Labels, map[string]string{"tier": "front", LabelStack: "app"}))
+	assert.Check(t, is.DeepEqual(web.Spec.Selector.MatchLabels, map[string]string{LabelService: "web", LabelStack: "app"}))
+	assert.Check(t, is.DeepEqual(web.Spec.Template.Labels, web.Spec.Selector.MatchLabels))
+	assert.Check(t, is.Equal(web.Spec.Strategy.RollingUpdate.MaxUnavailable.IntValue(), 2))
+	assert.Check(t, is.DeepEqual(web.Spec.Template.Spec.NodeSelector, map[string]string{"zone": "east"}))
+	resources := web.Spec.Template.Spec.Containers[0].Resources
+	assert.Check(t, is.Equal(resources.Limits.Cpu().String(), "500m"))
+	assert.Check(t, is.Equal(resources.Limits.Memory().String(), "64Mi"))
+	assert.Check(t, is.Equal(resources.Requests.Memory().String(), "32Mi"))
+	assert.Check(t, is.Len(resources.Requests, 1))
+}
+
+func TestConvertPorts(t *testing.T) {
+	config := &composetypes.Config{Services: []composetypes.ServiceConfig{
+		{Name: "web", Image: "nginx", Ports: []composetypes.ServicePortConfig{
+			{Target: 80, Published: 8080, Protocol: "tcp"},
+			{Target: 443, Protocol: "tcp"},
+			{Target: 53, Published: 53, Protocol: "udp", Mode: "host"},
+		}},
+	}}
+	objects, err := Convert(config, "")
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(kinds(objects), []string{"Deployment", "Service", "Service"}))
+
+	container := objects[0].(*appsv1.Deployment).Spec.Template.Spec.Containers[0]
+	assert.Check(t, is.DeepEqual(container.Ports, []corev1.ContainerPort{
+		{ContainerPort: 80, Protocol: corev1.ProtocolTCP},
+		{ContainerPort: 443, Protocol: corev1.ProtocolTCP},
+		{ContainerPort: 53, HostPort: 53, Protocol: corev1.ProtocolUDP},
+	}))
+	internal := objects[1].(*corev1.Service)
+	assert.Check(t, is.Equal(internal.Name, "web"))
+	assert.Check(t, is.Equal(internal.Spec.Type, corev1.ServiceType("")))
+	assert.Check(t, is.DeepEqual(internal.Spec.Ports, []corev1.ServicePort{
+		{Name: "tcp-80", Port: 80, TargetPort: intstr.FromInt(80), Protocol: corev1.ProtocolTCP},
+		{Name: "tcp-443", Port: 443, TargetPort: intstr.FromInt(443), Protocol: corev1.ProtocolTCP},
+		{Name: "udp-53", Port: 53, TargetPort: intstr.FromInt(53), Protocol: corev1.ProtocolUDP},
+	}))
+	published := objects[2].(*corev1.Service)
+	assert.Check(t, is.Equal(published.Name, "web-published"))
+	assert.Check(t, is.Equal(published.Spec.Type, corev1.ServiceTypeLoadBalancer))
+	assert.Check(t, is.DeepEqual(published.Spec.Ports, []corev1.ServicePort{
+		{Name: "tcp-8080", Port: 8080, TargetPort: intstr.FromInt(80), Protocol: corev1.ProtocolTCP},
+	}))
+}
+
+func TestConvertHealthCheck(t *testing.T) {
+	config := &composetypes.Config{Services: []composetypes.ServiceConfig{
+		{Name: "shell", Image: "nginx", HealthCheck: &composetypes.HealthCheckConfig{
+			Test:        []string{"CMD-SHELL", "curl -f http://localhost"},
+			Interval:    durationPtr(10 * time.Second),
+			Timeout:     durationPtr(1500 * time.Millisecond),
+			StartPeriod: durationPtr(time.Minute),
+			Retries:     uint64Ptr(5),
+		}},
+		{Name: "exec", Image: "nginx", HealthCheck: &composetypes.HealthCheckConfig{Test: []string{"CMD", "check", "--quick"}}},
+		{Name: "disabled", Image: "nginx", HealthCheck: &composetypes.HealthCheckConfig{Test: []string{"NONE"}}},
+	}}
+	objects, err := Convert(config, "")
+	assert.NilError(t, err)
+	container := func(i int) corev1.Container {
+		return objects[i].(*appsv1.Deployment).Spec.Template.Spec.Containers[0]
+	}
+
+	assert.Check(t, is.Nil(container(0).LivenessProbe))
+	exec := container(2)
+	assert.Check(t, is.DeepEqual(exec.LivenessProbe.Exec.Command, []string{"check", "--quick"}))
+	shell := container(4)
+	assert.Check(t, is.DeepEqual(shell.LivenessProbe, &corev1.Probe{
+		Handler:             corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"/bin/sh", "-c", "curl -f http://localhost"}}},
+		PeriodSeconds:       10,
+		TimeoutSeconds:      2,
+		InitialDelaySeconds: 60,
+		FailureThreshold:    5,
+	}))
+	assert.Check(t, is.DeepEqual(shell.ReadinessProbe, shell.LivenessProbe))
+}
+
+func TestConvertConfigsSecrets(t *testing.T) {
+	dir := fs.NewDir(t, "files",
+		fs.WithFile("nginx.conf", "server {}"),
+		fs.WithFile("password", "s3cr3t"),
+	)
+	defer dir.Remove()
+	config := &composetypes.Config{
+		Services: []composetypes.ServiceConfig{
+			{Name: "web", Image: "nginx",
+				Configs: []composetypes.ServiceConfigObjConfig{
+					{Source: "nginx", Target: "/etc/nginx/nginx.conf"},
+					{Source: "shared"},
+				},
+				Secrets: []composetypes.ServiceSecretConfig{{Source: "password"}},
+			},
+		},
+		Configs: map[string]composetypes.ConfigObjConfig{
+			"nginx":  {File: dir.Join("nginx.conf")},
+			"shared": {External: composetypes.External{External: true, Name: "cluster-config"}},
+		},
+		Secrets: map[string]composetypes.SecretConfig{
+			"password": {File: dir.Join("password")},
+		},
+	}
+	objects, err := Convert(config, "")
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(kinds(objects), []string{"ConfigMap", "Secret", "Deployment", "Service"}))
+	assert.Check(t, is.DeepEqual(objects[0].(*corev1.ConfigMap).Data, map[string]string{"nginx": "server {}"}))
+	assert.Check(t, is.DeepEqual(objects[1].(*corev1.Secret).Data, map[string][]byte{"password": []byte("s3cr3t")}))
+
+	pod := objects[2].(*appsv1.Deployment).Spec.Template.Spec
+	assert.Check(t, is.DeepEqual(pod.Containers[0].VolumeMounts, []corev1.VolumeMount{
+		{Name: "config-0", MountPath: "/etc/nginx/nginx.conf", SubPath: "nginx.conf", ReadOnly: true},
+		{Name: "config-1", MountPath: "/shared", SubPath: "shared", ReadOnly: true},
+		{Name: "secret-0", MountPath: "/run/secrets/password", SubPath: "password", ReadOnly: true},
+	}))
+	assert.Check(t, is.Equal(pod.Volumes[1].ConfigMap.Name, "cluster-config"))
+	assert.Check(t, is.DeepEqual(pod.Volumes[2].Secret.Items, []corev1.KeyToPath{{Key: "password", Path: "password"}}))
+
+	config.Secrets["password"] = composetypes.SecretConfig{File: dir.Join("missing")}
+	_, err = Convert(config, "")
+	assert.ErrorContains(t, err, "failed to read secret password")
+}
+
+func TestConvertInvalidVolumeSize(t *testing.T) {
+	config := &composetypes.Config{
+		Services: []composetypes.ServiceConfig{
+			{Name: "db", Image: "postgres", Volumes: []composetypes.ServiceVolumeConfig{
+				{Type: "volume", Source: "data", Target: "/var/lib/postgresql/data"},
+			}},
+		},
+		Volumes: map[string]composetypes.VolumeConfig{
+			"data": {DriverOpts: map[string]string{"size": "10GB"}},
+		},
+	}
+	_, err := Convert(config, "app")
+	assert.ErrorContains(t, err, `failed to convert service db: invalid size "10GB" of volume data`)
+
+	// volumes not claimed by a stateful set
+	config.Volumes["logs"] = composetypes.VolumeConfig{DriverOpts: map[string]string{"size": "lots"}}
+	_, err = Convert(config, "app")
+	assert.ErrorContains(t, err, `invalid size "lots" of volume logs`)
+}
+
+func TestMarshal(t *testing.T) {
+	config := &composetypes.Config{Services: []composetypes.ServiceConfig{{Name: "web", Image: "nginx"}}}
+	objects, err := Convert(config, "")
+	assert.NilError(t, err)
+	raw, err := Marshal(objects)
+	assert.NilError(t, err)
+	documents := strings.Split(string(raw), "---\n")
+	assert.Assert(t, is.Len(documents, 2))
+	assert.Check(t, is.Contains(documents[0], "apiVersion: apps/v1\nkind: Deployment\n"))
+	assert.Check(t, is.Contains(documents[1], "apiVersion: v1\nkind: Service\n"))
+	assert.Check(t, is.Contains(documents[1], "clusterIP: None\n"))
+}
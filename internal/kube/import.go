@@ -0,0 +1,615 @@
+package kube
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/app/internal/yaml"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Manifest is a Kubernetes object read from a manifest. It is kept as generic
+// maps, its values may be ${...} placeholders where numbers or booleans are
+// expected.
+type Manifest struct {
+	// Source is the file the object was read from
+	Source string
+	Object map[interface{}]interface{}
+}
+
+func (m Manifest) kind() string {
+	return str(m.Object, "kind")
+}
+
+func (m Manifest) name() string {
+	return str(m.Object, "metadata", "name")
+}
+
+func (m Manifest) String() string {
+	return fmt.Sprintf("%s %s in %s", m.kind(), m.name(), m.Source)
+}
+
+// ReadManifests reads the Kubernetes objects of a multi-document YAML stream,
+// expanding the lists
+func ReadManifests(source string, data []byte) ([]Manifest, error) {
+	var manifests []Manifest
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc map[interface{}]interface{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", source)
+		}
+		if doc == nil {
+			continue
+		}
+		items := []interface{}{doc}
+		if str(doc, "kind") == "List" {
+			items = list(doc, "items")
+		}
+		for _, item := range items {
+			object, ok := item.(map[interface{}]interface{})
+			if !ok || str(object, "kind") == "" || str(object, "metadata", "name") == "" {
+				return nil, errors.Errorf("%s holds a document which is not a kubernetes object", source)
+			}
+			manifests = append(manifests, Manifest{Source: source, Object: object})
+		}
+	}
+	return manifests, nil
+}
+
+// Imported is the compose application imported from Kubernetes objects
+type Imported struct {
+	Services map[string]interface{}
+	Volumes  map[string]interface{}
+	Configs  map[string]interface{}
+	Secrets  map[string]interface{}
+	// Files are the contents of the configs and secrets, by path relative
+	// to the compose file
+	Files map[string][]byte
+	// Unsupported describes the objects and fields left out
+	Unsupported []string
+}
+
+// ToCompose converts Kubernetes objects to a compose application, the inverse
+// of Convert. Deployments, StatefulSets and DaemonSets become services, from
+// the first container of their pods, published by the LoadBalancer and
+// NodePort Services selecting them. ConfigMaps and Secrets become configs and
+// secrets, their keys written to files, and PersistentVolumeClaims become
+// named volumes. The objects and fields with no compose equivalent are
+// reported as unsupported.
+func ToCompose(manifests []Manifest) (*Imported, error) {
+	imp := &importer{
+		Imported: &Imported{
+			Services: map[string]interface{}{},
+			Volumes:  map[string]interface{}{},
+			Configs:  map[string]interface{}{},
+			Secrets:  map[string]interface{}{},
+			Files:    map[string][]byte{},
+		},
+		configMaps: map[string]Manifest{},
+		secrets:    map[string]Manifest{},
+	}
+	var workloads, services []Manifest
+	for _, m := range manifests {
+		switch m.kind() {
+		case "ConfigMap":
+			if err := validateFileObject(m, "configs"); err != nil {
+				return nil, err
+			}
+			imp.configMaps[m.name()] = m
+		case "Secret":
+			if err := validateFileObject(m, "secrets"); err != nil {
+				return nil, err
+			}
+			imp.secrets[m.name()] = m
+		case "PersistentVolumeClaim":
+			imp.Volumes[m.name()] = map[string]interface{}{}
+		case "Deployment", "StatefulSet", "DaemonSet":
+			workloads = append(workloads, m)
+		case "Service":
+			services = append(services, m)
+		default:
+			imp.unsupported("%s is not supported", m)
+		}
+	}
+	for _, w := range workloads {
+		if err := imp.workload(w); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range services {
+		imp.service(s, workloads)
+	}
+	// the config maps and secrets no pod mounts are kept as well
+	for _, name := range sortedKeys(imp.configMaps) {
+		for _, key := range objectKeys(imp.configMaps[name]) {
+			imp.fileObject(false, name, key)
+		}
+	}
+	for _, name := range sortedKeys(imp.secrets) {
+		for _, key := range objectKeys(imp.secrets[name]) {
+			imp.fileObject(true, name, key)
+		}
+	}
+	return imp.Imported, nil
+}
+
+type importer struct {
+	*Imported
+	configMaps map[string]Manifest
+	secrets    map[string]Manifest
+}
+
+func (imp *importer) unsupported(format string, args ...interface{}) {
+	imp.Unsupported = append(imp.Unsupported, fmt.Sprintf(format, args...))
+}
+
+// workload imports the service of a Deployment, StatefulSet or DaemonSet
+func (imp *importer) workload(m Manifest) error {
+	pod := dict(m.Object, "spec", "template", "spec")
+	containers := list(pod, "containers")
+	if len(containers) == 0 {
+		return errors.Errorf("%s has no containers", m)
+	}
+	for _, c := range containers[1:] {
+		imp.unsupported("%s: container %s is left out, only the first container of a pod is imported", m, str(c, "name"))
+	}
+	for _, key := range []string{"initContainers", "affinity", "tolerations", "hostNetwork", "serviceAccountName"} {
+		if field(pod, key) != nil {
+			imp.unsupported("%s: %s is not supported", m, key)
+		}
+	}
+	c := containers[0]
+	service := map[string]interface{}{}
+	set(service, "image", str(c, "image"))
+	set(service, "entrypoint", list(c, "command"))
+	set(service, "command", list(c, "args"))
+	set(service, "working_dir", str(c, "workingDir"))
+	set(service, "environment", imp.environment(m, c))
+	set(service, "healthcheck", imp.healthCheck(m, c))
+	imp.securityContext(service, pod, c)
+
+	deploy := map[string]interface{}{}
+	if m.kind() == "DaemonSet" {
+		deploy["mode"] = "global"
+	} else {
+		set(deploy, "replicas", field(m.Object, "spec", "replicas"))
+	}
+	resources := map[string]interface{}{}
+	set(resources, "limits", resourceRequirements(dict(c, "resources", "limits")))
+	set(resources, "reservations", resourceRequirements(dict(c, "resources", "requests")))
+	set(deploy, "resources", resources)
+	if placement := constraints(dict(pod, "nodeSelector")); len(placement) != 0 {
+		deploy["placement"] = map[string]interface{}{"constraints": placement}
+	}
+	set(service, "deploy", deploy)
+
+	if err := imp.volumes(service, m, pod, c); err != nil {
+		return err
+	}
+	imp.Services[m.name()] = service
+	return nil
+}
+
+func (imp *importer) environment(m Manifest, c interface{}) map[string]interface{} {
+	env := map[string]interface{}{}
+	for _, e := range list(c, "env") {
+		if field(e, "valueFrom") != nil {
+			imp.unsupported("%s: environment variable %s: valueFrom is not supported", m, str(e, "name"))
+			continue
+		}
+		env[str(e, "name")] = str(e, "value")
+	}
+	if field(c, "envFrom") != nil {
+		imp.unsupported("%s: envFrom is not supported", m)
+	}
+	return env
+}
+
+func (imp *importer) healthCheck(m Manifest, c interface{}) map[string]interface{} {
+	probe := dict(c, "livenessProbe")
+	if probe == nil {
+		probe = dict(c, "readinessProbe")
+	}
+	if probe == nil {
+		return nil
+	}
+	command := list(probe, "exec", "command")
+	if command == nil {
+		imp.unsupported("%s: only exec probes are supported", m)
+		return nil
+	}
+	healthCheck := map[string]interface{}{"test": append([]interface{}{"CMD"}, command...)}
+	set(healthCheck, "interval", durationSeconds(field(probe, "periodSeconds")))
+	set(healthCheck, "timeout", durationSeconds(field(probe, "timeoutSeconds")))
+	set(healthCheck, "start_period", durationSeconds(field(probe, "initialDelaySeconds")))
+	set(healthCheck, "retries", field(probe, "failureThreshold"))
+	return healthCheck
+}
+
+// securityContext imports the container security context, falling back to
+// the pod's for the user
+func (imp *importer) securityContext(service map[string]interface{}, pod, c interface{}) {
+	user := field(c, "securityContext", "runAsUser")
+	group := field(c, "securityContext", "runAsGroup")
+	if user == nil {
+		user = field(pod, "securityContext", "runAsUser")
+	}
+	if group == nil {
+		group = field(pod, "securityContext", "runAsGroup")
+	}
+	if user != nil {
+		if group != nil {
+			service["user"] = fmt.Sprintf("%v:%v", user, group)
+		} else {
+			service["user"] = fmt.Sprint(user)
+		}
+	}
+	set(service, "privileged", field(c, "securityContext", "privileged"))
+	set(service, "read_only", field(c, "securityContext", "readOnlyRootFilesystem"))
+	set(service, "cap_add", list(c, "securityContext", "capabilities", "add"))
+	set(service, "cap_drop", list(c, "securityContext", "capabilities", "drop"))
+}
+
+// volumes imports the volume mounts of the container as volumes, tmpfs,
+// configs and secrets
+func (imp *importer) volumes(service map[string]interface{}, m Manifest, pod, c interface{}) error {
+	podVolumes := map[string]interface{}{}
+	for _, v := range list(pod, "volumes") {
+		podVolumes[str(v, "name")] = v
+	}
+	claimTemplates := map[string]bool{}
+	for _, t := range list(m.Object, "spec", "volumeClaimTemplates") {
+		name := str(t, "metadata", "name")
+		claimTemplates[name] = true
+		imp.Volumes[name] = map[string]interface{}{}
+	}
+	var volumes, tmpfs, configs, secrets []interface{}
+	for _, mount := range list(c, "volumeMounts") {
+		name := str(mount, "name")
+		target := str(mount, "mountPath")
+		subPath := str(mount, "subPath")
+		mode := ""
+		if field(mount, "readOnly") == true {
+			mode = ":ro"
+		}
+		if claimTemplates[name] {
+			volumes = append(volumes, name+":"+target+mode)
+			continue
+		}
+		v, ok := podVolumes[name]
+		if !ok {
+			return errors.Errorf("%s: volume %s is mounted but not declared", m, name)
+		}
+		switch {
+		case dict(v, "configMap") != nil:
+			configs = append(configs, imp.fileMounts(m, false, str(v, "configMap", "name"), list(v, "configMap", "items"), target, subPath)...)
+		case dict(v, "secret") != nil:
+			secrets = append(secrets, imp.fileMounts(m, true, str(v, "secret", "secretName"), list(v, "secret", "items"), target, subPath)...)
+		case dict(v, "persistentVolumeClaim") != nil:
+			if subPath != "" {
+				imp.unsupported("%s: volume %s: subPath is not supported for persistent volume claims", m, name)
+				continue
+			}
+			claim := str(v, "persistentVolumeClaim", "claimName")
+			if _, ok := imp.Volumes[claim]; !ok {
+				imp.Volumes[claim] = map[string]interface{}{}
+			}
+			volumes = append(volumes, claim+":"+target+mode)
+		case dict(v, "hostPath") != nil:
+			volumes = append(volumes, path.Join(str(v, "hostPath", "path"), subPath)+":"+target+mode)
+		case field(v, "emptyDir") != nil:
+			if str(v, "emptyDir", "medium") == "Memory" {
+				tmpfs = append(tmpfs, target)
+			} else {
+				volumes = append(volumes, target)
+			}
+		default:
+			imp.unsupported("%s: volume %s: only config maps, secrets, persistent volume claims, host paths and empty dirs are supported", m, name)
+		}
+	}
+	set(service, "volumes", volumes)
+	set(service, "tmpfs", tmpfs)
+	set(service, "configs", configs)
+	set(service, "secrets", secrets)
+	return nil
+}
+
+// fileMounts imports the mount of the keys of a config map or secret as
+// configs or secrets
+func (imp *importer) fileMounts(m Manifest, secret bool, object string, items []interface{}, target, subPath string) []interface{} {
+	objects, kind := imp.configMaps, "config map"
+	if secret {
+		objects, kind = imp.secrets, "secret"
+	}
+	o, ok := objects[object]
+	if !ok {
+		imp.unsupported("%s: %s %s is not part of the imported objects", m, kind, object)
+		return nil
+	}
+	var keys, paths []string
+	if items != nil {
+		for _, item := range items {
+			keys = append(keys, str(item, "key"))
+			paths = append(paths, str(item, "path"))
+		}
+	} else {
+		keys = objectKeys(o)
+		paths = keys
+	}
+	var mounts []interface{}
+	for i, key := range keys {
+		mountTarget := path.Join(target, paths[i])
+		if subPath != "" {
+			if paths[i] != subPath {
+				continue
+			}
+			mountTarget = target
+		}
+		if name, ok := imp.fileObject(secret, object, key); ok {
+			mounts = append(mounts, map[string]interface{}{"source": name, "target": mountTarget})
+		}
+	}
+	if subPath != "" && len(mounts) == 0 {
+		imp.unsupported("%s: %s %s has no key mounted at %s", m, kind, object, subPath)
+	}
+	return mounts
+}
+
+// fileObject imports a key of a config map or secret as a config or secret
+// read from a file, and returns its name
+func (imp *importer) fileObject(secret bool, object, key string) (string, bool) {
+	objects, fileObjects, dir := imp.configMaps, imp.Configs, "configs"
+	if secret {
+		objects, fileObjects, dir = imp.secrets, imp.Secrets, "secrets"
+	}
+	o := objects[object]
+	name := fileObjectName(object, key)
+	if _, ok := fileObjects[name]; ok {
+		return name, true
+	}
+	var (
+		data    []byte
+		encoded string
+	)
+	switch {
+	case field(o.Object, "stringData", key) != nil:
+		data = []byte(str(o.Object, "stringData", key))
+	case field(o.Object, "binaryData", key) != nil:
+		encoded = str(o.Object, "binaryData", key)
+	case secret && field(o.Object, "data", key) != nil:
+		encoded = str(o.Object, "data", key)
+	case field(o.Object, "data", key) != nil:
+		data = []byte(str(o.Object, "data", key))
+	default:
+		imp.unsupported("%s has no key %s", o, key)
+		return "", false
+	}
+	if encoded != "" {
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			imp.unsupported("%s: key %s is not base64 encoded", o, key)
+			return "", false
+		}
+	}
+	file := path.Join(dir, name)
+	imp.Files[file] = data
+	fileObjects[name] = map[string]interface{}{"file": "./" + file}
+	return name, true
+}
+
+// fileObjectName returns the name of the config or secret a key of a config
+// map or secret is imported as
+func fileObjectName(object, key string) string {
+	if key == object {
+		return object
+	}
+	return object + "-" + key
+}
+
+// validateFileObject checks the name and keys of a config map or secret, so
+// that the files its keys are written to stay in the directory
+func validateFileObject(m Manifest, dir string) error {
+	if errs := validation.IsDNS1123Subdomain(m.name()); len(errs) > 0 {
+		return errors.Errorf("%s: invalid name: %s", m, strings.Join(errs, ", "))
+	}
+	for _, key := range objectKeys(m) {
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return errors.Errorf("%s: invalid key %q: %s", m, key, strings.Join(errs, ", "))
+		}
+		if file := path.Join(dir, fileObjectName(m.name(), key)); path.Dir(file) != dir {
+			return errors.Errorf("%s: key %q is written outside of %s", m, key, dir)
+		}
+	}
+	return nil
+}
+
+// service imports the ports published by a Service on the service of the
+// workload it selects
+func (imp *importer) service(m Manifest, workloads []Manifest) {
+	selector := dict(m.Object, "spec", "selector")
+	var workload *Manifest
+	for i, w := range workloads {
+		if len(selector) != 0 && matchLabels(selector, dict(w.Object, "spec", "template", "metadata", "labels")) {
+			workload = &workloads[i]
+			break
+		}
+	}
+	if workload == nil {
+		imp.unsupported("%s selects no imported workload", m)
+		return
+	}
+	serviceType := str(m.Object, "spec", "type")
+	if serviceType != "LoadBalancer" && serviceType != "NodePort" {
+		if m.name() != workload.name() {
+			imp.unsupported("%s: the service is reachable as %s instead", m, workload.name())
+		}
+		return
+	}
+	service := imp.Services[workload.name()].(map[string]interface{})
+	container := list(workload.Object, "spec", "template", "spec", "containers")[0]
+	ports, _ := service["ports"].([]interface{})
+	for _, p := range list(m.Object, "spec", "ports") {
+		published := field(p, "port")
+		if serviceType == "NodePort" && field(p, "nodePort") != nil {
+			published = field(p, "nodePort")
+		}
+		port := fmt.Sprintf("%v:%v", published, targetPort(p, container))
+		if strings.EqualFold(str(p, "protocol"), "UDP") {
+			port += "/udp"
+		}
+		ports = append(ports, port)
+	}
+	set(service, "ports", ports)
+}
+
+// targetPort returns the container port a Service port targets, resolving
+// port names
+func targetPort(port, container interface{}) interface{} {
+	target := field(port, "targetPort")
+	if target == nil {
+		return field(port, "port")
+	}
+	name, ok := target.(string)
+	if !ok || strings.Contains(name, "${") {
+		return target
+	}
+	if _, err := strconv.Atoi(name); err == nil {
+		return target
+	}
+	for _, p := range list(container, "ports") {
+		if str(p, "name") == name {
+			return field(p, "containerPort")
+		}
+	}
+	return target
+}
+
+func matchLabels(selector, labels map[interface{}]interface{}) bool {
+	for k, v := range selector {
+		if fmt.Sprint(labels[k]) != fmt.Sprint(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// resourceRequirements imports the cpu and memory of Kubernetes resource
+// requirements, the cpu from millis to a decimal number of cpus
+func resourceRequirements(r map[interface{}]interface{}) map[string]interface{} {
+	res := map[string]interface{}{}
+	cpus := str(r, "cpu")
+	if millis, err := strconv.Atoi(strings.TrimSuffix(cpus, "m")); err == nil && strings.HasSuffix(cpus, "m") {
+		cpus = strconv.FormatFloat(float64(millis)/1000, 'f', -1, 64)
+	}
+	set(res, "cpus", cpus)
+	set(res, "memory", str(r, "memory"))
+	return res
+}
+
+// constraints imports a node selector as placement constraints
+func constraints(nodeSelector map[interface{}]interface{}) []interface{} {
+	var constraints []interface{}
+	for _, key := range sortedFields(nodeSelector) {
+		node := "node.labels." + key
+		switch key {
+		case "kubernetes.io/hostname":
+			node = "node.hostname"
+		case "kubernetes.io/os", "beta.kubernetes.io/os":
+			node = "node.platform.os"
+		case "kubernetes.io/arch", "beta.kubernetes.io/arch":
+			node = "node.platform.arch"
+		}
+		constraints = append(constraints, fmt.Sprintf("%s == %v", node, nodeSelector[key]))
+	}
+	return constraints
+}
+
+func durationSeconds(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return fmt.Sprintf("%vs", v)
+}
+
+// objectKeys returns the sorted keys of a config map or secret
+func objectKeys(m Manifest) []string {
+	keys := map[string]bool{}
+	for _, data := range []string{"data", "stringData", "binaryData"} {
+		for _, key := range sortedFields(dict(m.Object, data)) {
+			keys[key] = true
+		}
+	}
+	return sortedKeys(keys)
+}
+
+func sortedFields(m map[interface{}]interface{}) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, fmt.Sprint(k))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// set sets the value of a compose field, unless it is empty
+func set(m map[string]interface{}, key string, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		return
+	case string:
+		if v == "" {
+			return
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			return
+		}
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return
+		}
+	}
+	m[key] = value
+}
+
+// field returns the value at the path of the generic object, or nil
+func field(o interface{}, path ...string) interface{} {
+	for _, key := range path {
+		m, ok := o.(map[interface{}]interface{})
+		if !ok {
+			return nil
+		}
+		o = m[key]
+	}
+	return o
+}
+
+func dict(o interface{}, path ...string) map[interface{}]interface{} {
+	m, _ := field(o, path...).(map[interface{}]interface{})
+	return m
+}
+
+func list(o interface{}, path ...string) []interface{} {
+	l, _ := field(o, path...).([]interface{})
+	return l
+}
+
+func str(o interface{}, path ...string) string {
+	v := field(o, path...)
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
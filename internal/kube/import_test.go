@@ -0,0 +1,208 @@
+package kube
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+const manifests = `apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  replicas: ${db.replicas}
+  selector:
+    matchLabels:
+      app: db
+  template:
+    metadata:
+      labels:
+        app: db
+    spec:
+      nodeSelector:
+        kubernetes.io/os: linux
+        disk: ssd
+      containers:
+      - name: db
+        image: postgres
+        args: ["-c", "max_connections=10"]
+        ports:
+        - name: pg
+          containerPort: 5432
+        env:
+        - name: PGDATA
+          value: /data
+        - name: POD
+          valueFrom:
+            fieldRef:
+              fieldPath: metadata.name
+        livenessProbe:
+          exec:
+            command: ["pg_isready"]
+          periodSeconds: 10
+          failureThreshold: 3
+        resources:
+          requests:
+            cpu: 250m
+            memory: 128Mi
+        securityContext:
+          runAsUser: 999
+        volumeMounts:
+        - name: data
+          mountPath: /data
+        - name: conf
+          mountPath: /etc/postgres
+          readOnly: true
+        - name: password
+          mountPath: /run/secrets/password
+          subPath: password
+      - name: exporter
+        image: exporter
+      volumes:
+      - name: conf
+        configMap:
+          name: conf
+      - name: password
+        secret:
+          secretName: db
+  volumeClaimTemplates:
+  - metadata:
+      name: data
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: database
+spec:
+  selector:
+    app: db
+  ports:
+  - port: 5432
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: db-published
+  spec:
+    type: LoadBalancer
+    selector:
+      app: db
+    ports:
+    - port: ${db.port}
+      targetPort: pg
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: conf
+  data:
+    a.conf: a
+    b.conf: b
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: db
+  data:
+    password: c2VjcmV0
+- apiVersion: batch/v1
+  kind: Job
+  metadata:
+    name: migrate
+`
+
+func TestToCompose(t *testing.T) {
+	m, err := ReadManifests("manifests.yaml", []byte(manifests))
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(m, 6))
+	imported, err := ToCompose(m)
+	assert.NilError(t, err)
+
+	assert.Check(t, is.DeepEqual(imported.Services, map[string]interface{}{
+		"db": map[string]interface{}{
+			"image":       "postgres",
+			"command":     []interface{}{"-c", "max_connections=10"},
+			"environment": map[string]interface{}{"PGDATA": "/data"},
+			"healthcheck": map[string]interface{}{
+				"test":     []interface{}{"CMD", "pg_isready"},
+				"interval": "10s",
+				"retries":  3,
+			},
+			"user": "999",
+			"deploy": map[string]interface{}{
+				"replicas": "${db.replicas}",
+				"resources": map[string]interface{}{
+					"reservations": map[string]interface{}{"cpus": "0.25", "memory": "128Mi"},
+				},
+				"placement": map[string]interface{}{
+					"constraints": []interface{}{"node.labels.disk == ssd", "node.platform.os == linux"},
+				},
+			},
+			"volumes": []interface{}{"data:/data"},
+			"configs": []interface{}{
+				map[string]interface{}{"source": "conf-a.conf", "target": "/etc/postgres/a.conf"},
+				map[string]interface{}{"source": "conf-b.conf", "target": "/etc/postgres/b.conf"},
+			},
+			"secrets": []interface{}{
+				map[string]interface{}{"source": "db-password", "target": "/run/secrets/password"},
+			},
+			"ports": []interface{}{"${db.port}:5432"},
+		},
+	}))
+	assert.Check(t, is.DeepEqual(imported.Volumes, map[string]interface{}{"data": map[string]interface{}{}}))
+	assert.Check(t, is.DeepEqual(imported.Configs, map[string]interface{}{
+		"conf-a.conf": map[string]interface{}{"file": "./configs/conf-a.conf"},
+		"conf-b.conf": map[string]interface{}{"file": "./configs/conf-b.conf"},
+	}))
+	assert.Check(t, is.DeepEqual(imported.Files, map[string][]byte{
+		"configs/conf-a.conf": []byte("a"),
+		"configs/conf-b.conf": []byte("b"),
+		"secrets/db-password": []byte("secret"),
+	}))
+	assert.Check(t, is.DeepEqual(imported.Unsupported, []string{
+		"Job migrate in manifests.yaml is not supported",
+		"StatefulSet db in manifests.yaml: container exporter is left out, only the first container of a pod is imported",
+		"StatefulSet db in manifests.yaml: environment variable POD: valueFrom is not supported",
+		"Service database in manifests.yaml: the service is reachable as db instead",
+	}))
+}
+
+func TestReadManifestsInvalid(t *testing.T) {
+	_, err := ReadManifests("invalid.yaml", []byte("foo: bar\n"))
+	assert.Check(t, is.ErrorContains(err, "invalid.yaml holds a document which is not a kubernetes object"))
+}
+
+func TestToComposeInvalidFileObjects(t *testing.T) {
+	testCases := []struct {
+		name     string
+		manifest string
+		expected string
+	}{
+		{
+			name:     "key-escaping",
+			manifest: "kind: ConfigMap\nmetadata:\n  name: nginx\ndata:\n  ../../../../tmp/pwned: x\n",
+			expected: `ConfigMap nginx in invalid.yaml: invalid key "../../../../tmp/pwned"`,
+		},
+		{
+			name:     "dot-dot-key",
+			manifest: "kind: Secret\nmetadata:\n  name: password\nstringData:\n  ..: x\n",
+			expected: `Secret password in invalid.yaml: invalid key ".."`,
+		},
+		{
+			name:     "invalid-name",
+			manifest: "kind: Secret\nmetadata:\n  name: ../password\ndata:\n  key: eA==\n",
+			expected: "Secret ../password in invalid.yaml: invalid name",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := ReadManifests("invalid.yaml", []byte(tc.manifest))
+			assert.NilError(t, err)
+			_, err = ToCompose(m)
+			assert.Check(t, is.ErrorContains(err, tc.expected))
+		})
+	}
+}
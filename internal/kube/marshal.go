@@ -0,0 +1,37 @@
+package kube
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Marshal marshals the objects to a multi-document YAML stream
+func Marshal(objects []runtime.Object) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for i, o := range objects {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		raw, err := yaml.Marshal(o)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal kubernetes object")
+		}
+		buf.Write(raw)
+	}
+	return buf.Bytes(), nil
+}
+
+// sortedKeys returns the sorted keys of a map with string keys
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,303 @@
+package local
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	composetypes "github.com/docker/cli/cli/compose/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/strslice"
+	"github.com/docker/go-connections/nat"
+	units "github.com/docker/go-units"
+	"github.com/pkg/errors"
+)
+
+// secretsDir is the directory the secrets are mounted in, unless their target
+// is absolute
+const secretsDir = "/run/secrets"
+
+// containerConfig converts the service config to the config of its containers.
+// Secrets and configs are mounted read-only from their file, as the engine
+// only stores them in swarm mode.
+func containerConfig(project string, config *composetypes.Config, s composetypes.ServiceConfig) (*container.Config, *container.HostConfig, error) {
+	c := &container.Config{
+		Hostname:     s.Hostname,
+		Domainname:   s.DomainName,
+		User:         s.User,
+		Tty:          s.Tty,
+		OpenStdin:    s.StdinOpen,
+		Env:          environment(s.Environment),
+		Cmd:          strslice.StrSlice(s.Command),
+		Entrypoint:   strslice.StrSlice(s.Entrypoint),
+		Image:        s.Image,
+		WorkingDir:   s.WorkingDir,
+		MacAddress:   s.MacAddress,
+		StopSignal:   s.StopSignal,
+		Labels:       map[string]string{},
+		ExposedPorts: nat.PortSet{},
+		Healthcheck:  healthcheck(s.HealthCheck),
+	}
+	for k, v := range s.Labels {
+		c.Labels[k] = v
+	}
+	if s.StopGracePeriod != nil {
+		timeout := int(time.Duration(*s.StopGracePeriod).Seconds())
+		c.StopTimeout = &timeout
+	}
+	h := &container.HostConfig{
+		CapAdd:         strslice.StrSlice(s.CapAdd),
+		CapDrop:        strslice.StrSlice(s.CapDrop),
+		DNS:            s.DNS,
+		DNSSearch:      s.DNSSearch,
+		ExtraHosts:     s.ExtraHosts,
+		Init:           s.Init,
+		IpcMode:        container.IpcMode(s.Ipc),
+		PidMode:        container.PidMode(s.Pid),
+		Privileged:     s.Privileged,
+		ReadonlyRootfs: s.ReadOnly,
+		SecurityOpt:    s.SecurityOpt,
+		UsernsMode:     container.UsernsMode(s.UserNSMode),
+		Isolation:      container.Isolation(s.Isolation),
+		PortBindings:   nat.PortMap{},
+		Sysctls:        map[string]string{},
+		Tmpfs:          map[string]string{},
+	}
+	if strings.HasPrefix(s.NetworkMode, "service:") {
+		service := composetypes.ServiceConfig{Name: strings.TrimPrefix(s.NetworkMode, "service:")}
+		for _, other := range config.Services {
+			if other.Name == service.Name {
+				service = other
+			}
+		}
+		h.NetworkMode = container.NetworkMode("container:" + ContainerName(project, service, 1))
+	} else {
+		h.NetworkMode = container.NetworkMode(s.NetworkMode)
+	}
+	if s.Logging != nil {
+		h.LogConfig = container.LogConfig{Type: s.Logging.Driver, Config: s.Logging.Options}
+	}
+	for _, p := range s.Ports {
+		port, err := nat.NewPort(protocol(p.Protocol), strconv.Itoa(int(p.Target)))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid port of service %s", s.Name)
+		}
+		c.ExposedPorts[port] = struct{}{}
+		binding := nat.PortBinding{}
+		if p.Published != 0 {
+			binding.HostPort = strconv.Itoa(int(p.Published))
+		}
+		h.PortBindings[port] = append(h.PortBindings[port], binding)
+	}
+	for _, e := range s.Expose {
+		parts := strings.SplitN(e, "/", 2)
+		port, err := nat.NewPort(protocol(strings.Join(parts[1:], "")), parts[0])
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid exposed port of service %s", s.Name)
+		}
+		c.ExposedPorts[port] = struct{}{}
+	}
+	for _, sysctl := range s.Sysctls {
+		parts := strings.SplitN(sysctl, "=", 2)
+		h.Sysctls[parts[0]] = strings.Join(parts[1:], "")
+	}
+	for _, tmpfs := range s.Tmpfs {
+		parts := strings.SplitN(tmpfs, ":", 2)
+		h.Tmpfs[parts[0]] = strings.Join(parts[1:], "")
+	}
+	if s.ShmSize != "" {
+		size, err := units.RAMInBytes(s.ShmSize)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid shm_size of service %s", s.Name)
+		}
+		h.ShmSize = size
+	}
+	for _, name := range sortedUlimits(s.Ulimits) {
+		l := s.Ulimits[name]
+		if l.Single != 0 {
+			h.Ulimits = append(h.Ulimits, &units.Ulimit{Name: name, Soft: int64(l.Single), Hard: int64(l.Single)})
+			continue
+		}
+		h.Ulimits = append(h.Ulimits, &units.Ulimit{Name: name, Soft: int64(l.Soft), Hard: int64(l.Hard)})
+	}
+	restart, err := restartPolicy(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	h.RestartPolicy = restart
+	if err := setResources(h, s); err != nil {
+		return nil, nil, err
+	}
+	for _, v := range s.Volumes {
+		h.Mounts = append(h.Mounts, volumeMount(project, config, v))
+	}
+	for _, ref := range s.Secrets {
+		m, err := fileMount("secret", composetypes.FileObjectConfig(config.Secrets[ref.Source]), composetypes.FileReferenceConfig(ref), secretsDir)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "service %s", s.Name)
+		}
+		h.Mounts = append(h.Mounts, m)
+	}
+	for _, ref := range s.Configs {
+		m, err := fileMount("config", composetypes.FileObjectConfig(config.Configs[ref.Source]), composetypes.FileReferenceConfig(ref), "/")
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "service %s", s.Name)
+		}
+		h.Mounts = append(h.Mounts, m)
+	}
+	return c, h, nil
+}
+
+func protocol(p string) string {
+	if p == "" {
+		return "tcp"
+	}
+	return p
+}
+
+func environment(env composetypes.MappingWithEquals) []string {
+	var vars []string
+	for k, v := range env {
+		if v != nil {
+			vars = append(vars, k+"="+*v)
+		}
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+func sortedUlimits(ulimits map[string]*composetypes.UlimitsConfig) []string {
+	var names []string
+	for name := range ulimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func healthcheck(h *composetypes.HealthCheckConfig) *container.HealthConfig {
+	if h == nil {
+		return nil
+	}
+	if h.Disable {
+		return &container.HealthConfig{Test: []string{"NONE"}}
+	}
+	config := &container.HealthConfig{Test: h.Test}
+	if h.Interval != nil {
+		config.Interval = time.Duration(*h.Interval)
+	}
+	if h.Timeout != nil {
+		config.Timeout = time.Duration(*h.Timeout)
+	}
+	if h.StartPeriod != nil {
+		config.StartPeriod = time.Duration(*h.StartPeriod)
+	}
+	if h.Retries != nil {
+		config.Retries = int(*h.Retries)
+	}
+	return config
+}
+
+// restartPolicy converts the restart option of the service, or else the
+// restart policy of its deploy config
+func restartPolicy(s composetypes.ServiceConfig) (container.RestartPolicy, error) {
+	if s.Restart != "" {
+		parts := strings.SplitN(s.Restart, ":", 2)
+		policy := container.RestartPolicy{Name: parts[0]}
+		switch {
+		case len(parts) == 2 && policy.Name == "on-failure":
+			count, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return policy, errors.Errorf("invalid restart policy %q of service %s", s.Restart, s.Name)
+			}
+			policy.MaximumRetryCount = count
+		case len(parts) == 2:
+			return policy, errors.Errorf("invalid restart policy %q of service %s", s.Restart, s.Name)
+		}
+		return policy, nil
+	}
+	p := s.Deploy.RestartPolicy
+	if p == nil {
+		return container.RestartPolicy{}, nil
+	}
+	switch p.Condition {
+	case "", "any":
+		return container.RestartPolicy{Name: "always"}, nil
+	case "on-failure":
+		policy := container.RestartPolicy{Name: "on-failure"}
+		if p.MaxAttempts != nil {
+			policy.MaximumRetryCount = int(*p.MaxAttempts)
+		}
+		return policy, nil
+	case "none":
+		return container.RestartPolicy{Name: "no"}, nil
+	}
+	return container.RestartPolicy{}, errors.Errorf("invalid restart condition %q of service %s", p.Condition, s.Name)
+}
+
+func setResources(h *container.HostConfig, s composetypes.ServiceConfig) error {
+	if limits := s.Deploy.Resources.Limits; limits != nil {
+		if limits.NanoCPUs != "" {
+			cpus, err := strconv.ParseFloat(limits.NanoCPUs, 64)
+			if err != nil {
+				return errors.Errorf("invalid cpus limit %q of service %s", limits.NanoCPUs, s.Name)
+			}
+			h.NanoCPUs = int64(cpus * 1e9)
+		}
+		h.Memory = int64(limits.MemoryBytes)
+	}
+	if reservations := s.Deploy.Resources.Reservations; reservations != nil {
+		h.MemoryReservation = int64(reservations.MemoryBytes)
+	}
+	return nil
+}
+
+func volumeMount(project string, config *composetypes.Config, v composetypes.ServiceVolumeConfig) mount.Mount {
+	m := mount.Mount{
+		Type:        mount.Type(v.Type),
+		Source:      v.Source,
+		Target:      v.Target,
+		ReadOnly:    v.ReadOnly,
+		Consistency: mount.Consistency(v.Consistency),
+	}
+	switch v.Type {
+	case "volume":
+		if v.Source != "" {
+			m.Source = VolumeName(project, config, v.Source)
+		}
+		if v.Volume != nil {
+			m.VolumeOptions = &mount.VolumeOptions{NoCopy: v.Volume.NoCopy}
+		}
+	case "bind":
+		if v.Bind != nil {
+			m.BindOptions = &mount.BindOptions{Propagation: mount.Propagation(v.Bind.Propagation)}
+		}
+	case "tmpfs":
+		if v.Tmpfs != nil {
+			m.TmpfsOptions = &mount.TmpfsOptions{SizeBytes: v.Tmpfs.Size}
+		}
+	}
+	return m
+}
+
+// fileMount mounts the file of a secret or a config, at its target relative
+// to the given directory
+func fileMount(kind string, obj composetypes.FileObjectConfig, ref composetypes.FileReferenceConfig, dir string) (mount.Mount, error) {
+	switch {
+	case obj.External.External:
+		return mount.Mount{}, errors.Errorf("%s %s is external, which is only supported in swarm mode", kind, ref.Source)
+	case obj.File == "":
+		return mount.Mount{}, errors.Errorf("%s %s has no file", kind, ref.Source)
+	}
+	target := ref.Target
+	if target == "" {
+		target = ref.Source
+	}
+	if !path.IsAbs(target) {
+		target = path.Join(dir, target)
+	}
+	return mount.Mount{Type: mount.TypeBind, Source: obj.File, Target: target, ReadOnly: true}, nil
+}
@@ -0,0 +1,53 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+)
+
+// Down stops and removes the containers and the networks of the project, and
+// its volumes if removeVolumes is set. External networks and volumes are left
+// alone, as they are not labeled with the project.
+func Down(ctx context.Context, client Client, project string, removeVolumes bool, out io.Writer) error {
+	list, err := containers(ctx, client, project)
+	if err != nil {
+		return err
+	}
+	for _, c := range list {
+		fmt.Fprintf(out, "Removing %s\n", nameOf(c))
+		if err := removeContainer(ctx, client, c, removeVolumes); err != nil {
+			return err
+		}
+	}
+	networks, err := client.NetworkList(ctx, types.NetworkListOptions{Filters: projectFilter(project)})
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the networks of %s", project)
+	}
+	sort.Slice(networks, func(i, j int) bool { return networks[i].Name < networks[j].Name })
+	for _, n := range networks {
+		fmt.Fprintf(out, "Removing network %s\n", n.Name)
+		if err := client.NetworkRemove(ctx, n.ID); err != nil {
+			return errors.Wrapf(err, "failed to remove network %s", n.Name)
+		}
+	}
+	if !removeVolumes {
+		return nil
+	}
+	volumes, err := client.VolumeList(ctx, projectFilter(project))
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the volumes of %s", project)
+	}
+	sort.Slice(volumes.Volumes, func(i, j int) bool { return volumes.Volumes[i].Name < volumes.Volumes[j].Name })
+	for _, v := range volumes.Volumes {
+		fmt.Fprintf(out, "Removing volume %s\n", v.Name)
+		if err := client.VolumeRemove(ctx, v.Name, false); err != nil {
+			return errors.Wrapf(err, "failed to remove volume %s", v.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	composetypes "github.com/docker/cli/cli/compose/types"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	volumetypes "github.com/docker/docker/api/types/volume"
+	"github.com/pkg/errors"
+)
+
+// The labels are the ones of docker-compose, so that it can also manage the
+// applications run by docker-app
+const (
+	// LabelProject is the label of the containers, networks and volumes of
+	// a project
+	LabelProject = "com.docker.compose.project"
+	// LabelService is the label naming the service of a container
+	LabelService = "com.docker.compose.service"
+	// LabelNumber is the label numbering the containers of a service
+	LabelNumber = "com.docker.compose.container-number"
+	// LabelConfigHash is the label of the hash of the service config a
+	// container was created from
+	LabelConfigHash = "com.docker.compose.config-hash"
+	// LabelOneOff is the label docker-compose sets to "True" on the
+	// containers of its run command
+	LabelOneOff = "com.docker.compose.oneoff"
+	// LabelNetwork is the label naming the network of a project network
+	LabelNetwork = "com.docker.compose.network"
+	// LabelVolume is the label naming the volume of a project volume
+	LabelVolume = "com.docker.compose.volume"
+	// LabelAppName is the label of the name of the application
+	LabelAppName = "com.docker.app.name"
+	// LabelAppVersion is the label of the version of the application
+	LabelAppVersion = "com.docker.app.version"
+
+	// defaultNetwork is the network of the services declaring none
+	defaultNetwork = "default"
+)
+
+// Client is the part of the Engine API client used to run an application on
+// a single engine
+type Client interface {
+	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, containerName string) (container.ContainerCreateCreatedBody, error)
+	ContainerInspect(ctx context.Context, container string) (types.ContainerJSON, error)
+	ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error)
+	ContainerLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error)
+	ContainerRemove(ctx context.Context, container string, options types.ContainerRemoveOptions) error
+	ContainerStart(ctx context.Context, container string, options types.ContainerStartOptions) error
+	ContainerStop(ctx context.Context, container string, timeout *time.Duration) error
+	ImageInspectWithRaw(ctx context.Context, image string) (types.ImageInspect, []byte, error)
+	ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error)
+	NetworkConnect(ctx context.Context, network, container string, config *network.EndpointSettings) error
+	NetworkCreate(ctx context.Context, name string, options types.NetworkCreate) (types.NetworkCreateResponse, error)
+	NetworkList(ctx context.Context, options types.NetworkListOptions) ([]types.NetworkResource, error)
+	NetworkRemove(ctx context.Context, network string) error
+	VolumeCreate(ctx context.Context, options volumetypes.VolumeCreateBody) (types.Volume, error)
+	VolumeList(ctx context.Context, filter filters.Args) (volumetypes.VolumeListOKBody, error)
+	VolumeRemove(ctx context.Context, volume string, force bool) error
+}
+
+// ContainerName is the name of the container of the given number of a
+// service, unless the service names its container
+func ContainerName(project string, service composetypes.ServiceConfig, number int) string {
+	if service.ContainerName != "" {
+		return service.ContainerName
+	}
+	return fmt.Sprintf("%s_%s_%d", project, service.Name, number)
+}
+
+// NetworkName is the engine name of a network of the config
+func NetworkName(project string, config *composetypes.Config, name string) string {
+	n := config.Networks[name]
+	return objectName(project, name, n.Name, n.External)
+}
+
+// VolumeName is the engine name of a volume of the config
+func VolumeName(project string, config *composetypes.Config, name string) string {
+	v := config.Volumes[name]
+	return objectName(project, name, v.Name, v.External)
+}
+
+func objectName(project, key, name string, external composetypes.External) string {
+	switch {
+	case external.External && external.Name != "":
+		return external.Name
+	case name != "":
+		return name
+	case external.External:
+		return key
+	}
+	return project + "_" + key
+}
+
+func projectFilter(project string) filters.Args {
+	return filters.NewArgs(filters.Arg("label", LabelProject+"="+project))
+}
+
+// containers lists the containers of the project, sorted by service and number
+func containers(ctx context.Context, client Client, project string) ([]types.Container, error) {
+	list, err := client.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: projectFilter(project)})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the containers of %s", project)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if si, sj := list[i].Labels[LabelService], list[j].Labels[LabelService]; si != sj {
+			return si < sj
+		}
+		return containerNumber(list[i]) < containerNumber(list[j])
+	})
+	return list, nil
+}
+
+func containerNumber(c types.Container) int {
+	n, _ := strconv.Atoi(c.Labels[LabelNumber])
+	return n
+}
+
+func nameOf(c types.Container) string {
+	if len(c.Names) == 0 {
+		return c.ID
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// Ps prints the containers of the project
+func Ps(ctx context.Context, client Client, project string, out io.Writer) error {
+	list, err := containers(ctx, client, project)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSERVICE\tIMAGE\tSTATE\tPORTS")
+	for _, c := range list {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", nameOf(c), c.Labels[LabelService], c.Image, c.State, formatPorts(c.Ports))
+	}
+	return w.Flush()
+}
+
+func formatPorts(ports []types.Port) string {
+	var formatted []string
+	for _, p := range ports {
+		if p.PublicPort == 0 {
+			formatted = append(formatted, fmt.Sprintf("%d/%s", p.PrivatePort, p.Type))
+			continue
+		}
+		ip := p.IP
+		if ip == "" {
+			ip = "0.0.0.0"
+		}
+		formatted = append(formatted, fmt.Sprintf("%s:%d->%d/%s", ip, p.PublicPort, p.PrivatePort, p.Type))
+	}
+	sort.Strings(formatted)
+	return strings.Join(formatted, ", ")
+}
@@ -0,0 +1,324 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/app/types/metadata"
+	composetypes "github.com/docker/cli/cli/compose/types"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	volumetypes "github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+type notFound string
+
+func (e notFound) Error() string  { return "no such image: " + string(e) }
+func (e notFound) NotFound() bool { return true }
+
+type fakeContainer struct {
+	types.Container
+	config     *container.Config
+	hostConfig *container.HostConfig
+	networks   []string
+	logs       string
+}
+
+// fakeEngine implements the container, network and volume operations of the
+// Engine API
+type fakeEngine struct {
+	images     map[string]bool
+	pulled     []string
+	containers map[string]*fakeContainer
+	networks   map[string]types.NetworkResource
+	volumes    map[string]*types.Volume
+	// created are the names of the created containers, in order
+	created []string
+	nextID  int
+}
+
+func newFakeEngine(images ...string) *fakeEngine {
+	e := &fakeEngine{
+		images:     map[string]bool{},
+		containers: map[string]*fakeContainer{},
+		networks:   map[string]types.NetworkResource{},
+		volumes:    map[string]*types.Volume{},
+	}
+	for _, image := range images {
+		e.images[image] = true
+	}
+	return e
+}
+
+func (e *fakeEngine) id() string {
+	e.nextID++
+	return fmt.Sprintf("id%d", e.nextID)
+}
+
+func (e *fakeEngine) byName(name string) *fakeContainer {
+	for _, c := range e.containers {
+		if c.Names[0] == "/"+name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (e *fakeEngine) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (container.ContainerCreateCreatedBody, error) {
+	if e.byName(name) != nil {
+		return container.ContainerCreateCreatedBody{}, fmt.Errorf("conflict: %s is in use", name)
+	}
+	c := &fakeContainer{
+		Container:  types.Container{ID: e.id(), Names: []string{"/" + name}, Image: config.Image, Labels: config.Labels, State: "created"},
+		config:     config,
+		hostConfig: hostConfig,
+	}
+	if networkingConfig != nil {
+		c.networks = append(c.networks, string(hostConfig.NetworkMode))
+	}
+	e.containers[c.ID] = c
+	e.created = append(e.created, name)
+	return container.ContainerCreateCreatedBody{ID: c.ID}, nil
+}
+
+func (e *fakeEngine) ContainerInspect(ctx context.Context, id string) (types.ContainerJSON, error) {
+	return types.ContainerJSON{Config: e.containers[id].config}, nil
+}
+
+func (e *fakeEngine) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	var list []types.Container
+	for _, c := range e.containers {
+		if options.Filters.MatchKVList("label", c.Labels) {
+			list = append(list, c.Container)
+		}
+	}
+	return list, nil
+}
+
+func (e *fakeEngine) ContainerLogs(ctx context.Context, id string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	buf := &bytes.Buffer{}
+	if _, err := stdcopy.NewStdWriter(buf, stdcopy.Stdout).Write([]byte(e.containers[id].logs)); err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(buf), nil
+}
+
+func (e *fakeEngine) ContainerRemove(ctx context.Context, id string, options types.ContainerRemoveOptions) error {
+	if e.containers[id].State == "running" {
+		return fmt.Errorf("container %s is running", id)
+	}
+	delete(e.containers, id)
+	return nil
+}
+
+func (e *fakeEngine) ContainerStart(ctx context.Context, id string, options types.ContainerStartOptions) error {
+	e.containers[id].State = "running"
+	return nil
+}
+
+func (e *fakeEngine) ContainerStop(ctx context.Context, id string, timeout *time.Duration) error {
+	e.containers[id].State = "exited"
+	return nil
+}
+
+func (e *fakeEngine) ImageInspectWithRaw(ctx context.Context, image string) (types.ImageInspect, []byte, error) {
+	if !e.images[image] {
+		return types.ImageInspect{}, nil, notFound(image)
+	}
+	return types.ImageInspect{ID: image}, nil, nil
+}
+
+func (e *fakeEngine) ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error) {
+	e.pulled = append(e.pulled, ref)
+	e.images[ref] = true
+	return ioutil.NopCloser(strings.NewReader(`{"status":"Downloaded newer image"}`)), nil
+}
+
+func (e *fakeEngine) NetworkConnect(ctx context.Context, network, id string, config *network.EndpointSettings) error {
+	e.containers[id].networks = append(e.containers[id].networks, network)
+	return nil
+}
+
+func (e *fakeEngine) NetworkCreate(ctx context.Context, name string, options types.NetworkCreate) (types.NetworkCreateResponse, error) {
+	id := e.id()
+	e.networks[id] = types.NetworkResource{ID: id, Name: name, Driver: options.Driver, Labels: options.Labels}
+	return types.NetworkCreateResponse{ID: id}, nil
+}
+
+func (e *fakeEngine) NetworkList(ctx context.Context, options types.NetworkListOptions) ([]types.NetworkResource, error) {
+	var list []types.NetworkResource
+	for _, n := range e.networks {
+		if options.Filters.MatchKVList("label", n.Labels) {
+			list = append(list, n)
+		}
+	}
+	return list, nil
+}
+
+func (e *fakeEngine) NetworkRemove(ctx context.Context, id string) error {
+	delete(e.networks, id)
+	return nil
+}
+
+func (e *fakeEngine) VolumeCreate(ctx context.Context, options volumetypes.VolumeCreateBody) (types.Volume, error) {
+	e.volumes[options.Name] = &types.Volume{Name: options.Name, Driver: options.Driver, Labels: options.Labels}
+	return *e.volumes[options.Name], nil
+}
+
+func (e *fakeEngine) VolumeList(ctx context.Context, filter filters.Args) (volumetypes.VolumeListOKBody, error) {
+	var list volumetypes.VolumeListOKBody
+	for _, v := range e.volumes {
+		if filter.MatchKVList("label", v.Labels) {
+			list.Volumes = append(list.Volumes, v)
+		}
+	}
+	return list, nil
+}
+
+func (e *fakeEngine) VolumeRemove(ctx context.Context, name string, force bool) error {
+	delete(e.volumes, name)
+	return nil
+}
+
+func (e *fakeEngine) networkNames() []string {
+	var names []string
+	for _, n := range e.networks {
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+func testConfig(webImage string, webReplicas uint64) *composetypes.Config {
+	return &composetypes.Config{
+		Services: composetypes.Services{
+			{
+				Name:      "web",
+				Image:     webImage,
+				DependsOn: []string{"db"},
+				Ports:     []composetypes.ServicePortConfig{{Target: 80, Published: 8080, Protocol: "tcp"}},
+				Networks:  map[string]*composetypes.ServiceNetworkConfig{"front": {Aliases: []string{"www"}}, "back": nil},
+				Secrets:   []composetypes.ServiceSecretConfig{{Source: "password"}},
+				Deploy:    composetypes.DeployConfig{Replicas: &webReplicas},
+			},
+			{
+				Name:     "db",
+				Image:    "postgres",
+				Networks: map[string]*composetypes.ServiceNetworkConfig{"back": nil},
+				Volumes:  []composetypes.ServiceVolumeConfig{{Type: "volume", Source: "data", Target: "/var/lib/postgresql/data"}},
+			},
+		},
+		Networks: map[string]composetypes.NetworkConfig{"front": {}, "back": {}},
+		Volumes:  map[string]composetypes.VolumeConfig{"data": {}},
+		Secrets:  map[string]composetypes.SecretConfig{"password": {File: "/app/password.txt"}},
+	}
+}
+
+func TestUp(t *testing.T) {
+	ctx := context.Background()
+	engine := newFakeEngine("nginx")
+	app := metadata.AppMetadata{Name: "myapp", Version: "0.1.0"}
+	out := &bytes.Buffer{}
+
+	assert.NilError(t, Up(ctx, engine, "myapp", app, testConfig("nginx", 2), out))
+	assert.Check(t, is.DeepEqual(engine.created, []string{"myapp_db_1", "myapp_web_1", "myapp_web_2"}))
+	assert.Check(t, is.DeepEqual(engine.pulled, []string{"postgres"}))
+	assert.Check(t, is.Len(engine.networks, 2))
+	assert.Check(t, is.Contains(engine.networkNames(), "myapp_front"))
+	assert.Check(t, is.Contains(engine.volumes, "myapp_data"))
+
+	web := engine.byName("myapp_web_1")
+	assert.Check(t, is.Equal(web.State, "running"))
+	assert.Check(t, is.Equal(web.Labels[LabelProject], "myapp"))
+	assert.Check(t, is.Equal(web.Labels[LabelService], "web"))
+	assert.Check(t, is.Equal(web.Labels[LabelNumber], "1"))
+	assert.Check(t, is.Equal(web.Labels[LabelAppName], "myapp"))
+	assert.Check(t, is.Equal(web.Labels[LabelAppVersion], "0.1.0"))
+	assert.Check(t, is.DeepEqual(web.networks, []string{"myapp_back", "myapp_front"}))
+	assert.Check(t, is.DeepEqual(web.hostConfig.PortBindings, nat.PortMap{"80/tcp": {{HostPort: "8080"}}}))
+	assert.Check(t, is.DeepEqual(web.hostConfig.Mounts, []mount.Mount{
+		{Type: mount.TypeBind, Source: "/app/password.txt", Target: "/run/secrets/password", ReadOnly: true},
+	}))
+	db := engine.byName("myapp_db_1")
+	assert.Check(t, is.DeepEqual(db.hostConfig.Mounts, []mount.Mount{
+		{Type: mount.TypeVolume, Source: "myapp_data", Target: "/var/lib/postgresql/data"},
+	}))
+
+	// nothing changed
+	out.Reset()
+	assert.NilError(t, Up(ctx, engine, "myapp", app, testConfig("nginx", 2), out))
+	assert.Check(t, is.Len(engine.created, 3))
+	assert.Check(t, is.Equal(out.String(), "myapp_db_1 is up-to-date\nmyapp_web_1 is up-to-date\nmyapp_web_2 is up-to-date\n"))
+
+	// the web service changed and is scaled down
+	out.Reset()
+	assert.NilError(t, Up(ctx, engine, "myapp", app, testConfig("nginx:alpine", 1), out))
+	assert.Check(t, is.Equal(out.String(), `myapp_db_1 is up-to-date
+Removing myapp_web_2
+Pulling nginx:alpine
+Recreating myapp_web_1
+`))
+	assert.Check(t, is.Equal(engine.byName("myapp_web_1").Image, "nginx:alpine"))
+	assert.Check(t, is.Len(engine.containers, 2))
+
+	// the db service is no longer in the config
+	out.Reset()
+	config := testConfig("nginx:alpine", 1)
+	config.Services = config.Services[:1]
+	config.Services[0].DependsOn = nil
+	assert.NilError(t, Up(ctx, engine, "myapp", app, config, out))
+	assert.Check(t, is.Equal(out.String(), "Recreating myapp_web_1\nRemoving orphan container myapp_db_1\n"))
+
+	out.Reset()
+	assert.NilError(t, Down(ctx, engine, "myapp", false, out))
+	assert.Check(t, is.Len(engine.containers, 0))
+	assert.Check(t, is.Len(engine.networks, 0))
+	assert.Check(t, is.Contains(engine.volumes, "myapp_data"))
+	assert.Check(t, is.Equal(out.String(), "Removing myapp_web_1\nRemoving network myapp_back\nRemoving network myapp_front\n"))
+}
+
+func TestUpDependencies(t *testing.T) {
+	config := &composetypes.Config{Services: composetypes.Services{
+		{Name: "a", DependsOn: []string{"b"}},
+		{Name: "b", DependsOn: []string{"c"}},
+		{Name: "c", DependsOn: []string{"a"}},
+	}}
+	err := Up(context.Background(), newFakeEngine(), "myapp", metadata.AppMetadata{}, config, ioutil.Discard)
+	assert.Check(t, is.Error(err, "circular dependency between services: a -> b -> c -> a"))
+
+	config.Services[2].DependsOn = []string{"d"}
+	err = Up(context.Background(), newFakeEngine(), "myapp", metadata.AppMetadata{}, config, ioutil.Discard)
+	assert.Check(t, is.Error(err, "service c depends on undefined service d"))
+}
+
+func TestLogs(t *testing.T) {
+	ctx := context.Background()
+	engine := newFakeEngine("nginx", "postgres")
+	config := testConfig("nginx", 1)
+	assert.NilError(t, Up(ctx, engine, "myapp", metadata.AppMetadata{}, config, ioutil.Discard))
+	engine.byName("myapp_web_1").logs = "GET /\nGET /favicon.ico"
+	engine.byName("myapp_db_1").logs = "ready\n"
+
+	out := &bytes.Buffer{}
+	assert.NilError(t, Logs(ctx, engine, "myapp", nil, LogsOptions{}, out))
+	assert.Check(t, is.Equal(out.String(), `myapp_db_1  | ready
+myapp_web_1 | GET /
+myapp_web_1 | GET /favicon.ico
+`))
+
+	out.Reset()
+	assert.NilError(t, Logs(ctx, engine, "myapp", []string{"db"}, LogsOptions{}, out))
+	assert.Check(t, is.Equal(out.String(), "myapp_db_1 | ready\n"))
+}
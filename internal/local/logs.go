@@ -0,0 +1,140 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/docker/app/internal/slices"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/pkg/errors"
+)
+
+// LogsOptions are the options of Logs
+type LogsOptions struct {
+	// Follow keeps streaming the logs until the context is done
+	Follow bool
+	// Tail is the number of lines to show from the end of the logs of each
+	// container (default: all)
+	Tail string
+	// Timestamps shows the timestamps of the lines
+	Timestamps bool
+}
+
+// Logs prints the logs of the containers of the project, or only of those of
+// the given services, each line prefixed with the name of its container. The
+// logs of the containers are printed one container after the other, unless
+// following them.
+func Logs(ctx context.Context, client Client, project string, services []string, opts LogsOptions, out io.Writer) error {
+	list, err := containers(ctx, client, project)
+	if err != nil {
+		return err
+	}
+	var selected []types.Container
+	width := 0
+	for _, c := range list {
+		if len(services) != 0 && !slices.ContainsString(services, c.Labels[LabelService]) {
+			continue
+		}
+		selected = append(selected, c)
+		if len(nameOf(c)) > width {
+			width = len(nameOf(c))
+		}
+	}
+	lock := &sync.Mutex{}
+	writer := func(c types.Container) *prefixWriter {
+		return &prefixWriter{lock: lock, out: out, prefix: fmt.Sprintf("%-*s | ", width, nameOf(c))}
+	}
+	if !opts.Follow {
+		for _, c := range selected {
+			if err := containerLogs(ctx, client, c, opts, writer(c)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	errs := make(chan error, len(selected))
+	for _, c := range selected {
+		go func(c types.Container) {
+			errs <- containerLogs(ctx, client, c, opts, writer(c))
+		}(c)
+	}
+	var firstErr error
+	for range selected {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func containerLogs(ctx context.Context, client Client, c types.Container, opts LogsOptions, w *prefixWriter) error {
+	inspect, err := client.ContainerInspect(ctx, c.ID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to inspect container %s", nameOf(c))
+	}
+	logs, err := client.ContainerLogs(ctx, c.ID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     opts.Follow,
+		Tail:       opts.Tail,
+		Timestamps: opts.Timestamps,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the logs of container %s", nameOf(c))
+	}
+	defer logs.Close()
+	// the logs of containers without a tty multiplex stdout and stderr
+	if inspect.Config != nil && inspect.Config.Tty {
+		_, err = io.Copy(w, logs)
+	} else {
+		_, err = stdcopy.StdCopy(w, w, logs)
+	}
+	if err != nil && ctx.Err() == nil {
+		return errors.Wrapf(err, "failed to read the logs of container %s", nameOf(c))
+	}
+	return w.flush()
+}
+
+// prefixWriter writes complete lines with a prefix, the writers of several
+// containers sharing the same lock
+type prefixWriter struct {
+	lock   *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := w.writeLine(w.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// flush writes the last line, if not terminated by a new line
+func (w *prefixWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := append(w.buf, '\n')
+	w.buf = nil
+	return w.writeLine(line)
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	_, err := fmt.Fprintf(w.out, "%s%s", w.prefix, line)
+	return err
+}
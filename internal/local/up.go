@@ -0,0 +1,406 @@
+package local
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/app/types/metadata"
+	composetypes "github.com/docker/cli/cli/compose/types"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	volumetypes "github.com/docker/docker/api/types/volume"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/pkg/errors"
+)
+
+// Up runs the rendered config on the engine under the given project name. It
+// creates the networks and volumes of the project, then the containers of the
+// services in the order of their dependencies, as many as their replicas, and
+// starts them. Containers created from a service config which changed since
+// are recreated, and those of services no longer in the config are removed.
+func Up(ctx context.Context, client Client, project string, app metadata.AppMetadata, config *composetypes.Config, out io.Writer) error {
+	services, err := sortServices(config.Services)
+	if err != nil {
+		return err
+	}
+	if err := createNetworks(ctx, client, project, config, out); err != nil {
+		return err
+	}
+	if err := createVolumes(ctx, client, project, config, out); err != nil {
+		return err
+	}
+	existing, err := containers(ctx, client, project)
+	if err != nil {
+		return err
+	}
+	byService := map[string][]types.Container{}
+	for _, c := range existing {
+		byService[c.Labels[LabelService]] = append(byService[c.Labels[LabelService]], c)
+	}
+	for _, s := range services {
+		u := serviceUp{client: client, project: project, app: app, config: config, service: s, out: out}
+		if err := u.run(ctx, byService[s.Name]); err != nil {
+			return err
+		}
+		delete(byService, s.Name)
+	}
+	for _, c := range existing {
+		if _, orphan := byService[c.Labels[LabelService]]; orphan {
+			fmt.Fprintf(out, "Removing orphan container %s\n", nameOf(c))
+			if err := removeContainer(ctx, client, c, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sortServices sorts the services so that each one comes after the services it
+// depends on, or whose network it uses
+func sortServices(services composetypes.Services) ([]composetypes.ServiceConfig, error) {
+	byName := map[string]composetypes.ServiceConfig{}
+	var names []string
+	for _, s := range services {
+		byName[s.Name] = s
+		names = append(names, s.Name)
+	}
+	sort.Strings(names)
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := map[string]int{}
+	var sorted []composetypes.ServiceConfig
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return errors.Errorf("circular dependency between services: %s", strings.Join(append(path, name), " -> "))
+		}
+		s, ok := byName[name]
+		if !ok {
+			return errors.Errorf("service %s depends on undefined service %s", path[len(path)-1], name)
+		}
+		state[name] = visiting
+		deps := append([]string{}, s.DependsOn...)
+		if strings.HasPrefix(s.NetworkMode, "service:") {
+			deps = append(deps, strings.TrimPrefix(s.NetworkMode, "service:"))
+		}
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		sorted = append(sorted, s)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// serviceNetworks returns the networks of the service, sorted, unless it uses
+// a network mode
+func serviceNetworks(s composetypes.ServiceConfig) []string {
+	if s.NetworkMode != "" {
+		return nil
+	}
+	if len(s.Networks) == 0 {
+		return []string{defaultNetwork}
+	}
+	var names []string
+	for name := range s.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func createNetworks(ctx context.Context, client Client, project string, config *composetypes.Config, out io.Writer) error {
+	used := map[string]bool{}
+	for _, s := range config.Services {
+		for _, name := range serviceNetworks(s) {
+			used[name] = true
+		}
+	}
+	var keys []string
+	for key := range used {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	existing, err := client.NetworkList(ctx, types.NetworkListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list networks")
+	}
+	found := map[string]bool{}
+	for _, n := range existing {
+		found[n.Name] = true
+	}
+	for _, key := range keys {
+		n := config.Networks[key]
+		name := NetworkName(project, config, key)
+		switch {
+		case found[name]:
+			continue
+		case n.External.External:
+			return errors.Errorf("network %s is external, but could not be found", name)
+		}
+		fmt.Fprintf(out, "Creating network %s\n", name)
+		options := types.NetworkCreate{
+			CheckDuplicate: true,
+			Driver:         n.Driver,
+			Options:        n.DriverOpts,
+			Internal:       n.Internal,
+			Attachable:     n.Attachable,
+			Labels:         projectLabels(project, LabelNetwork, key, n.Labels),
+		}
+		if options.Driver == "" {
+			options.Driver = "bridge"
+		}
+		if n.Ipam.Driver != "" || len(n.Ipam.Config) != 0 {
+			options.IPAM = &network.IPAM{Driver: n.Ipam.Driver}
+			for _, pool := range n.Ipam.Config {
+				options.IPAM.Config = append(options.IPAM.Config, network.IPAMConfig{Subnet: pool.Subnet})
+			}
+		}
+		if _, err := client.NetworkCreate(ctx, name, options); err != nil {
+			return errors.Wrapf(err, "failed to create network %s", name)
+		}
+	}
+	return nil
+}
+
+func createVolumes(ctx context.Context, client Client, project string, config *composetypes.Config, out io.Writer) error {
+	used := map[string]bool{}
+	for _, s := range config.Services {
+		for _, v := range s.Volumes {
+			if v.Type == "volume" && v.Source != "" {
+				used[v.Source] = true
+			}
+		}
+	}
+	var keys []string
+	for key := range used {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	existing, err := client.VolumeList(ctx, filters.NewArgs())
+	if err != nil {
+		return errors.Wrap(err, "failed to list volumes")
+	}
+	found := map[string]bool{}
+	for _, v := range existing.Volumes {
+		found[v.Name] = true
+	}
+	for _, key := range keys {
+		v, ok := config.Volumes[key]
+		if !ok {
+			return errors.Errorf("volume %s is not declared", key)
+		}
+		name := VolumeName(project, config, key)
+		switch {
+		case found[name]:
+			continue
+		case v.External.External:
+			return errors.Errorf("volume %s is external, but could not be found", name)
+		}
+		fmt.Fprintf(out, "Creating volume %s\n", name)
+		if _, err := client.VolumeCreate(ctx, volumetypes.VolumeCreateBody{
+			Name:       name,
+			Driver:     v.Driver,
+			DriverOpts: v.DriverOpts,
+			Labels:     projectLabels(project, LabelVolume, key, v.Labels),
+		}); err != nil {
+			return errors.Wrapf(err, "failed to create volume %s", name)
+		}
+	}
+	return nil
+}
+
+func projectLabels(project, label, key string, labels composetypes.Labels) map[string]string {
+	merged := map[string]string{LabelProject: project, label: key}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return merged
+}
+
+// serviceUp brings up the containers of a service
+type serviceUp struct {
+	client  Client
+	project string
+	app     metadata.AppMetadata
+	config  *composetypes.Config
+	service composetypes.ServiceConfig
+	out     io.Writer
+}
+
+func (u *serviceUp) run(ctx context.Context, existing []types.Container) error {
+	s := u.service
+	replicas := 1
+	if s.Deploy.Replicas != nil && s.Deploy.Mode != "global" {
+		replicas = int(*s.Deploy.Replicas)
+	}
+	if s.ContainerName != "" && replicas > 1 {
+		return errors.Errorf("service %s names its container %s, it can't have %d replicas", s.Name, s.ContainerName, replicas)
+	}
+	hash, err := configHash(s)
+	if err != nil {
+		return err
+	}
+	byNumber := map[int]types.Container{}
+	for _, c := range existing {
+		if n := containerNumber(c); n >= 1 && n <= replicas {
+			byNumber[n] = c
+			continue
+		}
+		fmt.Fprintf(u.out, "Removing %s\n", nameOf(c))
+		if err := removeContainer(ctx, u.client, c, false); err != nil {
+			return err
+		}
+	}
+	if replicas > 0 {
+		if err := pullImage(ctx, u.client, s.Image, u.out); err != nil {
+			return err
+		}
+	}
+	for n := 1; n <= replicas; n++ {
+		name := ContainerName(u.project, s, n)
+		c, ok := byNumber[n]
+		switch {
+		case ok && c.Labels[LabelConfigHash] == hash:
+			if c.State == "running" {
+				fmt.Fprintf(u.out, "%s is up-to-date\n", name)
+				continue
+			}
+			fmt.Fprintf(u.out, "Starting %s\n", name)
+			if err := u.client.ContainerStart(ctx, c.ID, types.ContainerStartOptions{}); err != nil {
+				return errors.Wrapf(err, "failed to start container %s", name)
+			}
+			continue
+		case ok:
+			fmt.Fprintf(u.out, "Recreating %s\n", name)
+			if err := removeContainer(ctx, u.client, c, false); err != nil {
+				return err
+			}
+		default:
+			fmt.Fprintf(u.out, "Creating %s\n", name)
+		}
+		if err := u.create(ctx, name, n, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *serviceUp) create(ctx context.Context, name string, number int, hash string) error {
+	s := u.service
+	config, hostConfig, err := containerConfig(u.project, u.config, s)
+	if err != nil {
+		return err
+	}
+	config.Labels[LabelProject] = u.project
+	config.Labels[LabelService] = s.Name
+	config.Labels[LabelNumber] = strconv.Itoa(number)
+	config.Labels[LabelConfigHash] = hash
+	config.Labels[LabelOneOff] = "False"
+	config.Labels[LabelAppName] = u.app.Name
+	config.Labels[LabelAppVersion] = u.app.Version
+	networks := serviceNetworks(s)
+	var networking *network.NetworkingConfig
+	if len(networks) > 0 {
+		first := NetworkName(u.project, u.config, networks[0])
+		hostConfig.NetworkMode = container.NetworkMode(first)
+		networking = &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{
+			first: endpointSettings(s, networks[0]),
+		}}
+	}
+	created, err := u.client.ContainerCreate(ctx, config, hostConfig, networking, name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create container %s", name)
+	}
+	if len(networks) > 1 {
+		for _, n := range networks[1:] {
+			if err := u.client.NetworkConnect(ctx, NetworkName(u.project, u.config, n), created.ID, endpointSettings(s, n)); err != nil {
+				return errors.Wrapf(err, "failed to connect container %s to network %s", name, n)
+			}
+		}
+	}
+	if err := u.client.ContainerStart(ctx, created.ID, types.ContainerStartOptions{}); err != nil {
+		return errors.Wrapf(err, "failed to start container %s", name)
+	}
+	return nil
+}
+
+// endpointSettings makes the containers of the service reachable on the
+// network by the name of the service and its aliases
+func endpointSettings(s composetypes.ServiceConfig, key string) *network.EndpointSettings {
+	settings := &network.EndpointSettings{Aliases: []string{s.Name}}
+	if n := s.Networks[key]; n != nil {
+		settings.Aliases = append(settings.Aliases, n.Aliases...)
+		if n.Ipv4Address != "" || n.Ipv6Address != "" {
+			settings.IPAMConfig = &network.EndpointIPAMConfig{IPv4Address: n.Ipv4Address, IPv6Address: n.Ipv6Address}
+		}
+	}
+	return settings
+}
+
+// configHash is the hash of the service config, labeling its containers to
+// detect the changes
+func configHash(s composetypes.ServiceConfig) (string, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to hash the config of service %s", s.Name)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// pullImage pulls the image unless the engine already has it
+func pullImage(ctx context.Context, client Client, image string, out io.Writer) error {
+	_, _, err := client.ImageInspectWithRaw(ctx, image)
+	if err == nil {
+		return nil
+	}
+	if !dockerclient.IsErrNotFound(err) {
+		return errors.Wrapf(err, "failed to inspect image %s", image)
+	}
+	fmt.Fprintf(out, "Pulling %s\n", image)
+	r, err := client.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to pull image %s", image)
+	}
+	defer r.Close()
+	if err := jsonmessage.DisplayJSONMessagesStream(r, ioutil.Discard, 0, false, nil); err != nil {
+		return errors.Wrapf(err, "failed to pull image %s", image)
+	}
+	return nil
+}
+
+func removeContainer(ctx context.Context, client Client, c types.Container, removeVolumes bool) error {
+	if c.State == "running" {
+		if err := client.ContainerStop(ctx, c.ID, nil); err != nil {
+			return errors.Wrapf(err, "failed to stop container %s", nameOf(c))
+		}
+	}
+	if err := client.ContainerRemove(ctx, c.ID, types.ContainerRemoveOptions{RemoveVolumes: removeVolumes}); err != nil {
+		return errors.Wrapf(err, "failed to remove container %s", nameOf(c))
+	}
+	return nil
+}
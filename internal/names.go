@@ -2,6 +2,7 @@ package internal
 
 import (
 	"fmt"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -18,13 +19,51 @@ const (
 	ComposeFileName = "docker-compose.yml"
 	// SettingsFileName is settings file name
 	SettingsFileName = "settings.yml"
+	// SettingsSchemaFileName is the optional settings schema file name
+	SettingsSchemaFileName = "settings-schema.yml"
+	// ComposeOverlaysDir is the directory holding the optional compose overlays,
+	// applied in order on top of the compose file
+	ComposeOverlaysDir = "compose"
+	// NamedSettingsDir is the directory holding the optional named settings files
+	NamedSettingsDir = "settings"
+	// DependenciesLockFileName is the optional file pinning the dependencies
+	// to the digests of their resolved versions
+	DependenciesLockFileName = "dependencies.lock"
 )
 
 var (
 	// FileNames lists the application file names, in order.
 	FileNames = []string{MetadataFileName, ComposeFileName, SettingsFileName}
+	// OptionalFileNames lists the optional application files and directories.
+	OptionalFileNames = []string{SettingsSchemaFileName, ComposeOverlaysDir, NamedSettingsDir, DependenciesLockFileName}
 )
 
+var settingsNameRe = regexp.MustCompile("^[a-zA-Z0-9][a-zA-Z0-9_.-]*$")
+
+// ComposeOverlayFileName returns the path of the i-th (starting at 1) compose
+// overlay, relative to the application directory
+func ComposeOverlayFileName(i int) string {
+	return path.Join(ComposeOverlaysDir, fmt.Sprintf("%d.yml", i))
+}
+
+// NamedSettingsFileName returns the path of the given named settings file,
+// relative to the application directory
+func NamedSettingsFileName(name string) string {
+	return path.Join(NamedSettingsDir, name+".yml")
+}
+
+// ValidateSettingsName takes a named settings name and returns an error if it
+// doesn't match the expected format
+func ValidateSettingsName(name string) error {
+	if settingsNameRe.MatchString(name) {
+		return nil
+	}
+	return fmt.Errorf(
+		"invalid settings name: %q ; settings names must start with a letter or a number, and must contain only letters, numbers, '.', '-' and '_'",
+		name,
+	)
+}
+
 var appNameRe, _ = regexp.Compile("^[a-zA-Z][a-zA-Z0-9_-]+$")
 
 // AppNameFromDir takes a path to an app directory and returns
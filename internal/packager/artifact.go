@@ -0,0 +1,66 @@
+package packager
+
+import (
+	"path"
+
+	"github.com/docker/app/internal"
+	"github.com/docker/app/pkg/resto"
+	"github.com/docker/app/types"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Media types of the layers of an app pushed as an OCI artifact
+const (
+	MediaTypeMetadata         = "application/vnd.docker.app.metadata.v1+yaml"
+	MediaTypeCompose          = "application/vnd.docker.app.compose.v1+yaml"
+	MediaTypeSettings         = "application/vnd.docker.app.settings.v1+yaml"
+	MediaTypeSettingsSchema   = "application/vnd.docker.app.settings-schema.v1+yaml"
+	MediaTypeDependenciesLock = "application/vnd.docker.app.dependencies-lock.v1+yaml"
+)
+
+// appArtifact returns the files of the app as an OCI artifact, each with the
+// media type of its document, annotated with the app metadata
+func appArtifact(app *types.App) *resto.Artifact {
+	meta := app.Metadata()
+	a := &resto.Artifact{
+		Annotations: map[string]string{
+			ociv1.AnnotationTitle: meta.Name,
+		},
+	}
+	if meta.Version != "" {
+		a.Annotations[ociv1.AnnotationVersion] = meta.Version
+	}
+	if meta.Description != "" {
+		a.Annotations[ociv1.AnnotationDescription] = meta.Description
+	}
+	if len(meta.Maintainers) > 0 {
+		a.Annotations[ociv1.AnnotationAuthors] = meta.Maintainers.String()
+	}
+	for p, data := range app.Files() {
+		a.Files = append(a.Files, resto.ArtifactFile{
+			Path:      p,
+			MediaType: fileMediaType(p),
+			Content:   data,
+		})
+	}
+	return a
+}
+
+// fileMediaType returns the media type of the app file at the given
+// slash-separated path
+func fileMediaType(p string) string {
+	switch {
+	case p == internal.MetadataFileName:
+		return MediaTypeMetadata
+	case p == internal.ComposeFileName, path.Dir(p) == internal.ComposeOverlaysDir:
+		return MediaTypeCompose
+	case p == internal.SettingsFileName, path.Dir(p) == internal.NamedSettingsDir:
+		return MediaTypeSettings
+	case p == internal.SettingsSchemaFileName:
+		return MediaTypeSettingsSchema
+	case p == internal.DependenciesLockFileName:
+		return MediaTypeDependenciesLock
+	default:
+		return resto.MediaTypeArtifactFile
+	}
+}
@@ -0,0 +1,69 @@
+package packager
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/app/internal"
+	"github.com/docker/app/internal/registrytest"
+	"github.com/docker/app/loader"
+	"github.com/docker/app/pkg/resto"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func TestPushArtifact(t *testing.T) {
+	registry := registrytest.New()
+	defer registry.Close()
+	dir := fs.NewDir(t, "app.dockerapp",
+		fs.WithFile(internal.MetadataFileName, "name: app\nversion: 0.1.0\ndescription: my app\nmaintainers:\n  - name: foo\n    email: foo@bar.com\n"),
+		fs.WithFile(internal.ComposeFileName, "version: \"3.6\"\nservices:\n  web:\n    image: nginx\n"),
+		fs.WithFile(internal.SettingsFileName, "port: 80\n"),
+		fs.WithDir(internal.NamedSettingsDir, fs.WithFile("prod.yml", "port: 443\n")),
+	)
+	defer dir.Remove()
+	app, err := loader.LoadFromDirectory(dir.Path())
+	assert.NilError(t, err)
+
+	_, err = Push(app, registry.Host(), "", "app.dockerapp")
+	assert.NilError(t, err)
+	a, err := resto.PullArtifact(context.Background(), registry.Host()+"/app.dockerapp:0.1.0", resto.RegistryOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(a.Annotations, map[string]string{
+		ociv1.AnnotationTitle:       "app",
+		ociv1.AnnotationVersion:     "0.1.0",
+		ociv1.AnnotationDescription: "my app",
+		ociv1.AnnotationAuthors:     "foo <foo@bar.com>",
+	}))
+	mediaTypes := map[string]string{}
+	for _, f := range a.Files {
+		mediaTypes[f.Path] = f.MediaType
+	}
+	assert.Check(t, is.DeepEqual(mediaTypes, map[string]string{
+		internal.MetadataFileName: MediaTypeMetadata,
+		internal.ComposeFileName:  MediaTypeCompose,
+		internal.SettingsFileName: MediaTypeSettings,
+		"settings/prod.yml":       MediaTypeSettings,
+	}))
+
+	layout := fs.NewDir(t, "layout")
+	defer layout.Remove()
+	ref, _, err := PushLayout(app, layout.Path(), "myorg", "", "app.dockerapp")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(ref, "myorg/app.dockerapp:0.1.0"))
+	out := fs.NewDir(t, "out")
+	defer out.Remove()
+	appDir, err := PullLayout(layout.Path(), "", out.Path())
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(appDir, filepath.Join(out.Path(), "app.dockerapp")))
+	assert.Assert(t, fs.Equal(appDir, fs.Expected(t,
+		fs.MatchAnyFileMode,
+		fs.WithFile(internal.MetadataFileName, "", fs.MatchAnyFileContent),
+		fs.WithFile(internal.ComposeFileName, "", fs.MatchAnyFileContent),
+		fs.WithFile(internal.SettingsFileName, "port: 80\n"),
+		fs.WithDir(internal.NamedSettingsDir, fs.MatchAnyFileMode, fs.WithFile("prod.yml", "port: 443\n")),
+	)))
+}
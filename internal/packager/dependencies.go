@@ -0,0 +1,149 @@
+package packager
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+
+	"github.com/docker/app/internal/semver"
+	"github.com/docker/app/loader"
+	"github.com/docker/app/types"
+	"github.com/docker/app/types/metadata"
+	"github.com/pkg/errors"
+)
+
+// maxDependencyDepth is the maximum nesting of dependencies, dependencies
+// being allowed to have dependencies themselves
+const maxDependencyDepth = 5
+
+// WithDependencies pulls and loads the dependencies declared in the app
+// metadata, at the digests pinned by the app dependencies lock when it
+// satisfies their version constraint. Dependencies are pulled, stored and
+// verified as configured by the options, as the app itself.
+func WithDependencies(opts PullOptions) func(*types.App) error {
+	return withDependencies(optionsRegistry{opts: opts}, 0)
+}
+
+func withDependencies(registry registryClient, depth int) func(*types.App) error {
+	return func(app *types.App) error {
+		deps := app.Metadata().Dependencies
+		if len(deps) == 0 {
+			return nil
+		}
+		if depth >= maxDependencyDepth {
+			return errors.Errorf("too many nested dependencies (maximum %d)", maxDependencyDepth)
+		}
+		for _, dep := range deps {
+			if err := addDependency(registry, app, dep, depth); err != nil {
+				// the app is not returned on error, clean up the dependencies
+				// already pulled
+				for _, d := range app.Dependencies() {
+					d.App.Cleanup()
+				}
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func addDependency(registry registryClient, app *types.App, dep metadata.Dependency, depth int) error {
+	locked, err := resolveDependency(context.Background(), registry, dep, app.DependenciesLock(), false)
+	if err != nil {
+		return err
+	}
+	depApp, err := pullDependency(registry, locked, depth)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load dependency %q", dep.Name)
+	}
+	if err := types.WithDependency(dep.Name, depApp, dep.Settings)(app); err != nil {
+		depApp.Cleanup()
+		return err
+	}
+	return nil
+}
+
+func pullDependency(registry registryClient, locked metadata.LockedDependency, depth int) (*types.App, error) {
+	dir, err := ioutil.TempDir("", "dockerapp-dependency")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temporary directory")
+	}
+	path, err := registry.Pull(locked.Image+"@"+locked.Digest, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	app, err := loader.LoadFromDirectory(path,
+		types.WithName(locked.Name),
+		types.WithRemote(),
+		types.WithCleanup(func() { os.RemoveAll(dir) }),
+		withDependencies(registry, depth+1),
+	)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return app, nil
+}
+
+// resolveDependency returns the locked version of the dependency if it is
+// still valid, or the newest version satisfying its constraint ("latest" if it
+// has none). With update, the lock is ignored.
+func resolveDependency(ctx context.Context, registry registryClient, dep metadata.Dependency, lock *metadata.Lock, update bool) (metadata.LockedDependency, error) {
+	constraint, err := dep.Constraint()
+	if err != nil {
+		return metadata.LockedDependency{}, err
+	}
+	if locked, ok := lock.Find(dep.Name); ok && !update && locked.Image == dep.Image {
+		if constraint == nil {
+			return locked, nil
+		}
+		if v, err := semver.Parse(locked.Version); err == nil && constraint.Check(v) {
+			return locked, nil
+		}
+	}
+	version := "latest"
+	if constraint != nil {
+		tags, err := registry.ListTags(ctx, dep.Image)
+		if err != nil {
+			return metadata.LockedDependency{}, errors.Wrapf(err, "failed to list versions of dependency %q", dep.Name)
+		}
+		var ok bool
+		if version, ok = constraint.Latest(tags); !ok {
+			return metadata.LockedDependency{}, errors.Errorf("no version of dependency %q (%s) satisfies %q", dep.Name, dep.Image, dep.Version)
+		}
+	}
+	dgst, err := registry.ResolveDigest(ctx, dep.Image+":"+version)
+	if err != nil {
+		return metadata.LockedDependency{}, errors.Wrapf(err, "failed to resolve dependency %q", dep.Name)
+	}
+	return metadata.LockedDependency{
+		Name:    dep.Name,
+		Image:   dep.Image,
+		Version: version,
+		Digest:  dgst,
+	}, nil
+}
+
+// Lock resolves the dependencies of the app and returns the lock pinning them.
+// Dependencies already locked keep their version, unless update is set or the
+// lock doesn't satisfy their constraint anymore.
+func Lock(app *types.App, update bool) (*metadata.Lock, error) {
+	return lock(restoRegistry{}, app, update)
+}
+
+func lock(registry registryClient, app *types.App, update bool) (*metadata.Lock, error) {
+	result := &metadata.Lock{Dependencies: []metadata.LockedDependency{}}
+	if lock := app.DependenciesLock(); lock != nil {
+		// the images stay pinned
+		result.Images = lock.Images
+	}
+	for _, dep := range app.Metadata().Dependencies {
+		locked, err := resolveDependency(context.Background(), registry, dep, app.DependenciesLock(), update)
+		if err != nil {
+			return nil, err
+		}
+		result.Dependencies = append(result.Dependencies, locked)
+	}
+	return result, nil
+}
@@ -0,0 +1,154 @@
+package packager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/app/internal"
+	"github.com/docker/app/types"
+	"github.com/docker/app/types/metadata"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+type fakeRegistry struct {
+	tags    map[string][]string
+	digests map[string]string
+	apps    map[string]map[string]string
+	pulled  []string
+}
+
+func (r *fakeRegistry) ListTags(ctx context.Context, repo string) ([]string, error) {
+	return r.tags[repo], nil
+}
+
+func (r *fakeRegistry) ResolveDigest(ctx context.Context, repoTag string) (string, error) {
+	d, ok := r.digests[repoTag]
+	if !ok {
+		return "", errors.Errorf("%s not found", repoTag)
+	}
+	return d, nil
+}
+
+func (r *fakeRegistry) Pull(repoTag, outputDir string) (string, error) {
+	files, ok := r.apps[repoTag]
+	if !ok {
+		return "", errors.Errorf("%s not found", repoTag)
+	}
+	r.pulled = append(r.pulled, repoTag)
+	dir := filepath.Join(outputDir, "app.dockerapp")
+	for k, v := range files {
+		if err := writeAppFile(dir, k, []byte(v)); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+func (r *fakeRegistry) PullFiles(ctx context.Context, repoTag string) (map[string]string, error) {
+	files, ok := r.apps[repoTag]
+	if !ok {
+		return nil, errors.Errorf("%s not found", repoTag)
+	}
+	r.pulled = append(r.pulled, repoTag)
+	return files, nil
+}
+
+func newFakeRegistry() *fakeRegistry {
+	monitoring := func(version string) map[string]string {
+		return map[string]string{
+			internal.MetadataFileName: "name: monitoring\nversion: " + version,
+			internal.ComposeFileName:  "version: \"3.6\"\nservices:\n  prometheus:\n    image: prom/prometheus",
+			internal.SettingsFileName: "",
+		}
+	}
+	return &fakeRegistry{
+		tags: map[string][]string{
+			"myorg/monitoring.dockerapp": {"1.2.0", "1.3.0", "2.0.0", "latest"},
+		},
+		digests: map[string]string{
+			"myorg/monitoring.dockerapp:1.3.0":  "sha256:13",
+			"myorg/monitoring.dockerapp:2.0.0":  "sha256:20",
+			"myorg/monitoring.dockerapp:latest": "sha256:20",
+		},
+		apps: map[string]map[string]string{
+			"myorg/monitoring.dockerapp@sha256:12": monitoring("1.2.0"),
+			"myorg/monitoring.dockerapp@sha256:13": monitoring("1.3.0"),
+			"myorg/monitoring.dockerapp@sha256:20": monitoring("2.0.0"),
+		},
+	}
+}
+
+func TestResolveDependency(t *testing.T) {
+	registry := newFakeRegistry()
+	lock := &metadata.Lock{Dependencies: []metadata.LockedDependency{
+		{Name: "monitoring", Image: "myorg/monitoring.dockerapp", Version: "1.2.0", Digest: "sha256:12"},
+	}}
+	testCases := []struct {
+		name     string
+		version  string
+		lock     *metadata.Lock
+		update   bool
+		expected string
+	}{
+		{name: "constraint", version: "^1.2", expected: "1.3.0@sha256:13"},
+		{name: "no-constraint", expected: "latest@sha256:20"},
+		{name: "locked", version: "^1.2", lock: lock, expected: "1.2.0@sha256:12"},
+		{name: "locked-without-constraint", lock: lock, expected: "1.2.0@sha256:12"},
+		{name: "update", version: "^1.2", lock: lock, update: true, expected: "1.3.0@sha256:13"},
+		{name: "lock-not-satisfying", version: ">=2", lock: lock, expected: "2.0.0@sha256:20"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dep := metadata.Dependency{Name: "monitoring", Image: "myorg/monitoring.dockerapp", Version: tc.version}
+			locked, err := resolveDependency(context.Background(), registry, dep, tc.lock, tc.update)
+			assert.NilError(t, err)
+			assert.Check(t, is.Equal(locked.Version+"@"+locked.Digest, tc.expected))
+		})
+	}
+
+	dep := metadata.Dependency{Name: "monitoring", Image: "myorg/monitoring.dockerapp", Version: "^3"}
+	_, err := resolveDependency(context.Background(), registry, dep, nil, false)
+	assert.Check(t, is.ErrorContains(err, `no version of dependency "monitoring" (myorg/monitoring.dockerapp) satisfies "^3"`))
+}
+
+func TestWithDependencies(t *testing.T) {
+	registry := newFakeRegistry()
+	app, err := types.NewApp("shop",
+		types.Metadata(strings.NewReader(`name: shop
+version: 0.1.0
+dependencies:
+  - name: monitoring
+    image: myorg/monitoring.dockerapp
+    version: ~1.3
+    settings:
+      retention: 15d
+`)),
+		withDependencies(registry, 0),
+	)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(registry.pulled, []string{"myorg/monitoring.dockerapp@sha256:13"}))
+	deps := app.Dependencies()
+	assert.Assert(t, is.Len(deps, 1))
+	assert.Check(t, is.Equal(deps[0].Name, "monitoring"))
+	assert.Check(t, is.Equal(deps[0].App.Metadata().Version, "1.3.0"))
+	assert.Check(t, is.DeepEqual(deps[0].Settings, map[string]string{"retention": "15d"}))
+
+	// cleaning up the app removes the pulled dependencies
+	path := deps[0].App.Path
+	_, err = os.Stat(path)
+	assert.NilError(t, err)
+	app.Cleanup()
+	_, err = os.Stat(path)
+	assert.Check(t, os.IsNotExist(err))
+
+	l, err := lock(registry, app, false)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(l, &metadata.Lock{Dependencies: []metadata.LockedDependency{
+		{Name: "monitoring", Image: "myorg/monitoring.dockerapp", Version: "1.3.0", Digest: "sha256:13"},
+	}}))
+}
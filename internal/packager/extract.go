@@ -43,12 +43,35 @@ func findApp() (string, error) {
 	return filepath.Join(cwd, hit), nil
 }
 
+// Locate returns the path of the directory or file of the local application
+// of the given name, looked up in the current directory if empty. It fails
+// for applications which are not local, such as images.
+func Locate(name string) (string, error) {
+	switch name {
+	case "":
+		return findApp()
+	case ".":
+		cwd, err := os.Getwd()
+		return cwd, errors.Wrap(err, "cannot resolve current working directory")
+	}
+	path := internal.DirNameFromAppName(name)
+	if _, err := os.Stat(path); err != nil {
+		return "", errors.Errorf("%s is not a local application", name)
+	}
+	return path, nil
+}
+
 func appNameFromRef(ref reference.Named) string {
 	parts := strings.Split(ref.Name(), "/")
 	return internal.DirNameFromAppName(parts[len(parts)-1])
 }
 
 func imageNameFromRef(ref reference.Named) string {
+	if digested, ok := ref.(reference.Digested); ok {
+		newRef, _ := reference.WithName(internal.DirNameFromAppName(ref.Name()))
+		newDigestedRef, _ := reference.WithDigest(newRef, digested.Digest())
+		return newDigestedRef.String()
+	}
 	if tagged, ok := ref.(reference.Tagged); ok {
 		name := internal.DirNameFromAppName(ref.Name())
 		newRef, _ := reference.WithName(name)
@@ -59,7 +82,7 @@ func imageNameFromRef(ref reference.Named) string {
 }
 
 // extractImage extracts a docker application in a docker image to a temporary directory
-func extractImage(appname string, ops ...func(*types.App) error) (*types.App, error) {
+func extractImage(appname string, pull func(repotag, outputDir string) (string, error), ops ...func(*types.App) error) (*types.App, error) {
 	ref, err := reference.ParseNormalizedNamed(appname)
 	if err != nil {
 		return nil, err
@@ -72,20 +95,22 @@ func extractImage(appname string, ops ...func(*types.App) error) (*types.App, er
 		return nil, errors.Wrap(err, "failed to create temporary directory")
 	}
 	// Attempt loading image based on default name permutation
-	path, err := Pull(imagename, tempDir)
+	path, err := pull(imagename, tempDir)
 	if err != nil {
 		if literalImageName == imagename {
 			os.RemoveAll(tempDir)
 			return nil, err
 		}
 		// Attempt loading image based on the literal name
-		path, err = Pull(literalImageName, tempDir)
+		path, err = pull(literalImageName, tempDir)
 		if err != nil {
 			os.RemoveAll(tempDir)
 			return nil, err
 		}
 	}
-	ops = append(ops, types.WithName(appname), types.WithCleanup(func() { os.RemoveAll(tempDir) }))
+	// set the cleanup first, so that the operations can chain theirs
+	ops = append([]func(*types.App) error{types.WithCleanup(func() { os.RemoveAll(tempDir) })}, ops...)
+	ops = append(ops, types.WithName(appname), types.WithRemote())
 	return loader.LoadFromDirectory(path, ops...)
 }
 
@@ -94,6 +119,16 @@ func extractImage(appname string, ops ...func(*types.App) error) (*types.App, er
 // If appname is empty, it looks into cwd, and all subdirs for a single matching .dockerapp
 // If nothing is found, it looks for an image and loads it
 func Extract(name string, ops ...func(*types.App) error) (*types.App, error) {
+	return extract(name, Pull, ops...)
+}
+
+// ExtractWith is Extract, getting apps from a registry as configured by the
+// options
+func ExtractWith(name string, opts PullOptions, ops ...func(*types.App) error) (*types.App, error) {
+	return extract(name, opts.pull, ops...)
+}
+
+func extract(name string, pull func(repotag, outputDir string) (string, error), ops ...func(*types.App) error) (*types.App, error) {
 	if name == "" {
 		var err error
 		if name, err = findApp(); err != nil {
@@ -113,10 +148,10 @@ func Extract(name string, ops ...func(*types.App) error) (*types.App, error) {
 		// URL or docker image
 		u, err := url.Parse(name)
 		if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
-			return loader.LoadFromURL(name, ops...)
+			return loader.LoadFromURL(name, append(ops, types.WithRemote())...)
 		}
 		// look for a docker image
-		return extractImage(name, ops...)
+		return extractImage(name, pull, ops...)
 	}
 	if s.IsDir() {
 		// directory: already decompressed
@@ -8,6 +8,8 @@ import (
 	is "gotest.tools/assert/cmp"
 )
 
+const testDigest = "sha256:6e9b39914542abd7d9a6805d36fcbd10c5b6e334dc52a6f5ba11a6f51d8171fd"
+
 func TestImageAppNameFromRef(t *testing.T) {
 	refs := []struct {
 		ref       string
@@ -26,6 +28,8 @@ func TestImageAppNameFromRef(t *testing.T) {
 		{ref: "gcr.io/namespace/baz.dockerapp", appName: "baz.dockerapp", imageName: "gcr.io/namespace/baz.dockerapp"},
 		{ref: "gcr.io/namespace/baz:0.2.0", appName: "baz.dockerapp", imageName: "gcr.io/namespace/baz.dockerapp:0.2.0"},
 		{ref: "gcr.io/namespace/baz.dockerapp:0.2.0", appName: "baz.dockerapp", imageName: "gcr.io/namespace/baz.dockerapp:0.2.0"},
+		{ref: "namespace/bar@" + testDigest, appName: "bar.dockerapp", imageName: "docker.io/namespace/bar.dockerapp@" + testDigest},
+		{ref: "namespace/bar.dockerapp:0.2.0@" + testDigest, appName: "bar.dockerapp", imageName: "docker.io/namespace/bar.dockerapp@" + testDigest},
 	}
 	for _, r := range refs {
 		ref, err := reference.ParseNormalizedNamed(r.ref)
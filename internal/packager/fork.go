@@ -2,7 +2,6 @@ package packager
 
 import (
 	"context"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -40,7 +39,7 @@ func Fork(originName, forkName, outputDir string, maintainers []string) error {
 	// iterate on contents
 	for k, vs := range payload {
 		v := []byte(vs)
-		if strings.Contains(k, "/") || strings.Contains(k, "\\") {
+		if !isAppFilePath(k) {
 			log.Infof("dropping payload element with unexpected path separator: %s", k)
 			continue
 		}
@@ -51,9 +50,8 @@ func Fork(originName, forkName, outputDir string, maintainers []string) error {
 				return err
 			}
 		}
-		dest := filepath.Join(appPath, k)
-		log.Debugf("Writing file at %s", dest)
-		if err := ioutil.WriteFile(dest, v, 0644); err != nil {
+		log.Debugf("Writing file %s in %s", k, appPath)
+		if err := writeAppFile(appPath, k, v); err != nil {
 			return errors.Wrap(err, "error writing output file")
 		}
 	}
@@ -0,0 +1,160 @@
+package packager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/app/internal"
+	"github.com/docker/app/internal/helm"
+	"github.com/docker/app/internal/kube"
+	"github.com/docker/app/internal/yaml"
+	"github.com/docker/app/types"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ImportSource is the Helm chart or the Kubernetes manifests an application
+// is initialized from
+type ImportSource struct {
+	// HelmChart is the directory of the chart
+	HelmChart string
+	// Manifests are the manifest files, or directories of manifest files
+	Manifests []string
+}
+
+func (s ImportSource) isSet() bool {
+	return s.HelmChart != "" || len(s.Manifests) != 0
+}
+
+// importedApp is the application imported from a Helm chart or Kubernetes
+// manifests
+type importedApp struct {
+	version     string
+	description string
+	maintainers []string
+	compose     []byte
+	settings    []byte
+	files       map[string][]byte
+	unsupported []string
+}
+
+// importedCompose is the compose file of an imported application
+type importedCompose struct {
+	Version  string                 `yaml:"version"`
+	Services map[string]interface{} `yaml:"services"`
+	Volumes  map[string]interface{} `yaml:"volumes,omitempty"`
+	Configs  map[string]interface{} `yaml:"configs,omitempty"`
+	Secrets  map[string]interface{} `yaml:"secrets,omitempty"`
+}
+
+// importApp converts the Helm chart or the Kubernetes manifests of the source
+// to an application. The chart values become the settings.
+func importApp(name string, source ImportSource) (*importedApp, error) {
+	app := &importedApp{settings: []byte{'\n'}}
+	var manifests []kube.Manifest
+	if source.HelmChart != "" {
+		chart, err := helm.Import(source.HelmChart, name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to import Helm chart %s", source.HelmChart)
+		}
+		app.version = chart.Version
+		app.description = chart.Description
+		for _, m := range chart.Maintainers {
+			app.maintainers = append(app.maintainers, m.Name+":"+m.Email)
+		}
+		if len(chart.Values) != 0 {
+			app.settings = chart.Values
+		}
+		manifests = chart.Manifests
+		app.unsupported = chart.Unsupported
+	}
+	for _, path := range source.Manifests {
+		m, err := readManifests(path)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m...)
+	}
+	imported, err := kube.ToCompose(manifests)
+	if err != nil {
+		return nil, err
+	}
+	app.compose, err = yaml.Marshal(importedCompose{
+		Version:  types.NewInitialComposeFile().Version,
+		Services: imported.Services,
+		Volumes:  imported.Volumes,
+		Configs:  imported.Configs,
+		Secrets:  imported.Secrets,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal compose file")
+	}
+	app.files = imported.Files
+	app.unsupported = append(app.unsupported, imported.Unsupported...)
+	return app, nil
+}
+
+// readManifests reads the Kubernetes objects of a manifest file, or of the
+// YAML and JSON files of a directory
+func readManifests(path string) ([]kube.Manifest, error) {
+	files := []string{path}
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		files = nil
+		for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+			matches, err := filepath.Glob(filepath.Join(path, pattern))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+		}
+		sort.Strings(files)
+	}
+	var manifests []kube.Manifest
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read Kubernetes manifests")
+		}
+		m, err := kube.ReadManifests(f, data)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m...)
+	}
+	return manifests, nil
+}
+
+// initFromImport writes the compose file, settings and config and secret
+// files of the imported application, and reports what was left out
+func initFromImport(dirName string, app *importedApp) error {
+	log.Debug("init from import")
+	if err := ioutil.WriteFile(filepath.Join(dirName, internal.ComposeFileName), app.compose, 0644); err != nil {
+		return errors.Wrap(err, "failed to write docker-compose.yml")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dirName, internal.SettingsFileName), app.settings, 0644); err != nil {
+		return errors.Wrap(err, "failed to write settings.yml")
+	}
+	for file, data := range app.files {
+		path := filepath.Join(dirName, filepath.FromSlash(file))
+		if rel, err := filepath.Rel(dirName, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return errors.Errorf("refusing to write %s outside of the application", file)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			return errors.Wrapf(err, "failed to write %s", file)
+		}
+	}
+	if len(app.unsupported) != 0 {
+		fmt.Println("The following could not be imported and are left out:")
+		for _, u := range app.unsupported {
+			fmt.Printf("  - %s\n", u)
+		}
+	}
+	return nil
+}
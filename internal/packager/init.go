@@ -33,10 +33,33 @@ func prependToFile(filename, text string) error {
 
 // Init is the entrypoint initialization function.
 // It generates a new application package based on the provided parameters.
-func Init(name string, composeFile string, description string, maintainers []string, singleFile bool) error {
+func Init(name string, composeFile string, description string, maintainers []string, singleFile bool, source ImportSource) error {
 	if err := internal.ValidateAppName(name); err != nil {
 		return err
 	}
+	meta := newMetadata(name, description, maintainers)
+	var imported *importedApp
+	if source.isSet() {
+		if composeFile != "" {
+			return errors.New("a compose file can't be used along with a Helm chart or Kubernetes manifests")
+		}
+		var err error
+		if imported, err = importApp(internal.AppNameFromDir(name), source); err != nil {
+			return err
+		}
+		if singleFile && len(imported.files) != 0 {
+			return errors.New("the imported configs and secrets can't be part of a single-file application")
+		}
+		if imported.version != "" {
+			meta.Version = imported.version
+		}
+		if description == "" {
+			meta.Description = imported.description
+		}
+		if len(maintainers) == 0 && len(imported.maintainers) != 0 {
+			meta.Maintainers = parseMaintainersData(imported.maintainers)
+		}
+	}
 	dirName := internal.DirNameFromAppName(name)
 	if err := os.Mkdir(dirName, 0755); err != nil {
 		return errors.Wrap(err, "failed to create application directory")
@@ -47,18 +70,21 @@ func Init(name string, composeFile string, description string, maintainers []str
 			os.RemoveAll(dirName)
 		}
 	}()
-	if err = writeMetadataFile(name, dirName, description, maintainers); err != nil {
+	if err = writeMetadataFile(dirName, meta); err != nil {
 		return err
 	}
 
-	if composeFile == "" {
+	if composeFile == "" && imported == nil {
 		if _, err := os.Stat(internal.ComposeFileName); err == nil {
 			composeFile = internal.ComposeFileName
 		}
 	}
-	if composeFile == "" {
+	switch {
+	case imported != nil:
+		err = initFromImport(dirName, imported)
+	case composeFile == "":
 		err = initFromScratch(name)
-	} else {
+	default:
 		err = initFromComposeFile(name, composeFile)
 	}
 	if err != nil {
@@ -202,8 +228,7 @@ description: {{ .Description }}
 #    email: john@doe.com
 {{ end }}`
 
-func writeMetadataFile(name, dirName string, description string, maintainers []string) error {
-	meta := newMetadata(name, description, maintainers)
+func writeMetadataFile(dirName string, meta metadata.AppMetadata) error {
 	tmpl, err := template.New("metadata").Parse(metaTemplate)
 	if err != nil {
 		return errors.Wrap(err, "internal error parsing metadata template")
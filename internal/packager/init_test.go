@@ -97,7 +97,7 @@ func TestWriteMetadataFile(t *testing.T) {
 	tmpdir := fs.NewDir(t, appName)
 	defer tmpdir.Remove()
 
-	err := writeMetadataFile(appName, tmpdir.Path(), "", []string{"bearclaw:bearclaw"})
+	err := writeMetadataFile(tmpdir.Path(), newMetadata(appName, "", []string{"bearclaw:bearclaw"}))
 	assert.NilError(t, err)
 
 	data := `# Version of the application
@@ -4,22 +4,27 @@ import (
 	"archive/tar"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 
 	"github.com/docker/app/internal"
+	"github.com/docker/app/internal/image"
 	"github.com/docker/docker/pkg/archive"
 )
 
 func tarAdd(tarout *tar.Writer, path, file string) error {
-	payload, err := ioutil.ReadFile(file)
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s, err := f.Stat()
 	if err != nil {
 		return err
 	}
 	h := &tar.Header{
 		Name:     path,
-		Size:     int64(len(payload)),
+		Size:     s.Size(),
 		Mode:     0644,
 		Typeflag: tar.TypeReg,
 	}
@@ -27,10 +32,41 @@ func tarAdd(tarout *tar.Writer, path, file string) error {
 	if err != nil {
 		return err
 	}
-	_, err = tarout.Write(payload)
+	_, err = io.Copy(tarout, f)
 	return err
 }
 
+// tarAddDir adds the directory of the app to the tarball, recursively
+func tarAddDir(tarout *tar.Writer, appname, dir string) error {
+	if err := tarout.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     dir,
+		Mode:     0755,
+	}); err != nil {
+		return err
+	}
+	d, err := os.Open(filepath.Join(appname, dir))
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	files, err := d.Readdir(0)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			err = tarAddDir(tarout, appname, filepath.Join(dir, f.Name()))
+		} else {
+			err = tarAdd(tarout, filepath.Join(dir, f.Name()), filepath.Join(appname, dir, f.Name()))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Pack packs the app as a single file
 func Pack(appname string, target io.Writer) error {
 	tarout := tar.NewWriter(target)
@@ -40,32 +76,20 @@ func Pack(appname string, target io.Writer) error {
 			return err
 		}
 	}
-	// check for images
-	dir := "images"
-	_, err := os.Stat(filepath.Join(appname, dir))
-	if err == nil {
-		if err := tarout.WriteHeader(&tar.Header{
-			Typeflag: tar.TypeDir,
-			Name:     dir,
-			Mode:     0755,
-		}); err != nil {
-			return err
-		}
-		imageDir, err := os.Open(filepath.Join(appname, dir))
+	// check for optional files and directories, and images
+	for _, f := range append(internal.OptionalFileNames, image.LayoutDir) {
+		s, err := os.Stat(filepath.Join(appname, f))
 		if err != nil {
-			return err
+			continue
+		}
+		if s.IsDir() {
+			err = tarAddDir(tarout, appname, f)
+		} else {
+			err = tarAdd(tarout, f, filepath.Join(appname, f))
 		}
-		defer imageDir.Close()
-		images, err := imageDir.Readdirnames(0)
 		if err != nil {
 			return err
 		}
-		for _, i := range images {
-			err = tarAdd(tarout, filepath.Join(dir, i), filepath.Join(appname, dir, i))
-			if err != nil {
-				return err
-			}
-		}
 	}
 	return tarout.Close()
 }
@@ -0,0 +1,60 @@
+package packager
+
+import (
+	"context"
+	"sort"
+
+	"github.com/docker/app/render"
+	"github.com/docker/app/types"
+	"github.com/docker/app/types/metadata"
+	"github.com/docker/distribution/reference"
+	"github.com/pkg/errors"
+)
+
+// Pin resolves the images of the services of the app, rendered with the given
+// settings, to their digests, and returns the app lock pinning them. Images
+// already pinned keep their digest unless update is set, and images the
+// Compose file pins to a digest are left as is.
+func Pin(app *types.App, env map[string]string, update bool) (*metadata.Lock, error) {
+	return pin(restoRegistry{}, app, env, update)
+}
+
+func pin(registry registryClient, app *types.App, env map[string]string, update bool) (*metadata.Lock, error) {
+	// the images of the dependencies are pinned by their own lock
+	if len(app.Dependencies()) != 0 {
+		return nil, errors.New("cannot pin the images of an app loaded with its dependencies")
+	}
+	rendered, err := render.Render(app, env, render.WithRedactedSecrets())
+	if err != nil {
+		return nil, err
+	}
+	result := &metadata.Lock{Dependencies: []metadata.LockedDependency{}}
+	if lock := app.DependenciesLock(); lock != nil {
+		result.Dependencies = lock.Dependencies
+	}
+	services := rendered.Services
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	for _, service := range services {
+		image := service.Image
+		if locked, ok := app.DependenciesLock().FindImage(service.Name); ok && image == locked.Image+"@"+locked.Digest {
+			if !update {
+				result.Images = append(result.Images, locked)
+				continue
+			}
+			image = locked.Image
+		}
+		named, err := reference.ParseNormalizedNamed(image)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid image for service %q", service.Name)
+		}
+		if _, ok := named.(reference.Digested); ok {
+			continue
+		}
+		dgst, err := registry.ResolveDigest(context.Background(), image)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve the image of service %q", service.Name)
+		}
+		result.Images = append(result.Images, metadata.LockedImage{Service: service.Name, Image: image, Digest: dgst})
+	}
+	return result, nil
+}
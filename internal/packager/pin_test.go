@@ -0,0 +1,77 @@
+package packager
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/app/types"
+	"github.com/docker/app/types/metadata"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestPin(t *testing.T) {
+	registry := newFakeRegistry()
+	registry.digests["nginx:1.15"] = "sha256:15"
+	registry.digests["nginx:1.16"] = "sha256:16"
+	registry.digests["busybox"] = "sha256:b1"
+	newApp := func(lock string) *types.App {
+		app, err := types.NewApp("shop",
+			types.Metadata(strings.NewReader("name: shop\nversion: 0.1.0\n")),
+			types.WithComposes(strings.NewReader(`version: "3.6"
+services:
+  front:
+    image: nginx:${version}
+  back:
+    image: redis@sha256:0000000000000000000000000000000000000000000000000000000000000001
+  worker:
+    image: busybox
+`)),
+			types.WithSettings(strings.NewReader("version: \"1.15\"\n")),
+			types.DependenciesLock(strings.NewReader(lock)),
+		)
+		assert.NilError(t, err)
+		return app
+	}
+
+	l, err := pin(registry, newApp("dependencies: []\n"), nil, false)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(l, &metadata.Lock{
+		Dependencies: []metadata.LockedDependency{},
+		Images: []metadata.LockedImage{
+			{Service: "front", Image: "nginx:1.15", Digest: "sha256:15"},
+			{Service: "worker", Image: "busybox", Digest: "sha256:b1"},
+		},
+	}))
+
+	// pinned images keep their digest unless updated, or changed
+	locked := newApp(`dependencies:
+  - name: monitoring
+    image: myorg/monitoring.dockerapp
+    version: 1.3.0
+    digest: sha256:13
+images:
+  - service: front
+    image: nginx:1.15
+    digest: sha256:old
+`)
+	for _, tc := range []struct {
+		env      map[string]string
+		update   bool
+		expected metadata.LockedImage
+	}{
+		{expected: metadata.LockedImage{Service: "front", Image: "nginx:1.15", Digest: "sha256:old"}},
+		{update: true, expected: metadata.LockedImage{Service: "front", Image: "nginx:1.15", Digest: "sha256:15"}},
+		{env: map[string]string{"version": "1.16"}, expected: metadata.LockedImage{Service: "front", Image: "nginx:1.16", Digest: "sha256:16"}},
+	} {
+		l, err := pin(registry, locked, tc.env, tc.update)
+		assert.NilError(t, err)
+		assert.Check(t, is.Len(l.Dependencies, 1))
+		i, _ := l.FindImage("front")
+		assert.Check(t, is.DeepEqual(i, tc.expected))
+	}
+
+	registry.digests = map[string]string{}
+	_, err = pin(registry, newApp("dependencies: []\n"), nil, false)
+	assert.Check(t, is.ErrorContains(err, `failed to resolve the image of service "front"`))
+}
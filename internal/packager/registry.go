@@ -15,6 +15,43 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// registryClient is the registry API used to resolve and pull the
+// dependencies and parents of an app
+type registryClient interface {
+	ListTags(ctx context.Context, repo string) ([]string, error)
+	ResolveDigest(ctx context.Context, repoTag string) (string, error)
+	Pull(repoTag, outputDir string) (string, error)
+	PullFiles(ctx context.Context, repoTag string) (map[string]string, error)
+}
+
+type restoRegistry struct{}
+
+func (restoRegistry) ListTags(ctx context.Context, repo string) ([]string, error) {
+	return resto.ListTags(ctx, repo, resto.RegistryOptions{})
+}
+
+func (restoRegistry) ResolveDigest(ctx context.Context, repoTag string) (string, error) {
+	return resto.ResolveDigest(ctx, repoTag, resto.RegistryOptions{})
+}
+
+func (restoRegistry) Pull(repoTag, outputDir string) (string, error) {
+	return Pull(repoTag, outputDir)
+}
+
+func (restoRegistry) PullFiles(ctx context.Context, repoTag string) (map[string]string, error) {
+	return resto.PullConfigMulti(ctx, repoTag, resto.RegistryOptions{})
+}
+
+// optionsRegistry pulls apps as configured by the pull options
+type optionsRegistry struct {
+	restoRegistry
+	opts PullOptions
+}
+
+func (r optionsRegistry) Pull(repoTag, outputDir string) (string, error) {
+	return r.opts.pull(repoTag, outputDir)
+}
+
 type imageComponents struct {
 	Name       string
 	Repository string
@@ -42,6 +79,33 @@ func Pull(repotag string, outputDir string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	return writeApp(repotag, outputDir, payload)
+}
+
+// PullLayout loads an app from an OCI image layout directory and returns the
+// extracted dir name. The reference name may be omitted if the layout holds a
+// single app.
+func PullLayout(layoutDir, ref, outputDir string) (string, error) {
+	a, err := resto.ReadLayout(layoutDir, ref)
+	if err != nil {
+		return "", err
+	}
+	if ref == "" {
+		entries, err := resto.ListLayout(layoutDir)
+		if err != nil {
+			return "", err
+		}
+		if len(entries) == 0 {
+			return "", errors.Errorf("the app of %s has no reference name", layoutDir)
+		}
+		ref = entries[0].Ref
+	}
+	return writeApp(ref, outputDir, a.Payload())
+}
+
+// writeApp writes the app files to a directory of the output directory, named
+// after the repository of the app, and returns its path
+func writeApp(repotag, outputDir string, payload map[string]string) (string, error) {
 	repoComps, err := splitImageName(repotag)
 	if err != nil {
 		return "", err
@@ -53,24 +117,61 @@ func Pull(repotag string, outputDir string) (string, error) {
 	}
 	for k, v := range payload {
 		// do not write files in any other directory
-		if strings.Contains(k, "/") || strings.Contains(k, "\\") {
+		if !isAppFilePath(k) {
 			log.Warnf("dropping image entry '%s' with unexpected path separator", k)
 			continue
 		}
-		target := filepath.Join(appDir, k)
-		if err := ioutil.WriteFile(target, []byte(v), 0644); err != nil {
+		if err := writeAppFile(appDir, k, []byte(v)); err != nil {
 			return "", errors.Wrap(err, "failed to write output file")
 		}
 	}
 	return appDir, nil
 }
 
+// isAppFilePath checks the given slash-separated path is either at the root of
+// the app, or directly inside one of the app directories (compose overlays and
+// named settings)
+func isAppFilePath(p string) bool {
+	if strings.Contains(p, "\\") {
+		return false
+	}
+	parts := strings.Split(p, "/")
+	switch len(parts) {
+	case 1:
+		return parts[0] != "" && parts[0] != "." && parts[0] != ".."
+	case 2:
+		return (parts[0] == internal.ComposeOverlaysDir || parts[0] == internal.NamedSettingsDir) &&
+			parts[1] != "" && parts[1] != "." && parts[1] != ".."
+	default:
+		return false
+	}
+}
+
+func writeAppFile(appDir, p string, data []byte) error {
+	target := filepath.Join(appDir, filepath.FromSlash(p))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(target, data, 0644)
+}
+
 // Push pushes an app to a registry. Returns the image digest.
 func Push(app *types.App, namespace, tag, repo string) (string, error) {
-	payload := make(map[string]string)
-	payload[internal.MetadataFileName] = string(app.MetadataRaw())
-	payload[internal.ComposeFileName] = string(app.Composes()[0])
-	payload[internal.SettingsFileName] = string(app.SettingsRaw()[0])
+	return resto.PushArtifact(context.Background(), appArtifact(app), ImageName(app, namespace, tag, repo), resto.RegistryOptions{})
+}
+
+// PushLayout writes an app to an OCI image layout directory, under the name
+// it would be pushed to a registry with. Returns the reference name and the
+// manifest digest.
+func PushLayout(app *types.App, layoutDir, namespace, tag, repo string) (string, string, error) {
+	ref := ImageName(app, namespace, tag, repo)
+	dgst, err := resto.WriteLayout(layoutDir, ref, appArtifact(app))
+	return ref, dgst, err
+}
+
+// ImageName returns the name of the image the app is pushed to, defaulting to
+// the namespace and version of the app metadata
+func ImageName(app *types.App, namespace, tag, repo string) string {
 	if namespace == "" || tag == "" {
 		metadata := app.Metadata()
 		if namespace == "" {
@@ -86,6 +187,5 @@ func Push(app *types.App, namespace, tag, repo string) (string, error) {
 	if namespace != "" && namespace[len(namespace)-1] != '/' {
 		namespace += "/"
 	}
-	imageName := namespace + repo + ":" + tag
-	return resto.PushConfigMulti(context.Background(), payload, imageName, resto.RegistryOptions{}, nil)
+	return namespace + repo + ":" + tag
 }
@@ -41,3 +41,12 @@ func TestSplitImageName(t *testing.T) {
 		assert.ErrorContains(t, err, "failed to parse image name", item)
 	}
 }
+
+func TestIsAppFilePath(t *testing.T) {
+	for _, valid := range []string{"metadata.yml", "compose/1.yml", "settings/prod.yml"} {
+		assert.Check(t, isAppFilePath(valid), valid)
+	}
+	for _, invalid := range []string{"", "..", "images/web", "compose/../../etc", "settings/..", "a\\b", "compose/sub/1.yml"} {
+		assert.Check(t, !isAppFilePath(invalid), invalid)
+	}
+}
@@ -0,0 +1,103 @@
+package packager
+
+import (
+	"context"
+	"strings"
+
+	"github.com/docker/app/internal"
+	"github.com/docker/app/pkg/resto"
+	"github.com/docker/distribution/reference"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// RemoteApp describes a version of an app pushed to a registry
+type RemoteApp struct {
+	Tag         string
+	Digest      string
+	Version     string
+	Description string
+	Maintainers string
+}
+
+// ListRemote describes the versions of the app pushed to the repository,
+// signatures excluded. The metadata is read from the annotations of OCI
+// artifacts, and pulled for apps pushed in other formats.
+func ListRemote(repo string) ([]RemoteApp, error) {
+	named, err := reference.ParseNormalizedNamed(repo)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse repository name")
+	}
+	if !reference.IsNameOnly(named) {
+		return nil, errors.Errorf("%s is not a repository name: it has a tag or a digest", repo)
+	}
+	tags, err := resto.ListTags(context.Background(), named.Name(), resto.RegistryOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var apps []RemoteApp
+	for _, tag := range tags {
+		if strings.HasSuffix(tag, ".sig") {
+			continue
+		}
+		app, err := describeRemote(named.Name(), tag)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to describe %s:%s", named.Name(), tag)
+		}
+		apps = append(apps, app)
+	}
+	return apps, nil
+}
+
+func describeRemote(repo, tag string) (RemoteApp, error) {
+	dgst, annotations, err := resto.DescribeArtifact(context.Background(), repo+":"+tag, resto.RegistryOptions{})
+	if err != nil {
+		return RemoteApp{}, err
+	}
+	app := RemoteApp{Tag: tag, Digest: dgst}
+	if annotations != nil {
+		app.Version = annotations[ociv1.AnnotationVersion]
+		app.Description = annotations[ociv1.AnnotationDescription]
+		app.Maintainers = annotations[ociv1.AnnotationAuthors]
+		return app, nil
+	}
+	payload, err := resto.PullConfigMulti(context.Background(), repo+"@"+dgst, resto.RegistryOptions{})
+	if err != nil {
+		return RemoteApp{}, err
+	}
+	raw, ok := payload[internal.MetadataFileName]
+	if !ok {
+		// not an app
+		return app, nil
+	}
+	meta, err := loadMetadata([]byte(raw))
+	if err != nil {
+		return RemoteApp{}, err
+	}
+	app.Version = meta.Version
+	app.Description = meta.Description
+	app.Maintainers = meta.Maintainers.String()
+	return app, nil
+}
+
+// Search returns the app repositories of the registry, those with the app
+// extension, whose name contains the term, ignoring case
+func Search(registry, term string) ([]string, error) {
+	repos, err := resto.ListRepositories(context.Background(), registry, resto.RegistryOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the repositories of %s", registry)
+	}
+	domain := registry
+	if i := strings.Index(domain, "://"); i != -1 {
+		domain = domain[i+3:]
+	}
+	domain = strings.TrimSuffix(domain, "/")
+	term = strings.ToLower(term)
+	var res []string
+	for _, repo := range repos {
+		if strings.HasSuffix(repo, internal.AppExtension) && strings.Contains(strings.ToLower(repo), term) {
+			res = append(res, domain+"/"+repo)
+		}
+	}
+	return res, nil
+}
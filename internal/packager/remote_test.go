@@ -0,0 +1,63 @@
+package packager
+
+import (
+	"testing"
+
+	"github.com/docker/app/internal"
+	"github.com/docker/app/internal/registrytest"
+	"github.com/docker/app/loader"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func TestListRemote(t *testing.T) {
+	for _, rejected := range []bool{false, true} {
+		var ops []func(*registrytest.Registry)
+		if rejected {
+			ops = append(ops, registrytest.WithRejectedMediaTypes(ociv1.MediaTypeImageManifest))
+		}
+		registry := registrytest.New(ops...)
+		defer registry.Close()
+		var digests []string
+		for _, version := range []string{"0.1.0", "0.2.0"} {
+			dir := fs.NewDir(t, "app.dockerapp",
+				fs.WithFile(internal.MetadataFileName, "name: app\nversion: "+version+"\ndescription: my app\nmaintainers:\n  - name: foo\n"),
+				fs.WithFile(internal.ComposeFileName, "version: \"3.6\"\nservices:\n  web:\n    image: nginx\n"),
+				fs.WithFile(internal.SettingsFileName, ""),
+			)
+			defer dir.Remove()
+			app, err := loader.LoadFromDirectory(dir.Path())
+			assert.NilError(t, err)
+			dgst, err := Push(app, registry.Host(), "", "app.dockerapp")
+			assert.NilError(t, err)
+			digests = append(digests, dgst)
+		}
+		keys := fs.NewDir(t, "keys")
+		defer keys.Remove()
+		writeKeyPair(t, keys, "alice")
+		_, err := Sign(registry.Host()+"/app.dockerapp:0.1.0", digests[0], keys.Join("alice.key"))
+		assert.NilError(t, err)
+
+		apps, err := ListRemote(registry.Host() + "/app.dockerapp")
+		assert.NilError(t, err)
+		assert.Check(t, is.DeepEqual(apps, []RemoteApp{
+			{Tag: "0.1.0", Digest: digests[0], Version: "0.1.0", Description: "my app", Maintainers: "foo"},
+			{Tag: "0.2.0", Digest: digests[1], Version: "0.2.0", Description: "my app", Maintainers: "foo"},
+		}), "rejected OCI: %v", rejected)
+
+		_, err = ListRemote(registry.Host() + "/app.dockerapp:0.1.0")
+		assert.ErrorContains(t, err, "is not a repository name")
+
+		for term, expected := range map[string][]string{
+			"":        {registry.Host() + "/app.dockerapp"},
+			"APP":     {registry.Host() + "/app.dockerapp"},
+			"unknown": nil,
+		} {
+			repos, err := Search(registry.Host(), term)
+			assert.NilError(t, err)
+			assert.Check(t, is.DeepEqual(repos, expected), term)
+		}
+	}
+}
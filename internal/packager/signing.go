@@ -0,0 +1,165 @@
+package packager
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"path"
+
+	"github.com/docker/app/internal/signing"
+	"github.com/docker/app/pkg/resto"
+	"github.com/docker/distribution/reference"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// MediaTypeSignature is the media type of the signatures pushed next to an app
+const MediaTypeSignature = "application/vnd.docker.app.signature.v1+json"
+
+// signatureTag returns the tag the signatures of a manifest are pushed to, in
+// the repository of the manifest
+func signatureTag(dgst digest.Digest) string {
+	return string(dgst.Algorithm()) + "-" + dgst.Hex() + ".sig"
+}
+
+// signaturesRef returns the reference of the signatures of the manifest of the
+// repository the given reference points to
+func signaturesRef(repotag string, dgst digest.Digest) (string, error) {
+	named, err := reference.ParseNormalizedNamed(repotag)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse image name")
+	}
+	return reference.TrimNamed(named).String() + ":" + signatureTag(dgst), nil
+}
+
+// Sign signs the manifest digest of an app pushed to the given reference with
+// the private key file, and pushes the signature next to the app, keeping the
+// signatures by other keys. Returns the reference of the signatures.
+func Sign(repotag, dgst, keyFile string) (string, error) {
+	key, err := signing.LoadPrivateKey(keyFile)
+	if err != nil {
+		return "", err
+	}
+	d, err := digest.Parse(dgst)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid digest %q", dgst)
+	}
+	sigRef, err := signaturesRef(repotag, d)
+	if err != nil {
+		return "", err
+	}
+	sig, err := signing.Sign(key, dgst)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(sig)
+	if err != nil {
+		return "", err
+	}
+	a, err := resto.PullArtifact(context.Background(), sigRef, resto.RegistryOptions{})
+	switch {
+	case err == nil:
+	case resto.IsNotFound(err):
+		a = &resto.Artifact{}
+	default:
+		return "", errors.Wrap(err, "failed to pull the existing signatures")
+	}
+	files := []resto.ArtifactFile{{Path: sig.KeyID + ".json", MediaType: MediaTypeSignature, Content: data}}
+	for _, f := range a.Files {
+		if f.Path != files[0].Path {
+			files = append(files, f)
+		}
+	}
+	a.Files = files
+	if _, err := resto.PushArtifact(context.Background(), a, sigRef, resto.RegistryOptions{}); err != nil {
+		return "", errors.Wrap(err, "failed to push the signature")
+	}
+	return sigRef, nil
+}
+
+// VerifyPolicy refuses apps pulled from a registry without a valid signature
+// by one of its trusted keys
+type VerifyPolicy struct {
+	trustedKeys map[string]*ecdsa.PublicKey
+}
+
+// NewVerifyPolicy returns a policy trusting the public keys of the directory
+func NewVerifyPolicy(trustedKeysDir string) (*VerifyPolicy, error) {
+	keys, err := signing.LoadTrustedKeys(trustedKeysDir)
+	if err != nil {
+		return nil, err
+	}
+	return &VerifyPolicy{trustedKeys: keys}, nil
+}
+
+// Verify checks the manifest the reference points to is signed by a trusted
+// key, and returns its digest
+func (p *VerifyPolicy) Verify(repotag string) (string, error) {
+	dgst, err := resto.ResolveDigest(context.Background(), repotag, resto.RegistryOptions{})
+	if err != nil {
+		return "", err
+	}
+	sigs, err := pullSignatures(repotag, dgst)
+	if err != nil {
+		return "", err
+	}
+	return dgst, p.verifySignatures(repotag, dgst, sigs)
+}
+
+// pullSignatures pulls the signatures of the manifest of the repository the
+// reference points to
+func pullSignatures(repotag, dgst string) (*resto.Artifact, error) {
+	d, err := digest.Parse(dgst)
+	if err != nil {
+		return nil, err
+	}
+	sigRef, err := signaturesRef(repotag, d)
+	if err != nil {
+		return nil, err
+	}
+	a, err := resto.PullArtifact(context.Background(), sigRef, resto.RegistryOptions{})
+	if err != nil {
+		if resto.IsNotFound(err) {
+			return nil, errors.Errorf("%s is not signed", repotag)
+		}
+		return nil, errors.Wrapf(err, "failed to pull the signatures of %s", repotag)
+	}
+	return a, nil
+}
+
+// verifySignatures checks one of the signatures is a signature of the digest
+// by a trusted key
+func (p *VerifyPolicy) verifySignatures(repotag, dgst string, sigs *resto.Artifact) error {
+	for _, f := range sigs.Files {
+		if path.Ext(f.Path) != ".json" {
+			continue
+		}
+		var sig signing.Signature
+		if err := json.Unmarshal(f.Content, &sig); err != nil {
+			log.Debugf("skipping malformed signature %s: %s", f.Path, err)
+			continue
+		}
+		if err := signing.Verify(&sig, dgst, p.trustedKeys); err != nil {
+			log.Debugf("skipping signature %s: %s", f.Path, err)
+			continue
+		}
+		log.Debugf("%s@%s is signed by key %s", repotag, dgst, sig.KeyID)
+		return nil
+	}
+	return errors.Errorf("%s has no valid signature by a trusted key", repotag)
+}
+
+// Pull verifies the app the reference points to, and pulls it by digest, so
+// that it cannot be replaced in between. Returns the extracted dir name.
+func (p *VerifyPolicy) Pull(repotag, outputDir string) (string, error) {
+	dgst, err := p.Verify(repotag)
+	if err != nil {
+		return "", err
+	}
+	named, err := reference.ParseNormalizedNamed(repotag)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse image name")
+	}
+	return Pull(reference.TrimNamed(named).String()+"@"+dgst, outputDir)
+}
@@ -0,0 +1,97 @@
+package packager
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/docker/app/internal"
+	"github.com/docker/app/internal/registrytest"
+	"github.com/docker/app/loader"
+	digest "github.com/opencontainers/go-digest"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func writeKeyPair(t *testing.T, dir *fs.Dir, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	priv, err := x509.MarshalECPrivateKey(key)
+	assert.NilError(t, err)
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.NilError(t, err)
+	assert.NilError(t, ioutil.WriteFile(dir.Join(name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: priv}), 0600))
+	assert.NilError(t, os.Mkdir(dir.Join(name), 0755))
+	assert.NilError(t, ioutil.WriteFile(dir.Join(name, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}), 0644))
+}
+
+func TestSignAndVerify(t *testing.T) {
+	registry := registrytest.New()
+	defer registry.Close()
+	keys := fs.NewDir(t, "keys")
+	defer keys.Remove()
+	writeKeyPair(t, keys, "alice")
+	writeKeyPair(t, keys, "bob")
+	dir := fs.NewDir(t, "app.dockerapp",
+		fs.WithFile(internal.MetadataFileName, "name: app\nversion: 0.1.0\n"),
+		fs.WithFile(internal.ComposeFileName, "version: \"3.6\"\nservices:\n  web:\n    image: nginx\n"),
+		fs.WithFile(internal.SettingsFileName, ""),
+	)
+	defer dir.Remove()
+	app, err := loader.LoadFromDirectory(dir.Path())
+	assert.NilError(t, err)
+	ref := ImageName(app, registry.Host(), "", "app.dockerapp")
+	dgst, err := Push(app, registry.Host(), "", "app.dockerapp")
+	assert.NilError(t, err)
+
+	alice, err := NewVerifyPolicy(keys.Join("alice"))
+	assert.NilError(t, err)
+	bob, err := NewVerifyPolicy(keys.Join("bob"))
+	assert.NilError(t, err)
+	_, err = alice.Verify(ref)
+	assert.ErrorContains(t, err, "is not signed")
+
+	sigRef, err := Sign(ref, dgst, keys.Join("alice.key"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(sigRef, registry.Host()+"/app.dockerapp:"+signatureTag(digest.Digest(dgst))))
+	verified, err := alice.Verify(ref)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(verified, dgst))
+	_, err = bob.Verify(ref)
+	assert.ErrorContains(t, err, "no valid signature by a trusted key")
+
+	// signatures by other keys are kept
+	_, err = Sign(ref, dgst, keys.Join("bob.key"))
+	assert.NilError(t, err)
+	for _, policy := range []*VerifyPolicy{alice, bob} {
+		_, err = policy.Verify(ref)
+		assert.NilError(t, err)
+	}
+
+	out := fs.NewDir(t, "out")
+	defer out.Remove()
+	_, err = alice.Pull(ref, out.Path())
+	assert.NilError(t, err)
+	assert.Assert(t, fs.Equal(out.Path(), fs.Expected(t, fs.MatchAnyFileMode,
+		fs.WithDir("app.dockerapp", fs.MatchAnyFileMode,
+			fs.WithFile(internal.MetadataFileName, "name: app\nversion: 0.1.0\n"),
+			fs.WithFile(internal.ComposeFileName, "", fs.MatchAnyFileContent),
+			fs.WithFile(internal.SettingsFileName, ""),
+		),
+	)))
+
+	// pushing another version under the same tag invalidates the signatures
+	assert.NilError(t, ioutil.WriteFile(dir.Join(internal.SettingsFileName), []byte("port: 80\n"), 0644))
+	app, err = loader.LoadFromDirectory(dir.Path())
+	assert.NilError(t, err)
+	_, err = Push(app, registry.Host(), "", "app.dockerapp")
+	assert.NilError(t, err)
+	_, err = alice.Verify(ref)
+	assert.ErrorContains(t, err, "is not signed")
+}
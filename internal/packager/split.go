@@ -2,57 +2,59 @@ package packager
 
 import (
 	"io"
-	"io/ioutil"
-	"os"
-	"path/filepath"
 
-	"github.com/docker/app/internal"
 	"github.com/docker/app/types"
 	"github.com/pkg/errors"
 )
 
 // Split converts an app package to the split version
 func Split(app *types.App, outputDir string) error {
-	if len(app.Composes()) > 1 {
-		return errors.New("split: multiple compose files is not supported")
-	}
 	if len(app.SettingsRaw()) > 1 {
 		return errors.New("split: multiple setting files is not supported")
 	}
-	err := os.MkdirAll(outputDir, 0755)
-	if err != nil {
-		return err
-	}
-	for file, data := range map[string][]byte{
-		internal.MetadataFileName: app.MetadataRaw(),
-		internal.ComposeFileName:  app.Composes()[0],
-		internal.SettingsFileName: app.SettingsRaw()[0],
-	} {
-		if err := ioutil.WriteFile(filepath.Join(outputDir, file), data, 0644); err != nil {
-			return err
-		}
-	}
-	return nil
+	return app.Extract(outputDir)
 }
 
 // Merge converts an app-package to the single-file merged version
 func Merge(app *types.App, target io.Writer) error {
-	if len(app.Composes()) > 1 {
-		return errors.New("merge: multiple compose files is not supported")
-	}
 	if len(app.SettingsRaw()) > 1 {
 		return errors.New("merge: multiple setting files is not supported")
 	}
-	for _, data := range [][]byte{
+	docs := [][]byte{
 		app.MetadataRaw(),
-		[]byte(types.SingleFileSeparator),
 		app.Composes()[0],
-		[]byte(types.SingleFileSeparator),
 		app.SettingsRaw()[0],
-	} {
+	}
+	for _, overlay := range app.Composes()[1:] {
+		docs = append(docs, withHeader(overlay, types.DocumentCompose))
+	}
+	if schema := app.SettingsSchemaRaw(); len(schema) != 0 {
+		docs = append(docs, withHeader(schema, types.DocumentSettingsSchema))
+	}
+	for _, name := range app.NamedSettingsNames() {
+		docs = append(docs, withHeader(app.NamedSettingsRaw()[name], types.DocumentSettings, name))
+	}
+	if lock := app.DependenciesLockRaw(); len(lock) != 0 {
+		docs = append(docs, withHeader(lock, types.DocumentDependenciesLock))
+	}
+	for i, data := range docs {
+		if i != 0 {
+			if _, err := io.WriteString(target, types.SingleFileSeparator); err != nil {
+				return err
+			}
+		}
 		if _, err := target.Write(data); err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// withHeader prepends the single-file header line for the given document kind and name
+func withHeader(data []byte, kind string, name ...string) []byte {
+	header := types.SingleFileHeaderPrefix + " " + kind
+	for _, n := range name {
+		header += " " + n
+	}
+	return append([]byte(header+"\n"), data...)
+}
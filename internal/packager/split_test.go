@@ -0,0 +1,77 @@
+package packager
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/docker/app/internal"
+	"github.com/docker/app/loader"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+const multiDocumentApp = `version: 0.1.0
+name: my-app
+---
+version: "3.6"
+services:
+  web:
+    image: nginx
+---
+port: 80
+---
+# docker-app: compose
+version: "3.6"
+services:
+  web:
+    image: nginx:alpine
+---
+# docker-app: settings-schema
+properties:
+  port:
+    type: integer
+---
+# docker-app: settings prod
+port: 443
+---
+# docker-app: settings staging
+port: 8443
+---
+# docker-app: dependencies-lock
+dependencies:
+- name: monitoring
+  image: myorg/monitoring.dockerapp
+  version: 1.3.0
+  digest: sha256:0123`
+
+func TestSplitMergeRoundTrip(t *testing.T) {
+	app, err := loader.LoadFromSingleFile("my-app", strings.NewReader(multiDocumentApp))
+	assert.NilError(t, err)
+
+	dir := fs.NewDir(t, "split")
+	defer dir.Remove()
+	assert.NilError(t, Split(app, dir.Join("my-app.dockerapp")))
+	assert.Assert(t, fs.Equal(dir.Join("my-app.dockerapp"), fs.Expected(t,
+		fs.WithMode(0755),
+		fs.WithFile(internal.MetadataFileName, "version: 0.1.0\nname: my-app", fs.WithMode(0644)),
+		fs.WithFile(internal.ComposeFileName, "version: \"3.6\"\nservices:\n  web:\n    image: nginx", fs.WithMode(0644)),
+		fs.WithFile(internal.SettingsFileName, "port: 80", fs.WithMode(0644)),
+		fs.WithFile(internal.SettingsSchemaFileName, "properties:\n  port:\n    type: integer", fs.WithMode(0644)),
+		fs.WithDir(internal.ComposeOverlaysDir, fs.WithMode(0755),
+			fs.WithFile("1.yml", "version: \"3.6\"\nservices:\n  web:\n    image: nginx:alpine", fs.WithMode(0644)),
+		),
+		fs.WithDir(internal.NamedSettingsDir, fs.WithMode(0755),
+			fs.WithFile("prod.yml", "port: 443", fs.WithMode(0644)),
+			fs.WithFile("staging.yml", "port: 8443", fs.WithMode(0644)),
+		),
+		fs.WithFile(internal.DependenciesLockFileName, "dependencies:\n- name: monitoring\n  image: myorg/monitoring.dockerapp\n  version: 1.3.0\n  digest: sha256:0123", fs.WithMode(0644)),
+	)))
+
+	splitApp, err := loader.LoadFromDirectory(dir.Join("my-app.dockerapp"))
+	assert.NilError(t, err)
+	buf := bytes.NewBuffer(nil)
+	assert.NilError(t, Merge(splitApp, buf))
+	assert.Check(t, is.Equal(buf.String(), multiDocumentApp))
+}
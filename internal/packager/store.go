@@ -0,0 +1,215 @@
+package packager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/app/internal/store"
+	"github.com/docker/app/pkg/resto"
+	"github.com/docker/distribution/reference"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// PullPolicy tells when apps are pulled from a registry rather than read from
+// the local store
+type PullPolicy string
+
+const (
+	// PullAlways pulls apps from the registry, updating the local store
+	PullAlways PullPolicy = "always"
+	// PullMissing pulls apps missing from the local store
+	PullMissing PullPolicy = "missing"
+	// PullNever only reads apps from the local store
+	PullNever PullPolicy = "never"
+)
+
+// ParsePullPolicy parses a pull policy
+func ParsePullPolicy(s string) (PullPolicy, error) {
+	switch p := PullPolicy(s); p {
+	case PullAlways, PullMissing, PullNever:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid pull policy %q: expected one of %q, %q or %q", s, PullAlways, PullMissing, PullNever)
+	}
+}
+
+// PullOptions configures how apps are pulled from a registry
+type PullOptions struct {
+	// Policy is the pull policy, PullMissing if empty
+	Policy PullPolicy
+	// Store is the local store apps are pulled to. Apps are pulled to a
+	// temporary directory if nil, and the policy is ignored.
+	Store *store.Store
+	// Verify, if set, refuses apps without a valid signature by a trusted key
+	Verify *VerifyPolicy
+}
+
+// pull gets the app the reference points to, as configured by the options,
+// and extracts it to a directory of the output directory. Returns the
+// extracted dir name.
+func (o PullOptions) pull(repotag, outputDir string) (string, error) {
+	if o.Store == nil {
+		if o.Verify != nil {
+			return o.Verify.Pull(repotag, outputDir)
+		}
+		return Pull(repotag, outputDir)
+	}
+	ref, err := o.fetch(repotag)
+	if err != nil {
+		return "", err
+	}
+	a, err := o.Store.Read(ref)
+	if err != nil {
+		return "", err
+	}
+	return writeApp(repotag, outputDir, a.Payload())
+}
+
+// PullWith loads an app from a registry as configured by the options, and
+// returns the extracted dir name
+func PullWith(repotag, outputDir string, opts PullOptions) (string, error) {
+	return opts.pull(repotag, outputDir)
+}
+
+// fetch makes sure the app the reference points to is in the store,
+// following the pull policy, verifies it, and returns the reference it is
+// stored under
+func (o PullOptions) fetch(repotag string) (string, error) {
+	ref, err := store.Normalize(repotag)
+	if err != nil {
+		return "", err
+	}
+	dgst, found, err := o.Store.Resolve(ref)
+	if err != nil {
+		return "", err
+	}
+	policy := o.Policy
+	if policy == "" {
+		policy = PullMissing
+	}
+	switch {
+	case policy == PullNever && !found:
+		return "", errors.Errorf("%s not found in the local store, and the pull policy is %q", repotag, PullNever)
+	case policy == PullAlways || !found:
+		if dgst, err = pullToStore(o.Store, ref); err != nil {
+			return "", err
+		}
+	default:
+		log.Debugf("using %s@%s from the local store", ref, dgst)
+	}
+	if o.Verify != nil {
+		if err := o.verifyStored(ref, dgst, policy != PullNever); err != nil {
+			return "", err
+		}
+	}
+	return ref, nil
+}
+
+// pullToStore pulls the app the reference points to into the store, unless the
+// store already holds its digest, and returns its digest
+func pullToStore(s *store.Store, ref string) (string, error) {
+	dgst, err := resto.ResolveDigest(context.Background(), ref, resto.RegistryOptions{})
+	if err != nil {
+		return "", err
+	}
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", err
+	}
+	pinned := reference.TrimNamed(named).String() + "@" + dgst
+	_, found, err := s.Resolve(pinned)
+	if err != nil {
+		return "", err
+	}
+	if found {
+		log.Debugf("%s is already in the local store", pinned)
+		return dgst, s.Tag(pinned, ref)
+	}
+	a, err := resto.PullArtifact(context.Background(), pinned, resto.RegistryOptions{})
+	if err != nil {
+		return "", err
+	}
+	return dgst, s.Write(ref, dgst, a)
+}
+
+// verifyStored verifies the stored app against the stored signatures, pulled
+// first if missing or, if online, if none is valid
+func (o PullOptions) verifyStored(ref, dgst string, online bool) error {
+	sigRef, err := signaturesRef(ref, digest.Digest(dgst))
+	if err != nil {
+		return err
+	}
+	if _, found, err := o.Store.Resolve(sigRef); err != nil {
+		return err
+	} else if found {
+		sigs, err := o.Store.Read(sigRef)
+		if err != nil {
+			return err
+		}
+		err = o.Verify.verifySignatures(ref, dgst, sigs)
+		if err == nil || !online {
+			return err
+		}
+	} else if !online {
+		return errors.Errorf("no signature of %s in the local store, and the pull policy is %q", ref, PullNever)
+	}
+	sigs, err := pullSignatures(ref, dgst)
+	if err != nil {
+		return err
+	}
+	if err := o.Store.Write(sigRef, "", sigs); err != nil {
+		return err
+	}
+	return o.Verify.verifySignatures(ref, dgst, sigs)
+}
+
+// isSignatureRef returns whether the stored reference is the one of signatures
+func isSignatureRef(ref string) bool {
+	return strings.HasSuffix(ref, ".sig")
+}
+
+// ListStore returns the apps of the store, signatures excluded
+func ListStore(s *store.Store) ([]store.Entry, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var apps []store.Entry
+	for _, e := range entries {
+		if !isSignatureRef(e.Ref) {
+			apps = append(apps, e)
+		}
+	}
+	return apps, nil
+}
+
+// RemoveFromStore removes the reference from the store, along with the
+// signatures of the apps no longer stored
+func RemoveFromStore(s *store.Store, ref string) error {
+	if err := s.Remove(ref); err != nil {
+		return err
+	}
+	entries, err := s.List()
+	if err != nil {
+		return err
+	}
+	stored := map[string]bool{}
+	for _, e := range entries {
+		if !isSignatureRef(e.Ref) {
+			if sigRef, err := signaturesRef(e.Ref, digest.Digest(e.Digest)); err == nil {
+				stored[sigRef] = true
+			}
+		}
+	}
+	for _, e := range entries {
+		if isSignatureRef(e.Ref) && !stored[e.Ref] {
+			if err := s.Remove(e.Ref); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package packager
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/app/internal"
+	"github.com/docker/app/internal/registrytest"
+	"github.com/docker/app/internal/store"
+	"github.com/docker/app/loader"
+	"github.com/docker/app/types"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func pushTestApp(t *testing.T, registry *registrytest.Registry, version, tag string) string {
+	dir := fs.NewDir(t, "app.dockerapp",
+		fs.WithFile(internal.MetadataFileName, "name: app\nversion: "+version+"\n"),
+		fs.WithFile(internal.ComposeFileName, "version: \"3.6\"\nservices:\n  web:\n    image: nginx\n"),
+		fs.WithFile(internal.SettingsFileName, ""),
+	)
+	defer dir.Remove()
+	app, err := loader.LoadFromDirectory(dir.Path())
+	assert.NilError(t, err)
+	dgst, err := Push(app, registry.Host(), tag, "app.dockerapp")
+	assert.NilError(t, err)
+	return dgst
+}
+
+func extractVersion(ref string, opts PullOptions) (string, error) {
+	app, err := ExtractWith(ref, opts)
+	if err != nil {
+		return "", err
+	}
+	defer app.Cleanup()
+	if !app.Remote() {
+		return "", errors.New("app pulled from a registry not marked as remote")
+	}
+	meta, err := loadMetadata(app.MetadataRaw())
+	if err != nil {
+		return "", err
+	}
+	return meta.Version, nil
+}
+
+func TestParsePullPolicy(t *testing.T) {
+	for _, s := range []string{"always", "missing", "never"} {
+		policy, err := ParsePullPolicy(s)
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(string(policy), s))
+	}
+	_, err := ParsePullPolicy("sometimes")
+	assert.ErrorContains(t, err, `invalid pull policy "sometimes"`)
+}
+
+func TestPullPolicies(t *testing.T) {
+	registry := registrytest.New()
+	defer registry.Close()
+	dir := fs.NewDir(t, "store")
+	defer dir.Remove()
+	s := store.New(dir.Join("store"))
+	ref := registry.Host() + "/app.dockerapp:latest"
+	pushTestApp(t, registry, "0.1.0", "latest")
+
+	_, err := extractVersion(ref, PullOptions{Policy: PullNever, Store: s})
+	assert.ErrorContains(t, err, "not found in the local store")
+	version, err := extractVersion(ref, PullOptions{Store: s})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(version, "0.1.0"))
+
+	// a newer version behind the tag is only pulled when always pulling
+	dgst := pushTestApp(t, registry, "0.2.0", "latest")
+	for _, policy := range []PullPolicy{PullNever, PullMissing} {
+		version, err = extractVersion(ref, PullOptions{Policy: policy, Store: s})
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(version, "0.1.0"), policy)
+	}
+	version, err = extractVersion(ref, PullOptions{Policy: PullAlways, Store: s})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(version, "0.2.0"))
+	apps, err := ListStore(s)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(apps, []store.Entry{{Ref: ref, Digest: dgst}}))
+
+	// stored apps are used offline, by tag or by digest
+	registry.Close()
+	for _, r := range []string{ref, registry.Host() + "/app.dockerapp@" + dgst} {
+		version, err = extractVersion(r, PullOptions{Store: s})
+		assert.NilError(t, err, r)
+		assert.Check(t, is.Equal(version, "0.2.0"), r)
+	}
+	_, err = extractVersion(ref, PullOptions{Policy: PullAlways, Store: s})
+	assert.Check(t, err != nil)
+}
+
+func TestPullPoliciesVerify(t *testing.T) {
+	registry := registrytest.New()
+	defer registry.Close()
+	dir := fs.NewDir(t, "store")
+	defer dir.Remove()
+	s := store.New(dir.Join("store"))
+	keys := fs.NewDir(t, "keys")
+	defer keys.Remove()
+	writeKeyPair(t, keys, "alice")
+	policy, err := NewVerifyPolicy(keys.Join("alice"))
+	assert.NilError(t, err)
+	ref := registry.Host() + "/app.dockerapp:0.1.0"
+	dgst := pushTestApp(t, registry, "0.1.0", "")
+
+	_, err = extractVersion(ref, PullOptions{Store: s, Verify: policy})
+	assert.ErrorContains(t, err, "is not signed")
+	_, err = Sign(ref, dgst, keys.Join("alice.key"))
+	assert.NilError(t, err)
+	_, err = extractVersion(ref, PullOptions{Store: s, Verify: policy})
+	assert.NilError(t, err)
+
+	// signatures are stored along with the app, but not listed
+	registry.Close()
+	_, err = extractVersion(ref, PullOptions{Policy: PullNever, Store: s, Verify: policy})
+	assert.NilError(t, err)
+	apps, err := ListStore(s)
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(apps, 1))
+	assert.NilError(t, RemoveFromStore(s, ref))
+	entries, err := s.List()
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(entries, 0))
+}
+
+func TestDependenciesPullOptions(t *testing.T) {
+	registry := registrytest.New()
+	defer registry.Close()
+	dir := fs.NewDir(t, "store")
+	defer dir.Remove()
+	s := store.New(dir.Join("store"))
+	keys := fs.NewDir(t, "keys")
+	defer keys.Remove()
+	writeKeyPair(t, keys, "alice")
+	policy, err := NewVerifyPolicy(keys.Join("alice"))
+	assert.NilError(t, err)
+	dgst := pushTestApp(t, registry, "0.1.0", "")
+	meta := "name: shop\nversion: 0.1.0\ndependencies:\n  - name: app\n    image: " + registry.Host() + "/app.dockerapp\n    version: ^0.1\n"
+	newApp := func(opts PullOptions) error {
+		app, err := types.NewApp("shop", types.Metadata(strings.NewReader(meta)), WithDependencies(opts))
+		if err == nil {
+			app.Cleanup()
+		}
+		return err
+	}
+
+	err = newApp(PullOptions{Policy: PullNever, Store: s})
+	assert.ErrorContains(t, err, "not found in the local store")
+	err = newApp(PullOptions{Store: s, Verify: policy})
+	assert.ErrorContains(t, err, "is not signed")
+	err = newApp(PullOptions{Verify: policy})
+	assert.ErrorContains(t, err, "is not signed")
+
+	_, err = Sign(registry.Host()+"/app.dockerapp:0.1.0", dgst, keys.Join("alice.key"))
+	assert.NilError(t, err)
+	assert.NilError(t, newApp(PullOptions{Store: s, Verify: policy}))
+	apps, err := ListStore(s)
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(apps, 1))
+	assert.Check(t, is.Equal(apps[0].Digest, dgst))
+	assert.NilError(t, newApp(PullOptions{Policy: PullNever, Store: s, Verify: policy}))
+}
+
+func TestPullWithStores(t *testing.T) {
+	registry := registrytest.New()
+	defer registry.Close()
+	dir := fs.NewDir(t, "store")
+	defer dir.Remove()
+	s := store.New(dir.Join("store"))
+	ref := registry.Host() + "/app.dockerapp:0.1.0"
+	dgst := pushTestApp(t, registry, "0.1.0", "")
+
+	_, err := PullWith(ref, dir.Path(), PullOptions{Policy: PullAlways, Store: s})
+	assert.NilError(t, err)
+	apps, err := ListStore(s)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(apps, []store.Entry{{Ref: ref, Digest: dgst}}))
+	registry.Close()
+	version, err := extractVersion(ref, PullOptions{Policy: PullNever, Store: s})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(version, "0.1.0"))
+}
@@ -0,0 +1,200 @@
+package packager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/docker/app/internal"
+	"github.com/docker/app/internal/diff3"
+	"github.com/docker/app/internal/semver"
+	"github.com/docker/app/internal/yaml"
+	"github.com/docker/app/types"
+	"github.com/docker/app/types/metadata"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// UpgradeResult describes the upgrade of a fork to a newer version of its parent
+type UpgradeResult struct {
+	// Parent is the repository of the parent application
+	Parent string
+	// From is the parent version the fork was based on
+	From string
+	// To is the parent version the fork was upgraded to, empty if the fork
+	// is up to date
+	To string
+	// Available lists the parent versions newer than From, in ascending order
+	Available []string
+	// Conflicts lists the files with conflicting changes, left with conflict
+	// markers or, for files deleted on one side and modified on the other,
+	// left as is
+	Conflicts []string
+}
+
+// Upgrade merges the changes made to the parent of the fork, from the version
+// the fork is based on to the newest version satisfying the constraint (any
+// version if empty), into the fork directory. The parent version recorded in
+// the fork metadata is updated, even if there are conflicts.
+func Upgrade(app *types.App, constraint string) (*UpgradeResult, error) {
+	return upgrade(restoRegistry{}, app, constraint)
+}
+
+func upgrade(registry registryClient, app *types.App, constraint string) (*UpgradeResult, error) {
+	if s, err := os.Stat(app.Path); err != nil || !s.IsDir() {
+		return nil, errors.Errorf("cannot upgrade %s: only application directories can be upgraded, use split first", app.Path)
+	}
+	meta := app.Metadata()
+	if len(meta.Parents) == 0 {
+		return nil, errors.Errorf("%s is not a fork", app.Name)
+	}
+	parent := meta.Parents[len(meta.Parents)-1]
+	current, err := semver.Parse(parent.Version)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot upgrade from the parent version")
+	}
+	result := &UpgradeResult{
+		Parent: parentRepository(parent),
+		From:   parent.Version,
+	}
+	tags, err := registry.ListTags(context.Background(), result.Parent)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list versions of %s", result.Parent)
+	}
+	result.Available = newerVersions(current, tags)
+	if len(result.Available) == 0 {
+		return result, nil
+	}
+	if constraint == "" {
+		result.To = result.Available[len(result.Available)-1]
+	} else {
+		c, err := semver.ParseConstraint(constraint)
+		if err != nil {
+			return nil, err
+		}
+		result.To, _ = c.Latest(result.Available)
+		if result.To == "" {
+			return result, nil
+		}
+	}
+
+	log.Debugf("Pulling %s:%s and %s:%s", result.Parent, result.From, result.Parent, result.To)
+	base, err := registry.PullFiles(context.Background(), result.Parent+":"+result.From)
+	if err != nil {
+		return nil, err
+	}
+	theirs, err := registry.PullFiles(context.Background(), result.Parent+":"+result.To)
+	if err != nil {
+		return nil, err
+	}
+	ours := map[string]string{}
+	for k, v := range app.Files() {
+		ours[k] = string(v)
+	}
+	if err := mergeFiles(app.Path, base, ours, theirs, result); err != nil {
+		return nil, err
+	}
+
+	// record the new parent version
+	upstream, err := loadMetadata([]byte(theirs[internal.MetadataFileName]))
+	if err != nil {
+		return nil, err
+	}
+	parent.Version = result.To
+	parent.Maintainers = upstream.Maintainers
+	meta.Parents[len(meta.Parents)-1] = parent
+	data, err := yaml.Marshal(meta)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render metadata structure")
+	}
+	if err := writeAppFile(app.Path, internal.MetadataFileName, data); err != nil {
+		return nil, errors.Wrap(err, "failed to write metadata")
+	}
+	return result, nil
+}
+
+// mergeFiles merges the changes from the base to their files into our files,
+// in the application directory. The metadata is left untouched.
+func mergeFiles(appDir string, base, ours, theirs map[string]string, result *UpgradeResult) error {
+	paths := map[string]bool{}
+	for _, files := range []map[string]string{base, ours, theirs} {
+		for p := range files {
+			if p != internal.MetadataFileName && isAppFilePath(p) {
+				paths[p] = true
+			}
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+	for _, p := range sorted {
+		baseData, inBase := base[p]
+		ourData, inOurs := ours[p]
+		theirData, inTheirs := theirs[p]
+		switch {
+		case inTheirs && theirData == baseData && inBase:
+			// unchanged upstream
+		case !inTheirs && !inBase:
+			// added in the fork
+		case !inTheirs:
+			// deleted upstream
+			if !inOurs {
+				continue
+			}
+			if ourData != baseData {
+				result.Conflicts = append(result.Conflicts, p)
+				continue
+			}
+			if err := os.Remove(filepath.Join(appDir, filepath.FromSlash(p))); err != nil {
+				return err
+			}
+		case !inOurs && inBase:
+			// deleted in the fork, modified upstream
+			result.Conflicts = append(result.Conflicts, p)
+		default:
+			merged, conflict := diff3.Merge([]byte(baseData), []byte(ourData), []byte(theirData), "fork", "upstream "+result.To)
+			if conflict {
+				result.Conflicts = append(result.Conflicts, p)
+			}
+			if err := writeAppFile(appDir, p, merged); err != nil {
+				return errors.Wrap(err, "error writing output file")
+			}
+		}
+	}
+	return nil
+}
+
+// parentRepository returns the repository the parent application was pushed to
+func parentRepository(parent metadata.ParentMetadata) string {
+	repo := internal.DirNameFromAppName(parent.Name)
+	if parent.Namespace != "" {
+		repo = parent.Namespace + "/" + repo
+	}
+	return repo
+}
+
+// newerVersions returns the semantic versions newer than the given one, in
+// ascending order
+func newerVersions(current semver.Version, tags []string) []string {
+	type version struct {
+		tag string
+		v   semver.Version
+	}
+	var newer []version
+	for _, tag := range tags {
+		v, err := semver.Parse(tag)
+		if err != nil || v.Compare(current) <= 0 {
+			continue
+		}
+		newer = append(newer, version{tag, v})
+	}
+	sort.Slice(newer, func(i, j int) bool { return newer[i].v.Compare(newer[j].v) < 0 })
+	res := make([]string, len(newer))
+	for i, n := range newer {
+		res[i] = n.tag
+	}
+	return res
+}
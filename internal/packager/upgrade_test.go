@@ -0,0 +1,100 @@
+package packager
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/docker/app/internal"
+	"github.com/docker/app/loader"
+	"github.com/docker/app/types/metadata"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func TestUpgrade(t *testing.T) {
+	upstream := func(version, image, settings string) map[string]string {
+		return map[string]string{
+			internal.MetadataFileName: "name: shop\nnamespace: myorg\nversion: " + version + "\nmaintainers:\n  - name: upstream-" + version,
+			internal.ComposeFileName:  "version: \"3.6\"\nservices:\n  web:\n    image: " + image + "\n    ports:\n      - 80:80\n",
+			internal.SettingsFileName: settings,
+		}
+	}
+	registry := &fakeRegistry{
+		tags: map[string][]string{
+			"myorg/shop.dockerapp": {"latest", "0.9.0", "1.0.0", "1.1.0", "1.2.0", "2.0.0"},
+		},
+		apps: map[string]map[string]string{
+			"myorg/shop.dockerapp:1.0.0": upstream("1.0.0", "nginx:1.14", "replicas: 1\n"),
+			"myorg/shop.dockerapp:1.2.0": upstream("1.2.0", "nginx:1.15", "replicas: 2\n"),
+		},
+	}
+	dir := fs.NewDir(t, "fork.dockerapp",
+		fs.WithFile(internal.MetadataFileName, `name: myshop
+version: 0.1.0
+parents:
+  - name: shop
+    namespace: myorg
+    version: 1.0.0
+`),
+		fs.WithFile(internal.ComposeFileName, "version: \"3.6\"\nservices:\n  web:\n    image: nginx:1.14\n    ports:\n      - 8080:80\n"),
+		fs.WithFile(internal.SettingsFileName, "replicas: 3\n"),
+	)
+	defer dir.Remove()
+	app, err := loader.LoadFromDirectory(dir.Path())
+	assert.NilError(t, err)
+
+	result, err := upgrade(registry, app, "^1")
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(result, &UpgradeResult{
+		Parent:    "myorg/shop.dockerapp",
+		From:      "1.0.0",
+		To:        "1.2.0",
+		Available: []string{"1.1.0", "1.2.0", "2.0.0"},
+		Conflicts: []string{internal.SettingsFileName},
+	}))
+	assert.Assert(t, fs.Equal(dir.Path(), fs.Expected(t,
+		fs.MatchAnyFileMode,
+		fs.WithFile(internal.MetadataFileName, "", fs.MatchAnyFileContent),
+		fs.WithFile(internal.ComposeFileName, "version: \"3.6\"\nservices:\n  web:\n    image: nginx:1.15\n    ports:\n      - 8080:80\n"),
+		fs.WithFile(internal.SettingsFileName, "<<<<<<< fork\nreplicas: 3\n=======\nreplicas: 2\n>>>>>>> upstream 1.2.0\n"),
+	)))
+	data, err := ioutil.ReadFile(dir.Join(internal.MetadataFileName))
+	assert.NilError(t, err)
+	meta, err := loadMetadata(data)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(meta.Parents, metadata.Parents{
+		{Name: "shop", Namespace: "myorg", Version: "1.2.0", Maintainers: metadata.Maintainers{{Name: "upstream-1.2.0"}}},
+	}))
+}
+
+func TestUpgradeUpToDate(t *testing.T) {
+	registry := &fakeRegistry{
+		tags: map[string][]string{"myorg/shop.dockerapp": {"1.0.0"}},
+	}
+	dir := fs.NewDir(t, "fork.dockerapp",
+		fs.WithFile(internal.MetadataFileName, "name: myshop\nversion: 0.1.0\nparents:\n  - name: shop\n    namespace: myorg\n    version: 1.0.0\n"),
+		fs.WithFile(internal.ComposeFileName, "version: \"3.6\"\n"),
+		fs.WithFile(internal.SettingsFileName, ""),
+	)
+	defer dir.Remove()
+	app, err := loader.LoadFromDirectory(dir.Path())
+	assert.NilError(t, err)
+	result, err := upgrade(registry, app, "")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(result.To, ""))
+	assert.Check(t, is.Len(result.Available, 0))
+}
+
+func TestUpgradeNotAFork(t *testing.T) {
+	dir := fs.NewDir(t, "app.dockerapp",
+		fs.WithFile(internal.MetadataFileName, "name: shop\nversion: 0.1.0\n"),
+		fs.WithFile(internal.ComposeFileName, "version: \"3.6\"\n"),
+		fs.WithFile(internal.SettingsFileName, ""),
+	)
+	defer dir.Remove()
+	app, err := loader.LoadFromDirectory(dir.Path())
+	assert.NilError(t, err)
+	_, err = upgrade(&fakeRegistry{}, app, "")
+	assert.Check(t, is.ErrorContains(err, "is not a fork"))
+}
@@ -0,0 +1,57 @@
+package plan
+
+import (
+	"github.com/docker/cli/cli/command/stack/kubernetes"
+	composetypes "github.com/docker/cli/cli/compose/types"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// StackGetter gets a stack deployed on Kubernetes
+type StackGetter interface {
+	Get(name string) (kubernetes.Stack, error)
+}
+
+// KubernetesServices returns the services of the given stack currently
+// deployed on Kubernetes, converted back to their Compose representation.
+// Kubernetes stacks have no networks.
+func KubernetesServices(stacks StackGetter, stackName string) ([]composetypes.ServiceConfig, error) {
+	stack, err := stacks.Get(stackName)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get stack %s", stackName)
+	}
+	if stack.Spec == nil {
+		return nil, nil
+	}
+	var configs []composetypes.ServiceConfig
+	for _, service := range stack.Spec.Services {
+		config := composetypes.ServiceConfig{
+			Name:        service.Name,
+			Image:       service.Image,
+			Environment: composetypes.MappingWithEquals(service.Environment),
+		}
+		config.Deploy.Mode = service.Deploy.Mode
+		config.Deploy.Replicas = service.Deploy.Replicas
+		for _, port := range service.Ports {
+			config.Ports = append(config.Ports, composetypes.ServicePortConfig{
+				Mode:      port.Mode,
+				Target:    port.Target,
+				Published: port.Published,
+				Protocol:  port.Protocol,
+			})
+		}
+		for _, volume := range service.Volumes {
+			config.Volumes = append(config.Volumes, composetypes.ServiceVolumeConfig{
+				Type:     volume.Type,
+				Source:   volume.Source,
+				Target:   volume.Target,
+				ReadOnly: volume.ReadOnly,
+			})
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
@@ -0,0 +1,67 @@
+package plan
+
+import (
+	"strings"
+
+	"github.com/docker/app/internal/diff"
+	composetypes "github.com/docker/cli/cli/compose/types"
+)
+
+// Compute returns the plan of a deploy, as the difference between the services
+// currently running in the stack and the rendered services: added services
+// are created, changed ones updated and removed ones removed.
+// Networks are only compared if the orchestrator supports them.
+func Compute(current []composetypes.ServiceConfig, rendered *composetypes.Config, withNetworks bool) *diff.Diff {
+	desired := make([]composetypes.ServiceConfig, len(rendered.Services))
+	for i, service := range rendered.Services {
+		desired[i] = service
+		desired[i].Ports = withIngressMode(service.Ports)
+		switch {
+		case !withNetworks:
+			desired[i].Networks = nil
+		case len(service.Networks) == 0:
+			// services are attached to the stack default network
+			desired[i].Networks = map[string]*composetypes.ServiceNetworkConfig{"default": nil}
+		}
+	}
+	digests := map[string]bool{}
+	for _, service := range desired {
+		digests[service.Name] = strings.Contains(service.Image, "@")
+	}
+	running := make([]composetypes.ServiceConfig, len(current))
+	for i, service := range current {
+		running[i] = service
+		running[i].Ports = withIngressMode(service.Ports)
+		// images are resolved to their digest on deploy
+		if !digests[service.Name] {
+			running[i].Image = stripDigest(service.Image)
+		}
+	}
+	return diff.Compare(
+		&composetypes.Config{Services: running},
+		&composetypes.Config{Services: desired},
+	)
+}
+
+// withIngressMode sets the mode left out of the long syntax ports to ingress,
+// the one the orchestrator defaults to and reports
+func withIngressMode(ports []composetypes.ServicePortConfig) []composetypes.ServicePortConfig {
+	if ports == nil {
+		return nil
+	}
+	defaulted := make([]composetypes.ServicePortConfig, len(ports))
+	for i, port := range ports {
+		if port.Mode == "" {
+			port.Mode = "ingress"
+		}
+		defaulted[i] = port
+	}
+	return defaulted
+}
+
+func stripDigest(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i]
+	}
+	return image
+}
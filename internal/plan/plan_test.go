@@ -0,0 +1,217 @@
+package plan
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/app/internal/diff"
+	"github.com/docker/cli/cli/command/stack/kubernetes"
+	composetypes "github.com/docker/cli/cli/compose/types"
+	"github.com/docker/cli/kubernetes/compose/v1beta2"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeDaemon serves a deployed "my-stack" swarm stack
+type fakeDaemon struct {
+	services []swarm.Service
+	networks []types.NetworkResource
+	filters  []string
+}
+
+func (d *fakeDaemon) ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error) {
+	d.filters = options.Filters.Get("label")
+	return d.services, nil
+}
+
+func (d *fakeDaemon) NetworkList(ctx context.Context, options types.NetworkListOptions) ([]types.NetworkResource, error) {
+	return d.networks, nil
+}
+
+func uint64Ptr(i uint64) *uint64 {
+	return &i
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func renderedConfig() *composetypes.Config {
+	return &composetypes.Config{
+		Services: []composetypes.ServiceConfig{
+			{
+				Name:        "web",
+				Image:       "nginx:1.15",
+				Environment: composetypes.MappingWithEquals{"DEBUG": strPtr("false")},
+				Ports:       []composetypes.ServicePortConfig{{Mode: "ingress", Target: 80, Published: 8080, Protocol: "tcp"}},
+				Volumes:     []composetypes.ServiceVolumeConfig{{Type: "volume", Source: "data", Target: "/data"}},
+				Deploy:      composetypes.DeployConfig{Replicas: uint64Ptr(3)},
+			},
+			{Name: "db", Image: "postgres:11"},
+		},
+	}
+}
+
+func TestSwarmPlan(t *testing.T) {
+	daemon := &fakeDaemon{
+		services: []swarm.Service{
+			{Spec: swarm.ServiceSpec{
+				Annotations: swarm.Annotations{Name: "my-stack_web"},
+				TaskTemplate: swarm.TaskSpec{
+					ContainerSpec: &swarm.ContainerSpec{
+						Image: "nginx:1.14@sha256:abcdef",
+						Env:   []string{"DEBUG=true"},
+						Mounts: []mount.Mount{
+							{Type: mount.TypeVolume, Source: "my-stack_data", Target: "/data"},
+						},
+					},
+					Networks: []swarm.NetworkAttachmentConfig{{Target: "net-id", Aliases: []string{"web"}}},
+				},
+				Mode: swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: uint64Ptr(3)}},
+				EndpointSpec: &swarm.EndpointSpec{Ports: []swarm.PortConfig{
+					{Protocol: swarm.PortConfigProtocolTCP, TargetPort: 80, PublishedPort: 8080, PublishMode: swarm.PortConfigPublishModeIngress},
+				}},
+			}},
+			{Spec: swarm.ServiceSpec{
+				Annotations: swarm.Annotations{Name: "my-stack_cache"},
+				TaskTemplate: swarm.TaskSpec{
+					ContainerSpec: &swarm.ContainerSpec{Image: "redis@sha256:012345"},
+					Networks:      []swarm.NetworkAttachmentConfig{{Target: "net-id", Aliases: []string{"cache"}}},
+				},
+				Mode: swarm.ServiceMode{Global: &swarm.GlobalService{}},
+			}},
+		},
+		networks: []types.NetworkResource{{ID: "net-id", Name: "my-stack_default"}},
+	}
+	current, err := SwarmServices(context.Background(), daemon, "my-stack")
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(daemon.filters, []string{"com.docker.stack.namespace=my-stack"}))
+
+	d := Compute(current, renderedConfig(), true)
+	assert.Check(t, is.DeepEqual(d, &diff.Diff{
+		Services: []diff.ServiceDiff{
+			{Name: "cache", Status: diff.Removed, Changes: []diff.Change{
+				{Field: "image", Status: diff.Removed, Before: "redis"},
+				{Field: "networks.default", Status: diff.Removed},
+				{Field: "replicas", Status: diff.Removed, Before: "1"},
+			}},
+			{Name: "db", Status: diff.Added, Changes: []diff.Change{
+				{Field: "image", Status: diff.Added, After: "postgres:11"},
+				{Field: "networks.default", Status: diff.Added},
+				{Field: "replicas", Status: diff.Added, After: "1"},
+			}},
+			{Name: "web", Status: diff.Changed, Changes: []diff.Change{
+				{Field: "environment.DEBUG", Status: diff.Changed, Before: "true", After: "false"},
+				{Field: "image", Status: diff.Changed, Before: "nginx:1.14", After: "nginx:1.15"},
+			}},
+		},
+	}))
+}
+
+func TestSwarmPlanUpToDate(t *testing.T) {
+	daemon := &fakeDaemon{
+		services: []swarm.Service{
+			{Spec: swarm.ServiceSpec{
+				Annotations: swarm.Annotations{Name: "my-stack_db"},
+				TaskTemplate: swarm.TaskSpec{
+					ContainerSpec: &swarm.ContainerSpec{Image: "postgres:11@sha256:abcdef"},
+					Networks:      []swarm.NetworkAttachmentConfig{{Target: "net-id", Aliases: []string{"db"}}},
+				},
+				Mode: swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: uint64Ptr(1)}},
+			}},
+		},
+		networks: []types.NetworkResource{{ID: "net-id", Name: "my-stack_default"}},
+	}
+	current, err := SwarmServices(context.Background(), daemon, "my-stack")
+	assert.NilError(t, err)
+	rendered := &composetypes.Config{Services: []composetypes.ServiceConfig{{Name: "db", Image: "postgres:11"}}}
+	assert.Check(t, Compute(current, rendered, true).Empty())
+}
+
+func TestSwarmPlanPortDefaults(t *testing.T) {
+	daemon := &fakeDaemon{
+		services: []swarm.Service{
+			{Spec: swarm.ServiceSpec{
+				Annotations: swarm.Annotations{Name: "my-stack_web"},
+				TaskTemplate: swarm.TaskSpec{
+					ContainerSpec: &swarm.ContainerSpec{Image: "nginx:1.15@sha256:abcdef"},
+				},
+				Mode: swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: uint64Ptr(1)}},
+				EndpointSpec: &swarm.EndpointSpec{Ports: []swarm.PortConfig{
+					{Protocol: swarm.PortConfigProtocolTCP, TargetPort: 80, PublishedPort: 8080, PublishMode: swarm.PortConfigPublishModeIngress},
+				}},
+			}},
+		},
+	}
+	current, err := SwarmServices(context.Background(), daemon, "my-stack")
+	assert.NilError(t, err)
+	// long syntax ports, without mode
+	rendered := &composetypes.Config{Services: []composetypes.ServiceConfig{{
+		Name:  "web",
+		Image: "nginx:1.15",
+		Ports: []composetypes.ServicePortConfig{{Target: 80, Published: 8080}},
+	}}}
+	assert.Check(t, Compute(current, rendered, false).Empty())
+	assert.Check(t, is.Equal(rendered.Services[0].Ports[0].Mode, ""))
+
+	rendered.Services[0].Ports[0].Mode = "host"
+	d := Compute(current, rendered, false)
+	assert.Check(t, is.DeepEqual(d.Services[0].Changes, []diff.Change{
+		{Field: "ports.8080:80/tcp/host", Status: diff.Added, After: "8080:80/tcp (host)"},
+		{Field: "ports.8080:80/tcp/ingress", Status: diff.Removed, Before: "8080:80/tcp (ingress)"},
+	}))
+}
+
+type fakeStacks struct {
+	stacks map[string]kubernetes.Stack
+}
+
+func (s *fakeStacks) Get(name string) (kubernetes.Stack, error) {
+	stack, ok := s.stacks[name]
+	if !ok {
+		return kubernetes.Stack{}, apierrors.NewNotFound(schema.GroupResource{Resource: "stacks"}, name)
+	}
+	return stack, nil
+}
+
+func TestKubernetesPlan(t *testing.T) {
+	stacks := &fakeStacks{stacks: map[string]kubernetes.Stack{
+		"my-stack": {Name: "my-stack", Spec: &v1beta2.StackSpec{Services: []v1beta2.ServiceConfig{
+			{
+				Name:        "web",
+				Image:       "nginx:1.15",
+				Environment: map[string]*string{"DEBUG": strPtr("false")},
+				Ports:       []v1beta2.ServicePortConfig{{Mode: "ingress", Target: 80, Published: 8080, Protocol: "tcp"}},
+				Volumes:     []v1beta2.ServiceVolumeConfig{{Type: "volume", Source: "data", Target: "/data"}},
+				Deploy:      v1beta2.DeployConfig{Replicas: uint64Ptr(2)},
+			},
+		}}},
+	}}
+	current, err := KubernetesServices(stacks, "my-stack")
+	assert.NilError(t, err)
+	d := Compute(current, renderedConfig(), false)
+	assert.Check(t, is.DeepEqual(d, &diff.Diff{
+		Services: []diff.ServiceDiff{
+			{Name: "db", Status: diff.Added, Changes: []diff.Change{
+				{Field: "image", Status: diff.Added, After: "postgres:11"},
+				{Field: "replicas", Status: diff.Added, After: "1"},
+			}},
+			{Name: "web", Status: diff.Changed, Changes: []diff.Change{
+				{Field: "replicas", Status: diff.Changed, Before: "2", After: "3"},
+			}},
+		},
+	}))
+
+	// a stack not deployed yet is created
+	current, err = KubernetesServices(stacks, "new-stack")
+	assert.NilError(t, err)
+	d = Compute(current, renderedConfig(), false)
+	assert.Check(t, is.Len(d.Services, 2))
+	assert.Check(t, is.Equal(d.Services[0].Status, diff.Added))
+	assert.Check(t, is.Equal(d.Services[1].Status, diff.Added))
+}
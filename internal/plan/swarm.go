@@ -0,0 +1,117 @@
+package plan
+
+import (
+	"context"
+	"strings"
+
+	"github.com/docker/cli/cli/compose/convert"
+	composetypes "github.com/docker/cli/cli/compose/types"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/pkg/errors"
+)
+
+// SwarmClient is the subset of the Docker API needed to read a deployed stack
+type SwarmClient interface {
+	ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error)
+	NetworkList(ctx context.Context, options types.NetworkListOptions) ([]types.NetworkResource, error)
+}
+
+// SwarmServices returns the services of the given stack currently running on
+// the swarm, converted back to their Compose representation
+func SwarmServices(ctx context.Context, client SwarmClient, stackName string) ([]composetypes.ServiceConfig, error) {
+	stackFilter := filters.NewArgs(filters.Arg("label", convert.LabelNamespace+"="+stackName))
+	services, err := client.ServiceList(ctx, types.ServiceListOptions{Filters: stackFilter})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list services of stack %s", stackName)
+	}
+	networks, err := client.NetworkList(ctx, types.NetworkListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list networks")
+	}
+	networkNames := make(map[string]string, len(networks))
+	for _, network := range networks {
+		networkNames[network.ID] = network.Name
+	}
+	var configs []composetypes.ServiceConfig
+	for _, service := range services {
+		configs = append(configs, fromSwarmService(service, stackName, networkNames))
+	}
+	return configs, nil
+}
+
+func fromSwarmService(service swarm.Service, stackName string, networkNames map[string]string) composetypes.ServiceConfig {
+	spec := service.Spec
+	name := strings.TrimPrefix(spec.Name, stackName+"_")
+	config := composetypes.ServiceConfig{
+		Name:        name,
+		Environment: composetypes.MappingWithEquals{},
+	}
+	if container := spec.TaskTemplate.ContainerSpec; container != nil {
+		config.Image = container.Image
+		for _, env := range container.Env {
+			parts := strings.SplitN(env, "=", 2)
+			if len(parts) == 1 {
+				config.Environment[parts[0]] = nil
+				continue
+			}
+			value := parts[1]
+			config.Environment[parts[0]] = &value
+		}
+		for _, mount := range container.Mounts {
+			source := mount.Source
+			if mount.Type == "volume" {
+				source = strings.TrimPrefix(source, stackName+"_")
+			}
+			config.Volumes = append(config.Volumes, composetypes.ServiceVolumeConfig{
+				Type:     string(mount.Type),
+				Source:   source,
+				Target:   mount.Target,
+				ReadOnly: mount.ReadOnly,
+			})
+		}
+	}
+	switch {
+	case spec.Mode.Replicated != nil:
+		config.Deploy.Replicas = spec.Mode.Replicated.Replicas
+	case spec.Mode.Global != nil:
+		config.Deploy.Mode = "global"
+	}
+	if spec.EndpointSpec != nil {
+		for _, port := range spec.EndpointSpec.Ports {
+			config.Ports = append(config.Ports, composetypes.ServicePortConfig{
+				Mode:      string(port.PublishMode),
+				Target:    port.TargetPort,
+				Published: port.PublishedPort,
+				Protocol:  string(port.Protocol),
+			})
+		}
+	}
+	networks := spec.TaskTemplate.Networks
+	if len(networks) == 0 {
+		networks = spec.Networks
+	}
+	if len(networks) > 0 {
+		config.Networks = map[string]*composetypes.ServiceNetworkConfig{}
+	}
+	for _, network := range networks {
+		networkName, ok := networkNames[network.Target]
+		if !ok {
+			networkName = network.Target
+		}
+		var aliases []string
+		for _, alias := range network.Aliases {
+			// the service name is always added as an alias on deploy
+			if alias != name {
+				aliases = append(aliases, alias)
+			}
+		}
+		var networkConfig *composetypes.ServiceNetworkConfig
+		if len(aliases) > 0 {
+			networkConfig = &composetypes.ServiceNetworkConfig{Aliases: aliases}
+		}
+		config.Networks[strings.TrimPrefix(networkName, stackName+"_")] = networkConfig
+	}
+	return config
+}
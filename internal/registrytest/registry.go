@@ -0,0 +1,242 @@
+package registrytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+// Registry is an in-memory registry, serving the subset of the registry v2 API
+// used by resto
+type Registry struct {
+	*httptest.Server
+	mu sync.Mutex
+	// blobs are shared across repositories
+	blobs map[digest.Digest][]byte
+	// manifests are indexed by repository, then by tag and digest
+	manifests map[string]map[string]manifest
+	uploads   map[string][]byte
+	rejected  map[string]bool
+}
+
+type manifest struct {
+	mediaType string
+	content   []byte
+}
+
+// New starts a registry, which must be closed by the caller
+func New(ops ...func(*Registry)) *Registry {
+	r := &Registry{
+		blobs:     map[digest.Digest][]byte{},
+		manifests: map[string]map[string]manifest{},
+		uploads:   map[string][]byte{},
+		rejected:  map[string]bool{},
+	}
+	for _, op := range ops {
+		op(r)
+	}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	return r
+}
+
+// WithRejectedMediaTypes makes the registry reject manifests of the given
+// media types, as registries which don't support them do
+func WithRejectedMediaTypes(mediaTypes ...string) func(*Registry) {
+	return func(r *Registry) {
+		for _, m := range mediaTypes {
+			r.rejected[m] = true
+		}
+	}
+}
+
+// Host returns the host:port of the registry, to be used as the domain of
+// image references
+func (r *Registry) Host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+// Manifest returns the media type and content of a manifest of the repository,
+// by tag or digest
+func (r *Registry) Manifest(repo, ref string) (string, []byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.manifests[repo][ref]
+	return m.mediaType, m.content, ok
+}
+
+func (r *Registry) serve(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	p := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case p == "" || p == "/":
+		w.WriteHeader(http.StatusOK)
+	case p == "_catalog":
+		r.serveCatalog(w)
+	case strings.HasSuffix(p, "/tags/list"):
+		r.serveTags(w, strings.TrimSuffix(p, "/tags/list"))
+	case strings.Contains(p, "/manifests/"):
+		i := strings.LastIndex(p, "/manifests/")
+		r.serveManifest(w, req, p[:i], p[i+len("/manifests/"):])
+	case strings.Contains(p, "/blobs/uploads/"):
+		i := strings.LastIndex(p, "/blobs/uploads/")
+		r.serveUpload(w, req, p[:i], p[i+len("/blobs/uploads/"):])
+	case strings.Contains(p, "/blobs/"):
+		i := strings.LastIndex(p, "/blobs/")
+		r.serveBlob(w, req, digest.Digest(p[i+len("/blobs/"):]))
+	default:
+		writeError(w, http.StatusNotFound, "UNSUPPORTED", "unsupported")
+	}
+}
+
+func (r *Registry) serveCatalog(w http.ResponseWriter) {
+	repos := []string{}
+	for repo := range r.manifests {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	writeJSON(w, map[string][]string{"repositories": repos})
+}
+
+func (r *Registry) serveTags(w http.ResponseWriter, repo string) {
+	manifests, ok := r.manifests[repo]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN", "repository name not known to registry")
+		return
+	}
+	tags := []string{}
+	for ref := range manifests {
+		if _, err := digest.Parse(ref); err != nil {
+			tags = append(tags, ref)
+		}
+	}
+	sort.Strings(tags)
+	writeJSON(w, map[string]interface{}{"name": repo, "tags": tags})
+}
+
+func (r *Registry) serveManifest(w http.ResponseWriter, req *http.Request, repo, ref string) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		m, ok := r.manifests[repo][ref]
+		if !ok {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown")
+			return
+		}
+		w.Header().Set("Content-Type", m.mediaType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(m.content)))
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(m.content).String())
+		w.WriteHeader(http.StatusOK)
+		if req.Method == http.MethodGet {
+			w.Write(m.content)
+		}
+	case http.MethodPut:
+		mediaType := req.Header.Get("Content-Type")
+		if r.rejected[mediaType] {
+			writeError(w, http.StatusBadRequest, "MANIFEST_INVALID", "manifest invalid")
+			return
+		}
+		content, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "MANIFEST_INVALID", err.Error())
+			return
+		}
+		dgst := digest.FromBytes(content)
+		if r.manifests[repo] == nil {
+			r.manifests[repo] = map[string]manifest{}
+		}
+		m := manifest{mediaType: mediaType, content: content}
+		r.manifests[repo][ref] = m
+		r.manifests[repo][dgst.String()] = m
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/manifests/%s", repo, dgst))
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.WriteHeader(http.StatusCreated)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "unsupported")
+	}
+}
+
+func (r *Registry) serveUpload(w http.ResponseWriter, req *http.Request, repo, id string) {
+	location := fmt.Sprintf("/v2/%s/blobs/uploads/", repo)
+	switch req.Method {
+	case http.MethodPost:
+		id = strconv.Itoa(len(r.uploads) + 1)
+		r.uploads[id] = nil
+		w.Header().Set("Location", location+id)
+		w.Header().Set("Docker-Upload-UUID", id)
+		w.Header().Set("Range", "0-0")
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPatch, http.MethodPut:
+		data, ok := r.uploads[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "blob upload unknown")
+			return
+		}
+		chunk, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "BLOB_UPLOAD_INVALID", err.Error())
+			return
+		}
+		data = append(data, chunk...)
+		r.uploads[id] = data
+		if req.Method == http.MethodPatch {
+			w.Header().Set("Location", location+id)
+			w.Header().Set("Docker-Upload-UUID", id)
+			end := len(data)
+			if end > 0 {
+				end--
+			}
+			w.Header().Set("Range", fmt.Sprintf("0-%d", end))
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		dgst := digest.Digest(req.URL.Query().Get("digest"))
+		if digest.FromBytes(data) != dgst {
+			writeError(w, http.StatusBadRequest, "DIGEST_INVALID", "provided digest did not match uploaded content")
+			return
+		}
+		delete(r.uploads, id)
+		r.blobs[dgst] = data
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", repo, dgst))
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.WriteHeader(http.StatusCreated)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "unsupported")
+	}
+}
+
+func (r *Registry) serveBlob(w http.ResponseWriter, req *http.Request, dgst digest.Digest) {
+	data, ok := r.blobs[dgst]
+	if !ok {
+		writeError(w, http.StatusNotFound, "BLOB_UNKNOWN", "blob unknown to registry")
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{{"code": code, "message": message}},
+	})
+}
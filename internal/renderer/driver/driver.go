@@ -1,7 +1,49 @@
 package driver
 
+import "strings"
+
 // Driver is the interface that must be implemented by a renderer driver.
 type Driver interface {
 	// Apply applies the settings to the string
 	Apply(s string, settings map[string]interface{}) (string, error)
 }
+
+// Options are the rendering options, for the drivers which take them
+type Options struct {
+	// Dir is the application directory, if the application has one
+	Dir string
+}
+
+// OptionsDriver is the interface implemented by the drivers which take
+// rendering options.
+type OptionsDriver interface {
+	// ApplyWithOptions applies the settings to the string, with the options
+	ApplyWithOptions(s string, settings map[string]interface{}, opts Options) (string, error)
+}
+
+// Error is an error at a position of the string the settings are applied to
+type Error struct {
+	// Line and Column start at 1, and are 0 when unknown
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Cause returns the underlying error
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// Position returns the line and the column, starting at 1, of the offset in
+// the string
+func Position(s string, offset int) (int, int) {
+	if offset > len(s) {
+		offset = len(s)
+	}
+	before := s[:offset]
+	return strings.Count(before, "\n") + 1, offset - strings.LastIndex(before, "\n")
+}
@@ -0,0 +1,20 @@
+package driver
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestPosition(t *testing.T) {
+	s := "version: \"3.6\"\nservices:\n  web:\n"
+	line, column := Position(s, 0)
+	assert.Equal(t, line, 1)
+	assert.Equal(t, column, 1)
+	line, column = Position(s, 17)
+	assert.Equal(t, line, 2)
+	assert.Equal(t, column, 3)
+	line, column = Position(s, len(s)+10)
+	assert.Equal(t, line, 4)
+	assert.Equal(t, column, 1)
+}
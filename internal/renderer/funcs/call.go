@@ -0,0 +1,78 @@
+package funcs
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Call calls the function of the library, converting the arguments to the
+// types of its parameters, for renderers which don't call Go functions
+// themselves. As with text/template, a panicking function returns an error.
+func Call(name string, args ...interface{}) (interface{}, error) {
+	return call(Map(), name, args...)
+}
+
+func call(fns map[string]interface{}, name string, args ...interface{}) (result interface{}, err error) {
+	fn, ok := fns[name]
+	if !ok {
+		return nil, errors.Errorf("function %q not defined", name)
+	}
+	f := reflect.ValueOf(fn)
+	t := f.Type()
+	if (!t.IsVariadic() && len(args) != t.NumIn()) || (t.IsVariadic() && len(args) < t.NumIn()-1) {
+		return nil, errors.Errorf("wrong number of arguments for %s: want %d got %d", name, t.NumIn(), len(args))
+	}
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var typ reflect.Type
+		if t.IsVariadic() && i >= t.NumIn()-1 {
+			typ = t.In(t.NumIn() - 1).Elem()
+		} else {
+			typ = t.In(i)
+		}
+		v, err := convert(arg, typ)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error calling %s", name)
+		}
+		in[i] = v
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, errors.Errorf("error calling %s: %v", name, r)
+		}
+	}()
+	out := f.Call(in)
+	if len(out) == 2 && !out[1].IsNil() {
+		return nil, errors.Wrapf(out[1].Interface().(error), "error calling %s", name)
+	}
+	return out[0].Interface(), nil
+}
+
+func convert(arg interface{}, typ reflect.Type) (reflect.Value, error) {
+	if arg == nil {
+		return reflect.Zero(typ), nil
+	}
+	v := reflect.ValueOf(arg)
+	if v.Type().AssignableTo(typ) {
+		return v, nil
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(fmt.Sprint(arg)), nil
+	case reflect.Int:
+		if i, err := toInt(arg); err == nil {
+			return reflect.ValueOf(int(i)), nil
+		}
+	case reflect.Bool:
+		if s, ok := arg.(string); ok {
+			if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+				return reflect.ValueOf(b), nil
+			}
+		}
+	}
+	return reflect.Value{}, errors.Errorf("wrong type for value; expected %s; got %T", typ, arg)
+}
@@ -0,0 +1,404 @@
+// Package funcs is the deterministic function library of the template renderers.
+package funcs
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/app/internal/semver"
+	"github.com/docker/app/internal/yaml"
+	"github.com/pkg/errors"
+)
+
+// Map returns the functions the template renderers share. They are
+// deterministic: none of them reads the time, the environment, files or the
+// network. As with pipelines, the value a function operates on is its last
+// argument, e.g. {{ .web.port | default 80 }}.
+func Map() map[string]interface{} {
+	return map[string]interface{}{
+		// defaults
+		"default":  defaultValue,
+		"required": required,
+		"empty":    empty,
+		"coalesce": coalesce,
+		"ternary":  ternary,
+		// strings
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"title":      strings.Title,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.Replace(s, old, new, -1) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"repeat":     repeat,
+		"trunc":      trunc,
+		"quote":      func(v interface{}) string { return strconv.Quote(toString(v)) },
+		"squote":     func(v interface{}) string { return "'" + toString(v) + "'" },
+		"indent":     indent,
+		"nindent":    nindent,
+		"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       join,
+		"toString":   toString,
+		// encodings
+		"b64enc":    func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"b64dec":    b64dec,
+		"sha256sum": func(s string) string { return fmt.Sprintf("%x", sha256.Sum256([]byte(s))) },
+		"toYaml":    toYaml,
+		"toJson":    toJSON,
+		// versions
+		"semverCompare": semverCompare,
+		// lists and dicts
+		"list":      func(items ...interface{}) []interface{} { return items },
+		"dict":      dict,
+		"keys":      keys,
+		"hasKey":    hasKey,
+		"get":       get,
+		"first":     first,
+		"last":      last,
+		"has":       has,
+		"uniq":      uniq,
+		"sortAlpha": sortAlpha,
+		// numbers
+		"int": toInt,
+		"add": arithmetic(func(a, b int64) (int64, error) { return a + b, nil }),
+		"sub": arithmetic(func(a, b int64) (int64, error) { return a - b, nil }),
+		"mul": arithmetic(func(a, b int64) (int64, error) { return a * b, nil }),
+		"div": arithmetic(func(a, b int64) (int64, error) {
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a / b, nil
+		}),
+		"mod": arithmetic(func(a, b int64) (int64, error) {
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a % b, nil
+		}),
+		"max": arithmetic(func(a, b int64) (int64, error) {
+			if a > b {
+				return a, nil
+			}
+			return b, nil
+		}),
+		"min": arithmetic(func(a, b int64) (int64, error) {
+			if a < b {
+				return a, nil
+			}
+			return b, nil
+		}),
+	}
+}
+
+// empty returns whether the value is missing, or the zero value of its type,
+// or an empty list or map
+func empty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return reflect.DeepEqual(v, reflect.Zero(rv.Type()).Interface())
+}
+
+func defaultValue(def, v interface{}) interface{} {
+	if empty(v) {
+		return def
+	}
+	return v
+}
+
+func required(message string, v interface{}) (interface{}, error) {
+	if empty(v) {
+		return nil, errors.New(message)
+	}
+	return v, nil
+}
+
+func coalesce(values ...interface{}) interface{} {
+	for _, v := range values {
+		if !empty(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+func ternary(whenTrue, whenFalse interface{}, condition bool) interface{} {
+	if condition {
+		return whenTrue
+	}
+	return whenFalse
+}
+
+func toString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func trunc(length int, s string) string {
+	if length < 0 || len(s) <= length {
+		return s
+	}
+	return s[:length]
+}
+
+// maxRepeatSize is the maximum size of the strings repeat and indent build,
+// so that templates can't exhaust the memory
+const maxRepeatSize = 1 << 20
+
+func repeat(count int, s string) (string, error) {
+	if count < 0 {
+		return "", errors.Errorf("negative repeat count %d", count)
+	}
+	if len(s) > 0 && count > maxRepeatSize/len(s) {
+		return "", errors.Errorf("repeating %d times a string of %d bytes exceeds the maximum of %d bytes", count, len(s), maxRepeatSize)
+	}
+	return strings.Repeat(s, count), nil
+}
+
+// indent indents every line of the string by the number of spaces
+func indent(spaces int, s string) (string, error) {
+	if spaces < 0 {
+		return "", errors.Errorf("negative indentation %d", spaces)
+	}
+	lines := strings.Count(s, "\n") + 1
+	if spaces > 0 && spaces > (maxRepeatSize-len(s))/lines {
+		return "", errors.Errorf("indenting %d lines by %d spaces exceeds the maximum of %d bytes", lines, spaces, maxRepeatSize)
+	}
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.Replace(s, "\n", "\n"+pad, -1), nil
+}
+
+func nindent(spaces int, s string) (string, error) {
+	s, err := indent(spaces, s)
+	if err != nil {
+		return "", err
+	}
+	return "\n" + s, nil
+}
+
+func join(sep string, list interface{}) (string, error) {
+	items, err := toList(list)
+	if err != nil {
+		return "", err
+	}
+	strs := make([]string, len(items))
+	for i, item := range items {
+		strs[i] = toString(item)
+	}
+	return strings.Join(strs, sep), nil
+}
+
+func b64dec(s string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid base64 value")
+	}
+	return string(data), nil
+}
+
+// toYaml returns the YAML representation of the value, without the final new
+// line, so that it can be indented
+func toYaml(v interface{}) (string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func semverCompare(constraint string, version interface{}) (bool, error) {
+	c, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+	v, err := semver.Parse(toString(version))
+	if err != nil {
+		return false, err
+	}
+	return c.Check(v), nil
+}
+
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("dict expects key and value pairs")
+	}
+	d := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		d[toString(pairs[i])] = pairs[i+1]
+	}
+	return d, nil
+}
+
+// toMap returns the string-keyed map the settings maps are
+func toMap(v interface{}) (map[string]interface{}, error) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, nil
+	case nil:
+		return nil, nil
+	}
+	return nil, errors.Errorf("%v is not a map", v)
+}
+
+func keys(v interface{}) ([]string, error) {
+	m, err := toMap(v)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func hasKey(v interface{}, key string) (bool, error) {
+	m, err := toMap(v)
+	if err != nil {
+		return false, err
+	}
+	_, ok := m[key]
+	return ok, nil
+}
+
+func get(v interface{}, key string) (interface{}, error) {
+	m, err := toMap(v)
+	if err != nil {
+		return nil, err
+	}
+	return m[key], nil
+}
+
+func toList(v interface{}) ([]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, errors.Errorf("%v is not a list", v)
+	}
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, nil
+}
+
+func first(v interface{}) (interface{}, error) {
+	items, err := toList(v)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return items[0], nil
+}
+
+func last(v interface{}) (interface{}, error) {
+	items, err := toList(v)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return items[len(items)-1], nil
+}
+
+func has(item, list interface{}) (bool, error) {
+	items, err := toList(list)
+	if err != nil {
+		return false, err
+	}
+	for _, i := range items {
+		if reflect.DeepEqual(i, item) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func uniq(list interface{}) ([]interface{}, error) {
+	items, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+	var unique []interface{}
+	for _, item := range items {
+		if ok, _ := has(item, unique); !ok {
+			unique = append(unique, item)
+		}
+	}
+	return unique, nil
+}
+
+func sortAlpha(list interface{}) ([]string, error) {
+	items, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+	strs := make([]string, len(items))
+	for i, item := range items {
+		strs[i] = toString(item)
+	}
+	sort.Strings(strs)
+	return strs, nil
+}
+
+func toInt(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case uint64:
+		return int64(n), nil
+	case float64:
+		return int64(n), nil
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+		if err != nil {
+			return 0, errors.Errorf("%q is not an integer", n)
+		}
+		return i, nil
+	case nil:
+		return 0, nil
+	}
+	return 0, errors.Errorf("%v is not an integer", v)
+}
+
+func arithmetic(op func(a, b int64) (int64, error)) func(a, b interface{}) (int64, error) {
+	return func(a, b interface{}) (int64, error) {
+		x, err := toInt(a)
+		if err != nil {
+			return 0, err
+		}
+		y, err := toInt(b)
+		if err != nil {
+			return 0, err
+		}
+		return op(x, y)
+	}
+}
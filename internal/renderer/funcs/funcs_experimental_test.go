@@ -0,0 +1,88 @@
+// +build experimental
+
+package funcs_test
+
+import (
+	"testing"
+
+	"github.com/docker/app/internal/renderer/gotemplate"
+	"github.com/docker/app/internal/renderer/mustache"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+var settings = map[string]interface{}{
+	"app": map[string]interface{}{
+		"name":    "My App",
+		"version": "1.4.2",
+		"port":    8080,
+		"tags":    []interface{}{"web", "db", "web"},
+	},
+	"empty":  "",
+	"secret": "aGVsbG8=",
+	"labels": map[string]interface{}{"tier": "front", "env": "prod"},
+}
+
+// TestRenderersParity checks that the renderers produce the same output for
+// the shared functions
+func TestRenderersParity(t *testing.T) {
+	testCases := []struct {
+		gotemplate string
+		mustache   string
+		expected   string
+	}{
+		{`{{ .empty | default "none" }}`, `{{default "none" empty}}`, "none"},
+		{`{{ .app.port | default 80 }}`, `{{default 80 app.port}}`, "8080"},
+		{`{{ coalesce .empty .app.name }}`, `{{coalesce empty app.name}}`, "My App"},
+		{`{{ ternary "on" "off" true }}`, `{{ternary "on" "off" true}}`, "on"},
+		{`{{ .app.name | upper }}`, `{{upper app.name}}`, "MY APP"},
+		{`{{ .app.name | lower }}`, `{{lower app.name}}`, "my app"},
+		{`{{ title "my app" }}`, `{{title "my app"}}`, "My App"},
+		{`{{ .app.name | replace " " "-" }}`, `{{replace " " "-" app.name}}`, "My-App"},
+		{`{{ .app.name | trunc 2 }}`, `{{trunc 2 app.name}}`, "My"},
+		{`{{ .app.name | quote }}`, `{{quote app.name}}`, `"My App"`},
+		{`{{ .app.name | squote }}`, `{{squote app.name}}`, `'My App'`},
+		{`{{ .app.name | b64enc }}`, `{{b64enc app.name}}`, "TXkgQXBw"},
+		{`{{ .secret | b64dec }}`, `{{b64dec secret}}`, "hello"},
+		{`{{ sha256sum "hello" }}`, `{{sha256sum "hello"}}`, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{`{{ .labels | toYaml | indent 2 }}`, `{{#indent 2}}{{toYaml labels}}{{/indent}}`, "  env: prod\n  tier: front"},
+		{`{{ .labels | toJson }}`, `{{toJson labels}}`, `{"env":"prod","tier":"front"}`},
+		{`{{ .app.tags | join "," }}`, `{{join "," app.tags}}`, "web,db,web"},
+		{`{{ .app.tags | uniq }}`, `{{uniq app.tags}}`, "[web db]"},
+		{`{{ .app.tags | sortAlpha }}`, `{{sortAlpha app.tags}}`, "[db web web]"},
+		{`{{ .labels | keys }}`, `{{keys labels}}`, "[env tier]"},
+		{`{{ .app.tags | first }}`, `{{first app.tags}}`, "web"},
+		{`{{ .app.tags | has "db" }}`, `{{has "db" app.tags}}`, "true"},
+		{`{{ hasKey .labels "tier" }}`, `{{hasKey labels "tier"}}`, "true"},
+		{`{{ get .labels "tier" }}`, `{{get labels "tier"}}`, "front"},
+		{`{{ semverCompare ">=1.2.0" .app.version }}`, `{{semverCompare ">=1.2.0" app.version}}`, "true"},
+		{`{{ add .app.port 1 }}`, `{{add app.port 1}}`, "8081"},
+		{`{{ max 3 7 }}`, `{{max 3 7}}`, "7"},
+		{`{{ "a,b" | split "," }}`, `{{split "," "a,b"}}`, "[a b]"},
+		{`{{ b64enc "x" | b64dec }}`, `{{#b64dec}}{{b64enc "x"}}{{/b64dec}}`, "x"},
+	}
+	for _, tc := range testCases {
+		out, err := (&gotemplate.Driver{}).Apply(tc.gotemplate, settings)
+		assert.NilError(t, err, tc.gotemplate)
+		assert.Check(t, is.Equal(out, tc.expected), tc.gotemplate)
+		out, err = (&mustache.Driver{}).Apply(tc.mustache, settings)
+		assert.NilError(t, err, tc.mustache)
+		assert.Check(t, is.Equal(out, tc.expected), tc.mustache)
+	}
+}
+
+func TestRenderersInvalidCounts(t *testing.T) {
+	_, err := (&gotemplate.Driver{}).Apply(`{{ "ab" | repeat -1 }}`, settings)
+	assert.Check(t, is.ErrorContains(err, "negative repeat count -1"))
+	_, err = (&mustache.Driver{}).Apply(`{{repeat -1 "ab"}}`, settings)
+	assert.Check(t, is.ErrorContains(err, "negative repeat count -1"))
+	_, err = (&mustache.Driver{}).Apply(`{{#nindent -2}}a{{/nindent}}`, settings)
+	assert.Check(t, is.ErrorContains(err, "negative indentation -2"))
+}
+
+func TestRenderersRequired(t *testing.T) {
+	_, err := (&gotemplate.Driver{}).Apply(`{{ .empty | required "empty is required" }}`, settings)
+	assert.Check(t, is.ErrorContains(err, "empty is required"))
+	_, err = (&mustache.Driver{}).Apply(`{{required "empty is required" empty}}`, settings)
+	assert.Check(t, is.ErrorContains(err, "empty is required"))
+}
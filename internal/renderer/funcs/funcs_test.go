@@ -0,0 +1,92 @@
+package funcs
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestEmpty(t *testing.T) {
+	for _, v := range []interface{}{nil, "", 0, false, []interface{}{}, map[string]interface{}{}} {
+		assert.Check(t, empty(v), "%#v", v)
+	}
+	for _, v := range []interface{}{"a", 1, true, []string{"a"}} {
+		assert.Check(t, !empty(v), "%#v", v)
+	}
+}
+
+func TestRequired(t *testing.T) {
+	v, err := required("port is required", 80)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(v, 80))
+	_, err = required("port is required", nil)
+	assert.Check(t, is.Error(err, "port is required"))
+}
+
+func TestSemverCompare(t *testing.T) {
+	ok, err := semverCompare(">=1.2.0", "1.3.0")
+	assert.NilError(t, err)
+	assert.Check(t, ok)
+	ok, err = semverCompare("^1.2.0", "2.0.0")
+	assert.NilError(t, err)
+	assert.Check(t, !ok)
+	_, err = semverCompare(">=1.2.0", "latest")
+	assert.Check(t, err != nil)
+}
+
+func TestArithmetic(t *testing.T) {
+	fns := Map()
+	v, err := fns["add"].(func(a, b interface{}) (int64, error))("2", 3.0)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(v, int64(5)))
+	_, err = fns["div"].(func(a, b interface{}) (int64, error))(1, 0)
+	assert.Check(t, is.Error(err, "division by zero"))
+	_, err = fns["mul"].(func(a, b interface{}) (int64, error))("two", 2)
+	assert.Check(t, is.Error(err, `"two" is not an integer`))
+}
+
+func TestRepeatAndIndent(t *testing.T) {
+	s, err := repeat(3, "ab")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(s, "ababab"))
+	_, err = repeat(-1, "ab")
+	assert.Check(t, is.Error(err, "negative repeat count -1"))
+	_, err = repeat(maxRepeatSize, "ab")
+	assert.Check(t, is.ErrorContains(err, "exceeds the maximum"))
+
+	s, err = nindent(2, "a\nb")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(s, "\n  a\n  b"))
+	_, err = indent(-2, "a")
+	assert.Check(t, is.Error(err, "negative indentation -2"))
+	_, err = nindent(maxRepeatSize, "a")
+	assert.Check(t, is.ErrorContains(err, "exceeds the maximum"))
+}
+
+func TestCallRecovers(t *testing.T) {
+	fns := map[string]interface{}{"boom": func(s string) string { panic(s) }}
+	_, err := call(fns, "boom", "bang")
+	assert.Check(t, is.Error(err, "error calling boom: bang"))
+	_, err = Call("repeat", -1, "ab")
+	assert.Check(t, is.Error(err, "error calling repeat: negative repeat count -1"))
+}
+
+func TestLists(t *testing.T) {
+	u, err := uniq([]interface{}{"a", "b", "a"})
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(u, []interface{}{"a", "b"}))
+	s, err := sortAlpha([]interface{}{"b", "c", "a"})
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(s, []string{"a", "b", "c"}))
+	_, err = first("a")
+	assert.Check(t, is.Error(err, "a is not a list"))
+	_, err = dict("a")
+	assert.Check(t, is.Error(err, "dict expects key and value pairs"))
+}
+
+func TestToYaml(t *testing.T) {
+	s, err := toYaml(map[string]interface{}{"b": 1, "a": []string{"x"}})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(s, "a:\n- x\nb: 1"))
+}
@@ -4,12 +4,20 @@ package gotemplate
 
 import (
 	"bytes"
+	"regexp"
+	"strconv"
 	"text/template"
 
 	"github.com/docker/app/internal/renderer"
+	"github.com/docker/app/internal/renderer/driver"
+	"github.com/docker/app/internal/renderer/funcs"
 	"github.com/pkg/errors"
 )
 
+// errorPosition matches the position text/template gives in its errors: the
+// line, and for execution errors the column, starting at 0
+var errorPosition = regexp.MustCompile(`template: compose:(\d+)(?::(\d+))?:`)
+
 func init() {
 	renderer.Register("gotemplate", &Driver{})
 }
@@ -19,14 +27,29 @@ type Driver struct{}
 
 // Apply applies the settings to the string
 func (d *Driver) Apply(s string, settings map[string]interface{}) (string, error) {
-	tmpl, err := template.New("compose").Parse(s)
+	tmpl, err := template.New("compose").Funcs(funcs.Map()).Parse(s)
 	if err != nil {
-		return "", err
+		return "", located(err)
 	}
 	tmpl.Option("missingkey=error")
 	buf := bytes.NewBuffer(nil)
 	if err := tmpl.Execute(buf, settings); err != nil {
-		return "", errors.Wrap(err, "failed to execute go template")
+		return "", located(errors.Wrap(err, "failed to execute go template"))
 	}
 	return buf.String(), nil
 }
+
+// located returns the error with the position text/template gives, if any
+func located(err error) error {
+	m := errorPosition.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	line, _ := strconv.Atoi(m[1])
+	column := 0
+	if m[2] != "" {
+		column, _ = strconv.Atoi(m[2])
+		column++
+	}
+	return &driver.Error{Line: line, Column: column, Err: err}
+}
@@ -5,6 +5,7 @@ package gotemplate
 import (
 	"testing"
 
+	"github.com/docker/app/internal/renderer/driver"
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
 )
@@ -71,3 +72,32 @@ func TestDriver(t *testing.T) {
 	assert.NilError(t, err)
 	assert.Check(t, is.Equal(s, expectedCompose))
 }
+
+func TestDriverErrorPosition(t *testing.T) {
+	testCases := []struct {
+		name     string
+		template string
+		line     int
+		column   int
+	}{
+		{
+			name:     "parse",
+			template: "version: \"3.4\"\nservices: {{}}\n",
+			line:     2,
+		},
+		{
+			name:     "execute",
+			template: "version: \"3.4\"\nservices:\n  web:\n    image: {{.web.image}}\n",
+			line:     4,
+			column:   18,
+		},
+	}
+	d := &Driver{}
+	for _, tc := range testCases {
+		_, err := d.Apply(tc.template, map[string]interface{}{})
+		derr, ok := err.(*driver.Error)
+		assert.Assert(t, ok, "%s: %v", tc.name, err)
+		assert.Check(t, is.Equal(derr.Line, tc.line), tc.name)
+		assert.Check(t, is.Equal(derr.Column, tc.column), tc.name)
+	}
+}
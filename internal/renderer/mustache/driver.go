@@ -3,11 +3,19 @@
 package mustache
 
 import (
+	"regexp"
+	"strconv"
+	"strings"
+
 	"github.com/cbroglie/mustache"
 	"github.com/docker/app/internal/renderer"
+	"github.com/docker/app/internal/renderer/driver"
 	"github.com/pkg/errors"
 )
 
+// parseErrorLine matches the line mustache gives in its parse errors
+var parseErrorLine = regexp.MustCompile(`^line (\d+): `)
+
 func init() {
 	renderer.Register("mustache", &Driver{})
 }
@@ -15,11 +23,70 @@ func init() {
 // Driver is the mustache implementation of rendered drivers.
 type Driver struct{}
 
-// Apply applies the settings to the string
+// Apply applies the settings to the string. Function tags of the shared
+// function library see the top-level settings, even inside sections.
 func (d *Driver) Apply(s string, settings map[string]interface{}) (string, error) {
+	data, err := d.render(s, 0, settings, &[]call{})
+	if err != nil {
+		err = errors.Wrap(err, "failed to execute mustache template")
+		if perr, ok := errors.Cause(err).(*positionError); ok {
+			return "", perr.located(s, err)
+		}
+		return "", err
+	}
+	return data, nil
+}
+
+// render renders the string, which starts at the offset of the template
+func (d *Driver) render(s string, offset int, settings map[string]interface{}, calls *[]call) (string, error) {
+	first := len(*calls)
+	s, err := extractCalls(s, offset, settings, calls)
+	if err != nil {
+		return "", err
+	}
 	data, err := mustache.Render(s, settings)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to execute mustache template")
+		// placeholders keep the lines of the tags they replace
+		if m := parseErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			return "", &positionError{offset: offset, line: line, err: err}
+		}
+		return "", err
+	}
+	for i := first; i < len(*calls); i++ {
+		c := (*calls)[i]
+		if !strings.Contains(data, c.placeholder) {
+			continue
+		}
+		output, err := c.render(settings, calls, d)
+		if err != nil {
+			if _, ok := err.(*positionError); !ok {
+				err = &positionError{offset: c.offset, err: err}
+			}
+			return "", err
+		}
+		data = strings.Replace(data, c.placeholder, output, -1)
 	}
 	return data, nil
 }
+
+// positionError is an error at an offset of the template or, if the line is
+// set, at a line of the text starting at the offset
+type positionError struct {
+	offset int
+	line   int
+	err    error
+}
+
+func (e *positionError) Error() string {
+	return e.err.Error()
+}
+
+// located returns the error, with the position of the error in the template
+func (e *positionError) located(template string, err error) error {
+	line, column := driver.Position(template, e.offset)
+	if e.line != 0 {
+		line, column = line+e.line-1, 0
+	}
+	return &driver.Error{Line: line, Column: column, Err: err}
+}
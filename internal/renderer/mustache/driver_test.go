@@ -5,6 +5,7 @@ package mustache
 import (
 	"testing"
 
+	"github.com/docker/app/internal/renderer/driver"
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
 )
@@ -64,3 +65,53 @@ func TestDriver(t *testing.T) {
 	assert.NilError(t, err)
 	assert.Check(t, is.Equal(s, expectedCompose))
 }
+
+func TestDriverFunctions(t *testing.T) {
+	d := &Driver{}
+	s, err := d.Apply(`{{#myapp.enable}}{{upper myapp.alpine_version}}-{{#trim}} {{myapp.alpine_version}} {{/trim}}{{/myapp.enable}}`, settings)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(s, "3.7-3.7"))
+
+	// settings take precedence over functions for tags without arguments
+	s, err = d.Apply(`{{#upper}}{{lower}}{{/upper}}`, map[string]interface{}{"upper": true, "lower": "x"})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(s, "x"))
+
+	_, err = d.Apply(`{{#upper}}x`, nil)
+	assert.Check(t, is.ErrorContains(err, "missing {{/upper}} for section upper"))
+}
+
+func TestDriverErrorPosition(t *testing.T) {
+	testCases := []struct {
+		name     string
+		template string
+		line     int
+		column   int
+	}{
+		{
+			name:     "parse",
+			template: "version: \"3.4\"\nservices:\n  web:\n    image: {{}}\n",
+			line:     4,
+		},
+		{
+			name:     "function",
+			template: "version: \"3.4\"\nservices:\n  web:\n    image: {{required \"no image\" web.image}}\n",
+			line:     4,
+			column:   12,
+		},
+		{
+			name:     "after a section",
+			template: "version: \"3.4\"\n{{#upper}}\nservices:\n{{/upper}}\n  web:\n    image: {{required \"no image\" web.image}}\n",
+			line:     6,
+			column:   12,
+		},
+	}
+	d := &Driver{}
+	for _, tc := range testCases {
+		_, err := d.Apply(tc.template, map[string]interface{}{})
+		derr, ok := err.(*driver.Error)
+		assert.Assert(t, ok, "%s: %v", tc.name, err)
+		assert.Check(t, is.Equal(derr.Line, tc.line), tc.name)
+		assert.Check(t, is.Equal(derr.Column, tc.column), tc.name)
+	}
+}
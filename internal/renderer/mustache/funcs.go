@@ -0,0 +1,194 @@
+// +build experimental
+
+package mustache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/app/internal/renderer/funcs"
+	"github.com/pkg/errors"
+)
+
+const (
+	openTag  = "{{"
+	closeTag = "}}"
+)
+
+// call is a function tag found in a template
+type call struct {
+	name string
+	args []string
+	// body is the template of a section tag, which is rendered and passed
+	// as the last argument
+	body *string
+	// offset is the offset of the tag in the template, and bodyOffset the
+	// offset of the body
+	offset     int
+	bodyOffset int
+	// placeholder replaces the tag in the template
+	placeholder string
+}
+
+// extractCalls replaces the function tags of the template, which starts at
+// the offset, with placeholders, as mustache has no lambdas. Function tags are
+// {{fn arg...}} and sections {{#fn arg...}}text{{/fn}}; tags without arguments
+// are only function tags when no setting has their name.
+func extractCalls(s string, offset int, settings map[string]interface{}, calls *[]call) (string, error) {
+	fns := funcs.Map()
+	out := strings.Builder{}
+	for {
+		start := strings.Index(s, openTag)
+		if start < 0 {
+			out.WriteString(s)
+			return out.String(), nil
+		}
+		end := strings.Index(s[start:], closeTag)
+		if end < 0 {
+			out.WriteString(s)
+			return out.String(), nil
+		}
+		end += start
+		tag := s[start+len(openTag) : end]
+		section := strings.HasPrefix(tag, "#")
+		fields, err := splitArgs(strings.TrimPrefix(tag, "#"))
+		if err != nil {
+			return "", &positionError{offset: offset + start, err: errors.Wrapf(err, "invalid tag %q", s[start:end+len(closeTag)])}
+		}
+		if len(fields) == 0 || !isCall(fns, fields, section, settings) {
+			out.WriteString(s[:end+len(closeTag)])
+			offset += end + len(closeTag)
+			s = s[end+len(closeTag):]
+			continue
+		}
+		c := call{name: fields[0], args: fields[1:], offset: offset + start}
+		out.WriteString(s[:start])
+		replaced := s[start : end+len(closeTag)]
+		offset += end + len(closeTag)
+		s = s[end+len(closeTag):]
+		if section {
+			body, rest, err := sectionBody(s, c.name)
+			if err != nil {
+				return "", &positionError{offset: c.offset, err: err}
+			}
+			c.body = &body
+			c.bodyOffset = offset
+			replaced += s[:len(s)-len(rest)]
+			offset += len(s) - len(rest)
+			s = rest
+		}
+		c.placeholder = placeholder(len(*calls), strings.Count(replaced, "\n"))
+		out.WriteString(c.placeholder)
+		*calls = append(*calls, c)
+	}
+}
+
+func isCall(fns map[string]interface{}, fields []string, section bool, settings map[string]interface{}) bool {
+	if _, ok := fns[fields[0]]; !ok {
+		return false
+	}
+	if len(fields) > 1 {
+		return true
+	}
+	_, isSetting := settings[fields[0]]
+	return section && !isSetting
+}
+
+// sectionBody returns the body of the section of the function, and what
+// follows its closing tag
+func sectionBody(s, name string) (string, string, error) {
+	open := openTag + "#" + name
+	end := openTag + "/" + name + closeTag
+	depth := 1
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], end):
+			depth--
+			if depth == 0 {
+				return s[:i], s[i+len(end):], nil
+			}
+			i += len(end)
+		case strings.HasPrefix(s[i:], open):
+			depth++
+			i += len(open)
+		default:
+			i++
+		}
+	}
+	return "", "", errors.Errorf("missing %s for section %s", end, name)
+}
+
+// splitArgs splits a tag on spaces, keeping quoted strings whole
+func splitArgs(tag string) ([]string, error) {
+	var fields []string
+	tag = strings.TrimSpace(tag)
+	for tag != "" {
+		n := strings.IndexAny(tag, " \t\n")
+		if strings.HasPrefix(tag, `"`) {
+			quoted, err := strconv.QuotedPrefix(tag)
+			if err != nil {
+				return nil, err
+			}
+			n = len(quoted)
+		}
+		if n < 0 {
+			n = len(tag)
+		}
+		fields = append(fields, tag[:n])
+		tag = strings.TrimSpace(tag[n:])
+	}
+	return fields, nil
+}
+
+// placeholder returns the placeholder of the i-th call, with the new lines of
+// the tag it replaces, so that errors keep their line
+func placeholder(i int, newlines int) string {
+	return fmt.Sprintf("\x00%d%s\x00", i, strings.Repeat("\n", newlines))
+}
+
+// render calls the function and returns its output the way text/template
+// prints values
+func (c call) render(settings map[string]interface{}, calls *[]call, driver *Driver) (string, error) {
+	var args []interface{}
+	for _, arg := range c.args {
+		args = append(args, argValue(arg, settings))
+	}
+	if c.body != nil {
+		body, err := driver.render(*c.body, c.bodyOffset, settings, calls)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, body)
+	}
+	result, err := funcs.Call(c.name, args...)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(result), nil
+}
+
+// argValue returns the value of a literal, or of a setting path
+func argValue(arg string, settings map[string]interface{}) interface{} {
+	if s, err := strconv.Unquote(arg); err == nil && strings.HasPrefix(arg, `"`) {
+		return s
+	}
+	if i, err := strconv.Atoi(arg); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(arg, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(arg); err == nil {
+		return b
+	}
+	var value interface{} = settings
+	for _, key := range strings.Split(arg, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
@@ -31,6 +31,12 @@ func Register(name string, driver driver.Driver) {
 // Apply applies the specified render to the specified string with the specified settings.
 // If the render is not present is the registered ones, it errors out.
 func Apply(s string, settings map[string]interface{}, renderers ...string) (string, error) {
+	return ApplyWithOptions(s, settings, driver.Options{}, renderers...)
+}
+
+// ApplyWithOptions is Apply, giving the rendering options to the drivers
+// which take them.
+func ApplyWithOptions(s string, settings map[string]interface{}, opts driver.Options, renderers ...string) (string, error) {
 	var err error
 	for _, r := range renderers {
 		if r == "none" {
@@ -42,7 +48,11 @@ func Apply(s string, settings map[string]interface{}, renderers ...string) (stri
 		if !present {
 			return "", errors.Errorf("unknown renderer %s", r)
 		}
-		s, err = d.Apply(s, settings)
+		if od, ok := d.(driver.OptionsDriver); ok {
+			s, err = od.ApplyWithOptions(s, settings, opts)
+		} else {
+			s, err = d.Apply(s, settings)
+		}
 		if err != nil {
 			return "", err
 		}
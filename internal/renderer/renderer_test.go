@@ -15,6 +15,16 @@ func (d *fakeDriver) Apply(s string, settings map[string]interface{}) (string, e
 	return s + "fake", nil
 }
 
+type fakeOptionsDriver struct{}
+
+func (d *fakeOptionsDriver) Apply(s string, settings map[string]interface{}) (string, error) {
+	return s + "fake", nil
+}
+
+func (d *fakeOptionsDriver) ApplyWithOptions(s string, settings map[string]interface{}, opts driver.Options) (string, error) {
+	return s + opts.Dir, nil
+}
+
 type fakeErrorDriver struct{}
 
 func (d *fakeErrorDriver) Apply(s string, settings map[string]interface{}) (string, error) {
@@ -90,6 +100,15 @@ func TestApply(t *testing.T) {
 	assert.Check(t, is.Equal(s, "foofake"))
 }
 
+func TestApplyWithOptions(t *testing.T) {
+	Register("fake", &fakeDriver{})
+	Register("options", &fakeOptionsDriver{})
+	defer resetDrivers()
+	s, err := ApplyWithOptions("foo", nil, driver.Options{Dir: "/app"}, "fake", "options")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(s, "foofake/app"))
+}
+
 func resetDrivers() {
 	drivers = map[string]driver.Driver{}
 }
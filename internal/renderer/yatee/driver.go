@@ -3,14 +3,23 @@
 package yatee
 
 import (
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/docker/app/internal/renderer"
+	"github.com/docker/app/internal/renderer/driver"
 	"github.com/docker/app/internal/yaml"
 	"github.com/docker/app/pkg/yatee"
 	"github.com/pkg/errors"
 )
 
+var (
+	missingVariable = regexp.MustCompile(`^variable '\{?([^'{}]*)\}?' not set`)
+	undefinedBlock  = regexp.MustCompile(`^block '([^']*)' is not defined`)
+	yamlLine        = regexp.MustCompile(`^yaml: line (\d+):`)
+)
+
 func init() {
 	renderer.Register("yatee", &Driver{})
 }
@@ -20,9 +29,19 @@ type Driver struct{}
 
 // Apply applies the settings to the string
 func (d *Driver) Apply(s string, settings map[string]interface{}) (string, error) {
-	yateed, err := yatee.Process(s, settings, yatee.OptionErrOnMissingKey)
+	return d.ApplyWithOptions(s, settings, driver.Options{})
+}
+
+// ApplyWithOptions applies the settings to the string, files being included
+// from the application directory
+func (d *Driver) ApplyWithOptions(s string, settings map[string]interface{}, opts driver.Options) (string, error) {
+	yateeOpts := []string{yatee.OptionErrOnMissingKey}
+	if opts.Dir != "" {
+		yateeOpts = append(yateeOpts, yatee.OptionIncludeDir+opts.Dir)
+	}
+	yateed, err := yatee.Process(s, settings, yateeOpts...)
 	if err != nil {
-		return "", err
+		return "", located(s, err)
 	}
 	m, err := yaml.Marshal(yateed)
 	if err != nil {
@@ -30,3 +49,46 @@ func (d *Driver) Apply(s string, settings map[string]interface{}) (string, error
 	}
 	return strings.Replace(string(m), "$", "$$", -1), nil
 }
+
+// located returns the error with its position in the template, which is
+// looked for as yatee processes the parsed template
+func located(template string, err error) error {
+	at := func(offset int, column bool) error {
+		line, col := driver.Position(template, offset)
+		if !column {
+			col = 0
+		}
+		return &driver.Error{Line: line, Column: col, Err: err}
+	}
+	if e, ok := err.(*yatee.ExprError); ok {
+		if i := strings.Index(template, e.Expr); i != -1 {
+			return at(i+e.Pos, true)
+		}
+		// quotes are escaped in the template, so only the line is known
+		prefix := e.Expr
+		if q := strings.IndexAny(prefix, `"'`); q != -1 {
+			prefix = prefix[:q]
+		}
+		if i := strings.Index(template, prefix); prefix != "" && i != -1 {
+			return at(i, false)
+		}
+		return err
+	}
+	var searches []string
+	if m := missingVariable.FindStringSubmatch(err.Error()); m != nil {
+		searches = []string{"${" + m[1] + "}", "$" + m[1]}
+	}
+	if m := undefinedBlock.FindStringSubmatch(err.Error()); m != nil {
+		searches = []string{"@use " + m[1]}
+	}
+	for _, search := range searches {
+		if i := strings.Index(template, search); i != -1 {
+			return at(i, true)
+		}
+	}
+	if m := yamlLine.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return &driver.Error{Line: line, Err: err}
+	}
+	return err
+}
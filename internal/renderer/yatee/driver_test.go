@@ -5,6 +5,7 @@ package yatee
 import (
 	"testing"
 
+	"github.com/docker/app/internal/renderer/driver"
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
 )
@@ -51,3 +52,39 @@ func TestDriver(t *testing.T) {
 	t.Log(s)
 	assert.Check(t, is.Equal(s, expectedCompose))
 }
+
+func TestDriverErrorPosition(t *testing.T) {
+	testCases := []struct {
+		name     string
+		template string
+		line     int
+		column   int
+	}{
+		{
+			name:     "missing variable",
+			template: "version: \"3.4\"\nservices:\n  web:\n    image: nginx:${version}\n",
+			line:     4,
+			column:   18,
+		},
+		{
+			name:     "expression",
+			template: "version: \"3.4\"\nservices:\n  web:\n    image: $(1 +)\n",
+			line:     4,
+			column:   17,
+		},
+		{
+			name:     "undefined block",
+			template: "version: \"3.4\"\nservices:\n  web:\n    \"@use logging\":\n",
+			line:     4,
+			column:   6,
+		},
+	}
+	d := &Driver{}
+	for _, tc := range testCases {
+		_, err := d.Apply(tc.template, map[string]interface{}{})
+		derr, ok := err.(*driver.Error)
+		assert.Assert(t, ok, "%s: %v", tc.name, err)
+		assert.Check(t, is.Equal(derr.Line, tc.line), tc.name)
+		assert.Check(t, is.Equal(derr.Column, tc.column), tc.name)
+	}
+}
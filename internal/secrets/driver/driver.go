@@ -0,0 +1,7 @@
+package driver
+
+// Driver is the interface that must be implemented by a secret provider driver.
+type Driver interface {
+	// Resolve returns the value of the secret identified by the reference
+	Resolve(reference string) (string, error)
+}
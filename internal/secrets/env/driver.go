@@ -0,0 +1,24 @@
+package env
+
+import (
+	"os"
+
+	"github.com/docker/app/internal/secrets"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	secrets.Register("env", &Driver{})
+}
+
+// Driver is the environment variables implementation of secret provider drivers.
+type Driver struct{}
+
+// Resolve returns the value of the environment variable named by the reference
+func (d *Driver) Resolve(reference string) (string, error) {
+	value, ok := os.LookupEnv(reference)
+	if !ok {
+		return "", errors.Errorf("environment variable %s is not set", reference)
+	}
+	return value, nil
+}
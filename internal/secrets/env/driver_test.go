@@ -0,0 +1,21 @@
+package env
+
+import (
+	"os"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestResolve(t *testing.T) {
+	os.Setenv("DOCKERAPP_TEST_SECRET", "s3cr3t")
+	defer os.Unsetenv("DOCKERAPP_TEST_SECRET")
+	d := &Driver{}
+	s, err := d.Resolve("DOCKERAPP_TEST_SECRET")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(s, "s3cr3t"))
+
+	_, err = d.Resolve("DOCKERAPP_TEST_MISSING_SECRET")
+	assert.Check(t, is.ErrorContains(err, "environment variable DOCKERAPP_TEST_MISSING_SECRET is not set"))
+}
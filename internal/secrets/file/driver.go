@@ -0,0 +1,26 @@
+package file
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/docker/app/internal/secrets"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	secrets.Register("file", &Driver{})
+}
+
+// Driver is the plain file implementation of secret provider drivers.
+type Driver struct{}
+
+// Resolve returns the content of the file at the reference path, without its
+// trailing newline
+func (d *Driver) Resolve(reference string) (string, error) {
+	data, err := ioutil.ReadFile(reference)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read secret file")
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,23 @@
+package file
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func TestResolve(t *testing.T) {
+	dir := fs.NewDir(t, "secrets",
+		fs.WithFile("password", "s3cr3t\n"),
+	)
+	defer dir.Remove()
+	d := &Driver{}
+	s, err := d.Resolve(dir.Join("password"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(s, "s3cr3t"))
+
+	_, err = d.Resolve(dir.Join("missing"))
+	assert.Check(t, is.ErrorContains(err, "failed to read secret file"))
+}
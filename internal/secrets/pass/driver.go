@@ -0,0 +1,67 @@
+package pass
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/app/internal/secrets"
+	"github.com/docker/docker/pkg/homedir"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	secrets.Register("pass", &Driver{decrypt: gpgDecrypt})
+}
+
+// Driver is the pass (https://www.passwordstore.org) implementation of secret
+// provider drivers. Secrets are gpg encrypted files of the password store,
+// $PASSWORD_STORE_DIR or ~/.password-store by default.
+type Driver struct {
+	decrypt func(path string) ([]byte, error)
+}
+
+// Resolve returns the first line of the decrypted password store entry named
+// by the reference, e.g. "db/password"
+func (d *Driver) Resolve(reference string) (string, error) {
+	store, err := storeDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(store, filepath.FromSlash(reference)+".gpg")
+	if !strings.HasPrefix(path, filepath.Clean(store)+string(filepath.Separator)) {
+		return "", errors.Errorf("invalid password store entry %q", reference)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", errors.Errorf("password store entry %q not found in %s", reference, store)
+	}
+	data, err := d.decrypt(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to decrypt password store entry %q", reference)
+	}
+	return strings.TrimRight(strings.SplitN(string(data), "\n", 2)[0], "\r"), nil
+}
+
+func storeDir() (string, error) {
+	if dir, ok := os.LookupEnv("PASSWORD_STORE_DIR"); ok {
+		return dir, nil
+	}
+	home := homedir.Get()
+	if home == "" {
+		return "", errors.New("failed to locate the password store, set PASSWORD_STORE_DIR")
+	}
+	return filepath.Join(home, ".password-store"), nil
+}
+
+func gpgDecrypt(path string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("gpg", "--quiet", "--batch", "--decrypt", path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrap(err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,33 @@
+package pass
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func TestResolve(t *testing.T) {
+	dir := fs.NewDir(t, "password-store",
+		fs.WithDir("db",
+			fs.WithFile("password.gpg", "s3cr3t\nuser: admin\n"),
+		),
+	)
+	defer dir.Remove()
+	os.Setenv("PASSWORD_STORE_DIR", dir.Path())
+	defer os.Unsetenv("PASSWORD_STORE_DIR")
+	// the fake decryption returns the file content as is
+	d := &Driver{decrypt: ioutil.ReadFile}
+
+	s, err := d.Resolve("db/password")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(s, "s3cr3t"))
+
+	_, err = d.Resolve("db/missing")
+	assert.Check(t, is.ErrorContains(err, `password store entry "db/missing" not found`))
+	_, err = d.Resolve("../db/password")
+	assert.Check(t, is.ErrorContains(err, `invalid password store entry "../db/password"`))
+}
@@ -0,0 +1,156 @@
+package secrets
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/app/internal/secrets/driver"
+	"github.com/docker/app/types/settings"
+	"github.com/pkg/errors"
+)
+
+// Redacted is the value replacing secrets in redacted outputs
+const Redacted = "<redacted>"
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]driver.Driver{}
+)
+
+// Register makes a secret provider available by the provided name.
+// If Register is called twice with the same name or if driver is nil,
+// it panics.
+func Register(name string, driver driver.Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if driver == nil {
+		panic("secrets: Register driver is nil")
+	}
+	if _, dup := drivers[name]; dup {
+		panic("secrets: Register called twice for driver " + name)
+	}
+	drivers[name] = driver
+}
+
+// Drivers returns a sorted list of the names of the registered drivers.
+func Drivers() []string {
+	list := []string{}
+	driversMu.RLock()
+	for name := range drivers {
+		list = append(list, name)
+	}
+	driversMu.RUnlock()
+	sort.Strings(list)
+	return list
+}
+
+// Resolve returns the value of the secret referenced by the given
+// "secret:<provider>:<reference>" value.
+// If the provider is not registered, it errors out.
+func Resolve(value string) (string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(value, settings.SecretReferencePrefix), ":", 2)
+	if !strings.HasPrefix(value, settings.SecretReferencePrefix) || len(parts) != 2 || parts[1] == "" {
+		return "", errors.Errorf("invalid secret reference %q, expected %s<provider>:<reference>", value, settings.SecretReferencePrefix)
+	}
+	driversMu.RLock()
+	d, ok := drivers[parts[0]]
+	driversMu.RUnlock()
+	if !ok {
+		return "", errors.Errorf("unknown secret provider %q", parts[0])
+	}
+	return d.Resolve(parts[1])
+}
+
+// ResolveSettings returns a copy of the settings, with the secret references
+// of the given flattened keys replaced by the secret value. The values of the
+// other keys are left untouched, even if they look like secret references.
+func ResolveSettings(s settings.Settings, keys []string) (settings.Settings, error) {
+	secretKeys := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		secretKeys[k] = true
+	}
+	return transform(s, func(key string, value interface{}) (interface{}, error) {
+		if !secretKeys[key] || !settings.IsSecretReference(value) {
+			return value, nil
+		}
+		secret, err := Resolve(value.(string))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve secret setting %s", key)
+		}
+		return secret, nil
+	})
+}
+
+// Redact returns a copy of the settings, with the values of the given
+// flattened keys replaced by Redacted.
+func Redact(s settings.Settings, keys []string) (settings.Settings, error) {
+	secretKeys := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		secretKeys[k] = true
+	}
+	return transform(s, func(key string, value interface{}) (interface{}, error) {
+		if secretKeys[key] {
+			return Redacted, nil
+		}
+		return value, nil
+	})
+}
+
+// RedactFlatten replaces, in place, the values of the given flattened keys by Redacted
+func RedactFlatten(m map[string]string, keys []string) {
+	for _, k := range keys {
+		if _, ok := m[k]; ok {
+			m[k] = Redacted
+		}
+	}
+}
+
+func transform(s settings.Settings, f func(key string, value interface{}) (interface{}, error)) (settings.Settings, error) {
+	res, err := transformMap("", s, f)
+	if err != nil {
+		return nil, err
+	}
+	return settings.Settings(res), nil
+}
+
+func transformMap(prefix string, m map[string]interface{}, f func(key string, value interface{}) (interface{}, error)) (map[string]interface{}, error) {
+	res := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		value, err := transformValue(joinKey(prefix, k), v, f)
+		if err != nil {
+			return nil, err
+		}
+		res[k] = value
+	}
+	return res, nil
+}
+
+func transformValue(key string, value interface{}, f func(key string, value interface{}) (interface{}, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case settings.Settings:
+		return transformMap(key, v, f)
+	case map[string]interface{}:
+		return transformMap(key, v, f)
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, e := range v {
+			value, err := transformValue(joinKey(key, strconv.Itoa(i)), e, f)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = value
+		}
+		return res, nil
+	default:
+		return f(key, v)
+	}
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
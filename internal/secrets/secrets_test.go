@@ -0,0 +1,131 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/docker/app/internal/secrets/driver"
+	"github.com/docker/app/types/settings"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+type fakeDriver struct{}
+
+func (d *fakeDriver) Resolve(reference string) (string, error) {
+	return "resolved-" + reference, nil
+}
+
+type fakeErrorDriver struct{}
+
+func (d *fakeErrorDriver) Resolve(reference string) (string, error) {
+	return "", errors.New("error in driver")
+}
+
+func TestRegisterNilPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("The code did not panic")
+		}
+		resetDrivers()
+	}()
+	Register("foo", nil)
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("The code did not panic")
+		}
+		resetDrivers()
+	}()
+	Register("bar", &fakeDriver{})
+	Register("bar", &fakeDriver{})
+}
+
+func TestRegisteredDrivers(t *testing.T) {
+	Register("foo", &fakeDriver{})
+	Register("bar", &fakeDriver{})
+	defer resetDrivers()
+	assert.Check(t, is.DeepEqual(Drivers(), []string{"bar", "foo"}))
+}
+
+func TestResolve(t *testing.T) {
+	Register("fake", &fakeDriver{})
+	Register("err", &fakeErrorDriver{})
+	defer resetDrivers()
+
+	s, err := Resolve("secret:fake:db:password")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(s, "resolved-db:password"))
+
+	_, err = Resolve("secret:err:foo")
+	assert.Check(t, is.ErrorContains(err, "error in driver"))
+	_, err = Resolve("secret:unknown:foo")
+	assert.Check(t, is.ErrorContains(err, `unknown secret provider "unknown"`))
+	for _, invalid := range []string{"secret:fake", "secret:fake:", "fake:foo"} {
+		_, err = Resolve(invalid)
+		assert.Check(t, is.ErrorContains(err, "invalid secret reference"), invalid)
+	}
+}
+
+func TestResolveSettings(t *testing.T) {
+	Register("fake", &fakeDriver{})
+	Register("err", &fakeErrorDriver{})
+	defer resetDrivers()
+
+	s := settings.Settings{
+		"db": map[string]interface{}{
+			"user":     "admin",
+			"password": "secret:fake:password",
+		},
+		"tokens": []interface{}{"secret:fake:token", 42},
+		"motto":  "secret:fake:not a secret",
+	}
+	resolved, err := ResolveSettings(s, []string{"db.password", "tokens.0", "tokens.1"})
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(resolved.Flatten(), map[string]string{
+		"db.user":     "admin",
+		"db.password": "resolved-password",
+		"tokens.0":    "resolved-token",
+		"tokens.1":    "42",
+		// only the secret keys are resolved
+		"motto": "secret:fake:not a secret",
+	}))
+	// the original settings are left untouched
+	assert.Check(t, is.Equal(s.Flatten()["db.password"], "secret:fake:password"))
+
+	_, err = ResolveSettings(settings.Settings{"db": map[string]interface{}{"password": "secret:err:password"}}, []string{"db.password"})
+	assert.Check(t, is.ErrorContains(err, "failed to resolve secret setting db.password: error in driver"))
+}
+
+func TestRedact(t *testing.T) {
+	s := settings.Settings{
+		"db": map[string]interface{}{
+			"user":     "admin",
+			"password": "secret:env:DB_PASSWORD",
+		},
+		"tokens": []interface{}{"foo", "bar"},
+	}
+	redacted, err := Redact(s, []string{"db.password", "tokens.1"})
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(redacted.Flatten(), map[string]string{
+		"db.user":     "admin",
+		"db.password": Redacted,
+		"tokens.0":    "foo",
+		"tokens.1":    Redacted,
+	}))
+
+	flatten := s.Flatten()
+	RedactFlatten(flatten, []string{"db.password", "missing"})
+	assert.Check(t, is.DeepEqual(flatten, map[string]string{
+		"db.user":     "admin",
+		"db.password": Redacted,
+		"tokens.0":    "foo",
+		"tokens.1":    "bar",
+	}))
+}
+
+func resetDrivers() {
+	drivers = map[string]driver.Driver{}
+}
@@ -0,0 +1,106 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/app/internal/secrets"
+	"github.com/docker/docker/pkg/homedir"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultAddress = "http://127.0.0.1:8200"
+	defaultField   = "value"
+)
+
+func init() {
+	secrets.Register("vault", &Driver{client: &http.Client{Timeout: 30 * time.Second}})
+}
+
+// Driver is the HashiCorp Vault HTTP API implementation of secret provider
+// drivers. The server address and token are read from $VAULT_ADDR and
+// $VAULT_TOKEN (or ~/.vault-token).
+type Driver struct {
+	client *http.Client
+}
+
+// Resolve returns a field of the secret at the reference path, given as
+// "<path>#<field>", e.g. "secret/data/db#password". The field defaults to
+// "value". Both KV version 1 and 2 secrets engines are supported.
+func (d *Driver) Resolve(reference string) (string, error) {
+	path, field := reference, defaultField
+	if i := strings.LastIndex(reference, "#"); i >= 0 {
+		path, field = reference[:i], reference[i+1:]
+	}
+	token, err := token()
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodGet, address()+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid vault secret path")
+	}
+	req.Header.Set("X-Vault-Token", token)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to query vault")
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read vault response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("failed to read vault secret %q: %s", path, resp.Status)
+	}
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", errors.Wrap(err, "invalid vault response")
+	}
+	data := secret.Data
+	// KV version 2 nests the secret data along its metadata
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", errors.Errorf("vault secret %q has no field %q", path, field)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprintf("%v", value), nil
+}
+
+func address() string {
+	if addr, ok := os.LookupEnv("VAULT_ADDR"); ok && addr != "" {
+		return strings.TrimSuffix(addr, "/")
+	}
+	return defaultAddress
+}
+
+func token() (string, error) {
+	if token, ok := os.LookupEnv("VAULT_TOKEN"); ok && token != "" {
+		return token, nil
+	}
+	home := homedir.Get()
+	if home == "" {
+		return "", errors.New("no vault token, set VAULT_TOKEN")
+	}
+	data, err := ioutil.ReadFile(filepath.Join(home, ".vault-token"))
+	if err != nil {
+		return "", errors.New("no vault token, set VAULT_TOKEN")
+	}
+	return strings.TrimSpace(string(data)), nil
+}
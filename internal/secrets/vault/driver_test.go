@@ -0,0 +1,54 @@
+package vault
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestResolve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "my-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/db":
+			fmt.Fprint(w, `{"data": {"value": "v1-secret", "port": 5432}}`)
+		case "/v1/secret/data/db":
+			fmt.Fprint(w, `{"data": {"data": {"password": "v2-secret"}, "metadata": {"version": 1}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	os.Setenv("VAULT_ADDR", server.URL)
+	defer os.Unsetenv("VAULT_ADDR")
+	os.Setenv("VAULT_TOKEN", "my-token")
+	defer os.Unsetenv("VAULT_TOKEN")
+	d := &Driver{client: server.Client()}
+
+	for reference, expected := range map[string]string{
+		"secret/db":               "v1-secret",
+		"secret/db#port":          "5432",
+		"secret/data/db#password": "v2-secret",
+	} {
+		s, err := d.Resolve(reference)
+		assert.NilError(t, err, reference)
+		assert.Check(t, is.Equal(s, expected), reference)
+	}
+
+	_, err := d.Resolve("secret/db#missing")
+	assert.Check(t, is.ErrorContains(err, `vault secret "secret/db" has no field "missing"`))
+	_, err = d.Resolve("secret/missing")
+	assert.Check(t, is.ErrorContains(err, `failed to read vault secret "secret/missing": 404 Not Found`))
+
+	os.Setenv("VAULT_TOKEN", "wrong-token")
+	_, err = d.Resolve("secret/db")
+	assert.Check(t, is.ErrorContains(err, "403 Forbidden"))
+}
@@ -0,0 +1,235 @@
+package semver
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var termPattern = regexp.MustCompile(`^(>=|<=|!=|=|>|<|~|\^)?\s*v?(\*|x|X|0|[1-9][0-9]*)(?:\.(\*|x|X|0|[1-9][0-9]*))?(?:\.(\*|x|X|0|[1-9][0-9]*))?(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?$`)
+
+// Constraint is a set of version ranges, e.g. "^1.2.0", "~1.2",
+// ">=1.0.0, <2.0.0" or "1.x || 2.x"
+type Constraint struct {
+	original string
+	// any of the groups must match, all the comparisons of a group must match
+	groups [][]comparison
+}
+
+type comparison struct {
+	op string
+	v  Version
+}
+
+// ParseConstraint parses a version constraint. Comparisons separated by
+// commas or spaces must all match, groups separated by "||" are alternatives.
+// Supported operators are =, !=, >, >=, <, <=, ~ (patch updates) and ^
+// (compatible updates). Partial versions and x wildcards are supported,
+// e.g. "1.2" or "1.2.x" stand for ">=1.2.0, <1.3.0".
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{original: s}
+	for _, group := range strings.Split(s, "||") {
+		var comparisons []comparison
+		for _, term := range splitTerms(group) {
+			cs, err := parseTerm(term)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid version constraint %q", s)
+			}
+			comparisons = append(comparisons, cs...)
+		}
+		if len(comparisons) == 0 {
+			return nil, errors.Errorf("invalid version constraint %q: empty range", s)
+		}
+		c.groups = append(c.groups, comparisons)
+	}
+	return c, nil
+}
+
+// splitTerms splits comparisons on commas and spaces, keeping operators
+// separated from their version by spaces together
+func splitTerms(group string) []string {
+	var terms []string
+	var pending string
+	for _, field := range strings.FieldsFunc(group, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		if strings.Trim(field, "=!<>~^") == "" {
+			pending += field
+			continue
+		}
+		terms = append(terms, pending+field)
+		pending = ""
+	}
+	if pending != "" {
+		terms = append(terms, pending)
+	}
+	return terms
+}
+
+func parseTerm(term string) ([]comparison, error) {
+	m := termPattern.FindStringSubmatch(term)
+	if m == nil {
+		return nil, errors.Errorf("invalid comparison %q", term)
+	}
+	op, prerelease := m[1], m[5]
+	// parts holds the specified version numbers, up to the first wildcard
+	var parts []uint64
+	for _, p := range m[2:5] {
+		if p == "" || p == "*" || p == "x" || p == "X" {
+			break
+		}
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid comparison %q", term)
+		}
+		parts = append(parts, n)
+	}
+	if prerelease != "" && len(parts) != 3 {
+		return nil, errors.Errorf("invalid comparison %q: prerelease on a partial version", term)
+	}
+	v := Version{Prerelease: prerelease}
+	for i, n := range parts {
+		switch i {
+		case 0:
+			v.Major = n
+		case 1:
+			v.Minor = n
+		case 2:
+			v.Patch = n
+		}
+	}
+	switch op {
+	case "", "=":
+		if len(parts) == 3 {
+			return []comparison{{"=", v}}, nil
+		}
+		return partialRange(v, len(parts)), nil
+	case "!=":
+		if len(parts) != 3 {
+			return nil, errors.Errorf("invalid comparison %q: != requires a full version", term)
+		}
+		return []comparison{{"!=", v}}, nil
+	case ">=":
+		return []comparison{{">=", v}}, nil
+	case "<":
+		return []comparison{{"<", v}}, nil
+	case ">":
+		if len(parts) == 3 {
+			return []comparison{{">", v}}, nil
+		}
+		// >1.2 stands for >=1.3.0
+		return []comparison{{">=", bump(v, len(parts))}}, nil
+	case "<=":
+		if len(parts) == 3 {
+			return []comparison{{"<=", v}}, nil
+		}
+		// <=1.2 stands for <1.3.0
+		return []comparison{{"<", bump(v, len(parts))}}, nil
+	case "~":
+		if len(parts) <= 1 {
+			return partialRange(v, len(parts)), nil
+		}
+		return []comparison{{">=", v}, {"<", bump(v, 2)}}, nil
+	case "^":
+		switch {
+		case len(parts) == 0:
+			return partialRange(v, 0), nil
+		case v.Major != 0 || len(parts) == 1:
+			return []comparison{{">=", v}, {"<", bump(v, 1)}}, nil
+		case v.Minor != 0 || len(parts) == 2:
+			return []comparison{{">=", v}, {"<", bump(v, 2)}}, nil
+		default:
+			return []comparison{{">=", v}, {"<", bump(v, 3)}}, nil
+		}
+	}
+	return nil, errors.Errorf("invalid comparison %q", term)
+}
+
+// partialRange returns the range of versions matching the first n parts of v
+func partialRange(v Version, n int) []comparison {
+	if n == 0 {
+		return []comparison{{">=", Version{}}}
+	}
+	return []comparison{{">=", v}, {"<", bump(v, n)}}
+}
+
+// bump increments the nth part of v, resetting the following parts
+func bump(v Version, n int) Version {
+	switch n {
+	case 1:
+		return Version{Major: v.Major + 1}
+	case 2:
+		return Version{Major: v.Major, Minor: v.Minor + 1}
+	default:
+		return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+}
+
+// String returns the constraint as it was parsed
+func (c *Constraint) String() string {
+	return c.original
+}
+
+// Check returns whether the version satisfies the constraint. Prerelease
+// versions only match groups with a prerelease comparison on the same
+// major, minor and patch numbers.
+func (c *Constraint) Check(v Version) bool {
+	for _, group := range c.groups {
+		if checkGroup(group, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkGroup(group []comparison, v Version) bool {
+	prereleaseAllowed := v.Prerelease == ""
+	for _, cmp := range group {
+		if !cmp.check(v) {
+			return false
+		}
+		if cmp.v.Prerelease != "" && cmp.v.Major == v.Major && cmp.v.Minor == v.Minor && cmp.v.Patch == v.Patch {
+			prereleaseAllowed = true
+		}
+	}
+	return prereleaseAllowed
+}
+
+func (c comparison) check(v Version) bool {
+	r := v.Compare(c.v)
+	switch c.op {
+	case "=":
+		return r == 0
+	case "!=":
+		return r != 0
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	}
+	return false
+}
+
+// Latest returns the greatest of the given versions satisfying the
+// constraint. Versions which are not semantic versions are ignored.
+func (c *Constraint) Latest(versions []string) (string, bool) {
+	var (
+		latest  string
+		latestV Version
+		found   bool
+	)
+	for _, s := range versions {
+		v, err := Parse(s)
+		if err != nil || !c.Check(v) {
+			continue
+		}
+		if !found || v.Compare(latestV) > 0 {
+			latest, latestV, found = s, v, true
+		}
+	}
+	return latest, found
+}
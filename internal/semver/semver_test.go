@@ -0,0 +1,95 @@
+package semver
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestParse(t *testing.T) {
+	v, err := Parse("v1.2.3-rc.1+build.5")
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(v, Version{Major: 1, Minor: 2, Patch: 3, Prerelease: "rc.1", Build: "build.5"}))
+	assert.Check(t, is.Equal(v.String(), "1.2.3-rc.1+build.5"))
+
+	for _, invalid := range []string{"", "1", "1.2", "01.2.3", "1.2.3-", "latest", "1.2.3.4"} {
+		_, err := Parse(invalid)
+		assert.Check(t, is.ErrorContains(err, "invalid semantic version"), invalid)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// sorted by precedence
+	versions := []string{"0.9.9", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.10.0", "2.0.0"}
+	for i := range versions {
+		for j := range versions {
+			a, err := Parse(versions[i])
+			assert.NilError(t, err)
+			b, err := Parse(versions[j])
+			assert.NilError(t, err)
+			expected := compareUint(uint64(i), uint64(j))
+			assert.Check(t, is.Equal(a.Compare(b), expected), "%s vs %s", versions[i], versions[j])
+		}
+	}
+	a, _ := Parse("1.0.0+a")
+	b, _ := Parse("1.0.0+b")
+	assert.Check(t, is.Equal(a.Compare(b), 0))
+}
+
+func TestConstraint(t *testing.T) {
+	for _, tc := range []struct {
+		constraint string
+		matching   []string
+		failing    []string
+	}{
+		{"1.2.3", []string{"1.2.3"}, []string{"1.2.4", "1.2.3-rc.1"}},
+		{"1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0", "1.1.9"}},
+		{"1.x", []string{"1.0.0", "1.9.9"}, []string{"2.0.0", "0.9.0"}},
+		{"*", []string{"0.0.1", "9.9.9"}, []string{"1.0.0-rc.1"}},
+		{"!=1.2.3", []string{"1.2.4"}, []string{"1.2.3"}},
+		{">1.2", []string{"1.3.0"}, []string{"1.2.9"}},
+		{"<=1.2", []string{"1.2.9"}, []string{"1.3.0"}},
+		{">= 1.2.0, < 2.0.0", []string{"1.2.0", "1.9.0"}, []string{"1.1.0", "2.0.0"}},
+		{">=1.2.0 <2.0.0", []string{"1.5.0"}, []string{"2.1.0"}},
+		{"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0", "1.2.2"}},
+		{"~1", []string{"1.9.0"}, []string{"2.0.0"}},
+		{"^1.2.3", []string{"1.2.3", "1.9.0"}, []string{"2.0.0", "1.2.2"}},
+		{"^0.2.3", []string{"0.2.9"}, []string{"0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"^1.2.3-beta.2", []string{"1.2.3-beta.3", "1.2.3", "1.3.0"}, []string{"1.2.3-beta.1", "1.2.4-beta.1"}},
+		{"1.x || >=3.1.0", []string{"1.5.0", "3.2.0"}, []string{"2.0.0", "3.0.0"}},
+	} {
+		c, err := ParseConstraint(tc.constraint)
+		assert.NilError(t, err, tc.constraint)
+		assert.Check(t, is.Equal(c.String(), tc.constraint))
+		for _, s := range tc.matching {
+			v, err := Parse(s)
+			assert.NilError(t, err)
+			assert.Check(t, c.Check(v), "%s should match %s", s, tc.constraint)
+		}
+		for _, s := range tc.failing {
+			v, err := Parse(s)
+			assert.NilError(t, err)
+			assert.Check(t, !c.Check(v), "%s should not match %s", s, tc.constraint)
+		}
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, invalid := range []string{"", "||", "latest", "!=1.2", "1.2-rc.1", ">>1.0.0"} {
+		_, err := ParseConstraint(invalid)
+		assert.Check(t, is.ErrorContains(err, "invalid version constraint"), invalid)
+	}
+}
+
+func TestLatest(t *testing.T) {
+	c, err := ParseConstraint("^1.2.0")
+	assert.NilError(t, err)
+	latest, ok := c.Latest([]string{"latest", "1.1.0", "v1.3.0", "1.2.5", "2.0.0", "1.4.0-rc.1"})
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(latest, "v1.3.0"))
+
+	_, ok = c.Latest([]string{"latest", "2.0.0"})
+	assert.Check(t, !ok)
+}
@@ -0,0 +1,185 @@
+package signing
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// Signature is a detached ECDSA signature over a manifest digest
+type Signature struct {
+	// KeyID identifies the public key of the signer
+	KeyID string `json:"keyid"`
+	// Digest is the signed manifest digest
+	Digest string `json:"digest"`
+	// Signature is the ASN.1 DER encoded signature of the SHA-256 hash of the
+	// digest string
+	Signature []byte `json:"signature"`
+}
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// KeyID returns the identifier of a public key: the hex SHA-256 hash of its
+// PKIX encoding
+func KeyID(pub *ecdsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Sign signs the manifest digest with the private key
+func Sign(key *ecdsa.PrivateKey, dgst string) (*Signature, error) {
+	if _, err := digest.Parse(dgst); err != nil {
+		return nil, errors.Wrapf(err, "invalid digest %q", dgst)
+	}
+	keyID, err := KeyID(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256([]byte(dgst))
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign")
+	}
+	sig, err := asn1.Marshal(ecdsaSignature{r, s})
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{KeyID: keyID, Digest: dgst, Signature: sig}, nil
+}
+
+// Verify checks the signature is a signature of the digest by one of the
+// trusted keys, indexed by key ID
+func Verify(sig *Signature, dgst string, trusted map[string]*ecdsa.PublicKey) error {
+	if sig.Digest != dgst {
+		return errors.Errorf("signature is for %s, not %s", sig.Digest, dgst)
+	}
+	pub, ok := trusted[sig.KeyID]
+	if !ok {
+		return errors.Errorf("key %s is not trusted", sig.KeyID)
+	}
+	var es ecdsaSignature
+	if rest, err := asn1.Unmarshal(sig.Signature, &es); err != nil || len(rest) != 0 || es.R == nil || es.S == nil {
+		return errors.New("malformed signature")
+	}
+	hash := sha256.Sum256([]byte(dgst))
+	if !ecdsa.Verify(pub, hash[:], es.R, es.S) {
+		return errors.Errorf("invalid signature by key %s", sig.KeyID)
+	}
+	return nil
+}
+
+// LoadPrivateKey loads a PEM encoded ECDSA private key, in SEC 1 ("EC PRIVATE
+// KEY") or PKCS #8 ("PRIVATE KEY") form, as generated by
+// `openssl ecparam -name prime256v1 -genkey -noout`
+func LoadPrivateKey(path string) (*ecdsa.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		return key, errors.Wrapf(err, "invalid private key %s", path)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid private key %s", path)
+		}
+		ecKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, errors.Errorf("%s is not an ECDSA private key", path)
+		}
+		return ecKey, nil
+	default:
+		return nil, errors.Errorf("%s: unexpected PEM block %q", path, block.Type)
+	}
+}
+
+// LoadPublicKey loads a PEM encoded ECDSA public key ("PUBLIC KEY"), as
+// generated by `openssl ec -pubout`
+func LoadPublicKey(path string) (*ecdsa.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type != "PUBLIC KEY" {
+		return nil, errors.Errorf("%s: unexpected PEM block %q", path, block.Type)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid public key %s", path)
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.Errorf("%s is not an ECDSA public key", path)
+	}
+	return ecKey, nil
+}
+
+// LoadTrustedKeys loads the public keys (*.pem and *.pub files) of the
+// directory, indexed by key ID
+func LoadTrustedKeys(dir string) (map[string]*ecdsa.PublicKey, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read trusted keys")
+	}
+	keys := map[string]*ecdsa.PublicKey{}
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".pem" && ext != ".pub") {
+			continue
+		}
+		key, err := LoadPublicKey(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		id, err := KeyID(key)
+		if err != nil {
+			return nil, err
+		}
+		keys[id] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.Errorf("no trusted keys found in %s", dir)
+	}
+	return keys, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.Errorf("key file %s not found", path)
+		}
+		return nil, err
+	}
+	// skip the EC PARAMETERS block openssl outputs before the key
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.Errorf("%s is not a PEM file", path)
+		}
+		if !strings.HasSuffix(block.Type, "PARAMETERS") {
+			return block, nil
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package signing
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+const testDigest = "sha256:6e9b39914542abd7d9a6805d36fcbd10c5b6e334dc52a6f5ba11a6f51d8171fd"
+
+func generateKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	return key
+}
+
+func encodePublicKey(t *testing.T, key *ecdsa.PrivateKey) string {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.NilError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestSignVerify(t *testing.T) {
+	key := generateKey(t)
+	other := generateKey(t)
+	id, err := KeyID(&key.PublicKey)
+	assert.NilError(t, err)
+	otherID, err := KeyID(&other.PublicKey)
+	assert.NilError(t, err)
+	trusted := map[string]*ecdsa.PublicKey{id: &key.PublicKey}
+
+	sig, err := Sign(key, testDigest)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(sig.KeyID, id))
+	assert.NilError(t, Verify(sig, testDigest, trusted))
+
+	assert.ErrorContains(t, Verify(sig, "sha256:0000000000000000000000000000000000000000000000000000000000000000", trusted), "signature is for")
+	otherSig, err := Sign(other, testDigest)
+	assert.NilError(t, err)
+	assert.ErrorContains(t, Verify(otherSig, testDigest, trusted), "is not trusted")
+	// a signature by another key, claiming to be by the trusted one
+	otherSig.KeyID = id
+	assert.ErrorContains(t, Verify(otherSig, testDigest, trusted), "invalid signature")
+	otherSig.Signature = []byte("garbage")
+	assert.ErrorContains(t, Verify(otherSig, testDigest, trusted), "malformed signature")
+	assert.Check(t, id != otherID)
+
+	_, err = Sign(key, "not-a-digest")
+	assert.ErrorContains(t, err, "invalid digest")
+}
+
+func TestLoadKeys(t *testing.T) {
+	key := generateKey(t)
+	ecDER, err := x509.MarshalECPrivateKey(key)
+	assert.NilError(t, err)
+	pkcs8DER, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NilError(t, err)
+	dir := fs.NewDir(t, "keys",
+		fs.WithFile("ec.key", "-----BEGIN EC PARAMETERS-----\nBggqhkjOPQMBBw==\n-----END EC PARAMETERS-----\n"+
+			string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}))),
+		fs.WithFile("pkcs8.key", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8DER}))),
+		fs.WithFile("garbage.key", "garbage"),
+		fs.WithDir("trusted",
+			fs.WithFile("key.pem", encodePublicKey(t, key)),
+			fs.WithFile("README", "ignored"),
+		),
+		fs.WithDir("empty"),
+	)
+	defer dir.Remove()
+
+	for _, name := range []string{"ec.key", "pkcs8.key"} {
+		loaded, err := LoadPrivateKey(dir.Join(name))
+		assert.NilError(t, err, name)
+		assert.Check(t, is.Equal(loaded.D.Cmp(key.D), 0), name)
+	}
+	_, err = LoadPrivateKey(dir.Join("garbage.key"))
+	assert.ErrorContains(t, err, "is not a PEM file")
+	_, err = LoadPrivateKey(dir.Join("missing.key"))
+	assert.ErrorContains(t, err, "not found")
+	_, err = LoadPrivateKey(dir.Join("trusted", "key.pem"))
+	assert.ErrorContains(t, err, "unexpected PEM block")
+
+	keys, err := LoadTrustedKeys(dir.Join("trusted"))
+	assert.NilError(t, err)
+	id, err := KeyID(&key.PublicKey)
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(keys, 1))
+	assert.Check(t, keys[id] != nil)
+	_, err = LoadTrustedKeys(dir.Join("empty"))
+	assert.ErrorContains(t, err, "no trusted keys found")
+}
@@ -0,0 +1,206 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/docker/app/pkg/resto"
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/distribution/reference"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// annotationSourceDigest is the annotation of the index entries holding the
+// digest of the manifest the app was pulled by, which differs from the digest
+// of the stored manifest for apps pushed in the legacy formats
+const annotationSourceDigest = "com.docker.app.source.digest"
+
+// Store is a local content-addressed store of apps, kept in an OCI image
+// layout directory
+type Store struct {
+	dir string
+}
+
+// Entry is an app of the store
+type Entry struct {
+	// Ref is the normalized reference of the app: repository:tag, or
+	// repository@digest for apps pulled by digest
+	Ref string
+	// Digest is the digest of the manifest the app was pulled by
+	Digest string
+}
+
+// DefaultDir returns the directory of the store, in the docker config
+// directory
+func DefaultDir() string {
+	return filepath.Join(config.Dir(), "app", "store")
+}
+
+// New returns the store kept in the directory, created on the first write
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Normalize returns the normalized reference the app is stored under,
+// defaulting to the latest tag
+func Normalize(ref string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse image name")
+	}
+	if canonical, ok := named.(reference.Canonical); ok {
+		// the tag of name:tag@digest is ignored, as it is when pulling
+		c, err := reference.WithDigest(reference.TrimNamed(named), canonical.Digest())
+		if err != nil {
+			return "", err
+		}
+		return c.String(), nil
+	}
+	return reference.TagNameOnly(named).String(), nil
+}
+
+// List returns the apps of the store
+func (s *Store) List() ([]Entry, error) {
+	layoutEntries, err := s.entries()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, len(layoutEntries))
+	for i, e := range layoutEntries {
+		entries[i] = toEntry(e)
+	}
+	return entries, nil
+}
+
+// Resolve returns the digest of the app stored under the reference, and
+// whether it was found. References pinned to a digest are looked up by digest.
+func (s *Store) Resolve(ref string) (string, bool, error) {
+	e, found, err := s.find(ref)
+	return e.Digest, found, err
+}
+
+// Read reads the app stored under the reference
+func (s *Store) Read(ref string) (*resto.Artifact, error) {
+	e, found, err := s.find(ref)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.Errorf("%s not found in the local store", ref)
+	}
+	return resto.ReadLayout(s.dir, e.Ref)
+}
+
+// Write stores the app under the reference, recording the digest it was
+// pulled by, if not empty
+func (s *Store) Write(ref, dgst string, a *resto.Artifact) error {
+	normalized, err := Normalize(ref)
+	if err != nil {
+		return err
+	}
+	annotations := map[string]string{}
+	if dgst != "" {
+		annotations[annotationSourceDigest] = dgst
+	}
+	_, err = resto.WriteLayoutEntry(s.dir, normalized, a, annotations)
+	return err
+}
+
+// Tag stores the app stored under the source reference under the target
+// reference too
+func (s *Store) Tag(source, target string) error {
+	e, found, err := s.find(source)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.Errorf("%s not found in the local store", source)
+	}
+	normalized, err := Normalize(target)
+	if err != nil {
+		return err
+	}
+	return resto.TagLayout(s.dir, e.Ref, normalized)
+}
+
+// Remove removes the reference from the store, along with the content no
+// other reference refers to. References pinned to a digest remove all the
+// references to that digest.
+func (s *Store) Remove(ref string) error {
+	normalized, err := Normalize(ref)
+	if err != nil {
+		return err
+	}
+	named, _ := reference.ParseNormalizedNamed(normalized)
+	canonical, pinned := named.(reference.Canonical)
+	layoutEntries, err := s.entries()
+	if err != nil {
+		return err
+	}
+	removed := false
+	for _, e := range layoutEntries {
+		entry := toEntry(e)
+		if entry.Ref == normalized || (pinned && entry.Digest == canonical.Digest().String() && sameRepository(entry.Ref, named)) {
+			if err := resto.RemoveLayout(s.dir, e.Ref); err != nil {
+				return err
+			}
+			removed = true
+		}
+	}
+	if !removed {
+		return errors.Errorf("%s not found in the local store", ref)
+	}
+	return nil
+}
+
+func (s *Store) entries() ([]resto.LayoutEntry, error) {
+	if _, err := os.Stat(filepath.Join(s.dir, ociv1.ImageLayoutFile)); os.IsNotExist(err) {
+		// nothing was stored yet
+		return nil, nil
+	}
+	return resto.ListLayout(s.dir)
+}
+
+// find returns the entry of the reference, by name, or by digest for
+// references pinned to a digest
+func (s *Store) find(ref string) (Entry, bool, error) {
+	normalized, err := Normalize(ref)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	named, _ := reference.ParseNormalizedNamed(normalized)
+	canonical, pinned := named.(reference.Canonical)
+	layoutEntries, err := s.entries()
+	if err != nil {
+		return Entry{}, false, err
+	}
+	for _, e := range layoutEntries {
+		entry := toEntry(e)
+		if entry.Ref == normalized {
+			return entry, true, nil
+		}
+	}
+	if pinned {
+		for _, e := range layoutEntries {
+			entry := toEntry(e)
+			if entry.Digest == canonical.Digest().String() && sameRepository(entry.Ref, named) {
+				return entry, true, nil
+			}
+		}
+	}
+	return Entry{}, false, nil
+}
+
+func toEntry(e resto.LayoutEntry) Entry {
+	dgst := e.Annotations[annotationSourceDigest]
+	if dgst == "" {
+		dgst = e.Digest
+	}
+	return Entry{Ref: e.Ref, Digest: dgst}
+}
+
+func sameRepository(ref string, named reference.Named) bool {
+	other, err := reference.ParseNormalizedNamed(ref)
+	return err == nil && other.Name() == named.Name()
+}
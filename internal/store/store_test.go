@@ -0,0 +1,92 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/docker/app/pkg/resto"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+const (
+	testDigest      = "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+	otherTestDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000002"
+)
+
+func testArtifact(version string) *resto.Artifact {
+	return &resto.Artifact{
+		Files: []resto.ArtifactFile{
+			{Path: "metadata.yml", Content: []byte("name: app\nversion: " + version + "\n")},
+		},
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for ref, expected := range map[string]string{
+		"app.dockerapp":                      "docker.io/library/app.dockerapp:latest",
+		"foo/app.dockerapp:0.1.0":            "docker.io/foo/app.dockerapp:0.1.0",
+		"localhost:5000/app.dockerapp:0.1.0": "localhost:5000/app.dockerapp:0.1.0",
+		"app.dockerapp@" + testDigest:        "docker.io/library/app.dockerapp@" + testDigest,
+		"app.dockerapp:0.1.0@" + testDigest:  "docker.io/library/app.dockerapp@" + testDigest,
+	} {
+		normalized, err := Normalize(ref)
+		assert.NilError(t, err, ref)
+		assert.Check(t, is.Equal(normalized, expected), ref)
+	}
+	_, err := Normalize("Invalid")
+	assert.ErrorContains(t, err, "failed to parse image name")
+}
+
+func TestStore(t *testing.T) {
+	dir := fs.NewDir(t, "store")
+	defer dir.Remove()
+	s := New(dir.Join("store"))
+
+	entries, err := s.List()
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(entries, 0))
+	_, found, err := s.Resolve("app.dockerapp:0.1.0")
+	assert.NilError(t, err)
+	assert.Check(t, !found)
+	_, err = s.Read("app.dockerapp:0.1.0")
+	assert.ErrorContains(t, err, "not found in the local store")
+
+	assert.NilError(t, s.Write("app.dockerapp:0.1.0", testDigest, testArtifact("0.1.0")))
+	assert.NilError(t, s.Write("app.dockerapp:0.2.0", otherTestDigest, testArtifact("0.2.0")))
+	dgst, found, err := s.Resolve("docker.io/library/app.dockerapp:0.1.0")
+	assert.NilError(t, err)
+	assert.Check(t, found)
+	assert.Check(t, is.Equal(dgst, testDigest))
+
+	// references pinned to a digest are looked up by digest, in the same repository
+	a, err := s.Read("app.dockerapp@" + otherTestDigest)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(a.Files[0].Content), "name: app\nversion: 0.2.0\n"))
+	_, found, err = s.Resolve("other.dockerapp@" + otherTestDigest)
+	assert.NilError(t, err)
+	assert.Check(t, !found)
+
+	assert.NilError(t, s.Tag("app.dockerapp:0.1.0", "app.dockerapp:prod"))
+	assert.ErrorContains(t, s.Tag("app.dockerapp:unknown", "app.dockerapp:prod"), "not found in the local store")
+	entries, err = s.List()
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(entries, []Entry{
+		{Ref: "docker.io/library/app.dockerapp:0.1.0", Digest: testDigest},
+		{Ref: "docker.io/library/app.dockerapp:0.2.0", Digest: otherTestDigest},
+		{Ref: "docker.io/library/app.dockerapp:prod", Digest: testDigest},
+	}))
+
+	// removing a digest removes all its tags
+	assert.NilError(t, s.Remove("app.dockerapp@"+testDigest))
+	entries, err = s.List()
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(entries, []Entry{
+		{Ref: "docker.io/library/app.dockerapp:0.2.0", Digest: otherTestDigest},
+	}))
+	assert.ErrorContains(t, s.Remove("app.dockerapp:prod"), "not found in the local store")
+	assert.NilError(t, s.Remove("app.dockerapp:0.2.0"))
+	entries, err = s.List()
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(entries, 0))
+}
@@ -0,0 +1,59 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// Interval is the default interval between two checks of the watched files
+const Interval = 500 * time.Millisecond
+
+// fileState is what a change of a file is detected from
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Run calls f, then calls it again each time a file changes, is added or is
+// removed under the given paths, until the context is done. Paths are files or
+// directories, watched recursively, and don't need to exist. Files are polled
+// at the given interval, so that editors saving files in several steps only
+// trigger a single call.
+func Run(ctx context.Context, paths []string, interval time.Duration, f func()) error {
+	state := snapshot(paths)
+	f()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		current := snapshot(paths)
+		if reflect.DeepEqual(current, state) {
+			continue
+		}
+		state = current
+		f()
+	}
+}
+
+// snapshot returns the state of the files under the paths
+func snapshot(paths []string) map[string]fileState {
+	state := map[string]fileState{}
+	for _, path := range paths {
+		filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				// the files may change while they are walked
+				return nil
+			}
+			state[p] = fileState{modTime: info.ModTime(), size: info.Size()}
+			return nil
+		})
+	}
+	return state
+}
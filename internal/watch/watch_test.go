@@ -0,0 +1,34 @@
+package watch
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	"gotest.tools/fs"
+)
+
+func TestRun(t *testing.T) {
+	dir := fs.NewDir(t, "watch", fs.WithDir("app.dockerapp", fs.WithFile("settings.yml", "port: 80\n")))
+	defer dir.Remove()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	calls := 0
+	err := Run(ctx, []string{dir.Join("app.dockerapp"), dir.Join("extra.yml")}, 10*time.Millisecond, func() {
+		calls++
+		switch calls {
+		case 1:
+			assert.NilError(t, ioutil.WriteFile(dir.Join("app.dockerapp", "settings.yml"), []byte("port: 8080\n"), 0644))
+		case 2:
+			// files which didn't exist are watched too
+			assert.NilError(t, ioutil.WriteFile(dir.Join("extra.yml"), []byte("debug: true\n"), 0644))
+		case 3:
+			cancel()
+		}
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, calls, 3)
+}
@@ -36,29 +36,126 @@ func LoadFromSingleFile(path string, r io.Reader, ops ...func(*types.App) error)
 		return nil, errors.Wrap(err, "error reading single-file")
 	}
 	parts := strings.Split(string(data), types.SingleFileSeparator)
-	if len(parts) != 3 {
-		return nil, errors.Errorf("malformed single-file application: expected 3 documents, got %d", len(parts))
-	}
-	// 0. is metadata
-	metadata := strings.NewReader(parts[0])
-	// 1. is compose
-	compose := strings.NewReader(parts[1])
-	// 2. is settings
-	setting := strings.NewReader(parts[2])
-	appOps := append([]func(*types.App) error{
-		types.WithComposes(compose),
-		types.WithSettings(setting),
-		types.Metadata(metadata),
-	}, ops...)
+	if len(parts) < 3 {
+		return nil, errors.Errorf("malformed single-file application: expected at least 3 documents, got %d", len(parts))
+	}
+	docOps, err := parseSingleFileDocuments(parts)
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed single-file application")
+	}
+	appOps := append(docOps, ops...)
 	return types.NewApp(path, appOps...)
 }
 
+// parseSingleFileDocuments returns the app operations loading the single-file
+// documents. The first document is the metadata. The other ones are, in order,
+// the compose file and the default settings, unless they start with a header
+// line giving their kind (and name for named settings):
+//
+//	# docker-app: compose
+//	# docker-app: settings [<name>]
+//	# docker-app: settings-schema
+//	# docker-app: dependencies-lock
+func parseSingleFileDocuments(parts []string) ([]func(*types.App) error, error) {
+	var (
+		composes      []io.Reader
+		setting       io.Reader
+		schema        io.Reader
+		lock          io.Reader
+		namedSettings []func(*types.App) error
+	)
+	for i, part := range parts[1:] {
+		kind, name, content := parseSingleFileHeader(part)
+		if kind == "" {
+			switch {
+			case len(composes) == 0:
+				kind = types.DocumentCompose
+			case setting == nil:
+				kind = types.DocumentSettings
+			default:
+				return nil, errors.Errorf("document %d has no header", i+2)
+			}
+		}
+		if name != "" && kind != types.DocumentSettings {
+			return nil, errors.Errorf("document %d: %s documents cannot be named", i+2, kind)
+		}
+		switch kind {
+		case types.DocumentCompose:
+			composes = append(composes, strings.NewReader(content))
+		case types.DocumentSettings:
+			switch {
+			case name != "":
+				namedSettings = append(namedSettings, types.WithNamedSettings(name, strings.NewReader(content)))
+			case setting == nil:
+				setting = strings.NewReader(content)
+			default:
+				return nil, errors.Errorf("document %d: only one default settings document is allowed", i+2)
+			}
+		case types.DocumentSettingsSchema:
+			if schema != nil {
+				return nil, errors.Errorf("document %d: only one settings schema document is allowed", i+2)
+			}
+			schema = strings.NewReader(content)
+		case types.DocumentDependenciesLock:
+			if lock != nil {
+				return nil, errors.Errorf("document %d: only one dependencies lock document is allowed", i+2)
+			}
+			lock = strings.NewReader(content)
+		default:
+			return nil, errors.Errorf("document %d: unknown document kind %q", i+2, kind)
+		}
+	}
+	if len(composes) == 0 {
+		return nil, errors.New("missing compose document")
+	}
+	if setting == nil {
+		return nil, errors.New("missing settings document")
+	}
+	appOps := []func(*types.App) error{
+		types.WithComposes(composes...),
+	}
+	if schema != nil {
+		appOps = append(appOps, types.SettingsSchema(schema))
+	}
+	appOps = append(appOps, types.WithSettings(setting))
+	appOps = append(appOps, namedSettings...)
+	if lock != nil {
+		appOps = append(appOps, types.DependenciesLock(lock))
+	}
+	return append(appOps, types.Metadata(strings.NewReader(parts[0]))), nil
+}
+
+// parseSingleFileHeader returns the kind and name given by the header line of
+// the document, if any, and the document content without the header.
+func parseSingleFileHeader(part string) (string, string, string) {
+	if !strings.HasPrefix(part, types.SingleFileHeaderPrefix) {
+		return "", "", part
+	}
+	header, content := part, ""
+	if i := strings.Index(part, "\n"); i != -1 {
+		header, content = part[:i], part[i+1:]
+	}
+	fields := strings.Fields(strings.TrimPrefix(header, types.SingleFileHeaderPrefix))
+	switch len(fields) {
+	case 0:
+		return "", "", content
+	case 1:
+		return fields[0], "", content
+	default:
+		return fields[0], fields[1], content
+	}
+}
+
 // LoadFromDirectory loads a docker app from a directory
 func LoadFromDirectory(path string, ops ...func(*types.App) error) (*types.App, error) {
 	appOps := append([]func(*types.App) error{
 		types.MetadataFile(filepath.Join(path, internal.MetadataFileName)),
 		types.WithComposeFiles(filepath.Join(path, internal.ComposeFileName)),
+		types.WithComposeOverlaysDir(filepath.Join(path, internal.ComposeOverlaysDir)),
+		types.SettingsSchemaFile(filepath.Join(path, internal.SettingsSchemaFileName)),
 		types.WithSettingsFiles(filepath.Join(path, internal.SettingsFileName)),
+		types.WithNamedSettingsDir(filepath.Join(path, internal.NamedSettingsDir)),
+		types.DependenciesLockFile(filepath.Join(path, internal.DependenciesLockFileName)),
 	}, ops...)
 	return types.NewApp(path, appOps...)
 }
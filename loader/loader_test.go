@@ -52,6 +52,72 @@ bar`))
 	assert.ErrorContains(t, err, "malformed single-file application")
 }
 
+func TestLoadFromSingleFileMultipleDocuments(t *testing.T) {
+	singlefile := fmt.Sprintf(`%s
+---
+%s
+---
+%s
+---
+# docker-app: compose
+version: "3.1"
+---
+# docker-app: settings prod
+foo: baz
+---
+# docker-app: settings-schema
+type: object
+---
+# docker-app: dependencies-lock
+dependencies: []
+---
+# docker-app: compose
+version: "3.2"`, metadata, yaml, settings)
+	app, err := LoadFromSingleFile("my-app", strings.NewReader(singlefile))
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(app.Composes(), 3))
+	assertContentIs(t, app.Composes()[0], yaml)
+	assertContentIs(t, app.Composes()[1], `version: "3.1"`)
+	assertContentIs(t, app.Composes()[2], `version: "3.2"`)
+	assert.Assert(t, is.Len(app.SettingsRaw(), 1))
+	assertContentIs(t, app.SettingsRaw()[0], settings)
+	assert.Check(t, is.DeepEqual(app.NamedSettingsNames(), []string{"prod"}))
+	assertContentIs(t, app.NamedSettingsRaw()["prod"], "foo: baz")
+	assertContentIs(t, app.SettingsSchemaRaw(), "type: object")
+	assertContentIs(t, app.DependenciesLockRaw(), "dependencies: []")
+}
+
+func TestLoadFromSingleFileHeadersOnly(t *testing.T) {
+	singlefile := fmt.Sprintf(`%s
+---
+# docker-app: settings
+%s
+---
+# docker-app: compose
+%s`, metadata, settings, yaml)
+	app, err := LoadFromSingleFile("my-app", strings.NewReader(singlefile))
+	assert.NilError(t, err)
+	assertAppContent(t, app)
+}
+
+func TestLoadFromSingleFileInvalidDocuments(t *testing.T) {
+	for _, tc := range []struct {
+		docs     string
+		expected string
+	}{
+		{docs: "foo: bar\n---\nbar: baz", expected: "document 4 has no header"},
+		{docs: "# docker-app: unknown\nfoo: bar", expected: `document 4: unknown document kind "unknown"`},
+		{docs: "# docker-app: settings\nfoo: bar", expected: "document 4: only one default settings document is allowed"},
+		{docs: "# docker-app: compose named\nversion: \"3.1\"", expected: "document 4: compose documents cannot be named"},
+		{docs: "# docker-app: settings ../prod\nfoo: bar", expected: `invalid settings name: "../prod"`},
+		{docs: "# docker-app: dependencies-lock\ndependencies:\n- name: foo", expected: "invalid dependencies lock"},
+	} {
+		singlefile := fmt.Sprintf("%s\n---\n%s\n---\n%s\n---\n%s", metadata, yaml, settings, tc.docs)
+		_, err := LoadFromSingleFile("my-app", strings.NewReader(singlefile))
+		assert.Check(t, is.ErrorContains(err, tc.expected))
+	}
+}
+
 func TestLoadFromDirectory(t *testing.T) {
 	dir := fs.NewDir(t, "my-app",
 		fs.WithFile(internal.MetadataFileName, metadata),
@@ -66,6 +132,80 @@ func TestLoadFromDirectory(t *testing.T) {
 	assertAppContent(t, app)
 }
 
+func TestLoadFromDirectoryWithOverlaysAndNamedSettings(t *testing.T) {
+	dir := fs.NewDir(t, "my-app",
+		fs.WithFile(internal.MetadataFileName, metadata),
+		fs.WithFile(internal.SettingsFileName, settings),
+		fs.WithFile(internal.ComposeFileName, yaml),
+		fs.WithDir(internal.ComposeOverlaysDir,
+			fs.WithFile("2.yml", `version: "3.2"`),
+			fs.WithFile("1.yml", `version: "3.1"`),
+		),
+		fs.WithDir(internal.NamedSettingsDir,
+			fs.WithFile("prod.yml", "foo: baz"),
+			fs.WithFile("staging.yml", "foo: qux"),
+		),
+	)
+	defer dir.Remove()
+	app, err := LoadFromDirectory(dir.Path())
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(app.Composes(), 3))
+	assertContentIs(t, app.Composes()[1], `version: "3.1"`)
+	assertContentIs(t, app.Composes()[2], `version: "3.2"`)
+	assert.Check(t, is.DeepEqual(app.NamedSettingsNames(), []string{"prod", "staging"}))
+}
+
+func TestLoadFromDirectoryWithDependenciesLock(t *testing.T) {
+	lock := `dependencies:
+- name: monitoring
+  image: myorg/monitoring.dockerapp
+  version: 1.3.0
+  digest: sha256:0123`
+	dir := fs.NewDir(t, "my-app",
+		fs.WithFile(internal.MetadataFileName, metadata),
+		fs.WithFile(internal.SettingsFileName, settings),
+		fs.WithFile(internal.ComposeFileName, yaml),
+		fs.WithFile(internal.DependenciesLockFileName, lock),
+	)
+	defer dir.Remove()
+	app, err := LoadFromDirectory(dir.Path())
+	assert.NilError(t, err)
+	assertContentIs(t, app.DependenciesLockRaw(), lock)
+	locked, ok := app.DependenciesLock().Find("monitoring")
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(locked.Digest, "sha256:0123"))
+	assertContentIs(t, app.Files()[internal.DependenciesLockFileName], lock)
+}
+
+func TestLoadFromDirectoryMissingOverlay(t *testing.T) {
+	dir := fs.NewDir(t, "my-app",
+		fs.WithFile(internal.MetadataFileName, metadata),
+		fs.WithFile(internal.SettingsFileName, settings),
+		fs.WithFile(internal.ComposeFileName, yaml),
+		fs.WithDir(internal.ComposeOverlaysDir,
+			fs.WithFile("2.yml", `version: "3.2"`),
+		),
+	)
+	defer dir.Remove()
+	_, err := LoadFromDirectory(dir.Path())
+	assert.ErrorContains(t, err, "missing compose overlay 1.yml")
+}
+
+func TestLoadFromDirectoryNonCanonicalOverlay(t *testing.T) {
+	dir := fs.NewDir(t, "my-app",
+		fs.WithFile(internal.MetadataFileName, metadata),
+		fs.WithFile(internal.SettingsFileName, settings),
+		fs.WithFile(internal.ComposeFileName, yaml),
+		fs.WithDir(internal.ComposeOverlaysDir,
+			fs.WithFile("1.yml", `version: "3.1"`),
+			fs.WithFile("01.yml", `version: "3.2"`),
+		),
+	)
+	defer dir.Remove()
+	_, err := LoadFromDirectory(dir.Path())
+	assert.ErrorContains(t, err, `invalid compose overlay file name "01.yml", expected <position>.yml`)
+}
+
 func TestLoadFromTarInexistent(t *testing.T) {
 	_, err := LoadFromTar("any-tar.tar")
 	assert.ErrorContains(t, err, "open any-tar.tar")
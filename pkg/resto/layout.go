@@ -0,0 +1,362 @@
+package resto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/distribution"
+	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+const layoutIndexFile = "index.json"
+
+// LayoutEntry is a manifest of an OCI image layout
+type LayoutEntry struct {
+	// Ref is the reference name of the manifest
+	Ref string
+	// Digest is the digest of the manifest
+	Digest string
+	// Annotations are the annotations of the index entry, other than the
+	// reference name
+	Annotations map[string]string
+}
+
+// WriteLayout writes the artifact to the OCI image layout directory, created
+// if needed, under the given reference name, replacing any manifest with the
+// same name. It returns the digest of the manifest.
+func WriteLayout(dir, ref string, a *Artifact) (string, error) {
+	return WriteLayoutEntry(dir, ref, a, nil)
+}
+
+// WriteLayoutEntry is WriteLayout, setting the given annotations on the index
+// entry of the manifest
+func WriteLayoutEntry(dir, ref string, a *Artifact, annotations map[string]string) (string, error) {
+	manifest, blobs, err := buildArtifact(a)
+	if err != nil {
+		return "", err
+	}
+	_, raw, err := manifest.Payload()
+	if err != nil {
+		return "", err
+	}
+	manifestDigest := digest.FromBytes(raw)
+	blobs[manifestDigest] = raw
+	if err := InitLayout(dir); err != nil {
+		return "", err
+	}
+	for dgst, data := range blobs {
+		if err := WriteLayoutBlob(dir, dgst, data); err != nil {
+			return "", err
+		}
+	}
+	index, err := ReadLayoutIndex(dir)
+	if err != nil {
+		return "", err
+	}
+	desc := ociv1.Descriptor{
+		MediaType:   ociv1.MediaTypeImageManifest,
+		Digest:      manifestDigest,
+		Size:        int64(len(raw)),
+		Annotations: map[string]string{},
+	}
+	for k, v := range annotations {
+		desc.Annotations[k] = v
+	}
+	desc.Annotations[ociv1.AnnotationRefName] = ref
+	setRef(index, desc)
+	if err := WriteLayoutIndex(dir, index); err != nil {
+		return "", err
+	}
+	return manifestDigest.String(), nil
+}
+
+// ReadLayout reads the artifact with the given reference name, or manifest
+// digest, from the OCI image layout directory. An empty name selects the only
+// manifest of the layout.
+func ReadLayout(dir, ref string) (*Artifact, error) {
+	index, err := ReadLayoutIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := findManifest(dir, index, ref)
+	if err != nil {
+		return nil, err
+	}
+	if desc.MediaType != ociv1.MediaTypeImageManifest {
+		return nil, errors.Errorf("unsupported manifest media type %q", desc.MediaType)
+	}
+	raw, err := ReadLayoutBlob(dir, desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	m, _, err := unmarshalOCIManifest(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid OCI manifest")
+	}
+	return parseArtifact(m.(*DeserializedOCIManifest), func(d digest.Digest) ([]byte, error) { return ReadLayoutBlob(dir, d) })
+}
+
+// ListLayout returns the manifests of the OCI image layout directory which
+// have a reference name
+func ListLayout(dir string) ([]LayoutEntry, error) {
+	index, err := ReadLayoutIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []LayoutEntry
+	for _, m := range index.Manifests {
+		ref, ok := m.Annotations[ociv1.AnnotationRefName]
+		if !ok {
+			continue
+		}
+		annotations := map[string]string{}
+		for k, v := range m.Annotations {
+			if k != ociv1.AnnotationRefName {
+				annotations[k] = v
+			}
+		}
+		entries = append(entries, LayoutEntry{Ref: ref, Digest: m.Digest.String(), Annotations: annotations})
+	}
+	return entries, nil
+}
+
+// TagLayout gives the manifest with the given reference name, or manifest
+// digest, another reference name, replacing any manifest with that name
+func TagLayout(dir, ref, newRef string) error {
+	index, err := ReadLayoutIndex(dir)
+	if err != nil {
+		return err
+	}
+	desc, err := findManifest(dir, index, ref)
+	if err != nil {
+		return err
+	}
+	annotations := map[string]string{}
+	for k, v := range desc.Annotations {
+		annotations[k] = v
+	}
+	annotations[ociv1.AnnotationRefName] = newRef
+	desc.Annotations = annotations
+	setRef(index, desc)
+	return WriteLayoutIndex(dir, index)
+}
+
+// RemoveLayout removes the reference name from the OCI image layout
+// directory, and the blobs no other manifest refers to
+func RemoveLayout(dir, ref string) error {
+	index, err := ReadLayoutIndex(dir)
+	if err != nil {
+		return err
+	}
+	manifests := []ociv1.Descriptor{}
+	for _, m := range index.Manifests {
+		if m.Annotations[ociv1.AnnotationRefName] != ref {
+			manifests = append(manifests, m)
+		}
+	}
+	if len(manifests) == len(index.Manifests) {
+		return errors.Errorf("%q not found in OCI layout %s", ref, dir)
+	}
+	index.Manifests = manifests
+	if err := WriteLayoutIndex(dir, index); err != nil {
+		return err
+	}
+	return CollectLayoutGarbage(dir, index)
+}
+
+// setRef adds the manifest to the index, replacing any manifest with the same
+// reference name
+func setRef(index *ociv1.Index, desc ociv1.Descriptor) {
+	ref := desc.Annotations[ociv1.AnnotationRefName]
+	manifests := []ociv1.Descriptor{}
+	for _, m := range index.Manifests {
+		if m.Annotations[ociv1.AnnotationRefName] != ref {
+			manifests = append(manifests, m)
+		}
+	}
+	index.Manifests = append(manifests, desc)
+}
+
+// findManifest returns the manifest of the index with the given reference
+// name or digest, the only one if empty
+func findManifest(dir string, index *ociv1.Index, ref string) (ociv1.Descriptor, error) {
+	var found []ociv1.Descriptor
+	for _, m := range index.Manifests {
+		if ref == "" || m.Annotations[ociv1.AnnotationRefName] == ref || m.Digest.String() == ref {
+			found = append(found, m)
+		}
+	}
+	switch {
+	case len(found) == 0:
+		return ociv1.Descriptor{}, errors.Errorf("%q not found in OCI layout %s", ref, dir)
+	case len(found) > 1 && ref == "":
+		return ociv1.Descriptor{}, errors.Errorf("OCI layout %s holds %d manifests, a reference name is required", dir, len(found))
+	}
+	return found[len(found)-1], nil
+}
+
+// CollectLayoutGarbage removes the blobs the manifests of the index don't
+// refer to from the OCI image layout directory
+func CollectLayoutGarbage(dir string, index *ociv1.Index) error {
+	used := map[digest.Digest]bool{}
+	for _, desc := range index.Manifests {
+		used[desc.Digest] = true
+		raw, err := ReadLayoutBlob(dir, desc.Digest)
+		if err != nil {
+			return err
+		}
+		var m OCIManifest
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return errors.Wrapf(err, "invalid manifest %s", desc.Digest)
+		}
+		used[m.Config.Digest] = true
+		for _, l := range m.Layers {
+			used[l.Digest] = true
+		}
+	}
+	blobsDir := filepath.Join(dir, "blobs", string(digest.SHA256))
+	entries, err := ioutil.ReadDir(blobsDir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if used[digest.NewDigestFromHex(string(digest.SHA256), e.Name())] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(blobsDir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportLayout pulls an artifact from a registry and writes it to the OCI image
+// layout directory, named after the given reference. It returns the digest of
+// the written manifest.
+func ExportLayout(ctx context.Context, repoTag, dir string, opts RegistryOptions) (string, error) {
+	a, err := PullArtifact(ctx, repoTag, opts)
+	if err != nil {
+		return "", err
+	}
+	return WriteLayout(dir, repoTag, a)
+}
+
+// ImportLayout reads the artifact with the given reference name from the OCI
+// image layout directory and pushes it to a registry. It returns the digest
+// of the pushed manifest.
+func ImportLayout(ctx context.Context, dir, ref, repoTag string, opts RegistryOptions) (string, error) {
+	a, err := ReadLayout(dir, ref)
+	if err != nil {
+		return "", err
+	}
+	return PushArtifact(ctx, a, repoTag, opts)
+}
+
+// InitLayout creates the OCI image layout directory, if needed
+func InitLayout(dir string) error {
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", string(digest.SHA256)), 0755); err != nil {
+		return errors.Wrap(err, "failed to create OCI layout")
+	}
+	layoutFile := filepath.Join(dir, ociv1.ImageLayoutFile)
+	if _, err := os.Stat(layoutFile); err == nil {
+		return nil
+	}
+	data, err := json.Marshal(ociv1.ImageLayout{Version: ociv1.ImageLayoutVersion})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(layoutFile, data, 0644)
+}
+
+// ReadLayoutIndex reads the index of the OCI image layout directory, empty if
+// it has none yet
+func ReadLayoutIndex(dir string) (*ociv1.Index, error) {
+	if _, err := os.Stat(filepath.Join(dir, ociv1.ImageLayoutFile)); err != nil {
+		return nil, errors.Errorf("%s is not an OCI image layout", dir)
+	}
+	index := &ociv1.Index{Versioned: specs.Versioned{SchemaVersion: 2}, Manifests: []ociv1.Descriptor{}}
+	data, err := ioutil.ReadFile(filepath.Join(dir, layoutIndexFile))
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, errors.Wrap(err, "invalid OCI layout index")
+	}
+	return index, nil
+}
+
+// WriteLayoutIndex replaces the index of the OCI image layout directory,
+// atomically
+func WriteLayoutIndex(dir string, index *ociv1.Index) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, layoutIndexFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to write OCI layout index")
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, layoutIndexFile))
+	}
+	return errors.Wrap(err, "failed to write OCI layout index")
+}
+
+// LayoutBlobPath returns the path of the blob in the OCI image layout
+// directory
+func LayoutBlobPath(dir string, dgst digest.Digest) (string, error) {
+	if err := dgst.Validate(); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "blobs", string(dgst.Algorithm()), dgst.Hex()), nil
+}
+
+// WriteLayoutBlob writes the blob to the OCI image layout directory
+func WriteLayoutBlob(dir string, dgst digest.Digest, data []byte) error {
+	p, err := LayoutBlobPath(dir, dgst)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, data, 0644)
+}
+
+// ReadLayoutBlob reads the blob from the OCI image layout directory, checking
+// its digest
+func ReadLayoutBlob(dir string, dgst digest.Digest) ([]byte, error) {
+	p, err := LayoutBlobPath(dir, dgst)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, distribution.ErrBlobUnknown
+	}
+	if err != nil {
+		return nil, err
+	}
+	if digest.FromBytes(data) != dgst {
+		return nil, fmt.Errorf("digest mismatch for blob %s", dgst)
+	}
+	return data, nil
+}
@@ -0,0 +1,337 @@
+package resto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/docker/distribution"
+	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MediaTypeArtifactConfig is the media type of the config of an OCI
+	// artifact pushed by resto
+	MediaTypeArtifactConfig = "application/vnd.docker.app.config.v1+json"
+	// MediaTypeArtifactFile is the default media type of the files of an OCI
+	// artifact
+	MediaTypeArtifactFile = "application/vnd.docker.app.file.v1"
+)
+
+// Artifact is a set of files, stored in a registry as an OCI artifact: an OCI
+// image manifest with a layer per file.
+type Artifact struct {
+	Files []ArtifactFile
+	// Annotations are set on the manifest
+	Annotations map[string]string
+}
+
+// ArtifactFile is a file of an artifact
+type ArtifactFile struct {
+	// Path is the slash-separated path of the file, stored as the title
+	// annotation of its layer
+	Path string
+	// MediaType is the media type of the layer, MediaTypeArtifactFile if empty
+	MediaType string
+	Content   []byte
+}
+
+// ArtifactConfig is the content-addressed config of an artifact
+type ArtifactConfig struct {
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Files       []string          `json:"files"`
+}
+
+// NewArtifact creates an artifact holding the given files, indexed by path,
+// with the default media type
+func NewArtifact(payload map[string]string, annotations map[string]string) *Artifact {
+	a := &Artifact{Annotations: annotations}
+	for p, content := range payload {
+		a.Files = append(a.Files, ArtifactFile{Path: p, Content: []byte(content)})
+	}
+	return a
+}
+
+// Payload returns the files of the artifact, indexed by path
+func (a *Artifact) Payload() map[string]string {
+	res := make(map[string]string, len(a.Files))
+	for _, f := range a.Files {
+		res[f.Path] = string(f.Content)
+	}
+	return res
+}
+
+// OCIManifest is an OCI image manifest
+type OCIManifest struct {
+	specs.Versioned
+	MediaType   string             `json:"mediaType,omitempty"`
+	Config      ociv1.Descriptor   `json:"config"`
+	Layers      []ociv1.Descriptor `json:"layers"`
+	Annotations map[string]string  `json:"annotations,omitempty"`
+}
+
+// DeserializedOCIManifest is an OCI image manifest along with its canonical
+// JSON payload
+type DeserializedOCIManifest struct {
+	OCIManifest
+	canonical []byte
+}
+
+// References returns the config and the layers of the manifest
+func (m *DeserializedOCIManifest) References() []distribution.Descriptor {
+	refs := []distribution.Descriptor{toDistribution(m.Config)}
+	for _, l := range m.Layers {
+		refs = append(refs, toDistribution(l))
+	}
+	return refs
+}
+
+// Payload returns the media type and the canonical payload of the manifest
+func (m *DeserializedOCIManifest) Payload() (string, []byte, error) {
+	return ociv1.MediaTypeImageManifest, m.canonical, nil
+}
+
+func toDistribution(d ociv1.Descriptor) distribution.Descriptor {
+	return distribution.Descriptor{MediaType: d.MediaType, Digest: d.Digest, Size: d.Size}
+}
+
+func unmarshalOCIManifest(b []byte) (distribution.Manifest, distribution.Descriptor, error) {
+	m := &DeserializedOCIManifest{canonical: b}
+	if err := json.Unmarshal(b, &m.OCIManifest); err != nil {
+		return nil, distribution.Descriptor{}, err
+	}
+	return m, distribution.Descriptor{
+		MediaType: ociv1.MediaTypeImageManifest,
+		Digest:    digest.FromBytes(b),
+		Size:      int64(len(b)),
+	}, nil
+}
+
+// asArtifactManifest returns the manifest as an OCI artifact manifest, if it
+// is one. OCI manifests are unmarshalled by the distribution client as schema2
+// manifests, so they are told apart by their config media type.
+func asArtifactManifest(manifest distribution.Manifest) (*DeserializedOCIManifest, bool) {
+	_, payload, err := manifest.Payload()
+	if err != nil {
+		return nil, false
+	}
+	m, _, err := unmarshalOCIManifest(payload)
+	if err != nil {
+		return nil, false
+	}
+	oci := m.(*DeserializedOCIManifest)
+	return oci, oci.Config.MediaType == MediaTypeArtifactConfig
+}
+
+// buildArtifact returns the manifest of the artifact, and its config and file
+// blobs indexed by digest. Files are sorted by path, so that the same
+// artifact always has the same digest.
+func buildArtifact(a *Artifact) (*DeserializedOCIManifest, map[digest.Digest][]byte, error) {
+	files := append([]ArtifactFile{}, a.Files...)
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	blobs := map[digest.Digest][]byte{}
+	config := ArtifactConfig{Annotations: a.Annotations, Files: []string{}}
+	var layers []ociv1.Descriptor
+	for _, f := range files {
+		mediaType := f.MediaType
+		if mediaType == "" {
+			mediaType = MediaTypeArtifactFile
+		}
+		dgst := digest.FromBytes(f.Content)
+		blobs[dgst] = f.Content
+		layers = append(layers, ociv1.Descriptor{
+			MediaType:   mediaType,
+			Digest:      dgst,
+			Size:        int64(len(f.Content)),
+			Annotations: map[string]string{ociv1.AnnotationTitle: f.Path},
+		})
+		config.Files = append(config.Files, f.Path)
+	}
+	configData, err := json.Marshal(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	configDigest := digest.FromBytes(configData)
+	blobs[configDigest] = configData
+	manifest := OCIManifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ociv1.MediaTypeImageManifest,
+		Config: ociv1.Descriptor{
+			MediaType: MediaTypeArtifactConfig,
+			Digest:    configDigest,
+			Size:      int64(len(configData)),
+		},
+		Layers:      layers,
+		Annotations: a.Annotations,
+	}
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &DeserializedOCIManifest{OCIManifest: manifest, canonical: raw}, blobs, nil
+}
+
+// parseArtifact reads the artifact of the manifest, fetching its layers
+func parseArtifact(m *DeserializedOCIManifest, fetch func(digest.Digest) ([]byte, error)) (*Artifact, error) {
+	if m.Config.MediaType != MediaTypeArtifactConfig {
+		return nil, fmt.Errorf("unexpected config media type %q", m.Config.MediaType)
+	}
+	a := &Artifact{Annotations: m.Annotations}
+	for _, l := range m.Layers {
+		p := l.Annotations[ociv1.AnnotationTitle]
+		if p == "" {
+			log.Debugf("dropping layer %s without title", l.Digest)
+			continue
+		}
+		content, err := fetch(l.Digest)
+		if err != nil {
+			return nil, err
+		}
+		if digest.FromBytes(content) != l.Digest {
+			return nil, fmt.Errorf("digest mismatch for %s", p)
+		}
+		a.Files = append(a.Files, ArtifactFile{Path: p, MediaType: l.MediaType, Content: content})
+	}
+	return a, nil
+}
+
+// PushArtifact pushes the artifact to a registry and returns its digest.
+// Registries which don't support OCI artifacts are pushed the artifact files
+// and annotations as PushConfigMulti would.
+func PushArtifact(ctx context.Context, a *Artifact, repoTag string, opts RegistryOptions) (string, error) {
+	pr, err := parseRef(repoTag)
+	if err != nil {
+		return "", err
+	}
+	if opts.Username == "" {
+		opts.Username, opts.Password, err = getCredentials(pr.domain)
+		if err != nil {
+			log.Debugf("failed to get credentials for %s: %s", pr.domain, err)
+		}
+	}
+	repo, err := NewRepository(ctx, pr.domain, pr.path, opts)
+	if err != nil {
+		return "", err
+	}
+	dgst, err := pushArtifact(ctx, a, pr, repo)
+	if _, ok := err.(unsupportedMediaType); !ok {
+		return dgst, err
+	}
+	log.Debugf("OCI artifacts are not supported by %s, falling back to a config manifest", pr.domain)
+	return pushConfig(ctx, a.Payload(), pr, repo, a.Annotations)
+}
+
+func pushArtifact(ctx context.Context, a *Artifact, pr parsedReference, repo distribution.Repository) (string, error) {
+	manifest, blobs, err := buildArtifact(a)
+	if err != nil {
+		return "", err
+	}
+	blobsService := repo.Blobs(ctx)
+	for _, d := range manifest.References() {
+		if _, err := blobsService.Stat(ctx, d.Digest); err == nil {
+			continue
+		}
+		if _, err := blobsService.Put(ctx, d.MediaType, blobs[d.Digest]); err != nil {
+			return "", err
+		}
+	}
+	manifestService, err := repo.Manifests(ctx)
+	if err != nil {
+		return "", err
+	}
+	dgst, err := manifestService.Put(ctx, manifest, distribution.WithTag(pr.tag))
+	if err != nil {
+		return "", asUnsupportedMediaType(err)
+	}
+	return dgst.String(), nil
+}
+
+// verifyManifestDigest checks the manifest content matches the digest it was
+// fetched by
+func verifyManifestDigest(manifest distribution.Manifest, dgst digest.Digest) error {
+	_, payload, err := manifest.Payload()
+	if err != nil {
+		return err
+	}
+	verifier := dgst.Verifier()
+	verifier.Write(payload)
+	if !verifier.Verified() {
+		return fmt.Errorf("manifest does not match digest %s", dgst)
+	}
+	return nil
+}
+
+// PullArtifact pulls an artifact from a registry, by tag or digest. Config
+// manifests and legacy images are read as artifacts with the default file
+// media type.
+func PullArtifact(ctx context.Context, repoTag string, opts RegistryOptions) (*Artifact, error) {
+	repo, manifest, _, err := fetchManifest(ctx, repoTag, opts)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := asArtifactManifest(manifest); ok {
+		blobsService := repo.Blobs(ctx)
+		return parseArtifact(m, func(d digest.Digest) ([]byte, error) { return blobsService.Get(ctx, d) })
+	}
+	payload, err := pullConfig(ctx, manifest, repo)
+	if err != nil {
+		return nil, err
+	}
+	return NewArtifact(payload, nil), nil
+}
+
+// DescribeArtifact returns the digest of the manifest the reference points to
+// and, for OCI artifacts, its annotations, without pulling the artifact files
+func DescribeArtifact(ctx context.Context, repoTag string, opts RegistryOptions) (string, map[string]string, error) {
+	_, manifest, dgst, err := fetchManifest(ctx, repoTag, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	if m, ok := asArtifactManifest(manifest); ok {
+		return dgst.String(), m.Annotations, nil
+	}
+	return dgst.String(), nil, nil
+}
+
+// fetchManifest fetches the manifest the reference points to, by tag or
+// digest, and checks its digest
+func fetchManifest(ctx context.Context, repoTag string, opts RegistryOptions) (distribution.Repository, distribution.Manifest, digest.Digest, error) {
+	pr, err := parseRef(repoTag)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if opts.Username == "" {
+		opts.Username, opts.Password, err = getCredentials(pr.domain)
+		if err != nil {
+			log.Debugf("failed to get credentials for %s: %s", pr.domain, err)
+		}
+	}
+	repo, err := NewRepository(ctx, pr.domain, pr.path, opts)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	dgst := pr.digest
+	if dgst == "" {
+		desc, err := repo.Tags(ctx).Get(ctx, pr.tag)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		dgst = desc.Digest
+	}
+	manifestService, err := repo.Manifests(ctx)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	manifest, err := manifestService.Get(ctx, dgst)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if err := verifyManifestDigest(manifest, dgst); err != nil {
+		return nil, nil, "", err
+	}
+	return repo, manifest, dgst, nil
+}
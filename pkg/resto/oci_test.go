@@ -0,0 +1,182 @@
+package resto
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/docker/app/internal/registrytest"
+	digest "github.com/opencontainers/go-digest"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func testArtifact() *Artifact {
+	return &Artifact{
+		Files: []ArtifactFile{
+			{Path: "metadata.yml", MediaType: "application/vnd.docker.app.metadata.v1+yaml", Content: []byte("name: app\n")},
+			{Path: "docker-compose.yml", MediaType: "application/vnd.docker.app.compose.v1+yaml", Content: []byte("version: \"3.6\"\n")},
+			{Path: "settings/prod.yml", Content: []byte("port: 80\n")},
+		},
+		Annotations: map[string]string{ociv1.AnnotationTitle: "app"},
+	}
+}
+
+func filesByPath(a *Artifact) map[string]ArtifactFile {
+	res := map[string]ArtifactFile{}
+	for _, f := range a.Files {
+		if f.MediaType == "" {
+			f.MediaType = MediaTypeArtifactFile
+		}
+		res[f.Path] = f
+	}
+	return res
+}
+
+func TestPushPullArtifact(t *testing.T) {
+	registry := registrytest.New()
+	defer registry.Close()
+	ref := registry.Host() + "/app.dockerapp:0.1.0"
+
+	dgst, err := PushArtifact(context.Background(), testArtifact(), ref, RegistryOptions{})
+	assert.NilError(t, err)
+	mediaType, _, ok := registry.Manifest("app.dockerapp", "0.1.0")
+	assert.Assert(t, ok)
+	assert.Check(t, is.Equal(mediaType, ociv1.MediaTypeImageManifest))
+
+	for _, r := range []string{ref, registry.Host() + "/app.dockerapp@" + dgst} {
+		pulled, err := PullArtifact(context.Background(), r, RegistryOptions{})
+		assert.NilError(t, err, r)
+		assert.Check(t, is.DeepEqual(filesByPath(pulled), filesByPath(testArtifact())), r)
+		assert.Check(t, is.DeepEqual(pulled.Annotations, testArtifact().Annotations), r)
+	}
+
+	_, err = PullArtifact(context.Background(), registry.Host()+"/app.dockerapp:unknown", RegistryOptions{})
+	assert.Check(t, IsNotFound(err), "%v", err)
+	_, err = PullArtifact(context.Background(), registry.Host()+"/unknown.dockerapp:0.1.0", RegistryOptions{})
+	assert.Check(t, IsNotFound(err), "%v", err)
+
+	// pushing the same artifact again yields the same digest
+	again, err := PushArtifact(context.Background(), testArtifact(), ref, RegistryOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(again, dgst))
+}
+
+func TestPushArtifactFallback(t *testing.T) {
+	registry := registrytest.New(registrytest.WithRejectedMediaTypes(ociv1.MediaTypeImageManifest))
+	defer registry.Close()
+	ref := registry.Host() + "/app.dockerapp:0.1.0"
+
+	_, err := PushArtifact(context.Background(), testArtifact(), ref, RegistryOptions{})
+	assert.NilError(t, err)
+	mediaType, _, ok := registry.Manifest("app.dockerapp", "0.1.0")
+	assert.Assert(t, ok)
+	assert.Check(t, is.Equal(mediaType, MediaTypeConfig))
+
+	payload, err := PullConfigMulti(context.Background(), ref, RegistryOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(payload, testArtifact().Payload()))
+}
+
+func TestLayout(t *testing.T) {
+	dir := fs.NewDir(t, "layout")
+	defer dir.Remove()
+
+	_, err := ReadLayout(dir.Path(), "")
+	assert.ErrorContains(t, err, "is not an OCI image layout")
+
+	dgst, err := WriteLayout(dir.Path(), "app.dockerapp:0.1.0", testArtifact())
+	assert.NilError(t, err)
+	other := testArtifact()
+	other.Files = other.Files[:1]
+	_, err = WriteLayout(dir.Path(), "app.dockerapp:0.2.0", other)
+	assert.NilError(t, err)
+	// rewriting a reference replaces it
+	again, err := WriteLayout(dir.Path(), "app.dockerapp:0.1.0", testArtifact())
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(again, dgst))
+
+	entries, err := ListLayout(dir.Path())
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(entries, []LayoutEntry{
+		{Ref: "app.dockerapp:0.2.0", Digest: entries[0].Digest, Annotations: map[string]string{}},
+		{Ref: "app.dockerapp:0.1.0", Digest: dgst, Annotations: map[string]string{}},
+	}))
+
+	a, err := ReadLayout(dir.Path(), "app.dockerapp:0.1.0")
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(filesByPath(a), filesByPath(testArtifact())))
+	_, err = ReadLayout(dir.Path(), "")
+	assert.ErrorContains(t, err, "a reference name is required")
+	_, err = ReadLayout(dir.Path(), "unknown")
+	assert.ErrorContains(t, err, "not found")
+	a, err = ReadLayout(dir.Path(), dgst)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(filesByPath(a), filesByPath(testArtifact())))
+}
+
+func TestTagRemoveLayout(t *testing.T) {
+	dir := fs.NewDir(t, "layout")
+	defer dir.Remove()
+	dgst, err := WriteLayoutEntry(dir.Path(), "app.dockerapp:0.1.0", testArtifact(), map[string]string{"source": "registry"})
+	assert.NilError(t, err)
+	other := testArtifact()
+	other.Files = other.Files[:1]
+	_, err = WriteLayout(dir.Path(), "app.dockerapp:0.2.0", other)
+	assert.NilError(t, err)
+
+	assert.NilError(t, TagLayout(dir.Path(), "app.dockerapp:0.1.0", "app.dockerapp:latest"))
+	assert.ErrorContains(t, TagLayout(dir.Path(), "unknown", "app.dockerapp:latest"), "not found")
+	entries, err := ListLayout(dir.Path())
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(entries[2], LayoutEntry{Ref: "app.dockerapp:latest", Digest: dgst, Annotations: map[string]string{"source": "registry"}}))
+
+	countBlobs := func() int {
+		blobs, err := ioutil.ReadDir(dir.Join("blobs", "sha256"))
+		assert.NilError(t, err)
+		return len(blobs)
+	}
+	// 3 files, 2 configs and 2 manifests, metadata.yml being shared
+	assert.Check(t, is.Equal(countBlobs(), 7))
+	assert.NilError(t, RemoveLayout(dir.Path(), "app.dockerapp:0.1.0"))
+	assert.Check(t, is.Equal(countBlobs(), 7))
+	assert.NilError(t, RemoveLayout(dir.Path(), "app.dockerapp:latest"))
+	assert.Check(t, is.Equal(countBlobs(), 3))
+	assert.ErrorContains(t, RemoveLayout(dir.Path(), "app.dockerapp:latest"), "not found")
+	_, err = ReadLayout(dir.Path(), "app.dockerapp:0.2.0")
+	assert.NilError(t, err)
+}
+
+func TestExportImportLayout(t *testing.T) {
+	source := registrytest.New()
+	defer source.Close()
+	target := registrytest.New()
+	defer target.Close()
+	dir := fs.NewDir(t, "layout")
+	defer dir.Remove()
+
+	ref := source.Host() + "/app.dockerapp:0.1.0"
+	dgst, err := PushArtifact(context.Background(), testArtifact(), ref, RegistryOptions{})
+	assert.NilError(t, err)
+	exported, err := ExportLayout(context.Background(), ref, dir.Path(), RegistryOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(exported, dgst))
+
+	imported, err := ImportLayout(context.Background(), dir.Path(), ref, target.Host()+"/app.dockerapp:0.1.0", RegistryOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(imported, dgst))
+	pulled, err := PullArtifact(context.Background(), target.Host()+"/app.dockerapp:0.1.0", RegistryOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(filesByPath(pulled), filesByPath(testArtifact())))
+}
+
+func TestVerifyManifestDigest(t *testing.T) {
+	manifest := NewConfigManifest(MediaTypeConfig, []byte(`{"payload":"{}"}`))
+	_, payload, err := manifest.Payload()
+	assert.NilError(t, err)
+	assert.NilError(t, verifyManifestDigest(manifest, digest.FromBytes(payload)))
+	err = verifyManifestDigest(manifest, digest.FromString("tampered"))
+	assert.ErrorContains(t, err, "manifest does not match digest")
+}
@@ -16,6 +16,8 @@ import (
 	"github.com/docker/distribution/manifest"
 	"github.com/docker/distribution/manifest/schema2"
 	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
 	"github.com/docker/distribution/registry/client"
 	digest "github.com/opencontainers/go-digest"
 	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
@@ -36,6 +38,23 @@ func (u unsupportedMediaType) Error() string {
 	return "Unsupported media type"
 }
 
+// IsNotFound returns whether the error means the requested repository, tag or
+// manifest doesn't exist in the registry
+func IsNotFound(err error) bool {
+	switch e := err.(type) {
+	case distribution.ErrTagUnknown, distribution.ErrManifestUnknown, distribution.ErrManifestUnknownRevision:
+		return true
+	case errcode.Errors:
+		return len(e) > 0 && IsNotFound(e[0])
+	case errcode.Error:
+		return IsNotFound(e.Code)
+	case errcode.ErrorCode:
+		return e == v2.ErrorCodeManifestUnknown || e == v2.ErrorCodeNameUnknown
+	default:
+		return false
+	}
+}
+
 // ManifestAny is a manifest type for arbitrary configuration data
 type ManifestAny struct {
 	manifest.Versioned
@@ -46,6 +65,7 @@ type parsedReference struct {
 	domain string
 	path   string
 	tag    string
+	digest digest.Digest
 }
 
 func parseRef(repoTag string) (parsedReference, error) {
@@ -61,11 +81,15 @@ func parseRef(repoTag string) (parsedReference, error) {
 	if rt, ok := ref.(reference.Tagged); ok {
 		tag = rt.Tag()
 	}
+	var dgst digest.Digest
+	if rd, ok := ref.(reference.Digested); ok {
+		dgst = rd.Digest()
+	}
 	domain := reference.Domain(ref)
 	if domain == "docker.io" {
 		domain = "registry-1.docker.io"
 	}
-	return parsedReference{"https://" + domain, reference.Path(ref), tag}, nil
+	return parsedReference{"https://" + domain, reference.Path(ref), tag, dgst}, nil
 }
 
 func getCredentials(domain string) (string, string, error) {
@@ -123,8 +147,30 @@ func makeTarGz(content map[string]string) ([]byte, digest.Digest, error) {
 
 const maxRepositoryCount = 10000
 
-// ListRepositories lists all the repositories in a registry
+// ListRepositories lists all the repositories in a registry. The endpoint
+// defaults to https, falling back to http for registries which don't support it.
 func ListRepositories(ctx context.Context, endpoint string, opts RegistryOptions) ([]string, error) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	if opts.Username == "" {
+		var err error
+		opts.Username, opts.Password, err = getCredentials(endpoint)
+		if err != nil {
+			log.Debugf("failed to get credentials for %s: %s", endpoint, err)
+		}
+	}
+	repos, err := listRepositories(ctx, endpoint, opts)
+	if err == nil || !strings.HasPrefix(endpoint, "https://") || !strings.Contains(err.Error(), "HTTP response to HTTPS client") {
+		return repos, err
+	}
+	if !opts.CleartextCredentials {
+		opts.Username, opts.Password = "", ""
+	}
+	return listRepositories(ctx, strings.Replace(endpoint, "https://", "http://", 1), opts)
+}
+
+func listRepositories(ctx context.Context, endpoint string, opts RegistryOptions) ([]string, error) {
 	tr, err := NewTransportCatalog(endpoint, opts)
 	if err != nil {
 		return nil, err
@@ -155,20 +201,14 @@ func ListTags(ctx context.Context, reponame string, opts RegistryOptions) ([]str
 	return tagService.All(ctx)
 }
 
-// PullConfig pulls a configuration file from a registry
-func PullConfig(ctx context.Context, repoTag string, opts RegistryOptions) (string, error) {
-	res, err := PullConfigMulti(ctx, repoTag, opts)
+// ResolveDigest returns the digest of the manifest the given tag points to
+func ResolveDigest(ctx context.Context, repoTag string, opts RegistryOptions) (string, error) {
+	pr, err := parseRef(repoTag)
 	if err != nil {
 		return "", err
 	}
-	return res["config"], nil
-}
-
-// PullConfigMulti pulls a set of configuration files from a registry
-func PullConfigMulti(ctx context.Context, repoTag string, opts RegistryOptions) (map[string]string, error) {
-	pr, err := parseRef(repoTag)
-	if err != nil {
-		return nil, err
+	if pr.digest != "" {
+		return pr.digest.String(), nil
 	}
 	if opts.Username == "" {
 		opts.Username, opts.Password, err = getCredentials(pr.domain)
@@ -178,21 +218,35 @@ func PullConfigMulti(ctx context.Context, repoTag string, opts RegistryOptions)
 	}
 	repo, err := NewRepository(ctx, pr.domain, pr.path, opts)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	tagService := repo.Tags(ctx)
-	dgst, err := tagService.Get(ctx, pr.tag)
+	desc, err := repo.Tags(ctx).Get(ctx, pr.tag)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	manifestService, err := repo.Manifests(ctx)
+	return desc.Digest.String(), nil
+}
+
+// PullConfig pulls a configuration file from a registry
+func PullConfig(ctx context.Context, repoTag string, opts RegistryOptions) (string, error) {
+	res, err := PullConfigMulti(ctx, repoTag, opts)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	manifest, err := manifestService.Get(ctx, dgst.Digest)
+	return res["config"], nil
+}
+
+// PullConfigMulti pulls a set of configuration files from a registry. The
+// reference can be pinned to a digest (repo@sha256:...).
+func PullConfigMulti(ctx context.Context, repoTag string, opts RegistryOptions) (map[string]string, error) {
+	a, err := PullArtifact(ctx, repoTag, opts)
 	if err != nil {
 		return nil, err
 	}
+	return a.Payload(), nil
+}
+
+func pullConfig(ctx context.Context, manifest distribution.Manifest, repo distribution.Repository) (map[string]string, error) {
 	mediaType, payload, err := manifest.Payload()
 	if err != nil {
 		return nil, err
@@ -258,22 +312,14 @@ func PushConfig(ctx context.Context, payload, repoTag string, opts RegistryOptio
 	}, repoTag, opts, labels)
 }
 
-// PushConfigMulti pushes a set of configuration files to a registry and returns its digest
+// PushConfigMulti pushes a set of configuration files to a registry and returns its digest.
+// They are pushed as an OCI artifact, or, if the registry doesn't support it,
+// as a config manifest or as a legacy image, labelled with the given labels.
 func PushConfigMulti(ctx context.Context, payload map[string]string, repoTag string, opts RegistryOptions, labels map[string]string) (string, error) {
-	pr, err := parseRef(repoTag)
-	if err != nil {
-		return "", err
-	}
-	if opts.Username == "" {
-		opts.Username, opts.Password, err = getCredentials(pr.domain)
-		if err != nil {
-			log.Debugf("failed to get credentials for %s: %s", pr.domain, err)
-		}
-	}
-	repo, err := NewRepository(ctx, pr.domain, pr.path, opts)
-	if err != nil {
-		return "", err
-	}
+	return PushArtifact(ctx, NewArtifact(payload, labels), repoTag, opts)
+}
+
+func pushConfig(ctx context.Context, payload map[string]string, pr parsedReference, repo distribution.Repository, labels map[string]string) (string, error) {
 	digest, err := pushConfigMediaType(ctx, payload, pr, repo)
 	if err == nil {
 		return digest, err
@@ -306,16 +352,22 @@ func pushConfigMediaType(ctx context.Context, payload map[string]string, pr pars
 	}
 	manifest := NewConfigManifest(MediaTypeConfig, raw)
 	dgst, err := manifestService.Put(ctx, manifest, distribution.WithTag(pr.tag))
-	if err == nil {
-		return dgst.String(), nil
+	if err != nil {
+		return "", asUnsupportedMediaType(err)
 	}
+	return dgst.String(), nil
+}
+
+// asUnsupportedMediaType returns unsupportedMediaType if the manifest push
+// error means the registry doesn't support the manifest media type
+func asUnsupportedMediaType(err error) error {
 	switch {
 	case strings.Contains(err.Error(), "manifest invalid"):
-		return "", unsupportedMediaType{}
+		return unsupportedMediaType{}
 	case strings.Contains(err.Error(), "manifest Unknown"):
-		return "", unsupportedMediaType{}
+		return unsupportedMediaType{}
 	default:
-		return "", err
+		return err
 	}
 }
 
@@ -0,0 +1,255 @@
+package yatee
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/docker/app/internal/slices"
+	"github.com/docker/app/internal/yaml"
+)
+
+var (
+	blockNameRe   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+	blockHeaderRe = regexp.MustCompile(`^([^(]+)(?:\(([^)]*)\))?$`)
+)
+
+// block is a @define block
+type block struct {
+	params []string
+	body   map[interface{}]interface{}
+}
+
+// blocks are the @define blocks of a template and of its included files, and
+// what is being used and included, to detect cycles
+type blocks struct {
+	defined map[string]block
+	// collected are the included files whose blocks are defined
+	collected map[string]bool
+	using     []string
+	including []string
+}
+
+func newBlocks() *blocks {
+	return &blocks{
+		defined:   map[string]block{},
+		collected: map[string]bool{},
+	}
+}
+
+// parseBlockHeader parses "NAME" or "NAME(param, ...)"
+func parseBlockHeader(header string) (string, []string, error) {
+	m := blockHeaderRe.FindStringSubmatch(strings.TrimSpace(header))
+	if m == nil || !blockNameRe.MatchString(strings.TrimSpace(m[1])) {
+		return "", nil, fmt.Errorf("invalid block definition '@define %s', expected '@define NAME' or '@define NAME(PARAM, ...)'", header)
+	}
+	var params []string
+	if strings.TrimSpace(m[2]) != "" {
+		for _, p := range strings.Split(m[2], ",") {
+			p = strings.TrimSpace(p)
+			if !blockNameRe.MatchString(p) {
+				return "", nil, fmt.Errorf("invalid parameter '%s' in '@define %s'", p, header)
+			}
+			params = append(params, p)
+		}
+	}
+	return strings.TrimSpace(m[1]), params, nil
+}
+
+// collect removes the @define blocks of the tree and defines them. The blocks
+// of the files included with literal paths are defined too, so that blocks
+// don't depend on the order they are processed in.
+func (b *blocks) collect(tree interface{}, o options) error {
+	switch t := tree.(type) {
+	case []interface{}:
+		for _, item := range t {
+			if err := b.collect(item, o); err != nil {
+				return err
+			}
+		}
+	case map[interface{}]interface{}:
+		for k, v := range t {
+			key, ok := k.(string)
+			if !ok {
+				continue
+			}
+			key = strings.TrimLeft(key, " ")
+			switch {
+			case strings.HasPrefix(key, "@define "):
+				if err := b.define(strings.TrimPrefix(key, "@define "), v); err != nil {
+					return err
+				}
+				delete(t, k)
+			case strings.HasPrefix(key, "@include ") && !strings.Contains(key, "$"):
+				if _, err := b.include(strings.TrimSpace(strings.TrimPrefix(key, "@include ")), o); err != nil {
+					return err
+				}
+			}
+			if err := b.collect(v, o); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *blocks) define(header string, v interface{}) error {
+	name, params, err := parseBlockHeader(header)
+	if err != nil {
+		return err
+	}
+	if _, ok := b.defined[name]; ok {
+		return fmt.Errorf("block '%s' is defined twice", name)
+	}
+	body, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return fmt.Errorf("@define value must be a mapping")
+	}
+	b.defined[name] = block{params: params, body: body}
+	return nil
+}
+
+// include loads the file of the application directory, and defines its
+// blocks
+func (b *blocks) include(path string, o options) (map[interface{}]interface{}, error) {
+	if o.includeDir == "" {
+		return nil, fmt.Errorf("cannot include '%s': files can only be included from an application directory", path)
+	}
+	clean := filepath.ToSlash(filepath.Clean(filepath.FromSlash(path)))
+	if filepath.IsAbs(path) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return nil, fmt.Errorf("cannot include '%s': it is outside of the application directory", path)
+	}
+	for i, p := range b.including {
+		if p == clean {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(b.including[i:], " -> "), clean)
+		}
+	}
+	data, err := ioutil.ReadFile(filepath.Join(o.includeDir, filepath.FromSlash(clean)))
+	if err != nil {
+		return nil, fmt.Errorf("cannot include '%s': %s", path, err)
+	}
+	included := make(map[interface{}]interface{})
+	if err := yaml.Unmarshal(data, included); err != nil {
+		return nil, fmt.Errorf("cannot include '%s': %s", path, err)
+	}
+	if b.collected[clean] {
+		return included, nil
+	}
+	b.collected[clean] = true
+	b.including = append(b.including, clean)
+	defer func() { b.including = b.including[:len(b.including)-1] }()
+	if err := b.collect(included, o); err != nil {
+		return nil, err
+	}
+	return included, nil
+}
+
+// use returns the content of the block, with its parameters set
+func (b *blocks) use(name string, v interface{}, settings map[string]interface{}, flattened map[string]interface{}, o options) (map[interface{}]interface{}, error) {
+	def, ok := b.defined[name]
+	if !ok {
+		return nil, fmt.Errorf("block '%s' is not defined", name)
+	}
+	for i, n := range b.using {
+		if n == name {
+			return nil, fmt.Errorf("block cycle: %s -> %s", strings.Join(b.using[i:], " -> "), name)
+		}
+	}
+	args, err := arguments("@use", v, settings, flattened, o)
+	if err != nil {
+		return nil, err
+	}
+	for arg := range args {
+		if !slices.ContainsString(def.params, arg) {
+			return nil, fmt.Errorf("unknown parameter '%s' for block '%s'", arg, name)
+		}
+	}
+	b.using = append(b.using, name)
+	defer func() { b.using = b.using[:len(b.using)-1] }()
+	return withVariables(def.params, args, flattened, func() (map[interface{}]interface{}, error) {
+		return recurse(def.body, settings, flattened, o)
+	})
+}
+
+// includeFile returns the content of the included file, with the given
+// variables set
+func (b *blocks) includeFile(path string, v interface{}, settings map[string]interface{}, flattened map[string]interface{}, o options) (map[interface{}]interface{}, error) {
+	included, err := b.include(path, o)
+	if err != nil {
+		return nil, err
+	}
+	args, err := arguments("@include", v, settings, flattened, o)
+	if err != nil {
+		return nil, err
+	}
+	clean := filepath.ToSlash(filepath.Clean(filepath.FromSlash(path)))
+	b.including = append(b.including, clean)
+	defer func() { b.including = b.including[:len(b.including)-1] }()
+	return withVariables(nil, args, flattened, func() (map[interface{}]interface{}, error) {
+		return recurse(included, settings, flattened, o)
+	})
+}
+
+// arguments evaluates the parameters given to @use or @include
+func arguments(directive string, v interface{}, settings map[string]interface{}, flattened map[string]interface{}, o options) (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	if v == nil {
+		return args, nil
+	}
+	mii, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s value must be a mapping of parameters", directive)
+	}
+	for k := range mii {
+		if _, ok := k.(string); !ok {
+			return nil, fmt.Errorf("%s parameter %v must be a string", directive, k)
+		}
+	}
+	evaluated, err := recurse(mii, settings, flattened, o)
+	if err != nil {
+		return nil, err
+	}
+	merge(args, evaluated)
+	return args, nil
+}
+
+// withVariables calls f with the arguments set, the other parameters unset,
+// and restores the variables afterwards
+func withVariables(params []string, args map[string]interface{}, flattened map[string]interface{}, f func() (map[interface{}]interface{}, error)) (map[interface{}]interface{}, error) {
+	variables := map[string]interface{}{}
+	flatten(args, variables, "")
+	saved := map[string]interface{}{}
+	save := func(name string) {
+		if _, done := saved[name]; done {
+			return
+		}
+		if old, ok := flattened[name]; ok {
+			saved[name] = old
+		} else {
+			saved[name] = unset{}
+		}
+	}
+	for _, p := range params {
+		save(p)
+		delete(flattened, p)
+	}
+	for name, value := range variables {
+		save(name)
+		flattened[name] = value
+	}
+	defer func() {
+		for name, old := range saved {
+			if _, ok := old.(unset); ok {
+				delete(flattened, name)
+			} else {
+				flattened[name] = old
+			}
+		}
+	}()
+	return f()
+}
+
+// unset marks the variables which were not set before a block
+type unset struct{}
@@ -0,0 +1,188 @@
+package yatee
+
+import (
+	"testing"
+
+	"github.com/docker/app/internal/yaml"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func testProcessDir(t *testing.T, dir, input string) (string, error) {
+	settings := map[string]interface{}{
+		"env": "prod",
+		"web": map[string]interface{}{"port": 8080},
+	}
+	res, err := Process(input, settings, OptionErrOnMissingKey, OptionIncludeDir+dir)
+	if err != nil {
+		return "", err
+	}
+	out, err := yaml.Marshal(res)
+	assert.NilError(t, err)
+	return string(out), nil
+}
+
+func TestBlocks(t *testing.T) {
+	out, err := testProcessDir(t, "", `
+"@define logging":
+  logging:
+    driver: json-file
+"@define healthcheck(port, interval)":
+  healthcheck:
+    test: curl -f http://localhost:$port
+    interval: $(default("30s", $interval))
+services:
+  web:
+    image: nginx
+    "@use logging":
+    "@use healthcheck":
+      port: $web.port
+  db:
+    image: postgres
+    logging:
+      driver: syslog
+    "@use logging":
+    "@use healthcheck":
+      port: 5432
+      interval: 10s
+`)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(out, `services:
+  db:
+    healthcheck:
+      interval: 10s
+      test: curl -f http://localhost:5432
+    image: postgres
+    logging:
+      driver: syslog
+  web:
+    healthcheck:
+      interval: 30s
+      test: curl -f http://localhost:8080
+    image: nginx
+    logging:
+      driver: json-file
+`))
+}
+
+func TestInclude(t *testing.T) {
+	dir := fs.NewDir(t, "yatee",
+		fs.WithFile("blocks.yml", `
+"@define deploy(replicas)":
+  deploy:
+    replicas: $replicas
+`),
+		fs.WithDir("services", fs.WithFile("web.yml", `
+web:
+  image: nginx:$tag
+  "@use deploy":
+    replicas: $(2 * 2)
+`)),
+	)
+	defer dir.Remove()
+	out, err := testProcessDir(t, dir.Path(), `
+"@include blocks.yml":
+services:
+  "@include services/web.yml":
+    tag: "1.15"
+  "@if $env == \"prod\"":
+    "@include services/../blocks.yml":
+`)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(out, `services:
+  web:
+    deploy:
+      replicas: 4
+    image: nginx:1.15
+`))
+}
+
+func TestBlocksErrors(t *testing.T) {
+	dir := fs.NewDir(t, "yatee",
+		fs.WithFile("a.yml", `"@include b.yml":`),
+		fs.WithFile("b.yml", `"@include a.yml":`),
+	)
+	defer dir.Remove()
+	testCases := []struct {
+		name          string
+		input         string
+		expectedError string
+	}{
+		{
+			name:          "undefined block",
+			input:         `"@use nope":`,
+			expectedError: "block 'nope' is not defined",
+		},
+		{
+			name: "defined twice",
+			input: `
+"@define a":
+  x: 1
+s:
+  "@define a":
+    y: 1
+`,
+			expectedError: "block 'a' is defined twice",
+		},
+		{
+			name: "unknown parameter",
+			input: `
+"@define a(x)":
+  x: $x
+"@use a":
+  z: 1
+`,
+			expectedError: "unknown parameter 'z' for block 'a'",
+		},
+		{
+			name: "block cycle",
+			input: `
+"@define a":
+  "@use b":
+"@define b":
+  "@use a":
+"@use a":
+`,
+			expectedError: "block cycle: a -> b -> a",
+		},
+		{
+			name: "conflicting blocks",
+			input: `
+"@define a":
+  x: 1
+"@define b":
+  x: 2
+"@use a":
+"@use b":
+`,
+			expectedError: "both set key 'x'",
+		},
+		{
+			name:          "include cycle",
+			input:         `"@include a.yml":`,
+			expectedError: "include cycle: a.yml -> b.yml -> a.yml",
+		},
+		{
+			name:          "missing include",
+			input:         `"@include missing.yml":`,
+			expectedError: "cannot include 'missing.yml'",
+		},
+		{
+			name:          "outside include",
+			input:         `"@include ../a.yml":`,
+			expectedError: "cannot include '../a.yml': it is outside of the application directory",
+		},
+		{
+			name:          "invalid definition",
+			input:         `"@define a(b c)":`,
+			expectedError: "invalid parameter 'b c' in '@define a(b c)'",
+		},
+	}
+	for _, tc := range testCases {
+		_, err := testProcessDir(t, dir.Path(), tc.input)
+		assert.Check(t, is.ErrorContains(err, tc.expectedError), tc.name)
+	}
+	_, err := Process(`"@include a.yml":`, nil)
+	assert.Check(t, is.ErrorContains(err, "files can only be included from an application directory"))
+}
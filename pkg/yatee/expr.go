@@ -0,0 +1,821 @@
+package yatee
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/app/internal/renderer/funcs"
+)
+
+// Expressions are made of typed values: nil, bool, int64, float64, string,
+// lists and maps. Operators, by increasing precedence, are:
+//   ||
+//   &&
+//   == !=
+//   < <= > >= in
+//   + -
+//   * / %
+//   ! - (unary)
+// Operands are literals (numbers, "strings", 'strings', true, false, null and
+// [lists]), variables ($foo.bar or ${foo.bar}), function calls and
+// parenthesized expressions, either (expr) or $(expr).
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokVariable
+	// tokBraced is a ${...} expansion, which is resolved as any other
+	// expansion
+	tokBraced
+	tokIdent
+	tokOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "$(", "+", "-", "*", "/", "%", "<", ">", "!", "(", ")", "[", "]", ","}
+
+// lexer reads the tokens of an expression one at a time, so that an
+// expression can be followed by text which is not an expression
+type lexer struct {
+	input  string
+	pos    int
+	peeked *token
+}
+
+// ExprError is an error in an expression
+type ExprError struct {
+	// Expr is the text the expression is in
+	Expr string
+	// Pos is the offset of the error in the text
+	Pos     int
+	Message string
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("%s at position %d in '%s'", e.Message, e.Pos+1, e.Expr)
+}
+
+func (l *lexer) errorf(pos int, format string, args ...interface{}) error {
+	// $$ are hidden while expanding
+	before := strings.Count(l.input[:pos], "\x00")
+	return &ExprError{
+		Expr:    strings.Replace(l.input, "\x00", "$$", -1),
+		Pos:     pos + before,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (l *lexer) peek() (token, error) {
+	if l.peeked == nil {
+		t, err := l.scan()
+		if err != nil {
+			return token{}, err
+		}
+		l.peeked = &t
+	}
+	return *l.peeked, nil
+}
+
+func (l *lexer) next() (token, error) {
+	t, err := l.peek()
+	l.peeked = nil
+	return t, err
+}
+
+func isIdentChar(r byte) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_'
+}
+
+// nolint: gocyclo
+func (l *lexer) scan() (token, error) {
+	for l.pos < len(l.input) && strings.IndexByte(" \t\n", l.input[l.pos]) != -1 {
+		l.pos++
+	}
+	start := l.pos
+	if start == len(l.input) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	c := l.input[start]
+	switch {
+	case c >= '0' && c <= '9':
+		for l.pos < len(l.input) && (isIdentChar(l.input[l.pos]) || l.input[l.pos] == '.') {
+			l.pos++
+		}
+		text := l.input[start:l.pos]
+		if i, err := strconv.ParseInt(text, 0, 64); err == nil {
+			return token{kind: tokNumber, text: text, value: i, pos: start}, nil
+		}
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return token{kind: tokNumber, text: text, value: f, pos: start}, nil
+		}
+		return token{}, l.errorf(start, "invalid number '%s'", text)
+	case c == '"':
+		quoted, err := strconv.QuotedPrefix(l.input[start:])
+		if err != nil {
+			return token{}, l.errorf(start, "unterminated string")
+		}
+		l.pos += len(quoted)
+		s, _ := strconv.Unquote(quoted)
+		return token{kind: tokString, text: quoted, value: s, pos: start}, nil
+	case c == '\'':
+		end := strings.IndexByte(l.input[start+1:], '\'')
+		if end == -1 {
+			return token{}, l.errorf(start, "unterminated string")
+		}
+		l.pos += end + 2
+		return token{kind: tokString, text: l.input[start:l.pos], value: l.input[start+1 : l.pos-1], pos: start}, nil
+	case c == '$' && strings.HasPrefix(l.input[start:], "${"):
+		depth := 0
+		for ; l.pos < len(l.input); l.pos++ {
+			if l.input[l.pos] == '{' {
+				depth++
+			}
+			if l.input[l.pos] == '}' {
+				depth--
+				if depth == 0 {
+					l.pos++
+					return token{kind: tokBraced, text: l.input[start:l.pos], pos: start}, nil
+				}
+			}
+		}
+		return token{}, l.errorf(start, "missing '}'")
+	case c == '$' && start+1 < len(l.input) && isIdentChar(l.input[start+1]):
+		l.pos++
+		for l.pos < len(l.input) && (isIdentChar(l.input[l.pos]) || l.input[l.pos] == '.') {
+			l.pos++
+		}
+		for l.input[l.pos-1] == '.' {
+			l.pos--
+		}
+		return token{kind: tokVariable, text: l.input[start+1 : l.pos], pos: start}, nil
+	case isIdentChar(c):
+		for l.pos < len(l.input) && isIdentChar(l.input[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokIdent, text: l.input[start:l.pos], pos: start}, nil
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(l.input[start:], op) {
+			l.pos += len(op)
+			return token{kind: tokOperator, text: op, pos: start}, nil
+		}
+	}
+	if c == '=' {
+		return token{}, l.errorf(start, "unexpected '=', use '==' to compare values")
+	}
+	return token{}, l.errorf(start, "unexpected character '%c'", c)
+}
+
+// scope is what expressions are evaluated with
+type scope struct {
+	flattened map[string]interface{}
+	o         options
+	lexer     *lexer
+}
+
+type node interface {
+	eval(sc *scope) (interface{}, error)
+}
+
+type literal struct {
+	value interface{}
+}
+
+type variable struct {
+	name string
+	pos  int
+}
+
+type braced struct {
+	text string
+	pos  int
+}
+
+type list struct {
+	items []node
+}
+
+type unary struct {
+	op  string
+	x   node
+	pos int
+}
+
+type binary struct {
+	op   string
+	x, y node
+	pos  int
+}
+
+type call struct {
+	name string
+	args []node
+	pos  int
+}
+
+// parser is a recursive descent parser of expressions
+type parser struct {
+	lexer *lexer
+}
+
+var precedences = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">=", "in"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// isOperator returns whether the token is one of the operators
+func isOperator(t token, ops ...string) bool {
+	if t.kind != tokOperator && !(t.kind == tokIdent && t.text == "in") {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(precedences) {
+		return p.parseUnary()
+	}
+	x, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, err := p.lexer.peek()
+		if err != nil {
+			return nil, err
+		}
+		if !isOperator(t, precedences[level]...) {
+			return x, nil
+		}
+		p.lexer.next()
+		y, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &binary{op: t.text, x: x, y: y, pos: t.pos}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t, err := p.lexer.peek()
+	if err != nil {
+		return nil, err
+	}
+	if isOperator(t, "!", "-") {
+		p.lexer.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: t.text, x: x, pos: t.pos}, nil
+	}
+	return p.parsePrimary()
+}
+
+// nolint: gocyclo
+func (p *parser) parsePrimary() (node, error) {
+	t, err := p.lexer.next()
+	if err != nil {
+		return nil, err
+	}
+	switch t.kind {
+	case tokEOF:
+		return nil, p.lexer.errorf(t.pos, "unexpected end of expression")
+	case tokNumber, tokString:
+		return &literal{value: t.value}, nil
+	case tokVariable:
+		return &variable{name: t.text, pos: t.pos}, nil
+	case tokBraced:
+		return &braced{text: t.text, pos: t.pos}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literal{value: true}, nil
+		case "false":
+			return &literal{value: false}, nil
+		case "null":
+			return &literal{value: nil}, nil
+		}
+		next, err := p.lexer.peek()
+		if err != nil {
+			return nil, err
+		}
+		if !isOperator(next, "(") {
+			return nil, p.lexer.errorf(t.pos, "unexpected '%s', variables start with '$'", t.text)
+		}
+		p.lexer.next()
+		args, err := p.parseList(")")
+		if err != nil {
+			return nil, err
+		}
+		return &call{name: t.text, args: args, pos: t.pos}, nil
+	}
+	switch t.text {
+	case "(", "$(":
+		x, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case "[":
+		items, err := p.parseList("]")
+		if err != nil {
+			return nil, err
+		}
+		return &list{items: items}, nil
+	}
+	return nil, p.lexer.errorf(t.pos, "unexpected '%s'", t.text)
+}
+
+// parseList parses comma separated expressions, up to the closing operator
+func (p *parser) parseList(closing string) ([]node, error) {
+	var items []node
+	t, err := p.lexer.peek()
+	if err != nil {
+		return nil, err
+	}
+	if isOperator(t, closing) {
+		p.lexer.next()
+		return items, nil
+	}
+	for {
+		item, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		t, err := p.lexer.next()
+		if err != nil {
+			return nil, err
+		}
+		if isOperator(t, closing) {
+			return items, nil
+		}
+		if !isOperator(t, ",") {
+			return nil, p.lexer.errorf(t.pos, "expected ',' or '%s'", closing)
+		}
+	}
+}
+
+func (p *parser) expect(op string) error {
+	t, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	if !isOperator(t, op) {
+		if t.kind == tokEOF {
+			return p.lexer.errorf(t.pos, "missing '%s'", op)
+		}
+		return p.lexer.errorf(t.pos, "expected '%s'", op)
+	}
+	return nil
+}
+
+// parseExpression parses the whole input as an expression
+func parseExpression(input string) (node, *lexer, error) {
+	l := &lexer{input: input}
+	p := &parser{lexer: l}
+	x, err := p.parseBinary(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := l.next()
+	if err != nil {
+		return nil, nil, err
+	}
+	if t.kind != tokEOF {
+		return nil, nil, l.errorf(t.pos, "unexpected '%s'", t.text)
+	}
+	return x, l, nil
+}
+
+// parseGroup parses the parenthesized expression, (expr) or $(expr), at the
+// start position of the input, and returns where it ends
+func parseGroup(input string, start int) (node, *lexer, int, error) {
+	l := &lexer{input: input, pos: start}
+	t, err := l.peek()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if !isOperator(t, "(", "$(") {
+		return nil, nil, 0, l.errorf(t.pos, "expected '('")
+	}
+	x, err := (&parser{lexer: l}).parsePrimary()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return x, l, l.pos, nil
+}
+
+// evalCondition evaluates the expression and returns whether its value is
+// true
+func evalCondition(input string, flattened map[string]interface{}, o options) (bool, error) {
+	x, l, err := parseExpression(input)
+	if err != nil {
+		return false, err
+	}
+	v, err := x.eval(&scope{flattened: flattened, o: o, lexer: l})
+	if err != nil {
+		return false, err
+	}
+	return truthy(v), nil
+}
+
+func (n *literal) eval(sc *scope) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *variable) eval(sc *scope) (interface{}, error) {
+	return n.lookup(sc, false)
+}
+
+// lookup returns the value of the variable. Missing optional variables are
+// null, so that functions such as default can handle them.
+func (n *variable) lookup(sc *scope, optional bool) (interface{}, error) {
+	v, ok := sc.flattened[n.name]
+	if !ok && !optional {
+		if sc.o.errOnMissingKey {
+			return nil, sc.lexer.errorf(n.pos, "variable '%s' not set", n.name)
+		}
+		fmt.Fprintf(os.Stderr, "variable '%s' not set, expanding to null", n.name)
+	}
+	return normalize(v), nil
+}
+
+func (n *braced) eval(sc *scope) (interface{}, error) {
+	v, err := eval(n.text, sc.flattened, sc.o)
+	if err != nil {
+		return nil, err
+	}
+	return normalize(v), nil
+}
+
+func (n *list) eval(sc *scope) (interface{}, error) {
+	items := []interface{}{}
+	for _, item := range n.items {
+		v, err := item.eval(sc)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
+
+func (n *unary) eval(sc *scope) (interface{}, error) {
+	x, err := n.x.eval(sc)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		return !truthy(x), nil
+	}
+	switch v := toNumber(x, true).(type) {
+	case int64:
+		return -v, nil
+	case float64:
+		return -v, nil
+	}
+	return nil, sc.lexer.errorf(n.pos, "cannot negate %s", describe(x))
+}
+
+// nolint: gocyclo
+func (n *binary) eval(sc *scope) (interface{}, error) {
+	x, err := n.x.eval(sc)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "&&":
+		if !truthy(x) {
+			return false, nil
+		}
+	case "||":
+		if truthy(x) {
+			return true, nil
+		}
+	}
+	y, err := n.y.eval(sc)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "&&", "||":
+		return truthy(y), nil
+	case "==":
+		return equal(x, y), nil
+	case "!=":
+		return !equal(x, y), nil
+	case "in":
+		found, ok := contains(y, x)
+		if !ok {
+			return nil, sc.lexer.errorf(n.pos, "cannot look for %s in %s", describe(x), describe(y))
+		}
+		return found, nil
+	case "<", "<=", ">", ">=":
+		c, ok := compare(x, y)
+		if !ok {
+			return nil, sc.lexer.errorf(n.pos, "cannot compare %s and %s", describe(x), describe(y))
+		}
+		switch n.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	case "+":
+		// strings are joined, even when they hold numbers
+		_, xs := x.(string)
+		_, ys := y.(string)
+		if a, b, ok := numbers(x, y); ok && (!xs || !ys) {
+			return arithmetic(n.op, a, b)
+		}
+		if xs || ys {
+			return stringify(x) + stringify(y), nil
+		}
+	default:
+		if a, b, ok := numbers(x, y); ok {
+			v, err := arithmetic(n.op, a, b)
+			if err != nil {
+				return nil, sc.lexer.errorf(n.pos, "%s", err)
+			}
+			return v, nil
+		}
+	}
+	return nil, sc.lexer.errorf(n.pos, "cannot apply '%s' to %s and %s", n.op, describe(x), describe(y))
+}
+
+func (n *call) eval(sc *scope) (interface{}, error) {
+	var args []interface{}
+	for _, arg := range n.args {
+		var (
+			v   interface{}
+			err error
+		)
+		if variable, ok := arg.(*variable); ok {
+			v, err = variable.lookup(sc, true)
+		} else {
+			v, err = arg.eval(sc)
+		}
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	if n.name == "len" {
+		if len(args) != 1 {
+			return nil, sc.lexer.errorf(n.pos, "wrong number of arguments for len: want 1 got %d", len(args))
+		}
+		if s, ok := args[0].(string); ok {
+			return int64(len(s)), nil
+		}
+		if args[0] == nil {
+			return int64(0), nil
+		}
+		v := reflect.ValueOf(args[0])
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Map {
+			return nil, sc.lexer.errorf(n.pos, "cannot get the length of %s", describe(args[0]))
+		}
+		return int64(v.Len()), nil
+	}
+	v, err := funcs.Call(n.name, args...)
+	if err != nil {
+		return nil, sc.lexer.errorf(n.pos, "%s", err)
+	}
+	return normalize(v), nil
+}
+
+// normalize converts the values of the settings and of the functions to the
+// types of expressions
+func normalize(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case int:
+		return int64(vv)
+	case int32:
+		return int64(vv)
+	case uint64:
+		return int64(vv)
+	case float32:
+		return float64(vv)
+	case []string:
+		items := make([]interface{}, len(vv))
+		for i, s := range vv {
+			items[i] = s
+		}
+		return items
+	}
+	return v
+}
+
+// truthy returns whether a value is true: null, false, 0, empty strings,
+// "false", "0" and empty lists and maps are not
+func truthy(v interface{}) bool {
+	switch vv := normalize(v).(type) {
+	case nil:
+		return false
+	case bool:
+		return vv
+	case int64:
+		return vv != 0
+	case float64:
+		return vv != 0
+	case string:
+		s := strings.TrimSpace(vv)
+		return s != "" && s != "false" && s != "0"
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Map {
+		return rv.Len() != 0
+	}
+	return true
+}
+
+// stringify returns the string a value is expanded to, lists being space
+// separated
+func stringify(v interface{}) string {
+	switch vv := v.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		values := []string{}
+		for _, i := range vv {
+			values = append(values, stringify(i))
+		}
+		return strings.Join(values, " ")
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func describe(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	}
+	return fmt.Sprintf("%T %v", v, v)
+}
+
+// numberRe matches the strings holding plain base-10 integers or decimals,
+// e.g. "42", "-7" or "1.5", but not "1.", "07", "0x10", "1e3", "nan" or "inf"
+var numberRe = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?$`)
+
+// toNumber returns the number the value is, or holds if it is a string and
+// strings are accepted
+func toNumber(v interface{}, fromString bool) interface{} {
+	switch vv := normalize(v).(type) {
+	case int64, float64:
+		return vv
+	case string:
+		if !fromString || !numberRe.MatchString(vv) {
+			return nil
+		}
+		if i, err := strconv.ParseInt(vv, 10, 64); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(vv, 64); err == nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// numbers returns both values as numbers, strings holding plain numbers
+// included
+func numbers(x, y interface{}) (interface{}, interface{}, bool) {
+	a, b := toNumber(x, true), toNumber(y, true)
+	return a, b, a != nil && b != nil
+}
+
+func arithmetic(op string, a, b interface{}) (interface{}, error) {
+	x, xok := a.(int64)
+	y, yok := b.(int64)
+	if xok && yok {
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		}
+		if y == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		if op == "/" {
+			return x / y, nil
+		}
+		return x % y, nil
+	}
+	f, g := toFloat(a), toFloat(b)
+	switch op {
+	case "+":
+		return f + g, nil
+	case "-":
+		return f - g, nil
+	case "*":
+		return f * g, nil
+	case "/":
+		if g == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return f / g, nil
+	}
+	return nil, fmt.Errorf("'%%' only applies to integers")
+}
+
+func toFloat(v interface{}) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
+
+// compare compares numbers, strings holding numbers included, or strings
+func compare(x, y interface{}) (int, bool) {
+	if a, b, ok := numbers(x, y); ok {
+		f, g := toFloat(a), toFloat(b)
+		switch {
+		case f < g:
+			return -1, true
+		case f > g:
+			return 1, true
+		}
+		return 0, true
+	}
+	s, sok := x.(string)
+	t, tok := y.(string)
+	if !sok || !tok {
+		return 0, false
+	}
+	return strings.Compare(s, t), true
+}
+
+func equal(x, y interface{}) bool {
+	x, y = normalize(x), normalize(y)
+	if c, ok := compare(x, y); ok {
+		return c == 0
+	}
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	_, xs := x.(string)
+	_, ys := y.(string)
+	if xs || ys {
+		return stringify(x) == stringify(y)
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// contains returns whether the item is in the list, a substring of the
+// string, or a key of the map
+func contains(container, item interface{}) (bool, bool) {
+	switch c := container.(type) {
+	case string:
+		return strings.Contains(c, stringify(item)), true
+	case map[string]interface{}:
+		_, ok := c[stringify(item)]
+		return ok, true
+	case nil:
+		return false, true
+	}
+	rv := reflect.ValueOf(container)
+	if rv.Kind() != reflect.Slice {
+		return false, false
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if equal(rv.Index(i).Interface(), item) {
+			return true, true
+		}
+	}
+	return false, true
+}
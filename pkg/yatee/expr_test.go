@@ -0,0 +1,143 @@
+package yatee
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestExpressions(t *testing.T) {
+	env := map[string]interface{}{
+		"env":      "prod",
+		"replicas": 3,
+		"ratio":    0.5,
+		"debug":    false,
+		"i":        "2",
+		"major":    "1",
+		"minor":    "2",
+		"ab":       []interface{}{"a", "b"},
+		"name":     "MyApp",
+	}
+	testCases := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{`$($env == "prod" && $replicas > 1)`, true},
+		{`$($env != "prod" || $debug)`, false},
+		{`$(!$debug && $replicas >= 3 && $replicas <= 3)`, true},
+		{`$($replicas < 2 || $env < "qa")`, true},
+		{`$("my-" + $env)`, "my-prod"},
+		{`$($i + 1)`, int64(3)},
+		{`$($i + "1")`, "21"},
+		{`$($i + "x")`, "2x"},
+		{`$($major + "." + $minor)`, "1.2"},
+		{`$("0" + "7")`, "07"},
+		{`$("1.5" + 1)`, 2.5},
+		{`$("1." + 1)`, "1.1"},
+		{`$("0x10" + 1)`, "0x101"},
+		{`$("1e3" + 1)`, "1e31"},
+		{`$("inf" + 1)`, "inf1"},
+		{`$("010" == "8")`, false},
+		{`$("010" == "10")`, false},
+		{`$("10" == "10.0")`, true},
+		{`$("nan" == "nan")`, true},
+		{`$("nan" != "NaN")`, true},
+		{`$("10" > "9")`, true},
+		{`$($replicas * $ratio)`, 1.5},
+		{`$(-$replicas + 10 / 4 % 2)`, int64(-3)},
+		{`$("a" in $ab)`, true},
+		{`$("c" in $ab)`, false},
+		{`$($env in ["prod", "staging"])`, true},
+		{`$("ro" in $env)`, true},
+		{`$(len($ab) + len($env))`, int64(6)},
+		{`$(default("none", $missing))`, "none"},
+		{`$(lower($name))`, "myapp"},
+		{`$(upper(default("x", $env)) + "-" + ${env})`, "PROD-prod"},
+		{`$($replicas == "3")`, true},
+		{`$($debug == null)`, false},
+		{`port $($replicas + 8000), $(true)`, "port 8003, true"},
+	}
+	for _, tc := range testCases {
+		v, err := eval(tc.expr, env, options{errOnMissingKey: true})
+		assert.NilError(t, err, tc.expr)
+		assert.Check(t, is.DeepEqual(v, tc.expected), tc.expr)
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	env := map[string]interface{}{"env": "prod", "replicas": 3}
+	testCases := []struct {
+		expr          string
+		expectedError string
+	}{
+		{`$($env == "prod" &&)`, `unexpected ')' at position 20 in '$($env == "prod" &&)'`},
+		{`$($env = "prod")`, `unexpected '=', use '==' to compare values at position 8`},
+		{`$($replicas > "two")`, `cannot compare int64 3 and string "two" at position 13`},
+		{`$($env - 1)`, `cannot apply '-' to string "prod" and int64 1 at position 8`},
+		{`$($replicas / 0)`, `division by zero at position 13`},
+		{`$(env == "prod")`, `unexpected 'env', variables start with '$' at position 3`},
+		{`$($nope > 1)`, `variable 'nope' not set at position 3`},
+		{`$(lower($env, 1))`, `wrong number of arguments for lower: want 1 got 2 at position 3`},
+		{`$(nope(1))`, `function "nope" not defined at position 3`},
+		{`$("prod`, `unterminated string at position 3`},
+		{`$(($env)`, `missing ')' at position 9`},
+	}
+	for _, tc := range testCases {
+		_, err := eval(tc.expr, env, options{errOnMissingKey: true})
+		assert.Check(t, is.ErrorContains(err, tc.expectedError), tc.expr)
+	}
+}
+
+func TestProcessConditions(t *testing.T) {
+	settings := `
+env: prod
+replicas: 2
+regions: [eu, us]
+`
+	testProcess(t,
+		`services:
+  "@if $env == \"prod\" && $replicas > 1":
+    ha: true
+    "@else":
+      single: true
+  "@if len($regions) > 2 || \"asia\" in $regions":
+    asia: true
+  "@for r in $regions":
+    "@if $r != \"us\"":
+      region$r: $($replicas * 2)
+  list:
+    - "@if ($env == \"prod\") production"
+    - "@if ($env == \"dev\") development"
+    - "@if (\"eu\" in $regions) $(upper(first($regions)))"
+`, `services:
+  ha: true
+  list:
+  - production
+  - EU
+  regioneu: 4
+`, settings, "")
+}
+
+func TestProcessStringSettings(t *testing.T) {
+	settings := `
+a: 1
+b: 2
+ten: "10"
+nine: "9"
+major: "1"
+minor: "2"
+`
+	testProcess(t,
+		`sum: $($a + $b)
+greater: $($ten > $nine)
+literals: $("10" > "9")
+words: $("abc" < "abd")
+version: $($major + "." + $minor)
+`, `greater: true
+literals: true
+sum: 3
+version: "1.2"
+words: true
+`, settings, "")
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"github.com/docker/app/internal/yaml"
 	"github.com/docker/app/pkg/yatee"
@@ -24,7 +25,7 @@ func main() {
 		fmt.Printf("%v\n", err)
 		os.Exit(1)
 	}
-	output, err := yatee.Process(string(input), settings)
+	output, err := yatee.Process(string(input), settings, yatee.OptionIncludeDir+filepath.Dir(os.Args[1]))
 	if err != nil {
 		fmt.Printf("processing error: %v\n", err)
 		os.Exit(1)
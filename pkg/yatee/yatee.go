@@ -13,13 +13,18 @@ import (
 const (
 	// OptionErrOnMissingKey if set will make rendering fail if a non-existing variable is used
 	OptionErrOnMissingKey = "ErrOnMissingKey"
+	// OptionIncludeDir followed by a directory is the directory @include paths are relative to
+	OptionIncludeDir = "IncludeDir="
 )
 
 type options struct {
 	errOnMissingKey bool
+	includeDir      string
+	blocks          *blocks
 }
 
-// flatten flattens a structure: foo.bar.baz -> 'foo.bar.baz'
+// flatten flattens a structure: foo.bar.baz -> 'foo.bar.baz'. Lists are kept
+// as lists for expressions, and expanded as space separated values.
 func flatten(in map[string]interface{}, out map[string]interface{}, prefix string) {
 	for k, v := range in {
 		switch vv := v.(type) {
@@ -27,12 +32,6 @@ func flatten(in map[string]interface{}, out map[string]interface{}, prefix strin
 			out[prefix+k] = vv
 		case map[string]interface{}:
 			flatten(vv, out, prefix+k+".")
-		case []interface{}:
-			values := []string{}
-			for _, i := range vv {
-				values = append(values, fmt.Sprintf("%v", i))
-			}
-			out[prefix+k] = strings.Join(values, " ")
 		default:
 			out[prefix+k] = v
 		}
@@ -80,11 +79,6 @@ func LoadSettings(files []string) (map[string]interface{}, error) {
 	return res, nil
 }
 
-func isIdentNumChar(r byte) bool {
-	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') ||
-		r == '.' || r == '_'
-}
-
 // extract extracts an expression from a string
 // nolint: gocyclo
 func extract(expr string) (string, error) {
@@ -127,91 +121,31 @@ func extract(expr string) (string, error) {
 	return expr[0:i], nil
 }
 
-func tokenize(expr string) ([]string, error) {
-	var tokens []string
-	p := 0
-	for p < len(expr) {
-		if isIdentNumChar(expr[p]) {
-			pp := p + 1
-			for ; pp < len(expr) && isIdentNumChar(expr[pp]); pp++ {
-			}
-			tokens = append(tokens, expr[p:pp])
-			p = pp
-		} else {
-			if expr[p] != ' ' {
-				tokens = append(tokens, expr[p:p+1])
-			}
-			p++
-		}
-	}
-	return tokens, nil
-}
-
-func evalValue(comps []string, i int) (int64, int, error) {
-	c := comps[i]
-	if c == "(" {
-		value, ni, error := evalSub(comps, i+1)
-		if error != nil {
-			return 0, 0, error
-		}
-		return value, ni, nil
-	}
-	v, err := strconv.ParseInt(c, 0, 64)
-	return v, i + 1, err
-}
-
-func evalSub(comps []string, i int) (int64, int, error) {
-	current, next, err := evalValue(comps, i)
-	if err != nil {
-		return 0, 0, err
-	}
-	i = next
-	for i < len(comps) {
-		c := comps[i]
-		if c == ")" {
-			return current, i + 1, nil
-		}
-		if c == "*" || c == "+" || c == "-" || c == "/" || c == "%" {
-			rhs, next, err := evalValue(comps, i+1)
-			if err != nil {
-				return 0, 0, err
-			}
-			switch c {
-			case "+":
-				current += rhs
-			case "-":
-				current -= rhs
-			case "/":
-				current /= rhs
-			case "*":
-				current *= rhs
-			case "%":
-				current %= rhs
-			}
-			i = next
-		} else {
-			return 0, 0, fmt.Errorf("expected operator")
-		}
-	}
-	return current, i, nil
-}
-
-// resolves an arithmetic expression
-func evalExpr(expr string) (int64, error) {
-	comps, err := tokenize(expr)
-	if err != nil {
-		return 0, err
-	}
-	v, _, err := evalSub(comps, 0)
-	return v, err
-}
-
 // resolves and evaluate all ${foo.bar}, $foo.bar and $(expr) in epr
 // nolint: gocyclo
 func eval(expr string, flattened map[string]interface{}, o options) (interface{}, error) {
 	// Since we go from right to left to support nesting, handling $$ escape is
 	// painful, so just hide them and restore them at the end
 	expr = strings.Replace(expr, "$$", "\x00", -1)
+	// Expressions are evaluated first, as they resolve their own variables
+	for {
+		i := strings.Index(expr, "$(")
+		if i == -1 {
+			break
+		}
+		x, l, end, err := parseGroup(expr, i)
+		if err != nil {
+			return "", err
+		}
+		val, err := x.eval(&scope{flattened: flattened, o: o, lexer: l})
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(expr) == expr[i:end] {
+			return val, nil
+		}
+		expr = expr[0:i] + strings.Replace(stringify(val), "$", "\x00", -1) + expr[end:]
+	}
 	end := len(expr)
 	// If evaluation resolves to a single value, return the type value, not a string
 	var bypass interface{}
@@ -231,46 +165,41 @@ func eval(expr string, flattened map[string]interface{}, o options) (interface{}
 			return "", err
 		}
 		var val interface{}
-		if len(comp) != 0 && comp[0] == '(' {
-			var err error
-			val, err = evalExpr(comp[1 : len(comp)-1])
-			if err != nil {
-				return "", err
-			}
-		} else {
-			var ok bool
-			if len(comp) != 0 && comp[0] == '{' {
-				content := comp[1 : len(comp)-1]
-				q := strings.Index(content, "?")
-				if q != -1 {
-					s := strings.Index(content, ":")
-					if s == -1 {
-						return "", fmt.Errorf("parse error in ternary '%s', missing ':'", content)
-					}
-					variable := content[0:q]
-					val, ok = flattened[variable]
-					if isTrue(fmt.Sprintf("%v", val)) {
-						val = content[q+1 : s]
-					} else {
-						val = content[s+1:]
-					}
+		var ok bool
+		if len(comp) != 0 && comp[0] == '{' {
+			content := comp[1 : len(comp)-1]
+			q := strings.Index(content, "?")
+			if q != -1 {
+				s := strings.Index(content, ":")
+				if s == -1 {
+					return "", fmt.Errorf("parse error in ternary '%s', missing ':'", content)
+				}
+				variable := content[0:q]
+				val, ok = flattened[variable]
+				if truthy(val) {
+					val = content[q+1 : s]
 				} else {
-					val, ok = flattened[comp[1:len(comp)-1]]
+					val = content[s+1:]
 				}
 			} else {
-				val, ok = flattened[comp]
+				val, ok = flattened[comp[1:len(comp)-1]]
 			}
-			if !ok {
-				if o.errOnMissingKey {
-					return "", fmt.Errorf("variable '%s' not set", comp)
-				}
-				fmt.Fprintf(os.Stderr, "variable '%s' not set, expanding to empty string", comp)
+		} else {
+			val, ok = flattened[comp]
+		}
+		if !ok {
+			if o.errOnMissingKey {
+				return "", fmt.Errorf("variable '%s' not set", comp)
 			}
+			fmt.Fprintf(os.Stderr, "variable '%s' not set, expanding to empty string", comp)
 		}
-		valstr := fmt.Sprintf("%v", val)
+		valstr := stringify(val)
 		expr = expr[0:i] + valstr + expr[i+1+len(comp):]
 		if strings.Trim(expr, " ") == valstr {
 			bypass = val
+			if _, ok := val.([]interface{}); ok {
+				bypass = valstr
+			}
 		}
 		end = len(expr)
 	}
@@ -281,14 +210,23 @@ func eval(expr string, flattened map[string]interface{}, o options) (interface{}
 	return expr, nil
 }
 
-func isTrue(cond string) bool {
-	ct := strings.TrimLeft(cond, " ")
-	reverse := len(cond) != 0 && cond[0] == '!'
-	if reverse {
-		cond = ct[1:]
+// evalListCondition evaluates a "@if (EXPR) VALUE" list item, and returns
+// whether it is included, and its value
+func evalListCondition(item string, flattened map[string]interface{}, o options) (bool, interface{}, error) {
+	rest := strings.TrimLeft(strings.TrimPrefix(item, "@if"), " ")
+	if !strings.HasPrefix(rest, "(") {
+		return false, nil, fmt.Errorf("parse error looking for if condition in '%s'", item)
+	}
+	x, l, end, err := parseGroup(rest, 0)
+	if err != nil {
+		return false, nil, err
+	}
+	cond, err := x.eval(&scope{flattened: flattened, o: o, lexer: l})
+	if err != nil || !truthy(cond) {
+		return false, nil, err
 	}
-	cond = strings.Trim(cond, " ")
-	return (cond != "" && cond != "false" && cond != "0") != reverse
+	value, err := eval(strings.Trim(rest[end:], " "), flattened, o)
+	return true, value, err
 }
 
 func recurseList(input []interface{}, settings map[string]interface{}, flattened map[string]interface{}, o options) ([]interface{}, error) {
@@ -308,25 +246,21 @@ func recurseList(input []interface{}, settings map[string]interface{}, flattened
 			}
 			res = append(res, newv)
 		case string:
+			trimed := strings.TrimLeft(vv, " ")
+			if strings.HasPrefix(trimed, "@if") {
+				include, value, err := evalListCondition(trimed, flattened, o)
+				if err != nil {
+					return nil, err
+				}
+				if include {
+					res = append(res, value)
+				}
+				continue
+			}
 			vvv, err := eval(vv, flattened, o)
 			if err != nil {
 				return nil, err
 			}
-			if vvvs, ok := vvv.(string); ok {
-				trimed := strings.TrimLeft(vvvs, " ")
-				if strings.HasPrefix(trimed, "@if") {
-					be := strings.Index(trimed, "(")
-					ee := strings.Index(trimed, ")")
-					if be == -1 || ee == -1 || be > ee {
-						return nil, fmt.Errorf("parse error looking for if condition in '%s'", vvv)
-					}
-					cond := trimed[be+1 : ee]
-					if isTrue(cond) {
-						res = append(res, strings.Trim(trimed[ee+1:], " "))
-					}
-					continue
-				}
-			}
 			res = append(res, vvv)
 		default:
 			res = append(res, v)
@@ -339,11 +273,33 @@ func recurseList(input []interface{}, settings map[string]interface{}, flattened
 // nolint: gocyclo
 func recurse(input map[interface{}]interface{}, settings map[string]interface{}, flattened map[string]interface{}, o options) (map[interface{}]interface{}, error) {
 	res := make(map[interface{}]interface{})
+	// keys of the used blocks and included files, which the other keys
+	// override
+	used := make(map[interface{}]interface{})
+	usedBy := make(map[interface{}]string)
 	for k, v := range input {
 		rk := k
 		kstr, isks := k.(string)
 		if isks {
 			trimed := strings.TrimLeft(kstr, " ")
+			if strings.HasPrefix(trimed, "@define ") {
+				// blocks are defined before processing
+				continue
+			}
+			if strings.HasPrefix(trimed, "@use ") || strings.HasPrefix(trimed, "@include ") {
+				val, err := useOrInclude(trimed, v, settings, flattened, o)
+				if err != nil {
+					return nil, err
+				}
+				for valk, valv := range val {
+					if other, ok := usedBy[valk]; ok {
+						return nil, fmt.Errorf("'%s' and '%s' both set key '%v'", other, trimed, valk)
+					}
+					used[valk] = valv
+					usedBy[valk] = trimed
+				}
+				continue
+			}
 			if strings.HasPrefix(trimed, "@switch ") {
 				mii, ok := v.(map[interface{}]interface{})
 				if !ok {
@@ -411,7 +367,7 @@ func recurse(input map[interface{}]interface{}, settings map[string]interface{},
 						return nil, err
 					}
 					for i := rangestart; i < rangeend; i++ {
-						flattened[varname] = fmt.Sprintf("%v", i)
+						flattened[varname] = i
 						val, err := recurse(mii, settings, flattened, o)
 						if err != nil {
 							return nil, err
@@ -437,7 +393,7 @@ func recurse(input map[interface{}]interface{}, settings map[string]interface{},
 				continue
 			}
 			if strings.HasPrefix(trimed, "@if ") {
-				cond, err := eval(strings.TrimPrefix(trimed, "@if "), flattened, o)
+				cond, err := evalCondition(strings.TrimPrefix(trimed, "@if "), flattened, o)
 				if err != nil {
 					return nil, err
 				}
@@ -445,7 +401,7 @@ func recurse(input map[interface{}]interface{}, settings map[string]interface{},
 				if !ok {
 					return nil, fmt.Errorf("@if value must be a mapping")
 				}
-				if isTrue(fmt.Sprintf("%v", cond)) {
+				if cond {
 					val, err := recurse(mii, settings, flattened, o)
 					if err != nil {
 						return nil, err
@@ -498,9 +454,30 @@ func recurse(input map[interface{}]interface{}, settings map[string]interface{},
 			res[rk] = v
 		}
 	}
+	for k, v := range used {
+		if _, ok := res[k]; !ok {
+			res[k] = v
+		}
+	}
 	return res, nil
 }
 
+// useOrInclude returns the content of a "@use NAME" block or of an
+// "@include PATH" file
+func useOrInclude(directive string, v interface{}, settings map[string]interface{}, flattened map[string]interface{}, o options) (map[interface{}]interface{}, error) {
+	if o.blocks == nil {
+		o.blocks = newBlocks()
+	}
+	if strings.HasPrefix(directive, "@use ") {
+		return o.blocks.use(strings.TrimSpace(strings.TrimPrefix(directive, "@use ")), v, settings, flattened, o)
+	}
+	path, err := eval(strings.TrimSpace(strings.TrimPrefix(directive, "@include ")), flattened, o)
+	if err != nil {
+		return nil, err
+	}
+	return o.blocks.includeFile(stringify(path), v, settings, flattened, o)
+}
+
 // ProcessStrings resolves input templated yaml using values in settings yaml
 func ProcessStrings(input, settings string) (string, error) {
 	ps := make(map[interface{}]interface{})
@@ -523,11 +500,13 @@ func ProcessStrings(input, settings string) (string, error) {
 
 // Process resolves input templated yaml using values given in settings
 func Process(inputString string, settings map[string]interface{}, opts ...string) (map[interface{}]interface{}, error) {
-	var o options
+	o := options{blocks: newBlocks()}
 	for _, v := range opts {
-		switch v {
-		case OptionErrOnMissingKey:
+		switch {
+		case v == OptionErrOnMissingKey:
 			o.errOnMissingKey = true
+		case strings.HasPrefix(v, OptionIncludeDir):
+			o.includeDir = strings.TrimPrefix(v, OptionIncludeDir)
 		default:
 			return nil, fmt.Errorf("unknown option '%s'", v)
 		}
@@ -537,6 +516,9 @@ func Process(inputString string, settings map[string]interface{}, opts ...string
 	if err != nil {
 		return nil, err
 	}
+	if err := o.blocks.collect(input, o); err != nil {
+		return nil, err
+	}
 	flattened := make(map[string]interface{})
 	flatten(settings, flattened, "")
 	return recurse(input, settings, flattened, o)
@@ -18,7 +18,8 @@ func TestEval(t *testing.T) {
 	testEval(t, "$foo ${baz}", env, "bar bam")
 	testEval(t, "${foo}$baz", env, "barbam")
 	testEval(t, "$(1 + 1)", env, int64(2))
-	testEval(t, "$(1+2*3)", env, int64(9))
+	testEval(t, "$(1+2*3)", env, int64(7))
+	testEval(t, "$((1+2)*3)", env, int64(9))
 	testEval(t, "$(1+(2*3))", env, int64(7))
 	testEval(t, "$(1+((2*3)))", env, int64(7))
 	testEval(t, "$$$foo $$${baz}", env, "$bar $bam")
@@ -127,7 +128,7 @@ ab:
   "@for i in 0..2":
     "@for j in 0..2":
       foo$i$j: $($i*2 + $j)
-      "@if $($i+$j%2)":
+      "@if $(($i+$j)%2)":
         bar$i$j: 1
 `, `services:
   bar01: 1
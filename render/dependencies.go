@@ -0,0 +1,171 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/docker/app/types"
+	composetypes "github.com/docker/cli/cli/compose/types"
+	"github.com/pkg/errors"
+)
+
+// defaultNetwork is the network services are attached to when they don't
+// declare any
+const defaultNetwork = "default"
+
+// mergeDependencies renders the dependencies of the app, with their settings
+// overrides, and merges them into the given config
+func mergeDependencies(config *composetypes.Config, app *types.App, ops ...func(*Options)) error {
+	for _, dep := range app.Dependencies() {
+		rendered, err := Render(dep.App, dep.Settings, ops...)
+		if err != nil {
+			return errors.Wrapf(err, "failed to render dependency %q", dep.Name)
+		}
+		namespace(rendered, dep.Name)
+		if err := merge(config, rendered); err != nil {
+			return errors.Wrapf(err, "failed to merge dependency %q", dep.Name)
+		}
+	}
+	return nil
+}
+
+// namespace prefixes the services, networks, volumes, secrets and configs of
+// the config with the given name. Services keep their original name as an
+// alias on their networks, so that they still reach each other, and are
+// attached to the default network of the including app.
+func namespace(config *composetypes.Config, name string) {
+	prefix := func(s string) string { return name + "-" + s }
+	usesDefaultNetwork := false
+	for i, service := range config.Services {
+		original := service.Name
+		service.Name = prefix(original)
+		service.DependsOn = prefixAll(service.DependsOn, prefix)
+		for j, link := range service.Links {
+			// links are "service" or "service:alias", the alias defaulting to
+			// the service name
+			parts := strings.SplitN(link, ":", 2)
+			alias := parts[len(parts)-1]
+			service.Links[j] = prefix(parts[0]) + ":" + alias
+		}
+		if service.NetworkMode == "" {
+			if len(service.Networks) == 0 {
+				service.Networks = map[string]*composetypes.ServiceNetworkConfig{defaultNetwork: nil}
+			}
+			networks := map[string]*composetypes.ServiceNetworkConfig{}
+			for network, cfg := range service.Networks {
+				aliased := composetypes.ServiceNetworkConfig{}
+				if cfg != nil {
+					aliased = *cfg
+				}
+				aliased.Aliases = append(append([]string{}, aliased.Aliases...), original)
+				networks[prefix(network)] = &aliased
+				usesDefaultNetwork = usesDefaultNetwork || network == defaultNetwork
+			}
+			networks[defaultNetwork] = nil
+			service.Networks = networks
+		}
+		for j, volume := range service.Volumes {
+			if _, ok := config.Volumes[volume.Source]; ok && volume.Type == "volume" {
+				service.Volumes[j].Source = prefix(volume.Source)
+			}
+		}
+		for j, secret := range service.Secrets {
+			if secret.Target == "" {
+				service.Secrets[j].Target = secret.Source
+			}
+			service.Secrets[j].Source = prefix(secret.Source)
+		}
+		for j, cfg := range service.Configs {
+			if cfg.Target == "" {
+				service.Configs[j].Target = "/" + cfg.Source
+			}
+			service.Configs[j].Source = prefix(cfg.Source)
+		}
+		config.Services[i] = service
+	}
+	networks := map[string]composetypes.NetworkConfig{}
+	if usesDefaultNetwork {
+		networks[prefix(defaultNetwork)] = composetypes.NetworkConfig{}
+	}
+	for k, v := range config.Networks {
+		networks[prefix(k)] = v
+	}
+	config.Networks = networks
+	volumes := map[string]composetypes.VolumeConfig{}
+	for k, v := range config.Volumes {
+		volumes[prefix(k)] = v
+	}
+	config.Volumes = volumes
+	secrets := map[string]composetypes.SecretConfig{}
+	for k, v := range config.Secrets {
+		secrets[prefix(k)] = v
+	}
+	config.Secrets = secrets
+	configs := map[string]composetypes.ConfigObjConfig{}
+	for k, v := range config.Configs {
+		configs[prefix(k)] = v
+	}
+	config.Configs = configs
+}
+
+func prefixAll(names []string, prefix func(string) string) []string {
+	if names == nil {
+		return nil
+	}
+	res := make([]string, len(names))
+	for i, n := range names {
+		res[i] = prefix(n)
+	}
+	return res
+}
+
+// merge adds the services, networks, volumes, secrets and configs of the
+// dependency config to the config
+func merge(config, dep *composetypes.Config) error {
+	services := map[string]bool{}
+	for _, service := range config.Services {
+		services[service.Name] = true
+	}
+	for _, service := range dep.Services {
+		if services[service.Name] {
+			return errors.Errorf("service %q already exists", service.Name)
+		}
+		config.Services = append(config.Services, service)
+	}
+	if config.Networks == nil {
+		config.Networks = map[string]composetypes.NetworkConfig{}
+	}
+	for k, v := range dep.Networks {
+		if _, ok := config.Networks[k]; ok {
+			return errors.Errorf("network %q already exists", k)
+		}
+		config.Networks[k] = v
+	}
+	if config.Volumes == nil {
+		config.Volumes = map[string]composetypes.VolumeConfig{}
+	}
+	for k, v := range dep.Volumes {
+		if _, ok := config.Volumes[k]; ok {
+			return errors.Errorf("volume %q already exists", k)
+		}
+		config.Volumes[k] = v
+	}
+	if config.Secrets == nil {
+		config.Secrets = map[string]composetypes.SecretConfig{}
+	}
+	for k, v := range dep.Secrets {
+		if _, ok := config.Secrets[k]; ok {
+			return errors.Errorf("secret %q already exists", k)
+		}
+		config.Secrets[k] = v
+	}
+	if config.Configs == nil {
+		config.Configs = map[string]composetypes.ConfigObjConfig{}
+	}
+	for k, v := range dep.Configs {
+		if _, ok := config.Configs[k]; ok {
+			return errors.Errorf("config %q already exists", k)
+		}
+		config.Configs[k] = v
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/app/types"
+	composetypes "github.com/docker/cli/cli/compose/types"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func newTestApp(t *testing.T, compose, settings string, ops ...func(*types.App) error) *types.App {
+	t.Helper()
+	app, err := types.NewApp("my-app", append([]func(*types.App) error{
+		types.Metadata(strings.NewReader(validMeta)),
+		types.WithComposes(strings.NewReader(compose)),
+		types.WithSettings(strings.NewReader(settings)),
+	}, ops...)...)
+	assert.NilError(t, err)
+	return app
+}
+
+func TestRenderWithDependencies(t *testing.T) {
+	monitoring := newTestApp(t, `
+version: "3.6"
+services:
+  prometheus:
+    image: prom/prometheus
+    command: ["--storage.tsdb.retention=${retention}"]
+    volumes:
+      - data:/prometheus
+    secrets:
+      - token
+  grafana:
+    image: grafana/grafana
+    depends_on:
+      - prometheus
+    networks:
+      - front
+volumes:
+  data: {}
+networks:
+  front: {}
+secrets:
+  token:
+    external: true
+`, "retention: 7d")
+	app := newTestApp(t, `
+version: "3.6"
+services:
+  web:
+    image: nginx
+`, "", types.WithDependency("monitoring", monitoring, map[string]string{"retention": "15d"}))
+
+	c, err := Render(app, nil)
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(c.Services, 3))
+	services := map[string]composetypes.ServiceConfig{}
+	for _, s := range c.Services {
+		services[s.Name] = s
+	}
+	assert.Check(t, is.DeepEqual([]string(services["monitoring-prometheus"].Command), []string{"--storage.tsdb.retention=15d"}))
+	assert.Check(t, is.Equal(services["monitoring-prometheus"].Volumes[0].Source, "monitoring-data"))
+	assert.Check(t, is.Equal(services["monitoring-prometheus"].Secrets[0].Source, "monitoring-token"))
+	assert.Check(t, is.Equal(services["monitoring-prometheus"].Secrets[0].Target, "token"))
+	assert.Check(t, is.DeepEqual(services["monitoring-prometheus"].Networks, map[string]*composetypes.ServiceNetworkConfig{
+		"default":            nil,
+		"monitoring-default": {Aliases: []string{"prometheus"}},
+	}))
+	assert.Check(t, is.DeepEqual(services["monitoring-grafana"].DependsOn, []string{"monitoring-prometheus"}))
+	assert.Check(t, is.DeepEqual(services["monitoring-grafana"].Networks, map[string]*composetypes.ServiceNetworkConfig{
+		"default":          nil,
+		"monitoring-front": {Aliases: []string{"grafana"}},
+	}))
+	_, ok := c.Networks["monitoring-default"]
+	assert.Check(t, ok)
+	_, ok = c.Networks["monitoring-front"]
+	assert.Check(t, ok)
+	_, ok = c.Volumes["monitoring-data"]
+	assert.Check(t, ok)
+	// external secrets keep their actual name
+	assert.Check(t, is.Equal(c.Secrets["monitoring-token"].Name, "token"))
+}
+
+func TestRenderWithConflictingDependency(t *testing.T) {
+	dep := newTestApp(t, `
+version: "3.6"
+services:
+  web:
+    image: nginx
+`, "")
+	app := newTestApp(t, `
+version: "3.6"
+services:
+  front-web:
+    image: nginx
+`, "", types.WithDependency("front", dep, nil))
+	_, err := Render(app, nil)
+	assert.Check(t, is.ErrorContains(err, `failed to merge dependency "front": service "front-web" already exists`))
+}
@@ -0,0 +1,212 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/app/internal/compose"
+	"github.com/docker/app/internal/renderer/driver"
+	"github.com/docker/cli/cli/compose/interpolation"
+	composetemplate "github.com/docker/cli/cli/compose/template"
+	composetypes "github.com/docker/cli/cli/compose/types"
+)
+
+const (
+	// StageParse is the stage of the errors parsing the rendered compose files
+	StageParse = "parse"
+	// StageInterpolation is the stage of the errors interpolating the
+	// variables of the compose files
+	StageInterpolation = "interpolation"
+)
+
+// Error is an error rendering a compose file of an application, at a
+// position of the file. Use AsError to get it from a rendering error.
+type Error struct {
+	// File is the compose file, relative to the application, or as given on
+	// the command line for the override compose files
+	File string `json:"file"`
+	// Line and Column start at 1, and are 0 when unknown
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+	// Snippet is the line of the file the error is at
+	Snippet string `json:"snippet,omitempty"`
+	// Rendered is whether the position is in the file as the previous
+	// renderers rendered it, and not in the file itself
+	Rendered bool `json:"rendered,omitempty"`
+	// Stage is the renderer which failed, StageParse or StageInterpolation
+	Stage   string `json:"stage"`
+	Message string `json:"message"`
+
+	// template and variable are the value and the variable which failed to
+	// be interpolated, until the error is located
+	template string
+	variable string
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.File)
+	if e.Rendered {
+		b.WriteString(" (rendered)")
+	}
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%d", e.Line)
+		if e.Column > 0 {
+			fmt.Fprintf(&b, ":%d", e.Column)
+		}
+	}
+	fmt.Fprintf(&b, ": %s: %s", e.Stage, e.Message)
+	if e.Snippet != "" {
+		margin := fmt.Sprintf("%d | ", e.Line)
+		fmt.Fprintf(&b, "\n%s%s", margin, e.Snippet)
+		if e.Column > 0 && e.Column <= len(e.Snippet)+1 {
+			// keep the tabs so that the caret is under the column
+			indent := strings.Map(func(r rune) rune {
+				if r == '\t' {
+					return r
+				}
+				return ' '
+			}, e.Snippet[:e.Column-1])
+			fmt.Fprintf(&b, "\n%s| %s^", strings.Repeat(" ", len(margin)-2), indent)
+		}
+	}
+	return b.String()
+}
+
+// AsError returns the rendering error which the error is, or wraps
+func AsError(err error) (*Error, bool) {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e, true
+		}
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		err = cause.Cause()
+	}
+	return nil, false
+}
+
+// newError returns the error of the stage on the text, located if the stage
+// knows where the error is
+func newError(file, stage, text string, rendered bool, err error) *Error {
+	e := &Error{File: file, Stage: stage, Rendered: rendered, Message: err.Error()}
+	for cause := err; cause != nil; {
+		if derr, ok := cause.(*driver.Error); ok {
+			e.at(text, derr.Line, derr.Column)
+			break
+		}
+		c, ok := cause.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		cause = c.Cause()
+	}
+	return e
+}
+
+// at sets the position of the error, and its snippet from the text
+func (e *Error) at(text string, line, column int) {
+	e.Line, e.Column = line, column
+	if lines := strings.Split(text, "\n"); line > 0 && line <= len(lines) {
+		e.Snippet = strings.TrimRight(lines[line-1], "\r")
+	}
+}
+
+// parseError returns the located error of the compose file which failed to
+// be parsed once rendered
+func parseError(err *compose.ParseError, file, source, rendered string) *Error {
+	e := &Error{
+		File:     file,
+		Stage:    StageParse,
+		Rendered: rendered != source,
+		Message:  err.Err.Error(),
+	}
+	e.at(rendered, err.Line, 0)
+	return e
+}
+
+// interpolationError returns the error of the first compose file which fails
+// to be interpolated, as the loader interpolates them one by one
+func interpolationError(configFiles []composetypes.ConfigFile, env map[string]string) error {
+	for _, file := range configFiles {
+		var template, variable string
+		_, err := interpolation.Interpolate(file.Config, interpolation.Options{
+			LookupValue: func(key string) (string, bool) {
+				value, ok := env[key]
+				return value, ok
+			},
+			Substitute: func(value string, mapping composetemplate.Mapping) (string, error) {
+				s, err := composetemplate.SubstituteWith(value, mapping, Pattern, func(substitution string, mapping composetemplate.Mapping) (string, bool, error) {
+					s, applied, err := errorIfMissing(substitution, mapping)
+					if err != nil {
+						variable = substitution
+					}
+					return s, applied, err
+				})
+				if err != nil {
+					template = value
+				}
+				return s, err
+			},
+		})
+		if err == nil {
+			continue
+		}
+		return &Error{
+			File:     file.Filename,
+			Stage:    StageInterpolation,
+			Message:  err.Error(),
+			template: template,
+			variable: variable,
+		}
+	}
+	return nil
+}
+
+// locate looks for the value which failed to be interpolated in the compose
+// file, or else in the file as rendered
+func (e *Error) locate(source, rendered string) {
+	if e.template == "" {
+		return
+	}
+	for _, text := range []string{source, rendered} {
+		offset := strings.Index(text, e.template)
+		if offset != -1 && e.variable != "" {
+			if i := variableIndex(e.template, e.variable); i != -1 {
+				offset += i
+			}
+		}
+		if offset == -1 && e.variable != "" {
+			// the value is quoted or escaped in the file
+			offset = variableIndex(text, e.variable)
+		}
+		if offset != -1 {
+			line, column := driver.Position(text, offset)
+			e.Rendered = text != source
+			e.at(text, line, column)
+			return
+		}
+	}
+}
+
+// variableIndex returns the index of the reference to the variable in the
+// text, or -1
+func variableIndex(text, variable string) int {
+	if i := strings.Index(text, "${"+variable); i != -1 {
+		return i
+	}
+	for i := strings.Index(text, "$"+variable); i != -1; {
+		// skip the escaped $$variable
+		if i == 0 || text[i-1] != '$' {
+			return i
+		}
+		next := strings.Index(text[i+1:], "$"+variable)
+		if next == -1 {
+			break
+		}
+		i += next + 1
+	}
+	return -1
+}
@@ -0,0 +1,106 @@
+package render
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/app/types"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func renderError(t *testing.T, composes ...string) *Error {
+	t.Helper()
+	defer os.Unsetenv("DOCKERAPP_RENDERERS")
+	os.Setenv("DOCKERAPP_RENDERERS", "none")
+	app := &types.App{Path: "my-app"}
+	assert.NilError(t, types.Metadata(strings.NewReader(validMeta))(app))
+	var readers []io.Reader
+	for _, c := range composes {
+		readers = append(readers, strings.NewReader(c))
+	}
+	assert.NilError(t, types.WithComposes(readers...)(app))
+	assert.NilError(t, types.WithSettings(strings.NewReader("image: nginx\n"))(app))
+	_, err := Render(app, nil)
+	assert.Assert(t, err != nil)
+	e, ok := AsError(err)
+	assert.Assert(t, ok, "%v", err)
+	return e
+}
+
+func TestRenderParseError(t *testing.T) {
+	e := renderError(t, `version: "3.6"
+services:
+  web:
+    image: nginx
+    command: [top
+`)
+	assert.Check(t, is.Equal(e.File, "docker-compose.yml"))
+	assert.Check(t, is.Equal(e.Stage, StageParse))
+	assert.Check(t, is.Equal(e.Line, 5))
+	assert.Check(t, is.Equal(e.Snippet, "    command: [top"))
+	assert.Check(t, !e.Rendered)
+	assert.Check(t, !strings.Contains(e.Error(), "services:"))
+}
+
+func TestRenderInterpolationError(t *testing.T) {
+	e := renderError(t, `version: "3.6"
+services:
+  web:
+    image: ${image}:${version}
+`)
+	assert.Check(t, is.Equal(e.File, "docker-compose.yml"))
+	assert.Check(t, is.Equal(e.Stage, StageInterpolation))
+	assert.Check(t, is.Equal(e.Line, 4))
+	assert.Check(t, is.Equal(e.Column, 21))
+	assert.Check(t, is.Equal(e.Snippet, "    image: ${image}:${version}"))
+	assert.Check(t, is.Contains(e.Message, "required variable version is missing a value"))
+	assert.Check(t, is.Equal(e.Error(), `docker-compose.yml:4:21: interpolation: `+e.Message+`
+4 |     image: ${image}:${version}
+  |                     ^`))
+}
+
+func TestRenderInterpolationErrorInOverlay(t *testing.T) {
+	e := renderError(t, `version: "3.6"
+services:
+  web:
+    image: ${image}
+`, `version: "3.6"
+services:
+  web:
+    command: echo $image
+
+    environment:
+      TAG: $tag
+`)
+	assert.Check(t, is.Equal(e.File, "compose/1.yml"))
+	assert.Check(t, is.Equal(e.Stage, StageInterpolation))
+	assert.Check(t, is.Equal(e.Line, 7))
+	assert.Check(t, is.Equal(e.Column, 12))
+	assert.Check(t, is.Equal(e.Snippet, "      TAG: $tag"))
+}
+
+func TestRenderErrorInOverrideFile(t *testing.T) {
+	dir := fs.NewDir(t, "composes", fs.WithFile("override.yml", `version: "3.6"
+services:
+  web:
+    command: [top
+`))
+	defer dir.Remove()
+	defer os.Unsetenv("DOCKERAPP_RENDERERS")
+	os.Setenv("DOCKERAPP_RENDERERS", "none")
+	app := &types.App{Path: "my-app"}
+	assert.NilError(t, types.Metadata(strings.NewReader(validMeta))(app))
+	assert.NilError(t, types.WithComposes(strings.NewReader("version: \"3.6\"\nservices:\n  web:\n    image: nginx\n"))(app))
+	assert.NilError(t, types.WithComposeOverrideFiles(dir.Join("override.yml"))(app))
+	assert.NilError(t, types.WithSettings(strings.NewReader(""))(app))
+	_, err := Render(app, nil)
+	e, ok := AsError(err)
+	assert.Assert(t, ok, "%v", err)
+	assert.Check(t, is.Equal(e.File, dir.Join("override.yml")))
+	assert.Check(t, is.Equal(e.Stage, StageParse))
+	assert.Check(t, is.Equal(e.Line, 4))
+}
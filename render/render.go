@@ -8,12 +8,16 @@ import (
 
 	"github.com/docker/app/internal/compose"
 	"github.com/docker/app/internal/renderer"
+	"github.com/docker/app/internal/renderer/driver"
+	"github.com/docker/app/internal/secrets"
 	"github.com/docker/app/internal/slices"
 	"github.com/docker/app/types"
+	"github.com/docker/app/types/metadata"
 	"github.com/docker/app/types/settings"
 	"github.com/docker/cli/cli/compose/loader"
 	composetemplate "github.com/docker/cli/cli/compose/template"
 	composetypes "github.com/docker/cli/cli/compose/types"
+	"github.com/docker/distribution/reference"
 	"github.com/pkg/errors"
 
 	// Register gotemplate renderer
@@ -25,8 +29,21 @@ import (
 
 	// Register json formatter
 	_ "github.com/docker/app/internal/formatter/json"
+	// Register kubernetes formatter
+	_ "github.com/docker/app/internal/formatter/kubernetes"
+	// Register text formatter
+	_ "github.com/docker/app/internal/formatter/text"
 	// Register yaml formatter
 	_ "github.com/docker/app/internal/formatter/yaml"
+
+	// Register env secret provider
+	_ "github.com/docker/app/internal/secrets/env"
+	// Register file secret provider
+	_ "github.com/docker/app/internal/secrets/file"
+	// Register pass secret provider
+	_ "github.com/docker/app/internal/secrets/pass"
+	// Register vault secret provider
+	_ "github.com/docker/app/internal/secrets/vault"
 )
 
 var (
@@ -42,9 +59,46 @@ var (
 	Pattern = regexp.MustCompile(patternString)
 )
 
+// Options contains rendering options
+type Options struct {
+	redactSecrets bool
+	pinnedImages  bool
+	remoteSecrets bool
+}
+
+// WithRedactedSecrets replaces the secret settings values by a placeholder
+// instead of resolving them from their provider. As the actual values are
+// unknown, the settings are not checked against the settings schema.
+func WithRedactedSecrets() func(*Options) {
+	return func(o *Options) {
+		o.redactSecrets = true
+	}
+}
+
+// WithRemoteSecrets resolves the secret references of the apps coming from a
+// registry or an URL. Without it, rendering fails if such an app declares
+// secret references in its package, as they could read any secret of the user.
+func WithRemoteSecrets() func(*Options) {
+	return func(o *Options) {
+		o.remoteSecrets = true
+	}
+}
+
+// WithPinnedImages fails rendering if the image of a service is not pinned to
+// a digest, by the app lock or by the Compose file
+func WithPinnedImages() func(*Options) {
+	return func(o *Options) {
+		o.pinnedImages = true
+	}
+}
+
 // Render renders the Compose file for this app, merging in settings files, other compose files, and env
 // appname string, composeFiles []string, settingsFiles []string
-func Render(app *types.App, env map[string]string) (*composetypes.Config, error) {
+func Render(app *types.App, env map[string]string, ops ...func(*Options)) (*composetypes.Config, error) {
+	options := &Options{}
+	for _, op := range ops {
+		op(options)
+	}
 	// prepend the app settings to the argument settings
 	// load the settings into a struct
 	fileSettings := app.Settings()
@@ -57,39 +111,233 @@ func Render(app *types.App, env map[string]string) (*composetypes.Config, error)
 	if err != nil {
 		return nil, err
 	}
-	allSettings, err := settings.Merge(fileSettings, metaPrefixed, envSettings)
+	userSettings, err := settings.Merge(fileSettings, envSettings)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to merge settings")
+	}
+	// resolve or redact the secrets, the env settings being merged again
+	// after the metadata
+	secretKeys := userSettings.SecretKeys(app.SettingsSchema())
+	if options.redactSecrets {
+		// the secret references can't be checked without being resolved
+		if err := app.SettingsSchema().ValidateUnresolved(userSettings); err != nil {
+			return nil, err
+		}
+		if userSettings, err = secrets.Redact(userSettings, secretKeys); err != nil {
+			return nil, err
+		}
+		if envSettings, err = secrets.Redact(envSettings, secretKeys); err != nil {
+			return nil, err
+		}
+	} else {
+		if app.Remote() && !options.remoteSecrets {
+			if err := checkPackagedSecrets(app, userSettings, secretKeys); err != nil {
+				return nil, err
+			}
+		}
+		if userSettings, err = secrets.ResolveSettings(userSettings, secretKeys); err != nil {
+			return nil, err
+		}
+		if envSettings, err = secrets.ResolveSettings(envSettings, secretKeys); err != nil {
+			return nil, err
+		}
+		// check the user settings against the app settings schema, if any
+		if err := app.SettingsSchema().Validate(userSettings); err != nil {
+			return nil, err
+		}
+	}
+	allSettings, err := settings.Merge(userSettings, metaPrefixed, envSettings)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to merge settings")
 	}
 	// prepend our app compose file to the list
-	renderers := renderer.Drivers()
-	if r, ok := os.LookupEnv("DOCKERAPP_RENDERERS"); ok {
-		rl := strings.Split(r, ",")
-		for _, r := range rl {
-			if !slices.ContainsString(renderer.Drivers(), r) {
-				return nil, fmt.Errorf("renderer '%s' not found", r)
+	renderers, err := ComposeRenderers(app)
+	if err != nil {
+		return nil, err
+	}
+	configFiles, texts, err := loadComposes(app, allSettings, renderers)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load composefiles")
+	}
+	rendered, err := render(configFiles, allSettings.Flatten())
+	if err != nil {
+		if e, ok := AsError(err); ok {
+			for i, file := range configFiles {
+				if file.Filename == e.File {
+					e.locate(string(app.Composes()[i]), texts[i])
+				}
+			}
+		}
+		return nil, err
+	}
+	pinImages(rendered, app.DependenciesLock())
+	if err := mergeDependencies(rendered, app, ops...); err != nil {
+		return nil, err
+	}
+	if options.pinnedImages {
+		if err := checkPinned(rendered); err != nil {
+			return nil, err
+		}
+	}
+	return rendered, nil
+}
+
+// checkPackagedSecrets fails if one of the secret references to resolve comes
+// from the app package rather than from the user
+func checkPackagedSecrets(app *types.App, s settings.Settings, secretKeys []string) error {
+	packaged, err := settings.LoadMultiple(app.PackageSettingsRaw())
+	if err != nil {
+		return err
+	}
+	values, packagedValues := s.Flatten(), packaged.Flatten()
+	var refused []string
+	for _, k := range secretKeys {
+		if v := values[k]; settings.IsSecretReference(v) && packagedValues[k] == v {
+			refused = append(refused, k)
+		}
+	}
+	if len(refused) > 0 {
+		return errors.Errorf("application %s comes from a registry or an URL, refusing to resolve the secret references of its settings %s: set them, or allow them with --allow-app-secrets",
+			app.Name, strings.Join(refused, ", "))
+	}
+	return nil
+}
+
+// pinImages pins the images of the services to the digests of the app lock,
+// unless their image changed since it was pinned
+func pinImages(config *composetypes.Config, lock *metadata.Lock) {
+	for i, service := range config.Services {
+		if pinned, ok := lock.FindImage(service.Name); ok && pinned.Image == service.Image {
+			config.Services[i].Image = pinned.Image + "@" + pinned.Digest
+		}
+	}
+}
+
+func checkPinned(config *composetypes.Config) error {
+	var unpinned []string
+	for _, service := range config.Services {
+		named, err := reference.ParseNormalizedNamed(service.Image)
+		if err != nil {
+			unpinned = append(unpinned, service.Name)
+			continue
+		}
+		if _, ok := named.(reference.Digested); !ok {
+			unpinned = append(unpinned, service.Name)
+		}
+	}
+	if len(unpinned) > 0 {
+		return errors.Errorf("the images of services %s are not pinned to a digest, run docker-app pin", strings.Join(unpinned, ", "))
+	}
+	return nil
+}
+
+// Renderers returns the default renderers, applied to the compose files of
+// the apps whose metadata declares none: the ones DOCKERAPP_RENDERERS lists,
+// or all of them
+func Renderers() ([]string, error) {
+	r, ok := os.LookupEnv("DOCKERAPP_RENDERERS")
+	if !ok {
+		return renderer.Drivers(), nil
+	}
+	rl := strings.Split(r, ",")
+	for _, r := range rl {
+		if !slices.ContainsString(renderer.Drivers(), r) {
+			return nil, fmt.Errorf("renderer '%s' not found", r)
+		}
+	}
+	return rl, nil
+}
+
+// ComposeRenderers returns the renderers applied to each compose file of the
+// app: the ones its metadata declares for the file, or else for all the files,
+// or else the default ones
+func ComposeRenderers(app *types.App) ([][]string, error) {
+	meta := app.Metadata()
+	for file := range meta.ComposeRenderers {
+		if _, ok := app.Files()[file]; !ok {
+			return nil, errors.Errorf("compose-renderers declares renderers for %s, which is not a compose file of the application", file)
+		}
+	}
+	var defaults []string
+	names := app.ComposeNames()
+	renderers := make([][]string, len(names))
+	for i := range renderers {
+		// the override compose files are not the ones the metadata names
+		if !app.IsComposeOverride(i) {
+			if renderers[i] = meta.ComposeFileRenderers(names[i]); renderers[i] != nil {
+				continue
 			}
 		}
-		renderers = rl
+		if defaults == nil {
+			var err error
+			if defaults, err = Renderers(); err != nil {
+				return nil, err
+			}
+		}
+		renderers[i] = defaults
 	}
-	configFiles, err := compose.Load(app.Composes(), func(data string) (string, error) {
-		return renderer.Apply(data, allSettings, renderers...)
+	return renderers, nil
+}
+
+// LoadComposes applies the renderers of each compose file of the app, with the
+// settings, and parses the compose files. The errors of the renderers and of
+// the parsing are *Error.
+func LoadComposes(app *types.App, s settings.Settings, renderers [][]string) ([]composetypes.ConfigFile, error) {
+	configFiles, _, err := loadComposes(app, s, renderers)
+	return configFiles, err
+}
+
+// loadComposes returns the parsed compose files, and their rendered text
+func loadComposes(app *types.App, s settings.Settings, renderers [][]string) ([]composetypes.ConfigFile, []string, error) {
+	sources, names := app.Composes(), app.ComposeNames()
+	texts := make([]string, len(sources))
+	configFiles, err := compose.Load(sources, func(i int, data string) (string, error) {
+		// apply the renderers one by one, to tell which one fails
+		text := data
+		for _, r := range renderers[i] {
+			out, err := renderer.ApplyWithOptions(text, s, driver.Options{Dir: app.Path}, r)
+			if err != nil {
+				return "", newError(names[i], r, text, text != data, err)
+			}
+			text = out
+		}
+		texts[i] = text
+		return text, nil
 	})
+	if perr, ok := err.(*compose.ParseError); ok {
+		return nil, nil, parseError(perr, names[perr.Index], string(sources[perr.Index]), texts[perr.Index])
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to load composefiles")
+		return nil, nil, err
+	}
+	for i := range configFiles {
+		configFiles[i].Filename = names[i]
 	}
-	return render(configFiles, allSettings.Flatten())
+	return configFiles, texts, nil
 }
 
 func render(configFiles []composetypes.ConfigFile, finalEnv map[string]string) (*composetypes.Config, error) {
+	interpolationFailed := false
 	rendered, err := loader.Load(composetypes.ConfigDetails{
 		WorkingDir:  ".",
 		ConfigFiles: configFiles,
 		Environment: finalEnv,
 	}, func(opts *loader.Options) {
-		opts.Interpolate.Substitute = substitute
+		opts.Interpolate.Substitute = func(template string, mapping composetemplate.Mapping) (string, error) {
+			s, err := substitute(template, mapping)
+			if err != nil {
+				interpolationFailed = true
+			}
+			return s, err
+		}
 	})
 	if err != nil {
+		if interpolationFailed {
+			// the loader doesn't tell which file failed
+			if ierr := interpolationError(configFiles, finalEnv); ierr != nil {
+				err = ierr
+			}
+		}
 		return nil, errors.Wrap(err, "failed to load Compose file")
 	}
 	if err := processEnabled(rendered); err != nil {
@@ -117,7 +365,7 @@ func processEnabled(config *composetypes.Config) error {
 	for _, service := range config.Services {
 		if service.Extras != nil {
 			if xEnabled, ok := service.Extras["x-enabled"]; ok {
-				enabled, err := isEnabled(xEnabled)
+				enabled, err := IsEnabled(xEnabled)
 				if err != nil {
 					return err
 				}
@@ -132,7 +380,9 @@ func processEnabled(config *composetypes.Config) error {
 	return nil
 }
 
-func isEnabled(e interface{}) (bool, error) {
+// IsEnabled returns whether the x-enabled value of a service, once
+// interpolated, enables it
+func IsEnabled(e interface{}) (bool, error) {
 	switch v := e.(type) {
 	case string:
 		v = strings.ToLower(strings.TrimSpace(v))
@@ -142,7 +392,7 @@ func isEnabled(e interface{}) (bool, error) {
 		case v == "", v == "0", v == "false":
 			return false, nil
 		case strings.HasPrefix(v, "!"):
-			nv, err := isEnabled(v[1:])
+			nv, err := IsEnabled(v[1:])
 			if err != nil {
 				return false, err
 			}
@@ -1,6 +1,7 @@
 package render
 
 import (
+	"os"
 	"strings"
 	"testing"
 
@@ -192,3 +193,195 @@ services:
 	assert.Assert(t, c != nil)
 	assert.NilError(t, err)
 }
+
+func TestRenderWithSettingsSchema(t *testing.T) {
+	composeFile := `
+version: "3.6"
+services:
+  front:
+    image: nginx
+    deploy:
+      replicas: ${replicas}
+`
+	schema := `
+type: object
+properties:
+  replicas:
+    type: integer
+    minimum: 1
+required:
+  - replicas
+`
+	app := &types.App{Path: "my-app"}
+	assert.NilError(t, types.Metadata(strings.NewReader(validMeta))(app))
+	assert.NilError(t, types.WithComposes(strings.NewReader(composeFile))(app))
+	assert.NilError(t, types.SettingsSchema(strings.NewReader(schema))(app))
+	assert.NilError(t, types.WithSettings(strings.NewReader(""))(app))
+
+	_, err := Render(app, nil)
+	assert.Error(t, err, "invalid settings:\n- replicas: replicas is required")
+	_, err = Render(app, map[string]string{"replicas": "abc"})
+	assert.Error(t, err, "invalid settings:\n- replicas: Invalid type. Expected: integer, given: string")
+	c, err := Render(app, map[string]string{"replicas": "2"})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(*c.Services[0].Deploy.Replicas, uint64(2)))
+
+	// the settings are still checked when the secrets are redacted
+	_, err = Render(app, map[string]string{"replicas": "abc"}, WithRedactedSecrets())
+	assert.Error(t, err, "invalid settings:\n- replicas: Invalid type. Expected: integer, given: string")
+	_, err = Render(app, nil, WithRedactedSecrets())
+	assert.Error(t, err, "invalid settings:\n- replicas: replicas is required")
+}
+
+func TestRenderWithSecrets(t *testing.T) {
+	composeFile := `
+version: "3.6"
+services:
+  front:
+    image: nginx
+    environment:
+      DB_USER: ${db.user}
+      DB_PASSWORD: ${db.password}
+      API_TOKEN: ${api.token}
+      MOTTO: ${motto}
+`
+	schema := `
+properties:
+  db:
+    properties:
+      user:
+        type: string
+      password:
+        type: string
+        secret: true
+  api:
+    properties:
+      token:
+        type: string
+        secret: true
+`
+	os.Setenv("DOCKERAPP_TEST_API_TOKEN", "t0k3n")
+	defer os.Unsetenv("DOCKERAPP_TEST_API_TOKEN")
+	app := &types.App{Path: "my-app"}
+	assert.NilError(t, types.Metadata(strings.NewReader(validMeta))(app))
+	assert.NilError(t, types.WithComposes(strings.NewReader(composeFile))(app))
+	assert.NilError(t, types.SettingsSchema(strings.NewReader(schema))(app))
+	assert.NilError(t, types.WithSettings(strings.NewReader(`
+db:
+  user: admin
+  password: s3cr3t
+api:
+  token: secret:env:DOCKERAPP_TEST_API_TOKEN
+motto: "secret:env:DOCKERAPP_TEST_API_TOKEN is not a secret"
+`))(app))
+
+	c, err := Render(app, nil)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(*c.Services[0].Environment["API_TOKEN"], "t0k3n"))
+	assert.Check(t, is.Equal(*c.Services[0].Environment["DB_PASSWORD"], "s3cr3t"))
+	// only the settings the schema marks as secret are secret references
+	assert.Check(t, is.Equal(*c.Services[0].Environment["MOTTO"], "secret:env:DOCKERAPP_TEST_API_TOKEN is not a secret"))
+
+	c, err = Render(app, map[string]string{"db.password": "0v3rr1d3n"}, WithRedactedSecrets())
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(*c.Services[0].Environment["API_TOKEN"], "<redacted>"))
+	assert.Check(t, is.Equal(*c.Services[0].Environment["DB_PASSWORD"], "<redacted>"))
+	assert.Check(t, is.Equal(*c.Services[0].Environment["DB_USER"], "admin"))
+
+	_, err = Render(app, map[string]string{"api.token": "secret:env:DOCKERAPP_TEST_MISSING"})
+	assert.Check(t, is.ErrorContains(err, "failed to resolve secret setting api.token: environment variable DOCKERAPP_TEST_MISSING is not set"))
+}
+
+func TestRenderRemoteSecrets(t *testing.T) {
+	os.Setenv("DOCKERAPP_TEST_API_TOKEN", "t0k3n")
+	defer os.Unsetenv("DOCKERAPP_TEST_API_TOKEN")
+	app := &types.App{Name: "remote-app"}
+	assert.NilError(t, types.Metadata(strings.NewReader(validMeta))(app))
+	assert.NilError(t, types.WithComposes(strings.NewReader(`
+version: "3.6"
+services:
+  front:
+    image: nginx
+    environment:
+      API_TOKEN: ${api.token}
+`))(app))
+	assert.NilError(t, types.SettingsSchema(strings.NewReader(`
+properties:
+  api:
+    properties:
+      token:
+        type: string
+        secret: true
+`))(app))
+	assert.NilError(t, types.WithSettings(strings.NewReader("api:\n  token: secret:env:DOCKERAPP_TEST_API_TOKEN\n"))(app))
+	assert.NilError(t, types.WithRemote()(app))
+
+	// the references an app from a registry declares are refused
+	_, err := Render(app, nil)
+	assert.Check(t, is.ErrorContains(err, "refusing to resolve the secret references of its settings api.token"))
+
+	// unless the user allows them
+	c, err := Render(app, nil, WithRemoteSecrets())
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(*c.Services[0].Environment["API_TOKEN"], "t0k3n"))
+
+	// or gives their own
+	os.Setenv("DOCKERAPP_TEST_USER_TOKEN", "us3r")
+	defer os.Unsetenv("DOCKERAPP_TEST_USER_TOKEN")
+	c, err = Render(app, map[string]string{"api.token": "secret:env:DOCKERAPP_TEST_USER_TOKEN"})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(*c.Services[0].Environment["API_TOKEN"], "us3r"))
+
+	// redacting doesn't resolve them
+	c, err = Render(app, nil, WithRedactedSecrets())
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(*c.Services[0].Environment["API_TOKEN"], "<redacted>"))
+}
+
+func TestRenderPinnedImages(t *testing.T) {
+	composeFile := `
+version: "3.6"
+services:
+  front:
+    image: nginx:${version}
+  back:
+    image: redis@sha256:0000000000000000000000000000000000000000000000000000000000000001
+  worker:
+    image: busybox
+`
+	lock := `
+dependencies: []
+images:
+  - service: front
+    image: nginx:1.15
+    digest: sha256:0000000000000000000000000000000000000000000000000000000000000002
+`
+	app := &types.App{Path: "my-app"}
+	assert.NilError(t, types.Metadata(strings.NewReader(validMeta))(app))
+	assert.NilError(t, types.WithComposes(strings.NewReader(composeFile))(app))
+	assert.NilError(t, types.WithSettings(strings.NewReader("version: \"1.15\"\n"))(app))
+	assert.NilError(t, types.DependenciesLock(strings.NewReader(lock))(app))
+
+	c, err := Render(app, nil)
+	assert.NilError(t, err)
+	images := map[string]string{}
+	for _, s := range c.Services {
+		images[s.Name] = s.Image
+	}
+	assert.Check(t, is.DeepEqual(images, map[string]string{
+		"front":  "nginx:1.15@sha256:0000000000000000000000000000000000000000000000000000000000000002",
+		"back":   "redis@sha256:0000000000000000000000000000000000000000000000000000000000000001",
+		"worker": "busybox",
+	}))
+	_, err = Render(app, nil, WithPinnedImages())
+	assert.Check(t, is.ErrorContains(err, "the images of services worker are not pinned to a digest"))
+
+	// images changed since they were pinned are not pinned anymore
+	c, err = Render(app, map[string]string{"version": "1.16"})
+	assert.NilError(t, err)
+	for _, s := range c.Services {
+		if s.Name == "front" {
+			assert.Check(t, is.Equal(s.Image, "nginx:1.16"))
+		}
+	}
+}
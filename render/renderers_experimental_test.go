@@ -0,0 +1,153 @@
+// +build experimental
+
+package render
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/app/types"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func TestRenderWithDeclaredRenderers(t *testing.T) {
+	// the renderers the metadata declares win over DOCKERAPP_RENDERERS
+	defer os.Unsetenv("DOCKERAPP_RENDERERS")
+	os.Setenv("DOCKERAPP_RENDERERS", "mustache")
+
+	app := &types.App{Path: "my-app"}
+	assert.NilError(t, types.Metadata(strings.NewReader(validMeta+`
+renderers: [gotemplate]
+compose-renderers:
+  compose/1.yml: [none]
+`))(app))
+	assert.NilError(t, types.WithComposes(
+		strings.NewReader(`version: "3.6"
+services:
+  web:
+    image: nginx:{{.web.version}}
+`),
+		strings.NewReader(`version: "3.6"
+services:
+  web:
+    command: echo "{{ not rendered }}"
+`),
+	)(app))
+	assert.NilError(t, types.WithSettings(strings.NewReader("web:\n  version: latest\n"))(app))
+
+	c, err := Render(app, nil)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(c.Services[0].Image, "nginx:latest"))
+	assert.Check(t, is.DeepEqual([]string(c.Services[0].Command), []string{"echo", "{{ not rendered }}"}))
+}
+
+func TestRenderYateeInclude(t *testing.T) {
+	dir := fs.NewDir(t, "my-app", fs.WithFile("logging.yml", `
+"@define logging":
+  logging:
+    driver: $driver
+`))
+	defer dir.Remove()
+	app := &types.App{Path: dir.Path()}
+	assert.NilError(t, types.Metadata(strings.NewReader(validMeta+"\nrenderers: [yatee]\n"))(app))
+	assert.NilError(t, types.WithComposes(strings.NewReader(`version: "3.6"
+"@include logging.yml":
+services:
+  web:
+    image: nginx
+    "@use logging":
+`))(app))
+	assert.NilError(t, types.WithSettings(strings.NewReader("driver: syslog\n"))(app))
+
+	c, err := Render(app, nil)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(c.Services[0].Logging.Driver, "syslog"))
+}
+
+func TestRenderRendererErrors(t *testing.T) {
+	testCases := []struct {
+		name      string
+		renderers string
+		compose   string
+		expected  Error
+	}{
+		{
+			name:      "gotemplate",
+			renderers: "gotemplate",
+			compose:   "version: \"3.6\"\nservices:\n  web:\n    image: {{.web.image.name}}\n",
+			expected: Error{
+				File: "docker-compose.yml", Line: 4, Column: 18, Stage: "gotemplate",
+				Snippet: "    image: {{.web.image.name}}",
+			},
+		},
+		{
+			name:      "mustache",
+			renderers: "mustache",
+			compose:   "version: \"3.6\"\nservices:\n  web:\n    image: {{}}\n",
+			expected: Error{
+				File: "docker-compose.yml", Line: 4, Stage: "mustache",
+				Snippet: "    image: {{}}",
+			},
+		},
+		{
+			name:      "yatee",
+			renderers: "yatee",
+			compose:   "version: \"3.6\"\nservices:\n  web:\n    image: nginx:$tag\n",
+			expected: Error{
+				File: "docker-compose.yml", Line: 4, Column: 18, Stage: "yatee",
+				Snippet: "    image: nginx:$tag",
+			},
+		},
+		{
+			name:      "parse after rendering",
+			renderers: "gotemplate",
+			compose:   "version: \"3.6\"\n{{/* a comment */}}\nservices:\n  web:\n    image: {{.web.image}}\n",
+			expected: Error{
+				File: "docker-compose.yml", Line: 5, Stage: StageParse, Rendered: true,
+				Snippet: "    image: [nginx",
+			},
+		},
+		{
+			name:      "interpolation of a rendered value",
+			renderers: "gotemplate",
+			compose:   "version: \"3.6\"\nservices:\n  web:\n    image: {{.web.registry}}/nginx:${tag}\n",
+			expected: Error{
+				File: "docker-compose.yml", Line: 4, Column: 36, Stage: StageInterpolation,
+				Snippet: "    image: {{.web.registry}}/nginx:${tag}",
+			},
+		},
+	}
+	defer os.Unsetenv("DOCKERAPP_RENDERERS")
+	for _, tc := range testCases {
+		os.Setenv("DOCKERAPP_RENDERERS", tc.renderers)
+		app := &types.App{Path: "my-app"}
+		assert.NilError(t, types.Metadata(strings.NewReader(validMeta))(app))
+		assert.NilError(t, types.WithComposes(strings.NewReader(tc.compose))(app))
+		assert.NilError(t, types.WithSettings(strings.NewReader("web:\n  image: \"[nginx\"\n  registry: registry.local\n"))(app))
+		_, err := Render(app, nil)
+		e, ok := AsError(err)
+		assert.Assert(t, ok, "%s: %v", tc.name, err)
+		got := *e
+		got.Message, got.template, got.variable = "", "", ""
+		assert.Check(t, is.Equal(got, tc.expected), tc.name)
+	}
+}
+
+func TestRenderErrorInOverlay(t *testing.T) {
+	app := &types.App{Path: "my-app"}
+	assert.NilError(t, types.Metadata(strings.NewReader(validMeta+"\nrenderers: [gotemplate]\n"))(app))
+	assert.NilError(t, types.WithComposes(
+		strings.NewReader("version: \"3.6\"\nservices:\n  web:\n    image: {{.web.image}}\n"),
+		strings.NewReader("version: \"3.6\"\nservices:\n  web:\n    command: {{.web.command.args}}\n"),
+	)(app))
+	assert.NilError(t, types.WithSettings(strings.NewReader("web:\n  image: nginx\n"))(app))
+	_, err := Render(app, nil)
+	e, ok := AsError(err)
+	assert.Assert(t, ok, "%v", err)
+	assert.Check(t, is.Equal(e.File, "compose/1.yml"))
+	assert.Check(t, is.Equal(e.Line, 4))
+	assert.Check(t, is.Equal(e.Stage, "gotemplate"))
+}
@@ -0,0 +1,55 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/app/types"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func TestComposeRenderers(t *testing.T) {
+	app := &types.App{Path: "my-app"}
+	assert.NilError(t, types.Metadata(strings.NewReader(validMeta+`
+compose-renderers:
+  compose/1.yml: [none]
+`))(app))
+	assert.NilError(t, types.WithComposes(strings.NewReader(`version: "3.6"`), strings.NewReader(`version: "3.6"`))(app))
+	assert.NilError(t, types.WithSettings(strings.NewReader(""))(app))
+
+	defaults, err := Renderers()
+	assert.NilError(t, err)
+	renderers, err := ComposeRenderers(app)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(renderers, [][]string{defaults, {"none"}}))
+
+	app = &types.App{Path: "my-app"}
+	assert.NilError(t, types.Metadata(strings.NewReader(validMeta+`
+compose-renderers:
+  compose/2.yml: [none]
+`))(app))
+	assert.NilError(t, types.WithComposes(strings.NewReader(`version: "3.6"`), strings.NewReader(`version: "3.6"`))(app))
+	assert.NilError(t, types.WithSettings(strings.NewReader(""))(app))
+	_, err = ComposeRenderers(app)
+	assert.Check(t, is.Error(err, "compose-renderers declares renderers for compose/2.yml, which is not a compose file of the application"))
+
+	// override compose files are neither overlays nor named as the app files
+	dir := fs.NewDir(t, "composes", fs.WithFile("override.yml", `version: "3.6"`))
+	defer dir.Remove()
+	assert.NilError(t, types.WithComposeOverrideFiles(dir.Join("override.yml"))(app))
+	_, err = ComposeRenderers(app)
+	assert.Check(t, is.Error(err, "compose-renderers declares renderers for compose/2.yml, which is not a compose file of the application"))
+	app = &types.App{Path: "my-app"}
+	assert.NilError(t, types.Metadata(strings.NewReader(validMeta+`
+compose-renderers:
+  docker-compose.yml: [none]
+`))(app))
+	assert.NilError(t, types.WithComposes(strings.NewReader(`version: "3.6"`))(app))
+	assert.NilError(t, types.WithComposeOverrideFiles(dir.Join("override.yml"))(app))
+	assert.NilError(t, types.WithSettings(strings.NewReader(""))(app))
+	renderers, err = ComposeRenderers(app)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(renderers, [][]string{{"none"}, defaults}))
+}
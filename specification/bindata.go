@@ -192,14 +192,19 @@ var _escData = map[string]*_escFile{
 
 	"/schemas/metadata_schema_v0.1.json": {
 		local:   "schemas/metadata_schema_v0.1.json",
-		size:    1019,
+		size:    3431,
 		modtime: 1518458244,
 		compressed: `
-H4sIAAAAAAAC/5xSy27CQAy85ytW2x6BUKmn/AqqkJsYWJR91GuQUJV/rxLz2nYTUHNKxvZkxuPvQiml
-9Gusd2hBV0rvmENVlvvo3VzQhadt2RBseL58LwV70TOZNE0/ZJGhAYa1VNfH5eJt0VNc2vgUsG/0n3us
-+YIG8gGJDUZdKZEy4A4sJkjCEZmM2545rtWNJws8OPCRB4ZrQ3fr1UekaLx7SJ8dbjDWZAJPEawSdKhk
-FYvTQ9vqBP7I/rj3EwPU+D/dFoxjMA4pjhMAEZx+r9Uw2r8zU+ml/nJJPiX//ulmeXa0YNqn6FejHdMB
-PQgrCW58+u44RXLeZTGN3L7k7bwVTfh1MIRNYlM2n7n7Qo6sK34CAAD//7xaWqb7AwAA
+H4sIAAAAAAAC/8xWzW7bMAy++ykItYdtiOMM2GW57BV2D7yCsZhUhS1pklogG/Lug+P82I4kK1uLzieD
+Ij9+FH/E3xkAALu31SM1yJbAHp3Ty6J4skrmnXSuzLbgBjcuX3wpOtkdm3WWgrdGDTnk6PChO314Wcw/
+z1uIk5rbaWoV1fqJKneSaqM0GSfIsiV0VAAAmMSGBpIBhnVGyO0R43y6UaZBd4hAWXdAOCvsL7rshYwV
+Sk7Ce4052coI7WIAq4EUAAKMAQCYfK5rNhCXXsdtPFZjRX/Hu0EhHQpJxoYB0Bjcja9VOGqubbqiMbRp
+7e4KThshRXsrtri4Gsa19xLTaEi6NyfVuUkhxEmT5CSrcVG+Aauzr10KM9PqGm8G/fAXAy9epRqtLOUR
+XG/Xnk+R84MnrL/7+/h2fqPosx5nZujnszDEBy3WjQpPe2cAAOXRtOd1OGgisQseYzx7nboI9W64Cnrt
+lUS5pz+7Jbl6IqXeEZ0U1yii08eoQVFPQq68p/EpG5m2p6/0W/VelY7edSS3zJRdWs56+u+Xs8CFaHSO
+jGwVf6ww/7XIv5Yfjj95+ekk+vjtPi3rosHtKxdS6IX/J1BLzgm5tdOo3tzcPjTTCz+t+I8N0KyvJsH4
+Y2ulakLJglql92Q/1RyjcvUO9NBgHxVMFmne0B1vsLYU2ULSmvOo+w6tF9twY6Ub3h3/vzaLLapJr23C
+qzuxloQW2OQyH+8v2T77MwCTDmTnZw0AAA==
 `,
 	},
 
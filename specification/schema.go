@@ -19,6 +19,30 @@ func Validate(config map[string]interface{}, version string) error {
 	}
 
 	schemaLoader := gojsonschema.NewStringLoader(string(schemaData))
+	return validate(schemaLoader, config, false, nil)
+}
+
+// ValidateSettingsSchema checks the given settings schema is a valid jsonschema
+func ValidateSettingsSchema(schema map[string]interface{}) error {
+	_, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema))
+	return err
+}
+
+// ValidateSettings uses the given settings jsonschema to validate the settings.
+// If partial is true, missing required settings are not reported, as they may
+// still be provided later on.
+func ValidateSettings(settings map[string]interface{}, schema map[string]interface{}, partial bool) error {
+	return validate(gojsonschema.NewGoLoader(schema), settings, partial, nil)
+}
+
+// ValidateSettingsWithout uses the given settings jsonschema to validate the
+// settings, the given flattened keys being left out on purpose: they are not
+// reported as missing if required.
+func ValidateSettingsWithout(settings map[string]interface{}, schema map[string]interface{}, missing []string) error {
+	return validate(gojsonschema.NewGoLoader(schema), settings, false, missing)
+}
+
+func validate(schemaLoader gojsonschema.JSONLoader, config map[string]interface{}, partial bool, missing []string) error {
 	dataLoader := gojsonschema.NewGoLoader(config)
 
 	result, err := gojsonschema.Validate(schemaLoader, dataLoader)
@@ -26,11 +50,14 @@ func Validate(config map[string]interface{}, version string) error {
 		return err
 	}
 
-	if !result.Valid() {
-		errs := make([]string, len(result.Errors()))
-		for i, err := range result.Errors() {
-			errs[i] = fmt.Sprintf("- %s", err)
+	var errs []string
+	for _, err := range result.Errors() {
+		if err.Type() == "required" && (partial || isMissing(err, missing)) {
+			continue
 		}
+		errs = append(errs, fmt.Sprintf("- %s", err))
+	}
+	if len(errs) != 0 {
 		sort.Strings(errs)
 		return errors.New(strings.Join(errs, "\n"))
 	}
@@ -38,3 +65,18 @@ func Validate(config map[string]interface{}, version string) error {
 	return nil
 
 }
+
+// isMissing returns whether the required property of the error is one of the
+// flattened keys
+func isMissing(err gojsonschema.ResultError, missing []string) bool {
+	key := err.Field()
+	if parent := strings.TrimPrefix(strings.TrimPrefix(err.Context().String(), gojsonschema.STRING_ROOT_SCHEMA_PROPERTY), "."); parent != "" {
+		key = parent + "." + key
+	}
+	for _, m := range missing {
+		if m == key {
+			return true
+		}
+	}
+	return false
+}
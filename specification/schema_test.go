@@ -26,3 +26,30 @@ func TestValidateMetadata(t *testing.T) {
 	}
 	assert.NilError(t, Validate(metadata, "v0.1"))
 }
+
+func TestValidateSettings(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"replicas": map[string]interface{}{
+				"type":    "integer",
+				"minimum": 1,
+			},
+			"mode": map[string]interface{}{
+				"enum": []interface{}{"replicated", "global"},
+			},
+		},
+		"required": []interface{}{"replicas"},
+	}
+	assert.NilError(t, ValidateSettings(map[string]interface{}{"replicas": 2, "mode": "global"}, schema, false))
+	assert.Error(t, ValidateSettings(map[string]interface{}{"replicas": "abc", "mode": "other"}, schema, false),
+		`- mode: mode must be one of the following: "replicated", "global"
+- replicas: Invalid type. Expected: integer, given: string`)
+	assert.Error(t, ValidateSettings(map[string]interface{}{}, schema, false), "- replicas: replicas is required")
+	assert.NilError(t, ValidateSettings(map[string]interface{}{}, schema, true))
+}
+
+func TestValidateSettingsSchema(t *testing.T) {
+	assert.NilError(t, ValidateSettingsSchema(map[string]interface{}{"type": "object"}))
+	assert.Check(t, ValidateSettingsSchema(map[string]interface{}{"type": "unknown"}) != nil)
+}
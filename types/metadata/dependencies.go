@@ -0,0 +1,124 @@
+package metadata
+
+import (
+	"github.com/docker/app/internal/semver"
+	"github.com/docker/app/internal/yaml"
+	"github.com/pkg/errors"
+)
+
+// Dependencies is a list of Dependency items
+type Dependencies []Dependency
+
+// Dependency is another application, hosted on a registry, included in the
+// application. Its services, networks, volumes, secrets and configs are
+// prefixed with its name.
+type Dependency struct {
+	Name     string            `json:"name"`
+	Image    string            `json:"image"`
+	Version  string            `yaml:",omitempty" json:"version,omitempty"`
+	Settings map[string]string `yaml:",omitempty" json:"settings,omitempty"`
+}
+
+// Find returns the named dependency, if any
+func (ds Dependencies) Find(name string) (Dependency, bool) {
+	for _, d := range ds {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Dependency{}, false
+}
+
+// Constraint returns the parsed version constraint of the dependency, or nil
+// if it has none
+func (d Dependency) Constraint() (*semver.Constraint, error) {
+	if d.Version == "" {
+		return nil, nil
+	}
+	c, err := semver.ParseConstraint(d.Version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid version constraint for dependency %q", d.Name)
+	}
+	return c, nil
+}
+
+func (ds Dependencies) validate() error {
+	seen := map[string]bool{}
+	for _, d := range ds {
+		if seen[d.Name] {
+			return errors.Errorf("duplicate dependency %q", d.Name)
+		}
+		seen[d.Name] = true
+		if _, err := d.Constraint(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lock pins the dependencies of an application to the digests of their
+// resolved versions, and the images of its services to their digests
+type Lock struct {
+	Dependencies []LockedDependency `json:"dependencies"`
+	Images       []LockedImage      `yaml:",omitempty" json:"images,omitempty"`
+}
+
+// LockedDependency is a dependency resolved to a given version and digest
+type LockedDependency struct {
+	Name    string `json:"name"`
+	Image   string `json:"image"`
+	Version string `json:"version"`
+	Digest  string `json:"digest"`
+}
+
+// LockedImage is the image of a service pinned to a digest
+type LockedImage struct {
+	Service string `json:"service"`
+	Image   string `json:"image"`
+	Digest  string `json:"digest"`
+}
+
+// Find returns the locked version of the named dependency, if any
+func (l *Lock) Find(name string) (LockedDependency, bool) {
+	if l == nil {
+		return LockedDependency{}, false
+	}
+	for _, d := range l.Dependencies {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return LockedDependency{}, false
+}
+
+// FindImage returns the pinned image of the named service, if any
+func (l *Lock) FindImage(service string) (LockedImage, bool) {
+	if l == nil {
+		return LockedImage{}, false
+	}
+	for _, i := range l.Images {
+		if i.Service == service {
+			return i, true
+		}
+	}
+	return LockedImage{}, false
+}
+
+// LoadLock loads the given data into a lock struct
+func LoadLock(data []byte) (*Lock, error) {
+	var lock Lock
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal dependencies lock")
+	}
+	for _, d := range lock.Dependencies {
+		if d.Name == "" || d.Image == "" || d.Digest == "" {
+			return nil, errors.Errorf("invalid dependencies lock: name, image and digest are required for each dependency")
+		}
+	}
+	for _, i := range lock.Images {
+		if i.Service == "" || i.Image == "" || i.Digest == "" {
+			return nil, errors.Errorf("invalid dependencies lock: service, image and digest are required for each image")
+		}
+	}
+	return &lock, nil
+}
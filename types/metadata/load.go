@@ -19,6 +19,12 @@ func Load(data []byte) (AppMetadata, error) {
 	if err := yaml.Unmarshal(data, &meta); err != nil {
 		return AppMetadata{}, errors.Wrap(err, "failed to unmarshal metadata")
 	}
+	if err := meta.Dependencies.validate(); err != nil {
+		return AppMetadata{}, err
+	}
+	if err := meta.validateRenderers(); err != nil {
+		return AppMetadata{}, err
+	}
 	return meta, nil
 }
 
@@ -37,3 +37,119 @@ maintainers:
 	assert.NilError(t, err)
 	assert.Check(t, is.DeepEqual(parsed, m))
 }
+
+func TestDependencies(t *testing.T) {
+	parsed, err := Load([]byte(`name: shop
+version: 0.1.0
+dependencies:
+  - name: monitoring
+    image: myorg/monitoring.dockerapp
+    version: ^1.2
+    settings:
+      retention: 15d
+      replicas: 2
+  - name: logging
+    image: myorg/logging.dockerapp
+`))
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(parsed.Dependencies, Dependencies{
+		{
+			Name:     "monitoring",
+			Image:    "myorg/monitoring.dockerapp",
+			Version:  "^1.2",
+			Settings: map[string]string{"retention": "15d", "replicas": "2"},
+		},
+		{
+			Name:  "logging",
+			Image: "myorg/logging.dockerapp",
+		},
+	}))
+	d, ok := parsed.Dependencies.Find("logging")
+	assert.Check(t, ok)
+	c, err := d.Constraint()
+	assert.NilError(t, err)
+	assert.Check(t, c == nil)
+}
+
+func TestInvalidDependencies(t *testing.T) {
+	testCases := []struct {
+		name     string
+		deps     string
+		expected string
+	}{
+		{
+			name:     "missing-image",
+			deps:     "  - name: monitoring\n",
+			expected: "image is required",
+		},
+		{
+			name:     "invalid-name",
+			deps:     "  - name: Monitoring\n    image: myorg/monitoring.dockerapp\n",
+			expected: "dependencies.0.name",
+		},
+		{
+			name:     "duplicate",
+			deps:     "  - name: monitoring\n    image: a.dockerapp\n  - name: monitoring\n    image: b.dockerapp\n",
+			expected: `duplicate dependency "monitoring"`,
+		},
+		{
+			name:     "invalid-constraint",
+			deps:     "  - name: monitoring\n    image: a.dockerapp\n    version: ^x.y.z.w\n",
+			expected: `invalid version constraint for dependency "monitoring"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load([]byte("name: shop\nversion: 0.1.0\ndependencies:\n" + tc.deps))
+			assert.Check(t, is.ErrorContains(err, tc.expected))
+		})
+	}
+}
+
+func TestLoadLock(t *testing.T) {
+	lock, err := LoadLock([]byte(`dependencies:
+  - name: monitoring
+    image: myorg/monitoring.dockerapp
+    version: 1.3.0
+    digest: sha256:0123
+images:
+  - service: web
+    image: nginx:1.15
+    digest: sha256:4567
+`))
+	assert.NilError(t, err)
+	d, ok := lock.Find("monitoring")
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(d.Digest, "sha256:0123"))
+	_, ok = lock.Find("logging")
+	assert.Check(t, !ok)
+	i, ok := lock.FindImage("web")
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(i.Image+"@"+i.Digest, "nginx:1.15@sha256:4567"))
+	_, ok = lock.FindImage("db")
+	assert.Check(t, !ok)
+
+	_, err = LoadLock([]byte("dependencies:\n  - name: monitoring\n"))
+	assert.Check(t, is.ErrorContains(err, "name, image and digest are required"))
+	_, err = LoadLock([]byte("dependencies: []\nimages:\n  - service: web\n"))
+	assert.Check(t, is.ErrorContains(err, "service, image and digest are required"))
+}
+
+func TestRenderers(t *testing.T) {
+	parsed, err := Load([]byte(`name: app
+version: 0.1.0
+renderers: [none]
+compose-renderers:
+  compose/1.yml: [none]
+`))
+	assert.NilError(t, err)
+	assert.Check(t, parsed.DeclaresRenderers())
+	assert.Check(t, is.DeepEqual(parsed.ComposeFileRenderers("compose/1.yml"), []string{"none"}))
+
+	_, err = Load([]byte("name: app\nversion: 0.1.0\nrenderers: [unknown]\n"))
+	assert.Check(t, is.ErrorContains(err, `unknown renderer "unknown"`))
+	_, err = Load([]byte("name: app\nversion: 0.1.0\ncompose-renderers:\n  settings.yml: [none]\n"))
+	assert.Check(t, is.ErrorContains(err, `invalid compose file "settings.yml" in compose-renderers`))
+	_, err = Load([]byte("name: app\nversion: 0.1.0\nrenderers: none\n"))
+	assert.Check(t, is.ErrorContains(err, "failed to validate metadata"))
+}
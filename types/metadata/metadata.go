@@ -33,12 +33,17 @@ func (m Maintainer) String() string {
 
 // AppMetadata is the format of the data found inside the metadata.yml file
 type AppMetadata struct {
-	Version     string      `json:"version"`
-	Name        string      `json:"name"`
-	Description string      `json:"description,omitempty"`
-	Namespace   string      `json:"namespace,omitempty"`
-	Maintainers Maintainers `json:"maintainers,omitempty"`
-	Parents     Parents     `yaml:",omitempty" json:"parents,omitempty"`
+	Version      string       `json:"version"`
+	Name         string       `json:"name"`
+	Description  string       `json:"description,omitempty"`
+	Namespace    string       `json:"namespace,omitempty"`
+	Maintainers  Maintainers  `json:"maintainers,omitempty"`
+	Parents      Parents      `yaml:",omitempty" json:"parents,omitempty"`
+	Dependencies Dependencies `yaml:",omitempty" json:"dependencies,omitempty"`
+	// Renderers is the renderer chain applied to the compose files
+	Renderers []string `yaml:",omitempty" json:"renderers,omitempty"`
+	// ComposeRenderers overrides the renderer chain of some compose files
+	ComposeRenderers map[string][]string `yaml:"compose-renderers,omitempty" json:"compose-renderers,omitempty"`
 }
 
 // Parents is a list of ParentMetadata items
@@ -66,12 +71,16 @@ func From(orig AppMetadata, modifiers ...Modifier) AppMetadata {
 	}
 
 	result := AppMetadata{
-		Version:     orig.Version,
-		Name:        orig.Name,
-		Namespace:   orig.Namespace,
-		Description: orig.Description,
-		Maintainers: orig.Maintainers,
-		Parents:     append(orig.Parents, parent),
+		Version:      orig.Version,
+		Name:         orig.Name,
+		Namespace:    orig.Namespace,
+		Description:  orig.Description,
+		Maintainers:  orig.Maintainers,
+		Parents:      append(orig.Parents, parent),
+		Dependencies: orig.Dependencies,
+
+		Renderers:        orig.Renderers,
+		ComposeRenderers: orig.ComposeRenderers,
 	}
 	for _, f := range modifiers {
 		result = f(result)
@@ -0,0 +1,67 @@
+package metadata
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/docker/app/internal"
+	"github.com/docker/app/internal/renderer"
+	"github.com/docker/app/internal/slices"
+	"github.com/pkg/errors"
+)
+
+// composeFileRe matches the names of the compose files whose renderers can be
+// overridden: the compose file and its overlays
+var composeFileRe = regexp.MustCompile(`^(` + regexp.QuoteMeta(internal.ComposeFileName) + `|` + internal.ComposeOverlaysDir + `/[1-9][0-9]*\.yml)$`)
+
+// DeclaresRenderers returns whether the metadata declares the renderers of
+// any compose file
+func (m AppMetadata) DeclaresRenderers() bool {
+	return len(m.Renderers) != 0 || len(m.ComposeRenderers) != 0
+}
+
+// ComposeFileRenderers returns the renderer chain declared for the given
+// compose file (e.g. docker-compose.yml or compose/1.yml), or nil if the
+// metadata declares none
+func (m AppMetadata) ComposeFileRenderers(file string) []string {
+	if renderers := m.ComposeRenderers[file]; len(renderers) != 0 {
+		return renderers
+	}
+	if len(m.Renderers) != 0 {
+		return m.Renderers
+	}
+	return nil
+}
+
+// validateRenderers checks the declared renderers are available, and are
+// declared for compose files
+func (m AppMetadata) validateRenderers() error {
+	if err := checkRenderers(m.Renderers); err != nil {
+		return err
+	}
+	var files []string
+	for file := range m.ComposeRenderers {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if !composeFileRe.MatchString(file) {
+			return errors.Errorf("invalid compose file %q in compose-renderers, expected %s or %s", file, internal.ComposeFileName, internal.ComposeOverlayFileName(1))
+		}
+		if err := checkRenderers(m.ComposeRenderers[file]); err != nil {
+			return errors.Wrapf(err, "invalid renderers for %s", file)
+		}
+	}
+	return nil
+}
+
+func checkRenderers(renderers []string) error {
+	available := renderer.Drivers()
+	for _, r := range renderers {
+		if !slices.ContainsString(available, r) {
+			return errors.Errorf("unknown renderer %q, available renderers: %s", r, strings.Join(available, ", "))
+		}
+	}
+	return nil
+}
@@ -52,6 +52,9 @@ func LoadMultiple(datas [][]byte, ops ...func(*Options)) (Settings, error) {
 			return nil, err
 		}
 	}
+	if err := validateWithOptions(m, ops...); err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
@@ -77,9 +80,20 @@ func LoadFiles(paths []string, ops ...func(*Options)) (Settings, error) {
 			return nil, err
 		}
 	}
+	if err := validateWithOptions(m, ops...); err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
+func validateWithOptions(s Settings, ops ...func(*Options)) error {
+	options := &Options{}
+	for _, op := range ops {
+		op(options)
+	}
+	return options.schema.validate(s, true)
+}
+
 // from cli
 func convertToStringKeysRecursive(value interface{}, keyPrefix string) (interface{}, error) {
 	if mapping, ok := value.(map[interface{}]interface{}); ok {
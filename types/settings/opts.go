@@ -3,6 +3,7 @@ package settings
 // Options contains loading options for settings
 type Options struct {
 	prefix string
+	schema Schema
 }
 
 // WithPrefix adds the given prefix when loading settings
@@ -11,3 +12,12 @@ func WithPrefix(prefix string) func(*Options) {
 		o.prefix = prefix
 	}
 }
+
+// WithSchema validates the loaded settings against the given schema.
+// Required settings are not enforced, as they can still be provided at
+// render time.
+func WithSchema(schema Schema) func(*Options) {
+	return func(o *Options) {
+		o.schema = schema
+	}
+}
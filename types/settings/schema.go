@@ -0,0 +1,87 @@
+package settings
+
+import (
+	"github.com/docker/app/specification"
+	"github.com/pkg/errors"
+)
+
+// Schema is a jsonschema describing the expected settings of an application
+// (types, enums, bounds, patterns, required keys and descriptions).
+type Schema map[string]interface{}
+
+// LoadSchema loads the given data (either YAML or JSON) as a settings schema
+func LoadSchema(data []byte) (Schema, error) {
+	s, err := Load(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read settings schema")
+	}
+	if err := specification.ValidateSettingsSchema(s); err != nil {
+		return nil, errors.Wrap(err, "invalid settings schema")
+	}
+	return Schema(s), nil
+}
+
+// Validate checks the given settings against the schema. A nil schema
+// accepts any settings.
+func (s Schema) Validate(settings Settings) error {
+	return s.validate(settings, false)
+}
+
+// ValidateUnresolved checks the given settings against the schema, but for the
+// secret references of the secret keys, which can only be checked once
+// resolved, e.g. when the secrets are redacted.
+func (s Schema) ValidateUnresolved(settings Settings) error {
+	if s == nil {
+		return nil
+	}
+	secretKeys := s.secretKeys("")
+	var references []string
+	for k, v := range settings.Flatten() {
+		if IsSecretReference(v) && hasKeyPrefix(k, secretKeys) {
+			references = append(references, k)
+		}
+	}
+	if err := specification.ValidateSettingsWithout(withoutSecretReferences(settings, secretKeys), s, references); err != nil {
+		return errors.Errorf("invalid settings:\n%s", err)
+	}
+	return nil
+}
+
+func (s Schema) validate(settings Settings, partial bool) error {
+	if s == nil {
+		return nil
+	}
+	if partial {
+		settings = withoutSecretReferences(settings, s.secretKeys(""))
+	}
+	if err := specification.ValidateSettings(settings, s, partial); err != nil {
+		return errors.Errorf("invalid settings:\n%s", err)
+	}
+	return nil
+}
+
+// secretKeys returns the flattened keys of the properties marked with
+// "secret: true"
+func (s Schema) secretKeys(prefix string) []string {
+	properties, ok := s["properties"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	var keys []string
+	for name, p := range properties {
+		property, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		if secret, ok := property["secret"].(bool); ok && secret {
+			keys = append(keys, key)
+			continue
+		}
+		keys = append(keys, Schema(property).secretKeys(key)...)
+	}
+	return keys
+}
@@ -0,0 +1,107 @@
+package settings
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+const testSchema = `
+type: object
+properties:
+  web:
+    type: object
+    properties:
+      port:
+        type: integer
+        maximum: 65535
+      image:
+        type: string
+        pattern: "^[a-z]+$"
+required:
+  - web
+`
+
+func TestLoadSchemaErrors(t *testing.T) {
+	_, err := LoadSchema([]byte("invalid yaml"))
+	assert.Check(t, is.ErrorContains(err, "failed to read settings schema"))
+
+	_, err = LoadSchema([]byte("type: unknown"))
+	assert.Check(t, is.ErrorContains(err, "invalid settings schema"))
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := LoadSchema([]byte(testSchema))
+	assert.NilError(t, err)
+
+	s, err := FromFlatten(map[string]string{"web.port": "8080", "web.image": "nginx"})
+	assert.NilError(t, err)
+	assert.NilError(t, schema.Validate(s))
+
+	s, err = FromFlatten(map[string]string{"web.port": "abc", "web.image": "Nginx"})
+	assert.NilError(t, err)
+	assert.Error(t, schema.Validate(s), `invalid settings:
+- web.image: Does not match pattern '^[a-z]+$'
+- web.port: Invalid type. Expected: integer, given: string`)
+
+	assert.Error(t, schema.Validate(Settings{}), `invalid settings:
+- web: web is required`)
+
+	var noSchema Schema
+	assert.NilError(t, noSchema.Validate(s))
+}
+
+func TestSchemaValidateUnresolved(t *testing.T) {
+	schema, err := LoadSchema([]byte(`
+type: object
+properties:
+  port:
+    type: integer
+  db:
+    type: object
+    properties:
+      password:
+        type: string
+        secret: true
+        pattern: "^[a-z0-9]{8,}$"
+    required:
+      - password
+required:
+  - port
+  - db
+`))
+	assert.NilError(t, err)
+
+	// the secret references are left out, even when required
+	s, err := FromFlatten(map[string]string{"port": "80", "db.password": "secret:env:DB_PASSWORD"})
+	assert.NilError(t, err)
+	assert.NilError(t, schema.ValidateUnresolved(s))
+	assert.Check(t, schema.Validate(s) != nil)
+
+	s, err = FromFlatten(map[string]string{"port": "abc", "db.password": "secret:env:DB_PASSWORD"})
+	assert.NilError(t, err)
+	assert.Error(t, schema.ValidateUnresolved(s), `invalid settings:
+- port: Invalid type. Expected: integer, given: string`)
+
+	s, err = FromFlatten(map[string]string{"db.password": "short"})
+	assert.NilError(t, err)
+	assert.Error(t, schema.ValidateUnresolved(s), `invalid settings:
+- db.password: Does not match pattern '^[a-z0-9]{8,}$'
+- port: port is required`)
+}
+
+func TestLoadMultipleWithSchema(t *testing.T) {
+	schema, err := LoadSchema([]byte(testSchema))
+	assert.NilError(t, err)
+
+	// required settings are not enforced when loading
+	_, err = LoadMultiple([][]byte{[]byte("foo: bar")}, WithSchema(schema))
+	assert.NilError(t, err)
+
+	_, err = LoadMultiple([][]byte{
+		[]byte("web:\n  port: 80"),
+		[]byte("web:\n  port: 100000"),
+	}, WithSchema(schema))
+	assert.Check(t, is.ErrorContains(err, "web.port: Must be less than or equal to 65535"))
+}
@@ -0,0 +1,74 @@
+package settings
+
+import (
+	"sort"
+	"strings"
+)
+
+// SecretReferencePrefix starts the values of the secret settings referencing
+// a secret held by a secret provider, e.g. "secret:env:DB_PASSWORD"
+const SecretReferencePrefix = "secret:"
+
+// IsSecretReference returns whether the given settings value has the syntax of
+// a secret reference. Only the values of the settings the schema marks as
+// secret are secret references: the other ones are plain values.
+func IsSecretReference(value interface{}) bool {
+	s, ok := value.(string)
+	return ok && strings.HasPrefix(s, SecretReferencePrefix)
+}
+
+// IsSecret returns whether the schema marks the flattened key, or one of its
+// parents, as secret
+func (s Schema) IsSecret(key string) bool {
+	return hasKeyPrefix(key, s.secretKeys(""))
+}
+
+// SecretKeys returns the sorted flattened keys the schema marks as secret
+func (s Settings) SecretKeys(schema Schema) []string {
+	schemaKeys := schema.secretKeys("")
+	var keys []string
+	for k := range s.Flatten() {
+		if hasKeyPrefix(k, schemaKeys) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// hasKeyPrefix returns whether key is one of the given keys or a sub key of one of them
+func hasKeyPrefix(key string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if key == p || strings.HasPrefix(key, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutSecretReferences returns a copy of the settings without the secret
+// references of the secret keys, which can only be checked once resolved
+func withoutSecretReferences(s Settings, secretKeys []string) Settings {
+	return Settings(removeSecretReferences("", s, secretKeys))
+}
+
+func removeSecretReferences(prefix string, m map[string]interface{}, secretKeys []string) map[string]interface{} {
+	res := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch vv := v.(type) {
+		case Settings:
+			res[k] = removeSecretReferences(key, vv, secretKeys)
+		case map[string]interface{}:
+			res[k] = removeSecretReferences(key, vv, secretKeys)
+		default:
+			if !IsSecretReference(v) || !hasKeyPrefix(key, secretKeys) {
+				res[k] = v
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,67 @@
+package settings
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestIsSecretReference(t *testing.T) {
+	assert.Check(t, IsSecretReference("secret:env:DB_PASSWORD"))
+	assert.Check(t, !IsSecretReference("env:DB_PASSWORD"))
+	assert.Check(t, !IsSecretReference(42))
+}
+
+func TestSecretKeys(t *testing.T) {
+	schema, err := LoadSchema([]byte(`
+properties:
+  db:
+    properties:
+      password:
+        type: string
+        secret: true
+  tls:
+    secret: true
+`))
+	assert.NilError(t, err)
+	s, err := Load([]byte(`
+db:
+  user: admin
+  password: s3cr3t
+tls:
+  cert: foo
+  key: bar
+api:
+  token: secret:env:API_TOKEN
+`))
+	assert.NilError(t, err)
+	// values looking like secret references are plain values, unless the
+	// schema marks them as secret
+	assert.Check(t, is.DeepEqual(s.SecretKeys(schema), []string{"db.password", "tls.cert", "tls.key"}))
+	assert.Check(t, is.Len(s.SecretKeys(nil), 0))
+	assert.Check(t, schema.IsSecret("tls.key"))
+	assert.Check(t, !schema.IsSecret("api.token"))
+}
+
+func TestLoadWithSchemaIgnoresSecretReferences(t *testing.T) {
+	schema, err := LoadSchema([]byte(`
+properties:
+  web:
+    properties:
+      port:
+        type: integer
+        secret: true
+      replicas:
+        type: integer
+`))
+	assert.NilError(t, err)
+	// secret references can only be checked once resolved, at render time
+	_, err = LoadMultiple([][]byte{[]byte("web:\n  port: secret:env:PORT")}, WithSchema(schema))
+	assert.NilError(t, err)
+	_, err = LoadMultiple([][]byte{[]byte("web:\n  port: foo")}, WithSchema(schema))
+	assert.Check(t, is.ErrorContains(err, "web.port: Invalid type"))
+	// the settings which are not secret are checked as they are
+	_, err = LoadMultiple([][]byte{[]byte("web:\n  replicas: secret:env:REPLICAS")}, WithSchema(schema))
+	assert.Check(t, is.ErrorContains(err, "web.replicas: Invalid type"))
+}
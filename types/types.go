@@ -1,19 +1,39 @@
 package types
 
 import (
-	"errors"
 	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/docker/app/internal"
 	"github.com/docker/app/types/metadata"
 	"github.com/docker/app/types/settings"
+	"github.com/pkg/errors"
 )
 
-// SingleFileSeparator is the separator used in single-file app
-const SingleFileSeparator = "\n---\n"
+const (
+	// SingleFileSeparator is the separator used in single-file app
+	SingleFileSeparator = "\n---\n"
+	// SingleFileHeaderPrefix starts the optional first line of a single-file app
+	// document, giving its kind and name (e.g. "# docker-app: settings prod")
+	SingleFileHeaderPrefix = "# docker-app:"
+)
+
+// Kinds of documents of a single-file app
+const (
+	// DocumentCompose is a compose file, or a compose overlay
+	DocumentCompose = "compose"
+	// DocumentSettings is the default settings, or a named settings
+	DocumentSettings = "settings"
+	// DocumentSettingsSchema is the settings schema
+	DocumentSettingsSchema = "settings-schema"
+	// DocumentDependenciesLock is the dependencies lock
+	DocumentDependenciesLock = "dependencies-lock"
+)
 
 // App represents an app
 type App struct {
@@ -21,11 +41,28 @@ type App struct {
 	Path    string
 	Cleanup func()
 
-	composesContent [][]byte
-	settingsContent [][]byte
-	settings        settings.Settings
-	metadataContent []byte
-	metadata        metadata.AppMetadata
+	composesContent         [][]byte
+	composeNames            []string
+	settingsContent         [][]byte
+	settings                settings.Settings
+	settingsSchemaContent   []byte
+	settingsSchema          settings.Schema
+	namedSettingsContent    map[string][]byte
+	environment             string
+	remote                  bool
+	metadataContent         []byte
+	metadata                metadata.AppMetadata
+	dependenciesLockContent []byte
+	dependenciesLock        *metadata.Lock
+	dependencies            []Dependency
+}
+
+// Dependency is a dependency of an app, loaded with the settings overrides
+// given by the app metadata
+type Dependency struct {
+	Name     string
+	App      *App
+	Settings map[string]string
 }
 
 // Composes returns compose files content
@@ -33,16 +70,90 @@ func (a *App) Composes() [][]byte {
 	return a.composesContent
 }
 
+// ComposeNames returns the names of the compose files, in the order of
+// Composes: their path relative to the app directory, or the path they were
+// given with for the override compose files
+func (a *App) ComposeNames() []string {
+	names := make([]string, len(a.composesContent))
+	for i := range names {
+		switch {
+		case i < len(a.composeNames) && a.composeNames[i] != "":
+			names[i] = a.composeNames[i]
+		case i == 0:
+			names[i] = internal.ComposeFileName
+		default:
+			names[i] = internal.ComposeOverlayFileName(i)
+		}
+	}
+	return names
+}
+
+// IsComposeOverride returns whether the compose file at the index overrides the
+// ones of the app, rather than being one of its files
+func (a *App) IsComposeOverride(i int) bool {
+	return i < len(a.composeNames) && a.composeNames[i] != ""
+}
+
 // SettingsRaw returns setting files content
 func (a *App) SettingsRaw() [][]byte {
 	return a.settingsContent
 }
 
+// PackageSettingsRaw returns the settings content of the app package, rather
+// than of the user: the app default settings, and the named settings of the
+// selected environment
+func (a *App) PackageSettingsRaw() [][]byte {
+	n := 1
+	if a.environment != "" {
+		n = 2
+	}
+	if n > len(a.settingsContent) {
+		n = len(a.settingsContent)
+	}
+	return a.settingsContent[:n]
+}
+
 // Settings returns map of settings
 func (a *App) Settings() settings.Settings {
 	return a.settings
 }
 
+// SettingsSchemaRaw returns settings schema file content, if any
+func (a *App) SettingsSchemaRaw() []byte {
+	return a.settingsSchemaContent
+}
+
+// SettingsSchema returns the settings schema, or nil if the app doesn't have one
+func (a *App) SettingsSchema() settings.Schema {
+	return a.settingsSchema
+}
+
+// NamedSettingsRaw returns named settings files content, indexed by name
+func (a *App) NamedSettingsRaw() map[string][]byte {
+	return a.namedSettingsContent
+}
+
+// NamedSettingsNames returns the sorted names of the named settings
+func (a *App) NamedSettingsNames() []string {
+	names := make([]string, 0, len(a.namedSettingsContent))
+	for name := range a.namedSettingsContent {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Environment returns the name of the selected environment, if any
+func (a *App) Environment() string {
+	return a.environment
+}
+
+// Remote returns whether the app comes from a registry or an URL, rather than
+// from the user
+func (a *App) Remote() bool {
+	return a.remote
+}
+
 // MetadataRaw returns metadata file content
 func (a *App) MetadataRaw() []byte {
 	return a.metadataContent
@@ -53,16 +164,56 @@ func (a *App) Metadata() metadata.AppMetadata {
 	return a.metadata
 }
 
-// Extract writes the app in the specified folder
-func (a *App) Extract(path string) error {
-	if err := ioutil.WriteFile(filepath.Join(path, internal.MetadataFileName), a.MetadataRaw(), 0644); err != nil {
-		return err
+// DependenciesLockRaw returns the dependencies lock file content, if any
+func (a *App) DependenciesLockRaw() []byte {
+	return a.dependenciesLockContent
+}
+
+// DependenciesLock returns the dependencies lock, or nil if the app doesn't have one
+func (a *App) DependenciesLock() *metadata.Lock {
+	return a.dependenciesLock
+}
+
+// Dependencies returns the loaded dependencies of the app
+func (a *App) Dependencies() []Dependency {
+	return a.dependencies
+}
+
+// Files returns the content of the app files, indexed by their slash-separated
+// path relative to the app directory
+func (a *App) Files() map[string][]byte {
+	files := map[string][]byte{
+		internal.MetadataFileName: a.MetadataRaw(),
+		internal.ComposeFileName:  a.Composes()[0],
+		internal.SettingsFileName: a.SettingsRaw()[0],
 	}
-	if err := ioutil.WriteFile(filepath.Join(path, internal.ComposeFileName), a.Composes()[0], 0644); err != nil {
-		return err
+	for i, overlay := range a.Composes()[1:] {
+		if !a.IsComposeOverride(i + 1) {
+			files[internal.ComposeOverlayFileName(i+1)] = overlay
+		}
+	}
+	if len(a.SettingsSchemaRaw()) != 0 {
+		files[internal.SettingsSchemaFileName] = a.SettingsSchemaRaw()
+	}
+	for name, data := range a.NamedSettingsRaw() {
+		files[internal.NamedSettingsFileName(name)] = data
 	}
-	if err := ioutil.WriteFile(filepath.Join(path, internal.SettingsFileName), a.SettingsRaw()[0], 0644); err != nil {
-		return err
+	if len(a.DependenciesLockRaw()) != 0 {
+		files[internal.DependenciesLockFileName] = a.DependenciesLockRaw()
+	}
+	return files
+}
+
+// Extract writes the app in the specified folder
+func (a *App) Extract(path string) error {
+	for file, data := range a.Files() {
+		target := filepath.Join(path, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(target, data, 0644); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -76,9 +227,10 @@ func NewApp(path string, ops ...func(*App) error) (*App, error) {
 		Path:    path,
 		Cleanup: noop,
 
-		composesContent: [][]byte{},
-		settingsContent: [][]byte{},
-		metadataContent: []byte{},
+		composesContent:      [][]byte{},
+		settingsContent:      [][]byte{},
+		namedSettingsContent: map[string][]byte{},
+		metadataContent:      []byte{},
 	}
 
 	for _, op := range ops {
@@ -96,7 +248,11 @@ func NewAppFromDefaultFiles(path string, ops ...func(*App) error) (*App, error)
 	appOps := append([]func(*App) error{
 		MetadataFile(filepath.Join(path, internal.MetadataFileName)),
 		WithComposeFiles(filepath.Join(path, internal.ComposeFileName)),
+		WithComposeOverlaysDir(filepath.Join(path, internal.ComposeOverlaysDir)),
+		SettingsSchemaFile(filepath.Join(path, internal.SettingsSchemaFileName)),
 		WithSettingsFiles(filepath.Join(path, internal.SettingsFileName)),
+		WithNamedSettingsDir(filepath.Join(path, internal.NamedSettingsDir)),
+		DependenciesLockFile(filepath.Join(path, internal.DependenciesLockFileName)),
 	}, ops...)
 	return NewApp(path, appOps...)
 }
@@ -117,6 +273,14 @@ func WithPath(path string) func(*App) error {
 	}
 }
 
+// WithRemote marks the app as coming from a registry or an URL
+func WithRemote() func(*App) error {
+	return func(app *App) error {
+		app.remote = true
+		return nil
+	}
+}
+
 // WithCleanup sets the cleanup function of the app
 func WithCleanup(f func()) func(*App) error {
 	return func(app *App) error {
@@ -142,12 +306,147 @@ func settingsLoader(f func() ([][]byte, error)) func(*App) error {
 			return err
 		}
 		settingsContents := append(app.settingsContent, settingsContent...)
-		loaded, err := settings.LoadMultiple(settingsContents)
+		loaded, err := settings.LoadMultiple(settingsContents, settings.WithSchema(app.settingsSchema))
+		if err != nil {
+			return err
+		}
+		app.settings = loaded
+		app.settingsContent = settingsContents
+		return nil
+	}
+}
+
+// SettingsSchemaFile adds the specified settings schema file to the app, if it exists
+func SettingsSchemaFile(file string) func(*App) error {
+	return settingsSchemaLoader(func() ([]byte, error) {
+		d, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return d, err
+	})
+}
+
+// SettingsSchema adds the specified settings schema reader to the app
+func SettingsSchema(r io.Reader) func(*App) error {
+	return settingsSchemaLoader(func() ([]byte, error) { return ioutil.ReadAll(r) })
+}
+
+func settingsSchemaLoader(f func() ([]byte, error)) func(*App) error {
+	return func(app *App) error {
+		d, err := f()
+		if err != nil {
+			return err
+		}
+		if len(d) == 0 {
+			return nil
+		}
+		loaded, err := settings.LoadSchema(d)
+		if err != nil {
+			return err
+		}
+		// settings may have been loaded before the schema
+		if _, err := settings.LoadMultiple(app.settingsContent, settings.WithSchema(loaded)); err != nil {
+			return err
+		}
+		for name, data := range app.namedSettingsContent {
+			if _, err := settings.LoadMultiple([][]byte{data}, settings.WithSchema(loaded)); err != nil {
+				return errors.Wrapf(err, "invalid %q settings", name)
+			}
+		}
+		app.settingsSchema = loaded
+		app.settingsSchemaContent = d
+		return nil
+	}
+}
+
+// WithNamedSettingsFile adds the specified file as the named settings to the app
+func WithNamedSettingsFile(name, file string) func(*App) error {
+	return namedSettingsLoader(name, func() ([]byte, error) { return ioutil.ReadFile(file) })
+}
+
+// WithNamedSettings adds the specified reader as the named settings to the app
+func WithNamedSettings(name string, r io.Reader) func(*App) error {
+	return namedSettingsLoader(name, func() ([]byte, error) { return ioutil.ReadAll(r) })
+}
+
+// WithNamedSettingsDir adds all the <name>.yml files of the specified directory,
+// if it exists, as named settings to the app
+func WithNamedSettingsDir(dir string) func(*App) error {
+	return func(app *App) error {
+		files, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if f.IsDir() || filepath.Ext(f.Name()) != ".yml" {
+				continue
+			}
+			name := strings.TrimSuffix(f.Name(), ".yml")
+			if err := WithNamedSettingsFile(name, filepath.Join(dir, f.Name()))(app); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WithEnvironment selects the given environment: its named settings are layered
+// on top of the app default settings, before any other settings file.
+// An empty name selects no environment.
+func WithEnvironment(name string) func(*App) error {
+	return func(app *App) error {
+		if name == "" {
+			return nil
+		}
+		data, ok := app.namedSettingsContent[name]
+		if !ok {
+			return errors.Errorf("unknown environment %q, available environments: %s", name, strings.Join(app.NamedSettingsNames(), ", "))
+		}
+		if app.environment != "" {
+			return errors.Errorf("environment %q already selected", app.environment)
+		}
+		// the first settings content is the app default settings
+		i := 1
+		if len(app.settingsContent) == 0 {
+			i = 0
+		}
+		settingsContents := append([][]byte{}, app.settingsContent[:i]...)
+		settingsContents = append(settingsContents, data)
+		settingsContents = append(settingsContents, app.settingsContent[i:]...)
+		loaded, err := settings.LoadMultiple(settingsContents, settings.WithSchema(app.settingsSchema))
 		if err != nil {
 			return err
 		}
 		app.settings = loaded
 		app.settingsContent = settingsContents
+		app.environment = name
+		return nil
+	}
+}
+
+func namedSettingsLoader(name string, f func() ([]byte, error)) func(*App) error {
+	return func(app *App) error {
+		if err := internal.ValidateSettingsName(name); err != nil {
+			return err
+		}
+		if _, exists := app.namedSettingsContent[name]; exists {
+			return errors.Errorf("duplicate %q settings", name)
+		}
+		d, err := f()
+		if err != nil {
+			return err
+		}
+		if _, err := settings.LoadMultiple([][]byte{d}, settings.WithSchema(app.settingsSchema)); err != nil {
+			return errors.Wrapf(err, "invalid %q settings", name)
+		}
+		if app.namedSettingsContent == nil {
+			app.namedSettingsContent = map[string][]byte{}
+		}
+		app.namedSettingsContent[name] = d
 		return nil
 	}
 }
@@ -178,16 +477,124 @@ func metadataLoader(f func() ([]byte, error)) func(app *App) error {
 	}
 }
 
+// DependenciesLockFile adds the specified dependencies lock file to the app, if it exists
+func DependenciesLockFile(file string) func(*App) error {
+	return dependenciesLockLoader(func() ([]byte, error) {
+		d, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return d, err
+	})
+}
+
+// DependenciesLock adds the specified dependencies lock reader to the app
+func DependenciesLock(r io.Reader) func(*App) error {
+	return dependenciesLockLoader(func() ([]byte, error) { return ioutil.ReadAll(r) })
+}
+
+func dependenciesLockLoader(f func() ([]byte, error)) func(*App) error {
+	return func(app *App) error {
+		d, err := f()
+		if err != nil {
+			return err
+		}
+		if len(d) == 0 {
+			return nil
+		}
+		loaded, err := metadata.LoadLock(d)
+		if err != nil {
+			return err
+		}
+		app.dependenciesLock = loaded
+		app.dependenciesLockContent = d
+		return nil
+	}
+}
+
+// WithDependency adds the given loaded dependency app to the app. The
+// dependency app is cleaned up with the app.
+func WithDependency(name string, dep *App, settings map[string]string) func(*App) error {
+	return func(app *App) error {
+		for _, d := range app.dependencies {
+			if d.Name == name {
+				return errors.Errorf("duplicate dependency %q", name)
+			}
+		}
+		app.dependencies = append(app.dependencies, Dependency{Name: name, App: dep, Settings: settings})
+		cleanup := app.Cleanup
+		app.Cleanup = func() {
+			dep.Cleanup()
+			cleanup()
+		}
+		return nil
+	}
+}
+
 // WithComposeFiles adds the specified compose files to the app
 func WithComposeFiles(files ...string) func(*App) error {
 	return composeLoader(func() ([][]byte, error) { return readFiles(files...) })
 }
 
+// WithComposeOverrideFiles adds compose files overriding the ones of the app,
+// such as the ones given on the command line. They are named by their path,
+// and are not part of the app files.
+func WithComposeOverrideFiles(files ...string) func(*App) error {
+	return func(app *App) error {
+		content, err := readFiles(files...)
+		if err != nil {
+			return err
+		}
+		for len(app.composeNames) < len(app.composesContent) {
+			app.composeNames = append(app.composeNames, "")
+		}
+		app.composesContent = append(app.composesContent, content...)
+		app.composeNames = append(app.composeNames, files...)
+		return nil
+	}
+}
+
 // WithComposes adds the specified compose readers to the app
 func WithComposes(readers ...io.Reader) func(*App) error {
 	return composeLoader(func() ([][]byte, error) { return readReaders(readers...) })
 }
 
+// WithComposeOverlaysDir adds the compose overlays of the specified directory,
+// if it exists, to the app. Overlays are named after their position (1.yml,
+// 2.yml...) and added in that order.
+func WithComposeOverlaysDir(dir string) func(*App) error {
+	return func(app *App) error {
+		files, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		overlays := map[int]string{}
+		for _, f := range files {
+			if f.IsDir() {
+				continue
+			}
+			i, err := strconv.Atoi(strings.TrimSuffix(f.Name(), ".yml"))
+			// only the canonical name is accepted, "01.yml" would clash with "1.yml"
+			if err != nil || i < 1 || f.Name() != strconv.Itoa(i)+".yml" {
+				return errors.Errorf("invalid compose overlay file name %q, expected <position>.yml", f.Name())
+			}
+			overlays[i] = filepath.Join(dir, f.Name())
+		}
+		paths := make([]string, len(overlays))
+		for i := range paths {
+			path, ok := overlays[i+1]
+			if !ok {
+				return errors.Errorf("missing compose overlay %d.yml in %s", i+1, dir)
+			}
+			paths[i] = path
+		}
+		return WithComposeFiles(paths...)(app)
+	}
+}
+
 func composeLoader(f func() ([][]byte, error)) func(app *App) error {
 	return func(app *App) error {
 		composesContent, err := f()
@@ -108,6 +108,24 @@ func TestWithComposeFiles(t *testing.T) {
 	assertContentIs(t, app.Composes()[0], validCompose)
 }
 
+func TestWithComposeOverrideFiles(t *testing.T) {
+	dir := fs.NewDir(t, "composes",
+		fs.WithFile("override.yml", validCompose),
+	)
+	defer dir.Remove()
+	app := &App{Path: "my-app"}
+	assert.NilError(t, WithComposes(strings.NewReader(validCompose), strings.NewReader(validCompose))(app))
+	assert.NilError(t, WithComposeOverrideFiles(dir.Join("override.yml"))(app))
+	assert.NilError(t, WithSettings(strings.NewReader(""))(app))
+	assert.Assert(t, is.Len(app.Composes(), 3))
+	assert.Check(t, is.DeepEqual(app.ComposeNames(), []string{"docker-compose.yml", "compose/1.yml", dir.Join("override.yml")}))
+	assert.Check(t, !app.IsComposeOverride(1))
+	assert.Check(t, app.IsComposeOverride(2))
+	// the override compose files are not part of the app
+	_, ok := app.Files()["compose/2.yml"]
+	assert.Check(t, !ok)
+}
+
 func TestWithComposes(t *testing.T) {
 	r := strings.NewReader(validCompose)
 	app := &App{Path: "my-app"}
@@ -181,3 +199,44 @@ name: myname`)
 	err = WithSettings(brokenSettings)(app)
 	assert.ErrorContains(t, err, `Non-string key in my-settings: 1`)
 }
+
+func TestSettingsSchemaFile(t *testing.T) {
+	dir := fs.NewDir(t, "schema",
+		fs.WithFile("schema.yml", "properties:\n  foo:\n    type: integer"),
+		fs.WithFile("settings.yml", "foo: bar"),
+	)
+	defer dir.Remove()
+
+	app := &App{Path: "my-app"}
+	assert.NilError(t, SettingsSchemaFile(dir.Join("missing.yml"))(app))
+	assert.Check(t, is.Nil(app.SettingsSchema()))
+
+	// settings loaded before the schema are checked too
+	assert.NilError(t, WithSettingsFiles(dir.Join("settings.yml"))(app))
+	err := SettingsSchemaFile(dir.Join("schema.yml"))(app)
+	assert.ErrorContains(t, err, "foo: Invalid type. Expected: integer, given: string")
+}
+
+func TestWithEnvironment(t *testing.T) {
+	app, err := NewApp("my-app",
+		WithSettings(strings.NewReader("foo: default\nbar: default\nbaz: default")),
+		WithNamedSettings("prod", strings.NewReader("foo: prod\nbar: prod")),
+		WithSettings(strings.NewReader("bar: override")),
+		WithEnvironment("prod"),
+	)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(app.Environment(), "prod"))
+	// environment settings come right after the defaults, before other settings
+	assert.Check(t, is.DeepEqual(app.Settings().Flatten(), map[string]string{"foo": "prod", "bar": "override", "baz": "default"}))
+
+	app, err = NewApp("my-app", WithSettings(strings.NewReader("foo: bar")), WithEnvironment(""))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(app.Environment(), ""))
+
+	_, err = NewApp("my-app",
+		WithNamedSettings("dev", strings.NewReader("foo: bar")),
+		WithNamedSettings("prod", strings.NewReader("foo: bar")),
+		WithEnvironment("staging"),
+	)
+	assert.ErrorContains(t, err, `unknown environment "staging", available environments: dev, prod`)
+}